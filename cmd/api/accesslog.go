@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"filmoteka/internal/data"
+	"filmoteka/internal/jsonlog"
+)
+
+var accessLogFormats = []string{"json", "combined", "logfmt"}
+
+/*
+Журнал запросов. В формате json записи идут через jsonlog вместе с логом
+приложения или, если задан отдельный файл, в него; combined и logfmt
+пишутся строками в тот же stdout или в файл. Успешные ответы могут
+записываться выборочно, ответы с ошибками записываются всегда.
+nil accessLog пишет все запросы в json в лог приложения.
+*/
+type accessLog struct {
+	format string
+	sample float64
+	// nil, если записи пишутся в stdout вместе с логом приложения.
+	out  io.Writer
+	json *jsonlog.Logger
+	mu   sync.Mutex
+}
+
+func newAccessLog(format string, sample float64, out io.Writer) (*accessLog, error) {
+	l := &accessLog{format: format, sample: sample, out: out}
+
+	switch format {
+	case "json":
+		if out != nil {
+			l.json = jsonlog.New(out, jsonlog.LevelInfo)
+		}
+	case "combined", "logfmt":
+	default:
+		return nil, fmt.Errorf("unknown access log format %q, expected one of %s", format, strings.Join(accessLogFormats, ", "))
+	}
+
+	return l, nil
+}
+
+type accessLogEntry struct {
+	time       time.Time
+	remoteAddr string
+	method     string
+	url        string
+	proto      string
+	route      string
+	status     int
+	bytes      int
+	duration   time.Duration
+	user       *data.User
+	userAgent  string
+	referer    string
+	requestID  string
+}
+
+// Данные, которые становятся известны глубже в цепочке middleware, например пользователь из authenticate.
+type accessInfo struct {
+	user *data.User
+}
+
+func (l *accessLog) sampled(status int) bool {
+	if l == nil || status >= http.StatusBadRequest || l.sample >= 1 {
+		return true
+	}
+
+	return rand.Float64() < l.sample
+}
+
+func (app *application) writeAccessLog(r *http.Request, entry accessLogEntry) {
+	l := app.accessLog
+
+	switch {
+	case l == nil || l.format == "json":
+		logger := app.contextGetLogger(r)
+		if l != nil && l.json != nil {
+			logger = l.json
+		}
+
+		logger.PrintInfo("Request processed", entry.properties())
+	case l.format == "combined":
+		l.writeLine(entry.combined())
+	default:
+		l.writeLine(entry.logfmt())
+	}
+}
+
+func (l *accessLog) writeLine(line string) {
+	out := l.out
+	if out == nil {
+		out = os.Stdout
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	io.WriteString(out, line+"\n")
+}
+
+// ID пользователя или пустая строка для анонимного запроса.
+func (e accessLogEntry) userID() string {
+	if e.user == nil || e.user.IsAnonymous() {
+		return ""
+	}
+
+	return strconv.FormatInt(e.user.ID, 10)
+}
+
+func (e accessLogEntry) properties() map[string]string {
+	properties := map[string]string{
+		"status_code": strconv.Itoa(e.status),
+		"url":         e.url,
+		"method":      e.method,
+		"remote_addr": e.remoteAddr,
+		"route":       e.route,
+		"bytes":       strconv.Itoa(e.bytes),
+		"duration_ms": strconv.FormatFloat(float64(e.duration.Microseconds())/1000, 'f', 3, 64),
+		"user_agent":  e.userAgent,
+	}
+
+	if id := e.userID(); id != "" {
+		properties["user_id"] = id
+	}
+
+	if e.referer != "" {
+		properties["referer"] = e.referer
+	}
+
+	if e.requestID != "" {
+		properties["request_id"] = e.requestID
+	}
+
+	return properties
+}
+
+/*
+Apache Combined Log Format с тремя дополнительными полями в конце:
+длительностью в микросекундах (%D), шаблоном маршрута и идентификатором
+запроса. Анализаторы формата Combined обычно пропускают лишние поля.
+*/
+func (e accessLogEntry) combined() string {
+	return fmt.Sprintf(`%s - %s [%s] "%s %s %s" %d %s "%s" "%s" %d "%s" "%s"`,
+		e.remoteAddr,
+		dashIfEmpty(e.userID()),
+		e.time.Format("02/Jan/2006:15:04:05 -0700"),
+		e.method,
+		escapeCombined(e.url),
+		e.proto,
+		e.status,
+		dashIfZero(e.bytes),
+		dashIfEmpty(escapeCombined(e.referer)),
+		dashIfEmpty(escapeCombined(e.userAgent)),
+		e.duration.Microseconds(),
+		dashIfEmpty(e.route),
+		dashIfEmpty(escapeCombined(e.requestID)),
+	)
+}
+
+func (e accessLogEntry) logfmt() string {
+	var b strings.Builder
+
+	pairs := [][2]string{
+		{"time", e.time.UTC().Format(time.RFC3339)},
+		{"level", "info"},
+		{"msg", "Request processed"},
+		{"method", e.method},
+		{"url", e.url},
+		{"route", e.route},
+		{"status", strconv.Itoa(e.status)},
+		{"bytes", strconv.Itoa(e.bytes)},
+		{"duration", e.duration.String()},
+		{"user_id", e.userID()},
+		{"remote_addr", e.remoteAddr},
+		{"user_agent", e.userAgent},
+		{"referer", e.referer},
+		{"request_id", e.requestID},
+	}
+
+	for _, pair := range pairs {
+		if pair[1] == "" {
+			continue
+		}
+
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+
+		b.WriteString(pair[0])
+		b.WriteByte('=')
+		b.WriteString(logfmtValue(pair[1]))
+	}
+
+	return b.String()
+}
+
+// Значения с пробелами, кавычками или = берутся в кавычки с экранированием, как в strconv.Quote.
+func logfmtValue(value string) string {
+	if strings.ContainsAny(value, " \"=\\") || strings.ContainsFunc(value, func(r rune) bool { return r < ' ' || r == 0x7f }) {
+		return strconv.Quote(value)
+	}
+
+	return value
+}
+
+// Экранирует кавычки и управляющие символы, как это делает Apache, чтобы клиент не мог подделать строку журнала.
+func escapeCombined(value string) string {
+	quoted := strconv.Quote(value)
+	return quoted[1 : len(quoted)-1]
+}
+
+func dashIfEmpty(value string) string {
+	if value == "" {
+		return "-"
+	}
+
+	return value
+}
+
+func dashIfZero(n int) string {
+	if n == 0 {
+		return "-"
+	}
+
+	return strconv.Itoa(n)
+}
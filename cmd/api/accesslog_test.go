@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"filmoteka/internal/data"
+	"filmoteka/internal/jsonlog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testAccessLogEntry() accessLogEntry {
+	return accessLogEntry{
+		time:       time.Date(2024, 3, 1, 12, 30, 45, 0, time.FixedZone("MSK", 3*60*60)),
+		remoteAddr: "192.0.2.1:1234",
+		method:     http.MethodGet,
+		url:        "/movies/1?include=actors",
+		proto:      "HTTP/1.1",
+		route:      "/movies/:id",
+		status:     http.StatusOK,
+		bytes:      512,
+		duration:   1500 * time.Microsecond,
+		user:       &data.User{ID: 7, Name: "user"},
+		userAgent:  `curl/8.0 "test"`,
+		requestID:  "req-1",
+	}
+}
+
+func TestAccessLogEntry_Formats(t *testing.T) {
+	entry := testAccessLogEntry()
+
+	t.Run("Combined", func(t *testing.T) {
+		want := `192.0.2.1:1234 - 7 [01/Mar/2024:12:30:45 +0300] "GET /movies/1?include=actors HTTP/1.1" 200 512 "-" "curl/8.0 \"test\"" 1500 "/movies/:id" "req-1"`
+
+		if got := entry.combined(); got != want {
+			t.Errorf("expected\n%s\ngot\n%s", want, got)
+		}
+	})
+
+	t.Run("Logfmt", func(t *testing.T) {
+		want := `time=2024-03-01T09:30:45Z level=info msg="Request processed" method=GET url="/movies/1?include=actors" route=/movies/:id status=200 bytes=512 duration=1.5ms user_id=7 remote_addr=192.0.2.1:1234 user_agent="curl/8.0 \"test\"" request_id=req-1`
+
+		if got := entry.logfmt(); got != want {
+			t.Errorf("expected\n%s\ngot\n%s", want, got)
+		}
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		want := map[string]string{
+			"status_code": "200",
+			"url":         "/movies/1?include=actors",
+			"method":      "GET",
+			"remote_addr": "192.0.2.1:1234",
+			"route":       "/movies/:id",
+			"bytes":       "512",
+			"duration_ms": "1.500",
+			"user_agent":  `curl/8.0 "test"`,
+			"user_id":     "7",
+			"request_id":  "req-1",
+		}
+
+		got := entry.properties()
+
+		if len(got) != len(want) {
+			t.Errorf("expected %v, got %v", want, got)
+		}
+
+		for key, value := range want {
+			if got[key] != value {
+				t.Errorf("expected %s=%q, got %q", key, value, got[key])
+			}
+		}
+	})
+
+	t.Run("Anonymous", func(t *testing.T) {
+		entry := testAccessLogEntry()
+		entry.user = data.AnonymousUser
+		entry.bytes = 0
+
+		if got := entry.combined(); !strings.HasPrefix(got, "192.0.2.1:1234 - - [") || !strings.Contains(got, `" 200 - "`) {
+			t.Errorf("expected dashes for anonymous user and empty body, got %s", got)
+		}
+
+		if got := entry.logfmt(); strings.Contains(got, "user_id") {
+			t.Errorf("expected no user_id for anonymous user, got %s", got)
+		}
+	})
+}
+
+func TestLogRequest_AccessLog(t *testing.T) {
+	var sink bytes.Buffer
+
+	newApp := func(format string, sample float64) *application {
+		sink.Reset()
+
+		accessLog, err := newAccessLog(format, sample, &sink)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return &application{
+			models:    data.NewMockModels(),
+			logger:    jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+			accessLog: accessLog,
+		}
+	}
+
+	t.Run("AllRequests", func(t *testing.T) {
+		app := newApp("json", 1)
+
+		req := httptest.NewRequest(http.MethodGet, "/movies/1", nil)
+		req.SetBasicAuth("user", "password123")
+
+		res := httptest.NewRecorder()
+		app.routes().ServeHTTP(res, req)
+		app.routes().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/movies/1", nil))
+
+		lines := strings.Split(strings.TrimSpace(sink.String()), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("expected 2 access log entries, got %d: %s", len(lines), sink.String())
+		}
+
+		var entry struct {
+			Message    string            `json:"message"`
+			Properties map[string]string `json:"properties"`
+		}
+
+		err := json.Unmarshal([]byte(lines[0]), &entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := map[string]string{
+			"status_code": "200",
+			"route":       "/movies/:id",
+			"user_id":     "1",
+			"bytes":       strconv.Itoa(res.Body.Len()),
+			"request_id":  res.Header().Get("X-Request-ID"),
+		}
+
+		for key, value := range want {
+			if entry.Properties[key] != value {
+				t.Errorf("expected %s=%q, got %q", key, value, entry.Properties[key])
+			}
+		}
+
+		err = json.Unmarshal([]byte(lines[1]), &entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if entry.Properties["status_code"] != "401" {
+			t.Errorf("expected unauthorized request to be logged, got %v", entry.Properties)
+		}
+	})
+
+	t.Run("Sampling", func(t *testing.T) {
+		app := newApp("logfmt", 0)
+
+		app.routes().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthcheck", nil))
+		app.routes().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/nonexistent", nil))
+
+		lines := strings.Split(strings.TrimSpace(sink.String()), "\n")
+		if len(lines) != 1 || !strings.Contains(lines[0], "status=404") {
+			t.Errorf("expected only the failed request to be logged, got %q", sink.String())
+		}
+	})
+
+	t.Run("Panic", func(t *testing.T) {
+		app := newApp("combined", 1)
+
+		handler := app.logRequest(app.recoverPanic(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("test panic")
+		})))
+
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/test", nil))
+
+		if !strings.Contains(sink.String(), `"GET /test HTTP/1.1" 500 `) {
+			t.Errorf("expected panicking request to be logged, got %q", sink.String())
+		}
+	})
+}
+
+func TestNewAccessLog(t *testing.T) {
+	_, err := newAccessLog("xml", 1, nil)
+	if err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"filmoteka/internal/data"
 	"filmoteka/internal/validator"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 )
@@ -20,7 +22,8 @@ type ActorEnvelope struct {
 }
 
 type ActorsEnvelope struct {
-	Actor []data.Actor `json:"actor"`
+	Actor    []data.Actor  `json:"actor"`
+	Metadata data.Metadata `json:"metadata"`
 }
 
 type MessageEnvelope struct {
@@ -34,6 +37,7 @@ type MessageEnvelope struct {
 // @Produce json
 // @Param input body ActorInput true "Actor data"
 // @Success 201 {object} ActorEnvelope "Actor successfully created"
+// @Header 201 {string} ETag "Version of the created actor"
 // @Failure 400 {object} errorResponse "Client error"
 // @Failure 401 {object} errorResponse "Unauthorized"
 // @Failure 403 {object} errorResponse "Forbidden"
@@ -41,6 +45,8 @@ type MessageEnvelope struct {
 // @Failure 500 {object} errorResponse "Internal server error"
 // @Router /actors [post]
 // @Security BasicAuth
+// @Security BearerAuth
+// @Security ApiKeyAuth
 func (app *application) addActorHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
 		FullName  string    `json:"full_name"`
@@ -66,7 +72,7 @@ func (app *application) addActorHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = app.models.Actors.Insert(actor)
+	err = app.models.Actors.Insert(r.Context(), actor)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrDuplicateName):
@@ -83,28 +89,34 @@ func (app *application) addActorHandler(w http.ResponseWriter, r *http.Request)
 		actor.Movies = []int{}
 	}
 
-	err = app.writeJSON(w, http.StatusCreated, envelope{"actor": actor}, nil)
+	err = app.writeJSON(w, http.StatusCreated, envelope{"actor": actor}, app.etagHeader(actor.Version))
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
 
 // @Summary Update actor
-// @Description Updates the information of a specific actor in the database. This can be a partial or full update. If a field is not provided in the request body, the current value of that field will be retained.
+// @Description Updates the information of a specific actor in the database. This can be a partial or full update. If a field is not provided in the request body, the current value of that field will be retained. To avoid overwriting concurrent changes, send the ETag received from a previous response in the If-Match header.
 // @Tags Actors
 // @Accept json
 // @Produce json
 // @Param id path int true "Actor ID"
+// @Param If-Match header string false "ETag of the actor version being updated"
 // @Param input body ActorInput true "Actor data"
 // @Success 200 {object} ActorEnvelope "Actor successfully updated"
+// @Header 200 {string} ETag "Version of the updated actor"
 // @Failure 400 {object} errorResponse "Client error"
 // @Failure 403 {object} errorResponse "Forbidden"
 // @Failure 404 {object} errorResponse "Actor not found"
+// @Failure 409 {object} errorResponse "Edit conflict"
+// @Failure 412 {object} errorResponse "If-Match does not match the current version"
 // @Failure 401 {object} errorResponse "Unauthorized"
 // @Failure 422 {object} errorResponse "Validation error"
 // @Failure 500 {object} errorResponse "Internal server error"
 // @Router /actors/{id} [patch]
 // @Security BasicAuth
+// @Security BearerAuth
+// @Security ApiKeyAuth
 func (app *application) updateActorHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := app.readIDParam(r)
 	if err != nil {
@@ -123,7 +135,7 @@ func (app *application) updateActorHandler(w http.ResponseWriter, r *http.Reques
 		app.badRequestResponse(w, r, err)
 		return
 	}
-	actor, err := app.models.Actors.Get(id)
+	actor, err := app.models.Actors.Get(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -135,15 +147,20 @@ func (app *application) updateActorHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	if *input.FullName != "" {
+	if !app.ifMatch(r, actor.Version) {
+		app.preconditionFailedResponse(w, r)
+		return
+	}
+
+	if input.FullName != nil {
 		actor.FullName = *input.FullName
 	}
 
-	if *input.Gender != "" {
+	if input.Gender != nil {
 		actor.Gender = strings.ToLower(*input.Gender)
 	}
 
-	if !(*input.BirthDate).Equal(time.Time{}) {
+	if input.BirthDate != nil {
 		actor.BirthDate = *input.BirthDate
 	}
 
@@ -153,9 +170,11 @@ func (app *application) updateActorHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	err = app.models.Actors.Update(actor)
+	err = app.models.Actors.Update(r.Context(), actor)
 	if err != nil {
 		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
 		case errors.Is(err, data.ErrDuplicateName):
 			v.AddError("full_name", "actor with this full name already exists")
 			app.failedValidationResponse(w, r, v.Errors)
@@ -166,19 +185,21 @@ func (app *application) updateActorHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"actor": actor}, nil)
+	err = app.writeJSON(w, http.StatusOK, envelope{"actor": actor}, app.etagHeader(actor.Version))
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
 
 // @Summary Get actor by ID
-// @Description Retrieves information about specific actor from the database, including actor's full name, gender, birth date, and a list of movies IDs he have appeared in. If the actor doesn't appear in any movies, the list will be empty.
+// @Description Retrieves information about specific actor from the database, including actor's full name, gender, birth date, and a list of movies IDs he have appeared in. If the actor doesn't appear in any movies, the list will be empty. With include=movies the full movie objects are returned in movie_details as well.
 // @Tags Actors
 // @Accept json
 // @Produce json
 // @Param id path int true "Actor ID"
+// @Param include query string false "Related resources to embed: movies"
 // @Success 200 {object} ActorEnvelope "Actor data"
+// @Header 200 {string} ETag "Version of the actor"
 // @Failure 400 {object} errorResponse "Client error"
 // @Failure 403 {object} errorResponse "Forbidden"
 // @Failure 404 {object} errorResponse "Actor not found"
@@ -186,6 +207,8 @@ func (app *application) updateActorHandler(w http.ResponseWriter, r *http.Reques
 // @Failure 401 {object} errorResponse "Unauthorized"
 // @Router /actors/{id} [get]
 // @Security BasicAuth
+// @Security BearerAuth
+// @Security ApiKeyAuth
 func (app *application) getActorHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := app.readIDParam(r)
 	if err != nil {
@@ -193,7 +216,15 @@ func (app *application) getActorHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	actor, err := app.models.Actors.Get(id)
+	v := validator.New()
+
+	include := app.readInclude(r.URL.Query(), v, "movies")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	actor, err := app.models.Actors.Get(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -205,31 +236,78 @@ func (app *application) getActorHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"actor": actor}, nil)
+	if slices.Contains(include, "movies") {
+		actors, err := app.includeMovies(r.Context(), []data.Actor{*actor})
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		actor = &actors[0]
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"actor": actor}, app.etagHeader(actor.Version))
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
 
 // @Summary Get actors
-// @Description Retrieves a list of all actors in the database. Each entry includes the actor's full name, gender, birth date, and a list of movies they have appeared in. If the actor doesn't appear in any movies, the list will be empty.
+// @Description Retrieves a paginated list of actors in the database. Actors are people credited as actors in at least one movie, plus people without any credits yet; crew members who never acted are listed only under /people. Each entry includes the actor's full name, gender, birth date, and a list of movies they have appeared in as an actor. If the actor doesn't appear in any movies, the list will be empty. With include=movies the full movie objects are returned in movie_details as well; all movies of the page are loaded in one batch. The default sort order is by full name in ascending order.
 // @Tags Actors
 // @Accept json
 // @Produce json
+// @Param sort query string false "Sort order: full_name, birth_date, -full_name, -birth_date"
+// @Param page query int false "Page number (default 1)"
+// @Param page_size query int false "Page size, maximum 100 (default 20)"
+// @Param cursor query string false "Cursor from metadata.next_cursor of the previous page"
+// @Param include query string false "Related resources to embed: movies"
 // @Success 200 {object} ActorsEnvelope "Actors data"
 // @Failure 400 {object} errorResponse "Client error"
+// @Failure 422 {object} errorResponse "Validation error"
 // @Failure 500 {object} errorResponse "Internal server error"
 // @Failure 401 {object} errorResponse "Unauthorized"
 // @Router /actors [get]
 // @Security BasicAuth
+// @Security BearerAuth
+// @Security ApiKeyAuth
 func (app *application) getActorsHandler(w http.ResponseWriter, r *http.Request) {
-	actors, err := app.models.Actors.GetAll()
+	var input struct {
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Cursor = app.readString(qs, "cursor", "")
+	include := app.readInclude(qs, v, "movies")
+
+	input.Filters.Sort = app.readString(qs, "sort", "full_name")
+	input.Filters.SortSafelist = []string{"full_name", "birth_date", "-full_name", "-birth_date"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	actors, metadata, err := app.models.Actors.GetAll(r.Context(), input.Filters)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"actors": actors}, nil)
+	if slices.Contains(include, "movies") {
+		actors, err = app.includeMovies(r.Context(), actors)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"actors": actors, "metadata": metadata}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -249,6 +327,8 @@ func (app *application) getActorsHandler(w http.ResponseWriter, r *http.Request)
 // @Failure 401 {object} errorResponse "Unauthorized"
 // @Router /actors/{id} [delete]
 // @Security BasicAuth
+// @Security BearerAuth
+// @Security ApiKeyAuth
 func (app *application) deleteActorHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := app.readIDParam(r)
 	if err != nil {
@@ -256,7 +336,7 @@ func (app *application) deleteActorHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	err = app.models.Actors.Delete(id)
+	err = app.models.Actors.Delete(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -273,3 +353,40 @@ func (app *application) deleteActorHandler(w http.ResponseWriter, r *http.Reques
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+/*
+Добавляет актерам полные данные фильмов, в которых они снимались. Все фильмы
+всех актеров загружаются одним запросом; исходный срез не изменяется.
+*/
+func (app *application) includeMovies(ctx context.Context, actors []data.Actor) ([]data.Actor, error) {
+	var ids []int64
+
+	for _, actor := range actors {
+		for _, movieID := range actor.Movies {
+			if !slices.Contains(ids, int64(movieID)) {
+				ids = append(ids, int64(movieID))
+			}
+		}
+	}
+
+	movies, err := app.models.Movies.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	included := slices.Clone(actors)
+
+	for i, actor := range included {
+		details := []*data.Movie{}
+
+		for _, movieID := range actor.Movies {
+			if movie, ok := movies[int64(movieID)]; ok {
+				details = append(details, movie)
+			}
+		}
+
+		included[i].MovieDetails = details
+	}
+
+	return included, nil
+}
@@ -249,6 +249,35 @@ func TestGetActorHandler(t *testing.T) {
 	})
 }
 
+func TestGetActorHandler_IncludeMovies(t *testing.T) {
+	app := &application{
+		models: data.NewMockModels(),
+		logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+	}
+
+	req := withIDParam(httptest.NewRequest(http.MethodGet, "/actors/1?include=movies", nil), 1)
+	res := httptest.NewRecorder()
+
+	app.getActorHandler(res, req)
+
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected status code %d, but got %d", http.StatusOK, res.Code)
+	}
+
+	var respBody struct {
+		Actor data.Actor `json:"actor"`
+	}
+	err := json.NewDecoder(res.Body).Decode(&respBody)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	details := respBody.Actor.MovieDetails
+	if len(details) != 1 || details[0].Title != "Mock Movie 1" {
+		t.Errorf("expected embedded movie %q, but got %+v", "Mock Movie 1", details)
+	}
+}
+
 func TestGetActorsHandler(t *testing.T) {
 	app := &application{
 		models: data.NewMockModels(),
@@ -365,6 +394,23 @@ func TestDeleteActorHandler(t *testing.T) {
 			t.Errorf("expected status code %d, but got %d", http.StatusNotFound, res.Code)
 		}
 	})
+
+	t.Run("CrewOnly", func(t *testing.T) {
+		app := &application{
+			models: data.NewMockModels(),
+			logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+		}
+
+		// Mock Director 1 снимался только как режиссер.
+		res := adminRequest(app, http.MethodDelete, "/actors/3", "")
+		if res.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, but got %d", http.StatusNotFound, res.Code)
+		}
+
+		if _, err := app.models.People.Get(context.Background(), 3); err != nil {
+			t.Errorf("expected the director to be kept, but got %v", err)
+		}
+	})
 }
 
 func TestUpdateActorHandler(t *testing.T) {
@@ -377,9 +423,9 @@ func TestUpdateActorHandler(t *testing.T) {
 		id := int64(1)
 
 		input := struct {
-			FullName  string    `json:"full_name"`
-			Gender    string    `json:"gender"`
-			BirthDate time.Time `json:"birth_date"` // RFC3339
+			FullName  string     `json:"full_name"`
+			Gender    string     `json:"gender,omitempty"`
+			BirthDate *time.Time `json:"birth_date,omitempty"` // RFC3339
 		}{
 			FullName: "John Doe",
 		}
@@ -430,4 +476,43 @@ func TestUpdateActorHandler(t *testing.T) {
 			t.Errorf("expected user name %q, but got %q", input.FullName, respBody.Actor.FullName)
 		}
 	})
+	t.Run("PartialInput", func(t *testing.T) {
+		app := &application{
+			models: data.NewMockModels(),
+			logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+		}
+
+		before, err := app.models.Actors.Get(context.Background(), 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, body := range []string{`{"gender": "FEMALE"}`, `{"birth_date": "1990-05-01T00:00:00Z"}`, `{}`} {
+			res := adminRequest(app, http.MethodPatch, "/actors/1", body)
+			if res.Code != http.StatusOK {
+				t.Fatalf("%s: expected status code %d, but got %d", body, http.StatusOK, res.Code)
+			}
+		}
+
+		actor, err := app.models.Actors.Get(context.Background(), 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if actor.FullName != before.FullName || actor.Gender != "female" || actor.BirthDate.Year() != 1990 {
+			t.Errorf("expected only the sent fields to change, but got %+v", actor)
+		}
+	})
+
+	t.Run("EmptyName", func(t *testing.T) {
+		app := &application{
+			models: data.NewMockModels(),
+			logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+		}
+
+		res := adminRequest(app, http.MethodPatch, "/actors/1", `{"full_name": ""}`)
+		if res.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected status code %d, but got %d", http.StatusUnprocessableEntity, res.Code)
+		}
+	})
 }
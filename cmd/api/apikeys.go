@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"filmoteka/internal/data"
+	"filmoteka/internal/validator"
+	"net/http"
+	"time"
+)
+
+type APIKeyInput struct {
+	UserID int64      `json:"user_id"`
+	Name   string     `json:"name"`
+	Scopes []string   `json:"scopes"`
+	Expiry *time.Time `json:"expiry"` // RFC3339
+}
+
+type APIKeyEnvelope struct {
+	APIKey data.APIKey `json:"api_key"`
+}
+
+type APIKeysEnvelope struct {
+	APIKeys []data.APIKey `json:"api_keys"`
+}
+
+// @Summary Create API key
+// @Description Creates an API key for service-to-service clients, tied to an existing user. The key is sent in the X-API-Key header and grants only the listed scopes, further limited by the permissions of the user it belongs to. A scope is a resource permission (movies, actors, people or genres with read, create, update or delete, e.g. movies:delete); <resource>:write is shorthand for create, update and delete. Keys can never manage other keys. The key itself is returned only in this response; afterwards only its prefix is shown. Without an expiry the key is valid until revoked.
+// @Tags API keys
+// @Accept json
+// @Produce json
+// @Param input body APIKeyInput true "API key data"
+// @Success 201 {object} APIKeyEnvelope "API key created"
+// @Failure 400 {object} errorResponse "Bad request"
+// @Failure 401 {object} errorResponse "Unauthorized"
+// @Failure 403 {object} errorResponse "Forbidden"
+// @Failure 422 {object} errorResponse "Validation failed"
+// @Failure 500 {object} errorResponse "Internal server error"
+// @Router /api-keys [post]
+// @Security BasicAuth
+// @Security BearerAuth
+func (app *application) createAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		UserID int64      `json:"user_id"`
+		Name   string     `json:"name"`
+		Scopes []string   `json:"scopes"`
+		Expiry *time.Time `json:"expiry"` // RFC3339
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	key := &data.APIKey{
+		UserID: input.UserID,
+		Name:   input.Name,
+		Scopes: lowerAll(input.Scopes),
+		Expiry: input.Expiry,
+	}
+
+	v := validator.New()
+	if data.ValidateAPIKey(v, key); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.APIKeys.Insert(r.Context(), key)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrUserNotFound):
+			v.AddError("user_id", "user with this ID does not exist")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"api_key": key}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// @Summary Get API keys
+// @Description Lists API keys with their scopes, expiry and the time they were last used. The keys themselves are never returned, only their prefixes.
+// @Tags API keys
+// @Produce json
+// @Param user_id query int false "Only keys of this user"
+// @Success 200 {object} APIKeysEnvelope "API keys"
+// @Failure 401 {object} errorResponse "Unauthorized"
+// @Failure 403 {object} errorResponse "Forbidden"
+// @Failure 422 {object} errorResponse "Validation failed"
+// @Failure 500 {object} errorResponse "Internal server error"
+// @Router /api-keys [get]
+// @Security BasicAuth
+// @Security BearerAuth
+func (app *application) getAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+
+	userID := app.readInt(r.URL.Query(), "user_id", 0, v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	keys, err := app.models.APIKeys.GetAll(r.Context(), int64(userID))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"api_keys": keys}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// @Summary Revoke API key
+// @Description Revokes an API key. Requests made with it are rejected from then on.
+// @Tags API keys
+// @Produce json
+// @Param id path int true "API key ID"
+// @Success 200 {object} MessageEnvelope "API key revoked"
+// @Failure 401 {object} errorResponse "Unauthorized"
+// @Failure 403 {object} errorResponse "Forbidden"
+// @Failure 404 {object} errorResponse "API key not found"
+// @Failure 500 {object} errorResponse "Internal server error"
+// @Router /api-keys/{id} [delete]
+// @Security BasicAuth
+// @Security BearerAuth
+func (app *application) deleteAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.APIKeys.Delete(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "API key successfully revoked"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
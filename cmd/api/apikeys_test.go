@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"filmoteka/internal/data"
+	"filmoteka/internal/jsonlog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func createAPIKey(t *testing.T, app *application, body string) data.APIKey {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/api-keys", strings.NewReader(body))
+	req.SetBasicAuth("admin", "password123")
+
+	res := httptest.NewRecorder()
+	app.routes().ServeHTTP(res, req)
+
+	if res.Code != http.StatusCreated {
+		t.Fatalf("expected status code %d, but got %d", http.StatusCreated, res.Code)
+	}
+
+	var respBody APIKeyEnvelope
+	err := json.NewDecoder(res.Body).Decode(&respBody)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return respBody.APIKey
+}
+
+func apiKeyRequest(app *application, method, target, key string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	req.Header.Set("X-API-Key", key)
+
+	res := httptest.NewRecorder()
+	app.routes().ServeHTTP(res, req)
+
+	return res
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	app := &application{
+		models: data.NewMockModels(),
+		logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+	}
+
+	key := createAPIKey(t, app, `{"user_id": 2, "name": "ingestion", "scopes": ["movies:read", "movies:write"]}`)
+
+	t.Run("ScopeGranted", func(t *testing.T) {
+		res := apiKeyRequest(app, http.MethodGet, "/movies/1", key.Plaintext)
+		if res.Code != http.StatusOK {
+			t.Errorf("expected status code %d, but got %d", http.StatusOK, res.Code)
+		}
+	})
+
+	t.Run("ScopeMissing", func(t *testing.T) {
+		res := apiKeyRequest(app, http.MethodGet, "/actors/1", key.Plaintext)
+		if res.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d, but got %d", http.StatusForbidden, res.Code)
+		}
+	})
+
+	t.Run("KeysCannotManageKeys", func(t *testing.T) {
+		res := apiKeyRequest(app, http.MethodGet, "/api-keys", key.Plaintext)
+		if res.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d, but got %d", http.StatusForbidden, res.Code)
+		}
+	})
+
+	t.Run("LimitedByOwnerRole", func(t *testing.T) {
+		userKey := createAPIKey(t, app, `{"user_id": 1, "name": "reader", "scopes": ["movies:write"]}`)
+
+		res := apiKeyRequest(app, http.MethodDelete, "/movies/1", userKey.Plaintext)
+		if res.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d, but got %d", http.StatusForbidden, res.Code)
+		}
+	})
+
+	t.Run("InvalidKey", func(t *testing.T) {
+		res := apiKeyRequest(app, http.MethodGet, "/movies/1", "fmk_unknown")
+		if res.Code != http.StatusUnauthorized {
+			t.Errorf("expected status code %d, but got %d", http.StatusUnauthorized, res.Code)
+		}
+	})
+
+	t.Run("Revoked", func(t *testing.T) {
+		req := withIDParam(httptest.NewRequest(http.MethodDelete, "/api-keys/", nil), key.ID)
+		res := httptest.NewRecorder()
+
+		app.deleteAPIKeyHandler(res, req)
+
+		if res.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, but got %d", http.StatusOK, res.Code)
+		}
+
+		res = apiKeyRequest(app, http.MethodGet, "/movies/1", key.Plaintext)
+		if res.Code != http.StatusUnauthorized {
+			t.Errorf("expected status code %d, but got %d", http.StatusUnauthorized, res.Code)
+		}
+	})
+}
+
+func TestCreateAPIKeyHandler_InvalidInput(t *testing.T) {
+	app := &application{
+		models: data.NewMockModels(),
+		logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+	}
+
+	for _, body := range []string{
+		`{"user_id": 2, "name": "ingestion", "scopes": ["movies:publish"]}`,
+		`{"user_id": 42, "name": "ingestion", "scopes": ["movies:read"]}`,
+		`{"user_id": 2, "name": "ingestion", "scopes": ["movies:read"], "expiry": "2000-01-01T00:00:00Z"}`,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api-keys", strings.NewReader(body))
+		res := httptest.NewRecorder()
+
+		app.createAPIKeyHandler(res, req)
+
+		if res.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected status code %d for %s, but got %d", http.StatusUnprocessableEntity, body, res.Code)
+		}
+	}
+}
+
+func TestGetAPIKeysHandler(t *testing.T) {
+	app := &application{
+		models: data.NewMockModels(),
+		logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+	}
+
+	createAPIKey(t, app, `{"user_id": 2, "name": "ingestion", "scopes": ["movies:write"]}`)
+	createAPIKey(t, app, `{"user_id": 1, "name": "reader", "scopes": ["movies:read"]}`)
+
+	req := httptest.NewRequest(http.MethodGet, "/api-keys?user_id=1", nil)
+	res := httptest.NewRecorder()
+
+	app.getAPIKeysHandler(res, req)
+
+	var respBody APIKeysEnvelope
+	err := json.NewDecoder(res.Body).Decode(&respBody)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(respBody.APIKeys) != 1 || respBody.APIKeys[0].Name != "reader" || respBody.APIKeys[0].Plaintext != "" {
+		t.Errorf("unexpected API keys %+v", respBody.APIKeys)
+	}
+}
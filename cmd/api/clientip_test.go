@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	proxies, err := parseTrustedProxies("10.0.0.0/8, 192.0.2.10")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		trustedProxies bool
+		remoteAddr     string
+		forwardedFor   string
+		realIP         string
+		expected       string
+	}{
+		{"NoProxies", false, "203.0.113.5:1234", "198.51.100.1", "198.51.100.2", "203.0.113.5"},
+		{"UntrustedPeer", true, "203.0.113.5:1234", "198.51.100.1", "", "203.0.113.5"},
+		{"TrustedPeer", true, "10.0.0.1:1234", "198.51.100.1", "", "198.51.100.1"},
+		{"SpoofedHop", true, "10.0.0.1:1234", "1.2.3.4, 198.51.100.1, 10.0.0.2", "", "198.51.100.1"},
+		{"SingleAddressProxy", true, "192.0.2.10:1234", "198.51.100.1", "", "198.51.100.1"},
+		{"AllTrusted", true, "10.0.0.1:1234", "10.0.0.3, 10.0.0.2", "", "10.0.0.3"},
+		{"InvalidHop", true, "10.0.0.1:1234", "garbage, 10.0.0.2", "", "10.0.0.2"},
+		{"RealIP", true, "10.0.0.1:1234", "", "198.51.100.2", "198.51.100.2"},
+		{"NoHeaders", true, "10.0.0.1:1234", "", "", "10.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &application{}
+			if tt.trustedProxies {
+				app.config.trustedProxies = proxies
+			}
+
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+
+			if tt.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+
+			if got := app.clientIP(req); got != tt.expected {
+				t.Errorf("expected client IP %q, got %q", tt.expected, got)
+			}
+		})
+	}
+
+	_, err = parseTrustedProxies("10.0.0.0/33")
+	if err == nil {
+		t.Error("expected an invalid CIDR to be rejected")
+	}
+}
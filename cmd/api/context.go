@@ -3,14 +3,31 @@ package main
 import (
 	"context"
 	"filmoteka/internal/data"
+	"filmoteka/internal/jsonlog"
 	"net/http"
 )
 
 type contextKey string
 
-const userContextKey = contextKey("user")
+const (
+	userContextKey      = contextKey("user")
+	apiKeyContextKey    = contextKey("api_key")
+	routeContextKey     = contextKey("route")
+	loggerContextKey    = contextKey("logger")
+	requestIDContextKey = contextKey("request_id")
+	accessContextKey    = contextKey("access")
+)
+
+// Шаблон маршрута, например "/movies/:id"; пустой, если маршрут не найден.
+type routeInfo struct {
+	pattern string
+}
 
 func (app *application) contextSetUser(r *http.Request, user *data.User) *http.Request {
+	if info, ok := r.Context().Value(accessContextKey).(*accessInfo); ok {
+		info.user = user
+	}
+
 	ctx := context.WithValue(r.Context(), userContextKey, user)
 	return r.WithContext(ctx)
 }
@@ -23,3 +40,60 @@ func (app *application) contextGetUser(r *http.Request) *data.User {
 	}
 	return user
 }
+
+func (app *application) contextSetRequestID(r *http.Request, id string) *http.Request {
+	ctx := context.WithValue(r.Context(), requestIDContextKey, id)
+	return r.WithContext(ctx)
+}
+
+// Возвращает идентификатор запроса или пустую строку для запроса, прошедшего мимо middleware requestID.
+func (app *application) contextGetRequestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDContextKey).(string)
+	return id
+}
+
+func (app *application) contextSetAccessInfo(r *http.Request, info *accessInfo) *http.Request {
+	ctx := context.WithValue(r.Context(), accessContextKey, info)
+	return r.WithContext(ctx)
+}
+
+func (app *application) contextSetAPIKey(r *http.Request, key *data.APIKey) *http.Request {
+	ctx := context.WithValue(r.Context(), apiKeyContextKey, key)
+	return r.WithContext(ctx)
+}
+
+// Возвращает API ключ, которым аутентифицирован запрос, или nil, если запрос выполнен без ключа.
+func (app *application) contextGetAPIKey(r *http.Request) *data.APIKey {
+	key, _ := r.Context().Value(apiKeyContextKey).(*data.APIKey)
+	return key
+}
+
+func (app *application) contextSetRoute(r *http.Request, route *routeInfo) *http.Request {
+	ctx := context.WithValue(r.Context(), routeContextKey, route)
+	return r.WithContext(ctx)
+}
+
+// Для запроса, прошедшего мимо matchRoute, например в тестах отдельных middleware, шаблон пустой.
+func (app *application) contextGetRoute(r *http.Request) *routeInfo {
+	route, ok := r.Context().Value(routeContextKey).(*routeInfo)
+	if !ok {
+		return &routeInfo{}
+	}
+
+	return route
+}
+
+func (app *application) contextSetLogger(r *http.Request, logger *jsonlog.Logger) *http.Request {
+	ctx := context.WithValue(r.Context(), loggerContextKey, logger)
+	return r.WithContext(ctx)
+}
+
+// Логгер запроса, добавляющий к записям его идентификаторы, или общий логгер приложения.
+func (app *application) contextGetLogger(r *http.Request) *jsonlog.Logger {
+	logger, ok := r.Context().Value(loggerContextKey).(*jsonlog.Logger)
+	if !ok {
+		return app.logger
+	}
+
+	return logger
+}
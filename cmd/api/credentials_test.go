@@ -0,0 +1,90 @@
+package main
+
+import (
+	"filmoteka/internal/credcache"
+	"filmoteka/internal/data"
+	"filmoteka/internal/jsonlog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCredentialsCache(t *testing.T) {
+	cache, err := credcache.New(100, time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	app := &application{
+		models:      data.NewMockModels(),
+		logger:      jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+		credentials: cache,
+	}
+
+	basicRequest := func(name, password string) int {
+		req := httptest.NewRequest(http.MethodGet, "/movies", nil)
+		req.SetBasicAuth(name, password)
+
+		res := httptest.NewRecorder()
+		app.routes().ServeHTTP(res, req)
+
+		return res.Code
+	}
+
+	for i := 0; i < 3; i++ {
+		if code := basicRequest("user", "password123"); code != http.StatusOK {
+			t.Fatalf("expected status code %d, but got %d", http.StatusOK, code)
+		}
+	}
+
+	if stats := cache.Stats(); stats.Hits != 2 || stats.Misses != 1 || stats.Entries != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	if code := basicRequest("user", "wrongpassword"); code != http.StatusUnauthorized {
+		t.Errorf("expected status code %d, but got %d", http.StatusUnauthorized, code)
+	}
+
+	t.Run("InvalidatedOnRoleChange", func(t *testing.T) {
+		res := adminRequest(app, http.MethodPatch, "/users/1", `{"role": "editor"}`)
+		if res.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, but got %d", http.StatusOK, res.Code)
+		}
+
+		if _, found := cache.Get("user", "password123"); found {
+			t.Fatal("expected cached credentials to be invalidated")
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/movies", nil)
+		req.SetBasicAuth("user", "password123")
+
+		user, ok := app.checkCredentials(httptest.NewRecorder(), req, "user", "password123")
+		if !ok || !user.Permissions.Include("movies:create") {
+			t.Errorf("expected new role permissions after invalidation, but got %v", user)
+		}
+	})
+
+	t.Run("InvalidatedOnPasswordChange", func(t *testing.T) {
+		body := `{"old_password": "password123", "new_password": "newpassword123"}`
+		req := httptest.NewRequest(http.MethodPut, "/users/me/password", strings.NewReader(body))
+		req.SetBasicAuth("user", "password123")
+
+		res := httptest.NewRecorder()
+		app.routes().ServeHTTP(res, req)
+
+		if res.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, but got %d", http.StatusOK, res.Code)
+		}
+
+		if code := basicRequest("user", "password123"); code != http.StatusUnauthorized {
+			t.Errorf("expected old password to be rejected, but got status code %d", code)
+		}
+
+		if code := basicRequest("user", "newpassword123"); code != http.StatusOK {
+			t.Errorf("expected new password to be accepted, but got status code %d", code)
+		}
+	})
+}
@@ -1,16 +1,27 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"filmoteka/internal/data"
+	"filmoteka/internal/jsonlog"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 )
 
+// Нестандартный статус nginx для запросов, клиент которых отключился до ответа.
+const statusClientClosedRequest = 499
+
 type errorResponse struct {
 	Error string `json:"error"`
+	// Только в ответах 5xx: идентификатор запроса для поиска записей в логе.
+	RequestID string `json:"request_id,omitempty"`
 }
 
 func (app *application) logError(r *http.Request, status int, err error) {
-	app.logger.PrintError(err, map[string]string{
+	app.contextGetLogger(r).PrintError(err, map[string]string{
 		"status_code": fmt.Sprint(status),
 		"url":         r.URL.String(),
 		"method":      r.Method,
@@ -21,6 +32,10 @@ func (app *application) logError(r *http.Request, status int, err error) {
 func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, status int, message interface{}) {
 	env := envelope{"error": message}
 
+	if id := app.contextGetRequestID(r); id != "" && status >= http.StatusInternalServerError {
+		env["request_id"] = id
+	}
+
 	err := app.writeJSON(w, status, env, nil)
 	if err != nil {
 		app.logError(r, status, err)
@@ -28,13 +43,51 @@ func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, st
 	}
 }
 
+/*
+Отмена запроса клиентом и истечение времени запроса к базе не считаются
+ошибками сервера: первое пишется в лог как информация и отвечает 499,
+второе отвечает 504, чтобы клиент мог отличить его от сбоя и повторить запрос.
+*/
 func (app *application) serverErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(r.Context().Err(), context.Canceled) || errors.Is(err, context.Canceled):
+		app.requestCanceledResponse(w, r)
+		return
+	case data.IsQueryTimeout(err):
+		app.timeoutResponse(w, r, err)
+		return
+	}
+
 	app.logError(r, http.StatusInternalServerError, err)
 
 	message := "the server encountered a problem and could not process your request"
 	app.errorResponse(w, r, http.StatusInternalServerError, message)
 }
 
+func (app *application) requestCanceledResponse(w http.ResponseWriter, r *http.Request) {
+	app.contextGetLogger(r).PrintInfo("request canceled", map[string]string{
+		"url":         r.URL.String(),
+		"method":      r.Method,
+		"remote_addr": r.RemoteAddr,
+	})
+
+	message := "the request was canceled by the client"
+	app.errorResponse(w, r, statusClientClosedRequest, message)
+}
+
+func (app *application) timeoutResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.contextGetLogger(r).Warn("request timed out",
+		jsonlog.Err(err),
+		jsonlog.Int("status_code", http.StatusGatewayTimeout),
+		jsonlog.String("url", r.URL.String()),
+		jsonlog.String("method", r.Method),
+		jsonlog.String("remote_addr", r.RemoteAddr),
+	)
+
+	message := "the request took too long to process"
+	app.errorResponse(w, r, http.StatusGatewayTimeout, message)
+}
+
 func (app *application) notFoundResponse(w http.ResponseWriter, r *http.Request) {
 	message := "the requested resource could not be found"
 	app.errorResponse(w, r, http.StatusNotFound, message)
@@ -59,16 +112,31 @@ func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http
 }
 
 func (app *application) invalidCredentialsResponse(w http.ResponseWriter, r *http.Request) {
+	app.metrics.authFailed("invalid_credentials")
 	message := "invalid authentication credentials"
 	app.errorResponse(w, r, http.StatusUnauthorized, message)
 }
 
 func (app *application) invalidAuthenticationCredentialsResponse(w http.ResponseWriter, r *http.Request) {
+	app.metrics.authFailed("malformed_credentials")
 	w.Header().Set("WWW-Authenticate", "Basic")
 	message := "invalid or missing authentication credentials"
 	app.errorResponse(w, r, http.StatusUnauthorized, message)
 }
 
+func (app *application) invalidAuthenticationTokenResponse(w http.ResponseWriter, r *http.Request) {
+	app.metrics.authFailed("invalid_token")
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	message := "invalid or expired authentication token"
+	app.errorResponse(w, r, http.StatusUnauthorized, message)
+}
+
+func (app *application) invalidAPIKeyResponse(w http.ResponseWriter, r *http.Request) {
+	app.metrics.authFailed("invalid_api_key")
+	message := "invalid or expired API key"
+	app.errorResponse(w, r, http.StatusUnauthorized, message)
+}
+
 func (app *application) authenticationRequiredResponse(w http.ResponseWriter, r *http.Request) {
 	message := "you must be authenticated to access this resource"
 	app.errorResponse(w, r, http.StatusUnauthorized, message)
@@ -78,3 +146,48 @@ func (app *application) notPermittedResponse(w http.ResponseWriter, r *http.Requ
 	message := "your user account doesn't have the necessary permissions to access this resource"
 	app.errorResponse(w, r, http.StatusForbidden, message)
 }
+
+func (app *application) inactiveAccountResponse(w http.ResponseWriter, r *http.Request) {
+	message := "your user account must be activated to access this resource"
+	app.errorResponse(w, r, http.StatusForbidden, message)
+}
+
+func (app *application) accountDisabledResponse(w http.ResponseWriter, r *http.Request) {
+	app.metrics.authFailed("account_disabled")
+	message := "your user account has been disabled"
+	app.errorResponse(w, r, http.StatusForbidden, message)
+}
+
+func (app *application) editConflictResponse(w http.ResponseWriter, r *http.Request) {
+	message := "unable to update the record due to an edit conflict, please try again"
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
+func (app *application) preconditionFailedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the record has been modified since it was retrieved, please fetch it again"
+	app.errorResponse(w, r, http.StatusPreconditionFailed, message)
+}
+
+func (app *application) missingScopeResponse(w http.ResponseWriter, r *http.Request, scope string) {
+	message := fmt.Sprintf("the %s scope is required to access this resource", scope)
+	app.errorResponse(w, r, http.StatusForbidden, message)
+}
+
+func (app *application) tooManyLoginAttemptsResponse(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	app.metrics.authFailed("login_backoff")
+	w.Header().Set("Retry-After", retryAfterSeconds(retryAfter))
+	message := "too many failed sign-in attempts, please try again later"
+	app.errorResponse(w, r, http.StatusTooManyRequests, message)
+}
+
+func (app *application) accountLockedResponse(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	app.metrics.authFailed("login_locked")
+	w.Header().Set("Retry-After", retryAfterSeconds(retryAfter))
+	message := "sign-in is temporarily locked after too many failed attempts"
+	app.errorResponse(w, r, http.StatusLocked, message)
+}
+
+// Retry-After в целых секундах с округлением вверх, чтобы клиент не пришел раньше срока.
+func retryAfterSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64((d+time.Second-1)/time.Second), 10)
+}
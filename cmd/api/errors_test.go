@@ -1,6 +1,9 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"filmoteka/internal/jsonlog"
 	"net/http"
@@ -8,6 +11,8 @@ import (
 	"os"
 	"strings"
 	"testing"
+
+	"github.com/lib/pq"
 )
 
 func TestServerErrorResponse(t *testing.T) {
@@ -37,6 +42,114 @@ func TestServerErrorResponse(t *testing.T) {
 	}
 }
 
+func TestServerErrorResponse_RequestID(t *testing.T) {
+	var logs bytes.Buffer
+
+	app := &application{
+		logger: jsonlog.New(&logs, jsonlog.LevelInfo),
+	}
+
+	handler := app.requestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			app.notFoundResponse(w, r)
+			return
+		}
+
+		app.serverErrorResponse(w, r, errors.New("test error"))
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("X-Request-ID", "req-42")
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+
+	var body errorResponse
+
+	err := json.NewDecoder(res.Body).Decode(&body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if body.RequestID != "req-42" {
+		t.Errorf("expected request ID %q in response body, got %q", "req-42", body.RequestID)
+	}
+
+	if !strings.Contains(logs.String(), `"request_id":"req-42"`) {
+		t.Errorf("expected error log to carry the request ID, got %q", logs.String())
+	}
+
+	res = httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	if strings.Contains(res.Body.String(), "request_id") {
+		t.Errorf("expected no request ID in 4xx response body, got %q", res.Body.String())
+	}
+}
+
+func TestServerErrorResponse_Interrupted(t *testing.T) {
+	app := &application{
+		logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name       string
+		ctx        context.Context
+		err        error
+		wantStatus int
+		wantError  string
+	}{
+		{
+			name:       "ClientCanceled",
+			ctx:        canceled,
+			err:        &pq.Error{Code: "57014"},
+			wantStatus: statusClientClosedRequest,
+			wantError:  "the request was canceled by the client",
+		},
+		{
+			name:       "QueryTimeout",
+			ctx:        context.Background(),
+			err:        &pq.Error{Code: "57014"},
+			wantStatus: http.StatusGatewayTimeout,
+			wantError:  "the request took too long to process",
+		},
+		{
+			name:       "DeadlineExceeded",
+			ctx:        context.Background(),
+			err:        context.DeadlineExceeded,
+			wantStatus: http.StatusGatewayTimeout,
+			wantError:  "the request took too long to process",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/test", nil).WithContext(tt.ctx)
+			res := httptest.NewRecorder()
+
+			app.serverErrorResponse(res, req, tt.err)
+
+			if res.Code != tt.wantStatus {
+				t.Errorf("expected status code %d, got %d", tt.wantStatus, res.Code)
+			}
+
+			var body errorResponse
+
+			err := json.NewDecoder(res.Body).Decode(&body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if body.Error != tt.wantError {
+				t.Errorf("expected error %q, got %q", tt.wantError, body.Error)
+			}
+		})
+	}
+}
+
 func TestErrorResponse(t *testing.T) {
 	app := &application{
 		logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
@@ -307,3 +420,55 @@ func TestNotPermittedResponse(t *testing.T) {
 		t.Errorf("expected response body %q, got %q", want, got)
 	}
 }
+
+func TestEditConflictResponse(t *testing.T) {
+	app := &application{
+		logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+	}
+
+	req, err := http.NewRequest("PATCH", "/test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := httptest.NewRecorder()
+
+	app.editConflictResponse(res, req)
+
+	if res.Code != http.StatusConflict {
+		t.Errorf("expected status code %d, got %d", http.StatusConflict, res.Code)
+	}
+
+	want := `{"error":"unable to update the record due to an edit conflict, please try again"}`
+	want = strings.Join(strings.Fields(want), "")
+	got := strings.Join(strings.Fields(res.Body.String()), "")
+	if want != got {
+		t.Errorf("expected response body %q, got %q", want, got)
+	}
+}
+
+func TestPreconditionFailedResponse(t *testing.T) {
+	app := &application{
+		logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+	}
+
+	req, err := http.NewRequest("PATCH", "/test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := httptest.NewRecorder()
+
+	app.preconditionFailedResponse(res, req)
+
+	if res.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected status code %d, got %d", http.StatusPreconditionFailed, res.Code)
+	}
+
+	want := `{"error":"the record has been modified since it was retrieved, please fetch it again"}`
+	want = strings.Join(strings.Fields(want), "")
+	got := strings.Join(strings.Fields(res.Body.String()), "")
+	if want != got {
+		t.Errorf("expected response body %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"errors"
+	"filmoteka/internal/data"
+	"filmoteka/internal/validator"
+	"net/http"
+	"strings"
+)
+
+type GenreInput struct {
+	Name *string `json:"name"`
+}
+
+type GenreEnvelope struct {
+	Genre data.Genre `json:"genre"`
+}
+
+type GenresEnvelope struct {
+	Genres []data.Genre `json:"genres"`
+}
+
+// @Summary Add new genre
+// @Description Adds a new genre to the database. The name is stored in lowercase and may contain only latin letters, digits and hyphens. Once the genre is added, it can be assigned to movies.
+// @Tags Genres
+// @Accept json
+// @Produce json
+// @Param input body GenreInput true "Genre data"
+// @Success 201 {object} GenreEnvelope "Genre successfully created"
+// @Failure 400 {object} errorResponse "Client error"
+// @Failure 401 {object} errorResponse "Unauthorized"
+// @Failure 403 {object} errorResponse "Forbidden"
+// @Failure 422 {object} errorResponse "Validation error"
+// @Failure 500 {object} errorResponse "Internal server error"
+// @Router /genres [post]
+// @Security BasicAuth
+// @Security BearerAuth
+// @Security ApiKeyAuth
+func (app *application) addGenreHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name string `json:"name"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	genre := &data.Genre{
+		Name: strings.ToLower(strings.TrimSpace(input.Name)),
+	}
+
+	v := validator.New()
+	if data.ValidateGenre(v, genre); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Genres.Insert(r.Context(), genre)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateName):
+			v.AddError("name", "genre with this name already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"genre": genre}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// @Summary Rename genre
+// @Description Renames a specific genre. Movies that have this genre keep it under the new name.
+// @Tags Genres
+// @Accept json
+// @Produce json
+// @Param id path int true "Genre ID"
+// @Param input body GenreInput true "Genre data"
+// @Success 200 {object} GenreEnvelope "Genre successfully updated"
+// @Failure 400 {object} errorResponse "Client error"
+// @Failure 401 {object} errorResponse "Unauthorized"
+// @Failure 403 {object} errorResponse "Forbidden"
+// @Failure 404 {object} errorResponse "Genre not found"
+// @Failure 422 {object} errorResponse "Validation error"
+// @Failure 500 {object} errorResponse "Internal server error"
+// @Router /genres/{id} [patch]
+// @Security BasicAuth
+// @Security BearerAuth
+// @Security ApiKeyAuth
+func (app *application) updateGenreHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Name *string `json:"name"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	genre, err := app.models.Genres.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	updated := *genre
+
+	if input.Name != nil {
+		updated.Name = strings.ToLower(strings.TrimSpace(*input.Name))
+	}
+
+	v := validator.New()
+	if data.ValidateGenre(v, &updated); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Genres.Update(r.Context(), &updated)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateName):
+			v.AddError("name", "genre with this name already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"genre": updated}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// @Summary Get genre by ID
+// @Description Retrieves a specific genre from the database.
+// @Tags Genres
+// @Produce json
+// @Param id path int true "Genre ID"
+// @Success 200 {object} GenreEnvelope "Genre data"
+// @Failure 401 {object} errorResponse "Unauthorized"
+// @Failure 404 {object} errorResponse "Genre not found"
+// @Failure 500 {object} errorResponse "Internal server error"
+// @Router /genres/{id} [get]
+// @Security BasicAuth
+// @Security BearerAuth
+// @Security ApiKeyAuth
+func (app *application) getGenreHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	genre, err := app.models.Genres.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"genre": genre}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// @Summary Get genres
+// @Description Retrieves all genres in the database sorted by name. Genre names can be passed to the genre parameter of GET /movies to filter the movie list.
+// @Tags Genres
+// @Produce json
+// @Success 200 {object} GenresEnvelope "Genres data"
+// @Failure 401 {object} errorResponse "Unauthorized"
+// @Failure 500 {object} errorResponse "Internal server error"
+// @Router /genres [get]
+// @Security BasicAuth
+// @Security BearerAuth
+// @Security ApiKeyAuth
+func (app *application) getGenresHandler(w http.ResponseWriter, r *http.Request) {
+	genres, err := app.models.Genres.GetAll(r.Context())
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"genres": genres}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// @Summary Delete genre
+// @Description Deletes a specific genre from the database. The genre is removed from all movies, but the movies themselves are kept.
+// @Tags Genres
+// @Produce json
+// @Param id path int true "Genre ID"
+// @Success 200 {object} MessageEnvelope "Genre successfully deleted"
+// @Failure 401 {object} errorResponse "Unauthorized"
+// @Failure 403 {object} errorResponse "Forbidden"
+// @Failure 404 {object} errorResponse "Genre not found"
+// @Failure 500 {object} errorResponse "Internal server error"
+// @Router /genres/{id} [delete]
+// @Security BasicAuth
+// @Security BearerAuth
+// @Security ApiKeyAuth
+func (app *application) deleteGenreHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Genres.Delete(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "genre successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"filmoteka/internal/data"
+	"filmoteka/internal/jsonlog"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func TestAddGenreHandler(t *testing.T) {
+	tests := []struct {
+		name         string
+		genre        string
+		expectedCode int
+	}{
+		{"Valid", "Sci-Fi", http.StatusCreated},
+		{"Duplicate", "drama", http.StatusUnprocessableEntity},
+		{"InvalidName", "science fiction", http.StatusUnprocessableEntity},
+		{"Empty", "", http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &application{
+				models: data.NewMockModels(),
+				logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+			}
+
+			body, err := json.Marshal(map[string]string{"name": tt.genre})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/genres", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+
+			res := httptest.NewRecorder()
+
+			app.addGenreHandler(res, req)
+
+			if res.Code != tt.expectedCode {
+				t.Errorf("expected status code %d, but got %d", tt.expectedCode, res.Code)
+			}
+
+			if tt.expectedCode != http.StatusCreated {
+				return
+			}
+
+			var respBody struct {
+				Genre data.Genre `json:"genre"`
+			}
+			err = json.NewDecoder(res.Body).Decode(&respBody)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			if respBody.Genre.Name != "sci-fi" {
+				t.Errorf("expected genre name %q, but got %q", "sci-fi", respBody.Genre.Name)
+			}
+		})
+	}
+}
+
+func TestGetGenreHandler(t *testing.T) {
+	tests := []struct {
+		name         string
+		id           int64
+		expectedCode int
+	}{
+		{"Valid", 1, http.StatusOK},
+		{"NotFound", 42, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &application{
+				models: data.NewMockModels(),
+				logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/genres/", nil)
+			req = withIDParam(req, tt.id)
+
+			res := httptest.NewRecorder()
+
+			app.getGenreHandler(res, req)
+
+			if res.Code != tt.expectedCode {
+				t.Errorf("expected status code %d, but got %d", tt.expectedCode, res.Code)
+			}
+		})
+	}
+}
+
+func TestGetGenresHandler(t *testing.T) {
+	app := &application{
+		models: data.NewMockModels(),
+		logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/genres", nil)
+
+	res := httptest.NewRecorder()
+
+	app.getGenresHandler(res, req)
+
+	if res.Code != http.StatusOK {
+		t.Errorf("expected status code %d, but got %d", http.StatusOK, res.Code)
+	}
+
+	var respBody struct {
+		Genres []data.Genre `json:"genres"`
+	}
+	err := json.NewDecoder(res.Body).Decode(&respBody)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if len(respBody.Genres) != 2 || respBody.Genres[0].Name != "comedy" {
+		t.Errorf("expected genres sorted by name, but got %+v", respBody.Genres)
+	}
+}
+
+func TestUpdateGenreHandler(t *testing.T) {
+	tests := []struct {
+		name         string
+		id           int64
+		genre        string
+		expectedCode int
+	}{
+		{"Valid", 1, "Melodrama", http.StatusOK},
+		{"Duplicate", 1, "comedy", http.StatusUnprocessableEntity},
+		{"NotFound", 42, "thriller", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &application{
+				models: data.NewMockModels(),
+				logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+			}
+
+			body, err := json.Marshal(map[string]string{"name": tt.genre})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodPatch, "/genres/", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req = withIDParam(req, tt.id)
+
+			res := httptest.NewRecorder()
+
+			app.updateGenreHandler(res, req)
+
+			if res.Code != tt.expectedCode {
+				t.Errorf("expected status code %d, but got %d", tt.expectedCode, res.Code)
+			}
+
+			if tt.expectedCode != http.StatusOK {
+				return
+			}
+
+			movie, err := app.models.Movies.Get(context.Background(), 1)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(movie.Genres) != 1 || movie.Genres[0] != "melodrama" {
+				t.Errorf("expected movie genres to be renamed, but got %v", movie.Genres)
+			}
+		})
+	}
+}
+
+func TestDeleteGenreHandler(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		app := &application{
+			models: data.NewMockModels(),
+			logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+		}
+
+		req := httptest.NewRequest(http.MethodDelete, "/genres/", nil)
+		req = withIDParam(req, 1)
+
+		res := httptest.NewRecorder()
+
+		app.deleteGenreHandler(res, req)
+
+		if res.Code != http.StatusOK {
+			t.Errorf("expected status code %d, but got %d", http.StatusOK, res.Code)
+		}
+
+		movie, err := app.models.Movies.Get(context.Background(), 1)
+		if err != nil {
+			t.Fatalf("expected movie to be kept, but got %v", err)
+		}
+
+		if len(movie.Genres) != 0 {
+			t.Errorf("expected genre to be removed from movie, but got %v", movie.Genres)
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		app := &application{
+			models: data.NewMockModels(),
+			logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+		}
+
+		req := httptest.NewRequest(http.MethodDelete, "/genres/", nil)
+		req = withIDParam(req, 42)
+
+		res := httptest.NewRecorder()
+
+		app.deleteGenreHandler(res, req)
+
+		if res.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, but got %d", http.StatusNotFound, res.Code)
+		}
+	})
+}
+
+func withIDParam(r *http.Request, id int64) *http.Request {
+	params := httprouter.Params{
+		httprouter.Param{
+			Key:   "id",
+			Value: fmt.Sprint(id),
+		},
+	}
+
+	return r.WithContext(context.WithValue(r.Context(), httprouter.ParamsKey, params))
+}
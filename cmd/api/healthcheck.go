@@ -12,7 +12,7 @@ type HealthCheckResponse struct {
 }
 
 // @Summary Healthcheck
-// @Description Check the health status of the application
+// @Description Check the health status of the application.
 // @Tags Healthcheck
 // @Accept json
 // @Produce json
@@ -109,6 +109,30 @@ func (app *application) readString(qs url.Values, key string, defaultValue strin
 	return s
 }
 
+func (app *application) readCSV(qs url.Values, key string, defaultValue []string) []string {
+	csv := qs.Get(key)
+
+	if csv == "" {
+		return defaultValue
+	}
+
+	return strings.Split(csv, ",")
+}
+
+func lowerAll(values []string) []string {
+	if values == nil {
+		return nil
+	}
+
+	lowered := make([]string, len(values))
+
+	for i, value := range values {
+		lowered[i] = strings.ToLower(strings.TrimSpace(value))
+	}
+
+	return lowered
+}
+
 func (app *application) readInt(qs url.Values, key string, defaultValue int, v *validator.Validator) int {
 	s := qs.Get(key)
 
@@ -124,3 +148,108 @@ func (app *application) readInt(qs url.Values, key string, defaultValue int, v *
 
 	return i
 }
+
+func (app *application) readBool(qs url.Values, key string, defaultValue bool, v *validator.Validator) bool {
+	s := qs.Get(key)
+
+	if s == "" {
+		return defaultValue
+	}
+
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		v.AddError(key, "must be a boolean value")
+		return defaultValue
+	}
+
+	return b
+}
+
+func (app *application) readFloat(qs url.Values, key string, defaultValue float64, v *validator.Validator) float64 {
+	s := qs.Get(key)
+
+	if s == "" {
+		return defaultValue
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		v.AddError(key, "must be a number")
+		return defaultValue
+	}
+
+	return f
+}
+
+/*
+Читает список связанных ресурсов из параметра include, например ?include=actors.
+Неизвестные значения приводят к ошибке валидации, а не игнорируются молча.
+*/
+func (app *application) readInclude(qs url.Values, v *validator.Validator, permitted ...string) []string {
+	include := lowerAll(app.readCSV(qs, "include", nil))
+
+	for _, value := range include {
+		if !validator.In(value, permitted...) {
+			v.AddError("include", fmt.Sprintf("must be one of: %s", strings.Join(permitted, ", ")))
+			break
+		}
+	}
+
+	return include
+}
+
+func etag(version int32) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+func (app *application) etagHeader(version int32) http.Header {
+	headers := make(http.Header)
+	headers.Set("ETag", etag(version))
+
+	return headers
+}
+
+/*
+Проверяет заголовок If-Match: запрос без заголовка или с "*" проходит всегда,
+иначе хотя бы один из перечисленных ETag должен совпасть с текущей версией записи.
+If-Match требует строгого сравнения (RFC 7232, 3.1), поэтому слабые ETag
+вида W/"3" не совпадают ни с одной версией.
+*/
+func (app *application) ifMatch(r *http.Request, version int32) bool {
+	header := strings.Join(r.Header.Values("If-Match"), ",")
+	if header == "" {
+		return true
+	}
+
+	current := etag(version)
+
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+
+		if tag == "*" || tag == current {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+Выполняет fn в фоновой горутине, учитываемой в app.wg: при остановке
+сервер дожидается завершения фоновых задач, например отправки писем.
+*/
+func (app *application) background(fn func()) {
+	app.wg.Add(1)
+
+	go func() {
+		defer app.wg.Done()
+
+		defer func() {
+			if err := recover(); err != nil {
+				app.logger.PrintError(fmt.Errorf("%s", err), nil)
+			}
+		}()
+
+		fn()
+	}()
+}
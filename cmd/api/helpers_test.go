@@ -195,3 +195,125 @@ func TestReadString(t *testing.T) {
 		}
 	})
 }
+
+func TestReadBool(t *testing.T) {
+	app := new(application)
+
+	t.Run("Empty Query String", func(t *testing.T) {
+		v := validator.New()
+
+		result := app.readBool(url.Values{}, "fuzzy", true, v)
+		if result != true {
+			t.Errorf("expected result to be %t, got %t", true, result)
+		}
+
+		if !v.Valid() {
+			t.Error("expected no validation errors")
+		}
+	})
+
+	t.Run("Non-boolean Value", func(t *testing.T) {
+		v := validator.New()
+
+		result := app.readBool(url.Values{"fuzzy": []string{"maybe"}}, "fuzzy", true, v)
+		if result != true {
+			t.Errorf("expected result to be %t, got %t", true, result)
+		}
+
+		expectedError := "must be a boolean value"
+		if v.Errors["fuzzy"] != expectedError {
+			t.Errorf("expected validation error: %s, got: %s", expectedError, v.Errors["fuzzy"])
+		}
+	})
+
+	t.Run("Valid Boolean Value", func(t *testing.T) {
+		v := validator.New()
+
+		result := app.readBool(url.Values{"fuzzy": []string{"false"}}, "fuzzy", true, v)
+		if result != false {
+			t.Errorf("expected result to be %t, got %t", false, result)
+		}
+
+		if !v.Valid() {
+			t.Error("expected no validation errors")
+		}
+	})
+}
+
+func TestReadFloat(t *testing.T) {
+	app := new(application)
+
+	t.Run("Empty Query String", func(t *testing.T) {
+		v := validator.New()
+
+		result := app.readFloat(url.Values{}, "threshold", 0.3, v)
+		if result != 0.3 {
+			t.Errorf("expected result to be %f, got %f", 0.3, result)
+		}
+
+		if !v.Valid() {
+			t.Error("expected no validation errors")
+		}
+	})
+
+	t.Run("Non-numeric Value", func(t *testing.T) {
+		v := validator.New()
+
+		result := app.readFloat(url.Values{"threshold": []string{"abc"}}, "threshold", 0.3, v)
+		if result != 0.3 {
+			t.Errorf("expected result to be %f, got %f", 0.3, result)
+		}
+
+		expectedError := "must be a number"
+		if v.Errors["threshold"] != expectedError {
+			t.Errorf("expected validation error: %s, got: %s", expectedError, v.Errors["threshold"])
+		}
+	})
+
+	t.Run("Valid Float Value", func(t *testing.T) {
+		v := validator.New()
+
+		result := app.readFloat(url.Values{"threshold": []string{"0.5"}}, "threshold", 0.3, v)
+		if result != 0.5 {
+			t.Errorf("expected result to be %f, got %f", 0.5, result)
+		}
+
+		if !v.Valid() {
+			t.Error("expected no validation errors")
+		}
+	})
+}
+
+func TestIfMatch(t *testing.T) {
+	app := new(application)
+
+	tests := []struct {
+		name   string
+		header []string
+		want   bool
+	}{
+		{"No Header", nil, true},
+		{"Any", []string{"*"}, true},
+		{"Current", []string{`"3"`}, true},
+		{"Stale", []string{`"2"`}, false},
+		{"Weak Current", []string{`W/"3"`}, false},
+		{"Weak Stale", []string{`W/"2"`}, false},
+		{"List With Current", []string{`"1", W/"2" ,"3"`}, true},
+		{"List With Weak Current", []string{`"1", W/"3"`}, false},
+		{"List Without Current", []string{`"1", W/"2"`}, false},
+		{"Several Headers", []string{`"1"`, `"3"`}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPatch, "/movies/1", nil)
+			for _, value := range tt.header {
+				req.Header.Add("If-Match", value)
+			}
+
+			if got := app.ifMatch(req, 3); got != tt.want {
+				t.Errorf("expected ifMatch(%q) to be %v, got %v", tt.header, tt.want, got)
+			}
+		})
+	}
+}
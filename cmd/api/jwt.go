@@ -0,0 +1,262 @@
+package main
+
+import (
+	"errors"
+	"filmoteka/internal/data"
+	"filmoteka/internal/jwt"
+	"filmoteka/internal/validator"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type JWTEnvelope struct {
+	AccessToken        string    `json:"access_token"`
+	TokenType          string    `json:"token_type"`
+	ExpiresIn          int       `json:"expires_in"`
+	RefreshToken       string    `json:"refresh_token"`
+	RefreshTokenExpiry time.Time `json:"refresh_token_expiry"`
+}
+
+type RefreshTokenInput struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+func (app *application) loadJWTKeys() error {
+	keys, err := jwt.LoadKeySet(app.config.jwt.issuer, app.config.jwt.audience, app.config.jwt.keys)
+	if err != nil {
+		return err
+	}
+
+	app.jwtKeys.Store(keys)
+
+	app.logger.PrintInfo("JWT signing keys loaded", map[string]string{
+		"signing_key": keys.SigningKey().ID,
+		"keys":        strconv.Itoa(len(keys.JWKS().Keys)),
+	})
+
+	return nil
+}
+
+/*
+Выпускает пару токенов: короткоживущий JWT, который проверяется без обращения
+к базе, и непрозрачный refresh токен, который хранится в базе в виде хеша
+и позволяет отозвать сессию и обновить роль пользователя в следующем JWT.
+*/
+func (app *application) issueJWT(w http.ResponseWriter, r *http.Request, keys *jwt.KeySet, user *data.User) {
+	accessTTL := app.config.jwt.accessTTL
+
+	accessToken, err := keys.Sign(jwt.Claims{
+		Subject:     strconv.FormatInt(user.ID, 10),
+		Name:        user.Name,
+		Role:        user.Role,
+		Permissions: user.Permissions,
+		ExpiresAt:   time.Now().Add(accessTTL).Unix(),
+	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	refreshToken, err := app.models.Tokens.New(r.Context(), user.ID, app.config.jwt.refreshTTL, data.ScopeRefresh)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{
+		"access_token":         accessToken,
+		"token_type":           "Bearer",
+		"expires_in":           int(accessTTL.Seconds()),
+		"refresh_token":        refreshToken.Plaintext,
+		"refresh_token_expiry": refreshToken.Expiry,
+	}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// @Summary Create JWT access token
+// @Description Exchanges a username and password for a signed JWT access token and a refresh token. The access token carries the user ID (sub), name, role and permissions claims and the audience (aud) claim and can be verified by other services with the keys from /.well-known/jwks.json without calling Filmoteka. Filmoteka authorizes requests by these claims too, without a database lookup, so blocking the account or changing its role reaches an issued access token only when it expires. Send it in the Authorization header as "Bearer <token>". Available only when the server is started with JWT signing keys.
+// @Tags Tokens
+// @Accept json
+// @Produce json
+// @Param input body CreateTokenInput true "User credentials"
+// @Success 201 {object} JWTEnvelope "Access and refresh tokens"
+// @Failure 400 {object} errorResponse "Bad request"
+// @Failure 401 {object} errorResponse "Invalid credentials"
+// @Failure 403 {object} errorResponse "User account is disabled or not activated"
+// @Failure 404 {object} errorResponse "JWT mode is not enabled"
+// @Failure 422 {object} errorResponse "Validation failed"
+// @Failure 423 {object} errorResponse "Sign-in locked after too many failed attempts"
+// @Failure 429 {object} errorResponse "Too many failed attempts, retry after the Retry-After delay"
+// @Failure 500 {object} errorResponse "Internal server error"
+// @Router /tokens/jwt [post]
+func (app *application) createJWTHandler(w http.ResponseWriter, r *http.Request) {
+	keys := app.jwtKeys.Load()
+	if keys == nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Name     string `json:"name"`
+		Password string `json:"password"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(input.Name != "", "name", "must be provided")
+	data.ValidatePasswordPlaintext(v, input.Password)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user, ok := app.checkCredentials(w, r, input.Name, input.Password)
+	if !ok {
+		return
+	}
+
+	if user.Disabled {
+		app.accountDisabledResponse(w, r)
+		return
+	}
+
+	if !user.Activated {
+		app.inactiveAccountResponse(w, r)
+		return
+	}
+
+	app.issueJWT(w, r, keys, user)
+}
+
+// @Summary Refresh JWT access token
+// @Description Exchanges a refresh token for a new access token and a new refresh token. The used refresh token is revoked, so each one works only once. The new access token reflects the user's current role.
+// @Tags Tokens
+// @Accept json
+// @Produce json
+// @Param input body RefreshTokenInput true "Refresh token"
+// @Success 201 {object} JWTEnvelope "Access and refresh tokens"
+// @Failure 400 {object} errorResponse "Bad request"
+// @Failure 401 {object} errorResponse "Invalid or expired refresh token"
+// @Failure 403 {object} errorResponse "User account is disabled or not activated"
+// @Failure 404 {object} errorResponse "JWT mode is not enabled"
+// @Failure 422 {object} errorResponse "Validation failed"
+// @Failure 500 {object} errorResponse "Internal server error"
+// @Router /tokens/refresh [post]
+func (app *application) refreshJWTHandler(w http.ResponseWriter, r *http.Request) {
+	keys := app.jwtKeys.Load()
+	if keys == nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	if data.ValidateTokenPlaintext(v, input.RefreshToken); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user, err := app.models.Users.GetForToken(r.Context(), data.ScopeRefresh, input.RefreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.invalidAuthenticationTokenResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	if user.Disabled {
+		app.accountDisabledResponse(w, r)
+		return
+	}
+
+	if !user.Activated {
+		app.inactiveAccountResponse(w, r)
+		return
+	}
+
+	err = app.models.Tokens.Delete(r.Context(), data.ScopeRefresh, input.RefreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.invalidAuthenticationTokenResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	app.issueJWT(w, r, keys, user)
+}
+
+// @Summary JSON Web Key Set
+// @Description Returns the public keys JWT access tokens are signed with. During key rotation the set contains both the current signing key and the previous keys, so tokens issued before the rotation stay verifiable until they expire.
+// @Tags Tokens
+// @Produce json
+// @Success 200 {object} jwt.JWKS "Public signing keys"
+// @Failure 404 {object} errorResponse "JWT mode is not enabled"
+// @Router /.well-known/jwks.json [get]
+func (app *application) jwksHandler(w http.ResponseWriter, r *http.Request) {
+	keys := app.jwtKeys.Load()
+	if keys == nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Cache-Control", "public, max-age=300")
+
+	jwks := keys.JWKS()
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"keys": jwks.Keys}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+/*
+Аутентифицирует запрос по JWT. Пользователь восстанавливается из подписанных
+claims без обращения к базе: роль и права берутся из токена. Поэтому
+блокировка аккаунта и смена роли доходят до уже выданного токена только
+после его истечения (-jwt-access-ttl), а новый токен через refresh
+заблокированный пользователь не получит: его refresh токены удаляются.
+*/
+func (app *application) authenticateJWT(w http.ResponseWriter, r *http.Request, keys *jwt.KeySet, token string) (*data.User, bool) {
+	claims, err := keys.Verify(token, time.Now())
+	if err != nil {
+		app.invalidAuthenticationTokenResponse(w, r)
+		return nil, false
+	}
+
+	id, err := strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil {
+		app.invalidAuthenticationTokenResponse(w, r)
+		return nil, false
+	}
+
+	// JWT выдается только активированным пользователям.
+	return &data.User{ID: id, Name: claims.Name, Role: claims.Role, Activated: true, Permissions: claims.Permissions}, true
+}
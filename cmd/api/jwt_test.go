@@ -0,0 +1,257 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"filmoteka/internal/jwt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newJWTTestApp(t *testing.T) *application {
+	t.Helper()
+
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := jwt.NewKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := jwt.NewKeySet("filmoteka", "filmoteka", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := newTokenTestApp()
+	app.config.jwt.accessTTL = time.Minute
+	app.config.jwt.refreshTTL = time.Hour
+	app.jwtKeys.Store(keys)
+
+	return app
+}
+
+func postJSON(app *application, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	res := httptest.NewRecorder()
+
+	app.routes().ServeHTTP(res, req)
+
+	return res
+}
+
+func decodeJWT(t *testing.T, res *httptest.ResponseRecorder) JWTEnvelope {
+	t.Helper()
+
+	if res.Code != http.StatusCreated {
+		t.Fatalf("expected status code %d, but got %d", http.StatusCreated, res.Code)
+	}
+
+	var respBody JWTEnvelope
+	err := json.NewDecoder(res.Body).Decode(&respBody)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return respBody
+}
+
+func TestCreateJWTHandler(t *testing.T) {
+	t.Run("RoleClaim", func(t *testing.T) {
+		app := newJWTTestApp(t)
+
+		tokens := decodeJWT(t, postJSON(app, "/tokens/jwt", `{"name": "admin", "password": "password123"}`))
+
+		if tokens.TokenType != "Bearer" || tokens.ExpiresIn != 60 {
+			t.Errorf("unexpected token metadata %+v", tokens)
+		}
+
+		res := bearerRequest(app, http.MethodDelete, "/movies/1", tokens.AccessToken)
+		if res.Code != http.StatusOK {
+			t.Errorf("expected admin role from the token claims, but got status %d", res.Code)
+		}
+	})
+
+	t.Run("UserRole", func(t *testing.T) {
+		app := newJWTTestApp(t)
+
+		tokens := decodeJWT(t, postJSON(app, "/tokens/jwt", `{"name": "user", "password": "password123"}`))
+
+		res := bearerRequest(app, http.MethodGet, "/movies/1", tokens.AccessToken)
+		if res.Code != http.StatusOK {
+			t.Errorf("expected status code %d, but got %d", http.StatusOK, res.Code)
+		}
+
+		res = bearerRequest(app, http.MethodDelete, "/movies/1", tokens.AccessToken)
+		if res.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d, but got %d", http.StatusForbidden, res.Code)
+		}
+	})
+
+	t.Run("ForgedToken", func(t *testing.T) {
+		app := newJWTTestApp(t)
+		other := newJWTTestApp(t)
+
+		tokens := decodeJWT(t, postJSON(other, "/tokens/jwt", `{"name": "admin", "password": "password123"}`))
+
+		res := bearerRequest(app, http.MethodGet, "/movies/1", tokens.AccessToken)
+		if res.Code != http.StatusUnauthorized {
+			t.Errorf("expected status code %d, but got %d", http.StatusUnauthorized, res.Code)
+		}
+	})
+
+	t.Run("ClaimsWithoutLookup", func(t *testing.T) {
+		app := newJWTTestApp(t)
+
+		// Пользователя 42 в базе нет: права берутся только из подписанных claims.
+		token, err := app.jwtKeys.Load().Sign(jwt.Claims{
+			Subject:     "42",
+			Name:        "reader",
+			Role:        "reader",
+			Permissions: []string{"movies:read"},
+			ExpiresAt:   time.Now().Add(time.Minute).Unix(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		res := bearerRequest(app, http.MethodGet, "/movies/1", token)
+		if res.Code != http.StatusOK {
+			t.Errorf("expected status code %d, but got %d", http.StatusOK, res.Code)
+		}
+
+		res = bearerRequest(app, http.MethodDelete, "/movies/1", token)
+		if res.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d, but got %d", http.StatusForbidden, res.Code)
+		}
+	})
+
+	t.Run("OtherAudience", func(t *testing.T) {
+		app := newJWTTestApp(t)
+		keys := app.jwtKeys.Load()
+
+		token, err := keys.Sign(jwt.Claims{
+			Audience:  "billing",
+			Subject:   "2",
+			Role:      "admin",
+			ExpiresAt: time.Now().Add(time.Minute).Unix(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		res := bearerRequest(app, http.MethodGet, "/movies/1", token)
+		if res.Code != http.StatusUnauthorized {
+			t.Errorf("expected status code %d, but got %d", http.StatusUnauthorized, res.Code)
+		}
+	})
+
+	t.Run("DisabledAfterIssue", func(t *testing.T) {
+		app := newJWTTestApp(t)
+
+		tokens := decodeJWT(t, postJSON(app, "/tokens/jwt", `{"name": "user", "password": "password123"}`))
+
+		res := adminRequest(app, http.MethodPatch, "/users/1", `{"disabled": true}`)
+		if res.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, but got %d", http.StatusOK, res.Code)
+		}
+
+		// Выданный токен действует до истечения, но обменять refresh токен уже нельзя.
+		res = bearerRequest(app, http.MethodGet, "/movies/1", tokens.AccessToken)
+		if res.Code != http.StatusOK {
+			t.Errorf("expected status code %d, but got %d", http.StatusOK, res.Code)
+		}
+
+		res = postJSON(app, "/tokens/refresh", `{"refresh_token": "`+tokens.RefreshToken+`"}`)
+		if res.Code != http.StatusUnauthorized {
+			t.Errorf("expected refresh token to be revoked, but got %d", res.Code)
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		app := newTokenTestApp()
+
+		res := postJSON(app, "/tokens/jwt", `{"name": "admin", "password": "password123"}`)
+		if res.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, but got %d", http.StatusNotFound, res.Code)
+		}
+	})
+}
+
+func TestRefreshJWTHandler(t *testing.T) {
+	app := newJWTTestApp(t)
+
+	tokens := decodeJWT(t, postJSON(app, "/tokens/jwt", `{"name": "user", "password": "password123"}`))
+
+	refreshed := decodeJWT(t, postJSON(app, "/tokens/refresh", `{"refresh_token": "`+tokens.RefreshToken+`"}`))
+
+	if refreshed.RefreshToken == tokens.RefreshToken {
+		t.Errorf("expected the refresh token to be rotated")
+	}
+
+	res := postJSON(app, "/tokens/refresh", `{"refresh_token": "`+tokens.RefreshToken+`"}`)
+	if res.Code != http.StatusUnauthorized {
+		t.Errorf("expected used refresh token to be rejected, but got %d", res.Code)
+	}
+}
+
+func TestRevokeJWT(t *testing.T) {
+	app := newJWTTestApp(t)
+
+	tokens := decodeJWT(t, postJSON(app, "/tokens/jwt", `{"name": "user", "password": "password123"}`))
+
+	res := bearerRequest(app, http.MethodDelete, "/tokens/authentication", tokens.AccessToken)
+	if res.Code != http.StatusBadRequest {
+		t.Fatalf("expected status code %d, but got %d", http.StatusBadRequest, res.Code)
+	}
+
+	var respBody errorResponse
+	err := json.NewDecoder(res.Body).Decode(&respBody)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.Contains(respBody.Error, "DELETE /tokens") {
+		t.Errorf("expected the error to point to DELETE /tokens, but got %v", respBody.Error)
+	}
+
+	res = bearerRequest(app, http.MethodDelete, "/tokens", tokens.AccessToken)
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected status code %d, but got %d", http.StatusOK, res.Code)
+	}
+
+	res = postJSON(app, "/tokens/refresh", `{"refresh_token": "`+tokens.RefreshToken+`"}`)
+	if res.Code != http.StatusUnauthorized {
+		t.Errorf("expected refresh token to be revoked, but got %d", res.Code)
+	}
+}
+
+func TestJWKSHandler(t *testing.T) {
+	app := newJWTTestApp(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+	res := httptest.NewRecorder()
+
+	app.routes().ServeHTTP(res, req)
+
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected status code %d, but got %d", http.StatusOK, res.Code)
+	}
+
+	var jwks jwt.JWKS
+	err := json.NewDecoder(res.Body).Decode(&jwks)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(jwks.Keys) != 1 || jwks.Keys[0].Algorithm != jwt.AlgEdDSA || jwks.Keys[0].X == "" {
+		t.Errorf("unexpected JWKS %+v", jwks)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"errors"
+	"filmoteka/internal/data"
+	"filmoteka/internal/jsonlog"
+	"filmoteka/internal/lockout"
+	"filmoteka/internal/tracing"
+	"filmoteka/internal/validator"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+/*
+Счетчики неудачных входов по имени пользователя и по IP адресу. Для IP
+задержки между попытками не применяются, а порог блокировки выше, чтобы
+пользователи за одним NAT не мешали друг другу.
+Оба поля nil, если защита отключена флагом -lockout-enabled=false.
+*/
+type loginLockouts struct {
+	users *lockout.Tracker
+	ips   *lockout.Tracker
+}
+
+func newLoginLockouts(cfg config) loginLockouts {
+	lockoutConfig := lockout.Config{
+		Threshold: cfg.lockout.threshold,
+		BaseDelay: cfg.lockout.baseDelay,
+		MaxDelay:  cfg.lockout.maxDelay,
+		Lockout:   cfg.lockout.duration,
+	}
+
+	users := lockout.New(lockoutConfig)
+
+	lockoutConfig.Threshold = cfg.lockout.ipThreshold
+	lockoutConfig.BaseDelay = 0
+
+	return loginLockouts{users: users, ips: lockout.New(lockoutConfig)}
+}
+
+type LockoutsEnvelope struct {
+	Usernames []lockout.Lock `json:"usernames"`
+	IPs       []lockout.Lock `json:"ips"`
+}
+
+/*
+Проверяет имя и пароль с учетом защиты от перебора: пока имя или IP адрес
+заблокированы или должны выждать задержку, пароль не проверяется вовсе.
+Неизвестные имена учитываются так же, как и неверные пароли, чтобы по
+ответам нельзя было узнать, существует ли пользователь. Успешные проверки
+кешируются, и повторный запрос с теми же данными обходится без базы и bcrypt.
+*/
+func (app *application) checkCredentials(w http.ResponseWriter, r *http.Request, name, password string) (*data.User, bool) {
+	ip := app.clientIP(r)
+
+	if !app.checkLoginAllowed(w, r, name, ip) {
+		return nil, false
+	}
+
+	if user, found := app.credentials.Get(name, password); found {
+		app.lockouts.users.Reset(name)
+		return user, true
+	}
+
+	generation := app.credentials.Generation()
+
+	user, err := app.models.Users.Get(r.Context(), name)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.loginFailed(w, r, name, ip)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return nil, false
+	}
+
+	_, span := app.tracer.Start(r.Context(), "bcrypt", tracing.SpanKindInternal)
+	match, err := user.Password.Matches(password)
+	span.End()
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return nil, false
+	}
+
+	if !match {
+		app.loginFailed(w, r, name, ip)
+		return nil, false
+	}
+
+	app.lockouts.users.Reset(name)
+	app.credentials.Set(name, password, user, generation)
+
+	return user, true
+}
+
+func (app *application) checkLoginAllowed(w http.ResponseWriter, r *http.Request, name, ip string) bool {
+	userWait, userLocked := app.lockouts.users.Check(name)
+	ipWait, ipLocked := app.lockouts.ips.Check(ip)
+
+	switch {
+	case userLocked || ipLocked:
+		app.accountLockedResponse(w, r, max(userWait, ipWait))
+	case userWait > 0 || ipWait > 0:
+		app.tooManyLoginAttemptsResponse(w, r, max(userWait, ipWait))
+	default:
+		return true
+	}
+
+	return false
+}
+
+func (app *application) loginFailed(w http.ResponseWriter, r *http.Request, name, ip string) {
+	userDelay, userLocked := app.lockouts.users.Fail(name)
+	ipDelay, ipLocked := app.lockouts.ips.Fail(ip)
+
+	if userLocked {
+		app.logLockout(r, "username", name, ip, userDelay)
+	}
+
+	if ipLocked {
+		app.logLockout(r, "ip", ip, ip, ipDelay)
+	}
+
+	app.invalidCredentialsResponse(w, r)
+}
+
+func (app *application) logLockout(r *http.Request, kind, key, ip string, duration time.Duration) {
+	app.contextGetLogger(r).Warn("login locked out",
+		jsonlog.String("kind", kind),
+		jsonlog.String("key", key),
+		jsonlog.String("remote_addr", ip),
+		jsonlog.Duration("duration", duration),
+	)
+}
+
+// @Summary List login lockouts
+// @Description Lists usernames and IP addresses that are temporarily locked out after too many failed sign-in attempts, with the number of failures and the time the lockout ends. Lockouts are kept in the memory of each server instance. Requires the users:manage permission.
+// @Tags Users
+// @Produce json
+// @Success 200 {object} LockoutsEnvelope "Active lockouts"
+// @Failure 401 {object} errorResponse "Unauthorized"
+// @Failure 403 {object} errorResponse "Forbidden"
+// @Router /lockouts [get]
+// @Security BasicAuth
+// @Security BearerAuth
+func (app *application) getLockoutsHandler(w http.ResponseWriter, r *http.Request) {
+	err := app.writeJSON(w, http.StatusOK, envelope{
+		"usernames": app.lockouts.users.Locks(),
+		"ips":       app.lockouts.ips.Locks(),
+	}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// @Summary Clear login lockout
+// @Description Clears failed sign-in attempts, backoff and lockout of a username and/or an IP address, so that sign-in is allowed again immediately. Requires the users:manage permission.
+// @Tags Users
+// @Produce json
+// @Param username query string false "Username to unlock"
+// @Param ip query string false "IP address to unlock"
+// @Success 200 {object} MessageEnvelope "Lockout cleared"
+// @Failure 401 {object} errorResponse "Unauthorized"
+// @Failure 403 {object} errorResponse "Forbidden"
+// @Failure 404 {object} errorResponse "No failed attempts recorded"
+// @Failure 422 {object} errorResponse "Validation failed"
+// @Router /lockouts [delete]
+// @Security BasicAuth
+// @Security BearerAuth
+func (app *application) deleteLockoutHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	username := app.readString(qs, "username", "")
+	ip := app.readString(qs, "ip", "")
+
+	v := validator.New()
+
+	if v.Check(username != "" || ip != "", "username", "username or ip must be provided"); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	found := false
+
+	if username != "" && app.lockouts.users.Reset(username) {
+		found = true
+	}
+
+	if ip != "" && app.lockouts.ips.Reset(ip) {
+		found = true
+	}
+
+	if !found {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	app.contextGetLogger(r).PrintInfo("login lockout cleared", map[string]string{
+		"username":   username,
+		"ip":         ip,
+		"cleared_by": strconv.FormatInt(app.contextGetUser(r).ID, 10),
+	})
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"message": "lockout successfully cleared"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"filmoteka/internal/data"
+	"filmoteka/internal/jsonlog"
+	"filmoteka/internal/lockout"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newLockoutTestApp(cfg lockout.Config) *application {
+	return &application{
+		models: data.NewMockModels(),
+		logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+		lockouts: loginLockouts{
+			users: lockout.New(cfg),
+			ips:   lockout.New(lockout.Config{Threshold: 100, Lockout: time.Hour}),
+		},
+	}
+}
+
+func signIn(app *application, name, password string) *httptest.ResponseRecorder {
+	body := `{"name": "` + name + `", "password": "` + password + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/tokens/authentication", strings.NewReader(body))
+
+	res := httptest.NewRecorder()
+	app.routes().ServeHTTP(res, req)
+
+	return res
+}
+
+func TestLoginLockout(t *testing.T) {
+	t.Run("Backoff", func(t *testing.T) {
+		app := newLockoutTestApp(lockout.Config{Threshold: 5, BaseDelay: time.Minute, MaxDelay: time.Hour, Lockout: time.Hour})
+
+		for i := 0; i < 2; i++ {
+			if res := signIn(app, "user", "wrongpassword"); res.Code != http.StatusUnauthorized {
+				t.Fatalf("expected status code %d, but got %d", http.StatusUnauthorized, res.Code)
+			}
+		}
+
+		res := signIn(app, "user", "password123")
+		if res.Code != http.StatusTooManyRequests {
+			t.Fatalf("expected status code %d, but got %d", http.StatusTooManyRequests, res.Code)
+		}
+
+		if retryAfter := res.Header().Get("Retry-After"); retryAfter != "60" {
+			t.Errorf("expected Retry-After 60, but got %q", retryAfter)
+		}
+
+		if res := signIn(app, "admin", "password123"); res.Code != http.StatusCreated {
+			t.Errorf("expected other users not to be throttled, but got status code %d", res.Code)
+		}
+	})
+
+	t.Run("LockoutAndClear", func(t *testing.T) {
+		app := newLockoutTestApp(lockout.Config{Threshold: 2, Lockout: time.Hour})
+
+		for i := 0; i < 2; i++ {
+			if res := signIn(app, "user", "wrongpassword"); res.Code != http.StatusUnauthorized {
+				t.Fatalf("expected status code %d, but got %d", http.StatusUnauthorized, res.Code)
+			}
+		}
+
+		res := signIn(app, "user", "password123")
+		if res.Code != http.StatusLocked {
+			t.Fatalf("expected status code %d, but got %d", http.StatusLocked, res.Code)
+		}
+
+		if retryAfter := res.Header().Get("Retry-After"); retryAfter != "3600" {
+			t.Errorf("expected Retry-After 3600, but got %q", retryAfter)
+		}
+
+		res = adminRequest(app, http.MethodGet, "/lockouts", "")
+		if res.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, but got %d", http.StatusOK, res.Code)
+		}
+
+		var respBody LockoutsEnvelope
+		err := json.NewDecoder(res.Body).Decode(&respBody)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(respBody.Usernames) != 1 || respBody.Usernames[0].Key != "user" || len(respBody.IPs) != 0 {
+			t.Errorf("expected only user to be locked, but got %+v", respBody)
+		}
+
+		if res := adminRequest(app, http.MethodDelete, "/lockouts", ""); res.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected status code %d, but got %d", http.StatusUnprocessableEntity, res.Code)
+		}
+
+		if res := adminRequest(app, http.MethodDelete, "/lockouts?username=user", ""); res.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, but got %d", http.StatusOK, res.Code)
+		}
+
+		if res := adminRequest(app, http.MethodDelete, "/lockouts?username=user", ""); res.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, but got %d", http.StatusNotFound, res.Code)
+		}
+
+		if res := signIn(app, "user", "password123"); res.Code != http.StatusCreated {
+			t.Errorf("expected status code %d after clearing, but got %d", http.StatusCreated, res.Code)
+		}
+	})
+
+	t.Run("UnknownUser", func(t *testing.T) {
+		app := newLockoutTestApp(lockout.Config{Threshold: 1, Lockout: time.Hour})
+
+		if res := signIn(app, "nobody", "wrongpassword"); res.Code != http.StatusUnauthorized {
+			t.Fatalf("expected status code %d, but got %d", http.StatusUnauthorized, res.Code)
+		}
+
+		if res := signIn(app, "nobody", "wrongpassword"); res.Code != http.StatusLocked {
+			t.Errorf("expected unknown usernames to be locked too, but got status code %d", res.Code)
+		}
+	})
+
+	t.Run("BasicAuth", func(t *testing.T) {
+		app := newLockoutTestApp(lockout.Config{Threshold: 1, Lockout: time.Hour})
+
+		req := httptest.NewRequest(http.MethodGet, "/movies", nil)
+		req.SetBasicAuth("user", "wrongpassword")
+
+		for _, want := range []int{http.StatusUnauthorized, http.StatusLocked} {
+			res := httptest.NewRecorder()
+			app.routes().ServeHTTP(res, req)
+
+			if res.Code != want {
+				t.Errorf("expected status code %d, but got %d", want, res.Code)
+			}
+		}
+	})
+}
+
+func TestChangePasswordLockout(t *testing.T) {
+	app := newLockoutTestApp(lockout.Config{Threshold: 2, BaseDelay: 0, Lockout: time.Hour})
+	app.config.tokens.ttl = time.Hour
+
+	token := createToken(t, app, "user", "password123")
+
+	changePassword := func(oldPassword string) *httptest.ResponseRecorder {
+		body := `{"old_password": "` + oldPassword + `", "new_password": "newpassword123"}`
+		req := httptest.NewRequest(http.MethodPut, "/users/me/password", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		res := httptest.NewRecorder()
+		app.routes().ServeHTTP(res, req)
+
+		return res
+	}
+
+	for i := 0; i < 2; i++ {
+		if res := changePassword("wrongpassword"); res.Code != http.StatusUnauthorized {
+			t.Fatalf("expected status code %d, but got %d", http.StatusUnauthorized, res.Code)
+		}
+	}
+
+	if res := changePassword("password123"); res.Code != http.StatusLocked {
+		t.Errorf("expected guessing the old password to lock the account, but got status code %d", res.Code)
+	}
+}
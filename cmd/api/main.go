@@ -4,12 +4,25 @@ import (
 	"context"
 	"database/sql"
 	"flag"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"filmoteka/internal/credcache"
 	"filmoteka/internal/data"
 	"filmoteka/internal/jsonlog"
+	"filmoteka/internal/jwt"
+	"filmoteka/internal/mailer"
+	"filmoteka/internal/ratelimit"
+	"filmoteka/internal/tracing"
+	"filmoteka/internal/validator"
 
 	_ "filmoteka/docs"
 
@@ -21,20 +34,104 @@ type config struct {
 	port    int
 	env     string
 	limiter struct {
-		rps     float64
-		burst   int
-		enabled bool
+		enabled   bool
+		redisURL  string
+		anonymous ratelimit.Limit
+		user      ratelimit.Limit
+		roles     map[string]ratelimit.Limit
+		users     map[string]ratelimit.Limit
+		groups    map[string]ratelimit.Limit
+		rps       float64
+		burst     int
 	}
 	db struct {
-		dsn string
+		dsn          string
+		queryTimeout time.Duration
+	}
+	search struct {
+		threshold float64
+	}
+	tokens struct {
+		ttl              time.Duration
+		activationTTL    time.Duration
+		passwordResetTTL time.Duration
+	}
+	smtp struct {
+		host     string
+		port     int
+		username string
+		password string
+		sender   string
+		timeout  time.Duration
+	}
+	mail struct {
+		file string
+	}
+	credentials struct {
+		enabled bool
+		size    int
+		ttl     time.Duration
+	}
+	lockout struct {
+		enabled     bool
+		threshold   int
+		ipThreshold int
+		baseDelay   time.Duration
+		maxDelay    time.Duration
+		duration    time.Duration
+	}
+	metrics struct {
+		enabled bool
+		addr    string
+	}
+	log struct {
+		level  jsonlog.Level
+		caller bool
+	}
+	accessLog struct {
+		format string
+		sample float64
+		file   string
+	}
+	tracing struct {
+		exporter    string
+		endpoint    string
+		headers     map[string]string
+		file        string
+		sampleRatio float64
+		serviceName string
 	}
+	jwt struct {
+		keys       []string
+		issuer     string
+		audience   string
+		accessTTL  time.Duration
+		refreshTTL time.Duration
+	}
+	// Прокси, которым разрешено передавать адрес клиента в X-Forwarded-For.
+	trustedProxies []*net.IPNet
 }
 
 type application struct {
 	config config
 	logger *jsonlog.Logger
 	models data.Models
+	mailer mailer.Mailer
 	wg     sync.WaitGroup
+	// nil, если метрики отключены.
+	metrics *appMetrics
+	// nil, если трассировка отключена.
+	tracer *tracing.Tracer
+	// Формат, выборка и место записи журнала запросов.
+	accessLog *accessLog
+	// Счетчики запросов для rateLimit; nil, если лимиты отключены.
+	limiter ratelimit.Store
+	// nil, если кеш проверок пароля отключен.
+	credentials *credcache.Cache
+	// Защита от перебора паролей, общая для Basic, токенов и JWT.
+	lockouts loginLockouts
+	// nil, если выпуск JWT не настроен; заменяется целиком при ротации ключей.
+	jwtKeys atomic.Pointer[jwt.KeySet]
 }
 
 // @title Filmoteka API
@@ -47,6 +144,16 @@ type application struct {
 
 // @BasePath /
 // @SecurityDefinitions.basic BasicAuth
+
+// @SecurityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description Authentication token from POST /tokens/authentication in the form "Bearer <token>"
+
+// @SecurityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-API-Key
+// @description Scoped API key created by an administrator via POST /api-keys
 func main() {
 	var cfg config
 
@@ -54,15 +161,142 @@ func main() {
 	flag.IntVar(&cfg.port, "port", 4000, "API server port")
 	flag.StringVar(&cfg.env, "env", "development", "Environment (development|staging|production)")
 
+	flag.Func("trusted-proxies", "Comma-separated IP addresses or CIDRs of reverse proxies whose X-Forwarded-For and X-Real-IP headers are trusted; if empty, the connection address is the client address", func(s string) (err error) {
+		cfg.trustedProxies, err = parseTrustedProxies(s)
+		return err
+	})
+
 	flag.StringVar(&cfg.db.dsn, "db-dsn", "", "PostgreSQL DSN")
+	flag.DurationVar(&cfg.db.queryTimeout, "db-query-timeout", data.DefaultQueryTimeout, "Maximum duration of a single database operation")
+
+	cfg.limiter.anonymous = ratelimit.Limit{Requests: 120, Period: time.Minute}
+	cfg.limiter.user = ratelimit.Limit{Requests: 600, Period: time.Minute}
 
-	flag.Float64Var(&cfg.limiter.rps, "limiter-rps", 2, "Rate limiter maximum requests per second")
-	flag.IntVar(&cfg.limiter.burst, "limiter-burst", 4, "Rate limiter maximum burst")
 	flag.BoolVar(&cfg.limiter.enabled, "limiter-enabled", false, "Enable rate limiter")
+	flag.StringVar(&cfg.limiter.redisURL, "limiter-redis-url", "", "Redis URL (redis://[:password@]host:port[/db]) to share rate limits between replicas; in-memory if empty")
+	flag.Func("limiter-anonymous", "Rate limit per IP address for anonymous requests (default 120/m)", func(s string) (err error) {
+		cfg.limiter.anonymous, err = ratelimit.ParseLimit(s)
+		return err
+	})
+	flag.Func("limiter-user", "Rate limit per authenticated user (default 600/m)", func(s string) (err error) {
+		cfg.limiter.user, err = ratelimit.ParseLimit(s)
+		return err
+	})
+	flag.Float64Var(&cfg.limiter.rps, "limiter-rps", 2, "Deprecated: use -limiter-anonymous; requests per second per IP address, converted to a per-minute limit")
+	flag.IntVar(&cfg.limiter.burst, "limiter-burst", 4, "Deprecated and ignored: fixed-window limits have no burst")
+	flag.Func("limiter-roles", "Comma-separated per-role rate limits, e.g. admin=6000/m,user=300/m", func(s string) (err error) {
+		cfg.limiter.roles, err = ratelimit.ParseLimits(s)
+		if err != nil {
+			return err
+		}
+
+		for role := range cfg.limiter.roles {
+			if !validator.In(role, data.Roles...) {
+				return fmt.Errorf("unknown role %q", role)
+			}
+		}
+
+		return nil
+	})
+	flag.Func("limiter-users", "Comma-separated per-user rate limits by user ID, e.g. 42=10000/m", func(s string) (err error) {
+		cfg.limiter.users, err = ratelimit.ParseLimits(s)
+		if err != nil {
+			return err
+		}
+
+		for id := range cfg.limiter.users {
+			if _, err := strconv.ParseInt(id, 10, 64); err != nil {
+				return fmt.Errorf("invalid user ID %q", id)
+			}
+		}
+
+		return nil
+	})
+	flag.Func("limiter-groups", "Comma-separated rate limits per route group (first path segment), applied in addition to the user limit, e.g. tokens=20/m,search=60/m", func(s string) (err error) {
+		cfg.limiter.groups, err = ratelimit.ParseLimits(s)
+		return err
+	})
+
+	flag.Float64Var(&cfg.search.threshold, "search-threshold", 0.3, "Default trigram similarity threshold for fuzzy search (0-1]")
+
+	flag.DurationVar(&cfg.tokens.ttl, "token-ttl", 24*time.Hour, "Lifetime of authentication tokens")
+	flag.DurationVar(&cfg.tokens.activationTTL, "activation-ttl", 3*24*time.Hour, "Lifetime of account activation tokens")
+	flag.DurationVar(&cfg.tokens.passwordResetTTL, "password-reset-ttl", 45*time.Minute, "Lifetime of password reset tokens")
+
+	flag.StringVar(&cfg.smtp.host, "smtp-host", "", "SMTP host; if empty, emails are written to -mail-file instead")
+	flag.IntVar(&cfg.smtp.port, "smtp-port", 25, "SMTP port")
+	flag.StringVar(&cfg.smtp.username, "smtp-username", "", "SMTP username")
+	flag.StringVar(&cfg.smtp.password, "smtp-password", "", "SMTP password")
+	flag.StringVar(&cfg.smtp.sender, "smtp-sender", "Filmoteka <no-reply@filmoteka.local>", "SMTP sender")
+	flag.DurationVar(&cfg.smtp.timeout, "smtp-timeout", 10*time.Second, "Maximum duration of sending one email, including connecting to the SMTP server")
+	flag.StringVar(&cfg.mail.file, "mail-file", "", "File to append emails to when SMTP is not configured (default stdout)")
+
+	flag.BoolVar(&cfg.credentials.enabled, "credentials-cache-enabled", true, "Cache successful password checks to skip bcrypt on repeated Basic auth")
+	flag.IntVar(&cfg.credentials.size, "credentials-cache-size", 10000, "Maximum number of cached password checks")
+	flag.DurationVar(&cfg.credentials.ttl, "credentials-cache-ttl", 5*time.Minute, "Lifetime of a cached password check or JWT user; the cache is per process, so with several replicas a password change, role change or block made through another replica applies here only after this TTL")
+
+	flag.BoolVar(&cfg.lockout.enabled, "lockout-enabled", true, "Enable brute-force protection of sign-in")
+	flag.IntVar(&cfg.lockout.threshold, "lockout-threshold", 5, "Failed sign-in attempts per username before a lockout")
+	flag.IntVar(&cfg.lockout.ipThreshold, "lockout-ip-threshold", 50, "Failed sign-in attempts per IP address before a lockout")
+	flag.DurationVar(&cfg.lockout.baseDelay, "lockout-base-delay", time.Second, "Delay after the second failed attempt, doubled with each further failure")
+	flag.DurationVar(&cfg.lockout.maxDelay, "lockout-max-delay", time.Minute, "Maximum delay between failed attempts before a lockout")
+	flag.DurationVar(&cfg.lockout.duration, "lockout-duration", 15*time.Minute, "Duration of a sign-in lockout")
+
+	flag.BoolVar(&cfg.metrics.enabled, "metrics-enabled", true, "Expose Prometheus metrics")
+	flag.StringVar(&cfg.metrics.addr, "metrics-addr", "127.0.0.1:9090", "Separate admin listener address for /metrics; if empty, /metrics is served by the API server to users with the users:manage permission")
+
+	cfg.log.level = jsonlog.LevelInfo
+
+	flag.Func("log-level", "Minimum log level (debug|info|warn|error|off) (default info)", func(s string) (err error) {
+		cfg.log.level, err = jsonlog.ParseLevel(s)
+		return err
+	})
+	flag.BoolVar(&cfg.log.caller, "log-caller", false, "Add the source file and line of the call to each log record")
+
+	flag.StringVar(&cfg.accessLog.format, "access-log-format", "json", "Access log format (json|combined|logfmt)")
+	flag.Float64Var(&cfg.accessLog.sample, "access-log-sample", 1, "Fraction of successful responses to write to the access log [0-1]; errors are always logged")
+	flag.StringVar(&cfg.accessLog.file, "access-log-file", "", "File to append the access log to; if empty, it is written to stdout with the application log")
+
+	flag.StringVar(&cfg.tracing.exporter, "tracing-exporter", "", "Trace exporter (otlp|stdout|file); tracing is disabled if empty")
+	flag.StringVar(&cfg.tracing.endpoint, "tracing-endpoint", "http://localhost:4318", "OTLP/HTTP collector base URL")
+	flag.Func("tracing-headers", "Comma-separated key=value HTTP headers sent to the OTLP collector, e.g. for authentication", func(s string) error {
+		cfg.tracing.headers = make(map[string]string)
+
+		for _, pair := range strings.Split(s, ",") {
+			key, value, found := strings.Cut(pair, "=")
+			if !found || strings.TrimSpace(key) == "" {
+				return fmt.Errorf("invalid header %q, expected key=value", pair)
+			}
+
+			cfg.tracing.headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+
+		return nil
+	})
+	flag.StringVar(&cfg.tracing.file, "tracing-file", "traces.json", "File to append spans to with -tracing-exporter=file")
+	flag.Float64Var(&cfg.tracing.sampleRatio, "tracing-sample-ratio", 1, "Fraction of new traces to record [0-1]; traces started by the caller follow its decision")
+	flag.StringVar(&cfg.tracing.serviceName, "tracing-service-name", "filmoteka", "Service name reported with spans")
+
+	flag.Func("jwt-keys", "Comma-separated PEM key files for JWT; the first one signs, the rest only verify (enables JWT mode)", func(s string) error {
+		cfg.jwt.keys = strings.Split(s, ",")
+		return nil
+	})
+	flag.StringVar(&cfg.jwt.issuer, "jwt-issuer", "filmoteka", "JWT issuer (iss claim)")
+	flag.StringVar(&cfg.jwt.audience, "jwt-audience", "filmoteka", "JWT audience (aud claim); tokens for other audiences are rejected")
+	flag.DurationVar(&cfg.jwt.accessTTL, "jwt-access-ttl", 15*time.Minute, "Lifetime of JWT access tokens; blocks and role changes reach already issued tokens only when they expire")
+	flag.DurationVar(&cfg.jwt.refreshTTL, "jwt-refresh-ttl", 30*24*time.Hour, "Lifetime of refresh tokens")
 
 	flag.Parse()
 
-	logger := jsonlog.New(os.Stdout, jsonlog.LevelInfo)
+	logger := jsonlog.New(os.Stdout, cfg.log.level).WithCaller(cfg.log.caller)
+
+	// Библиотеки, пишущие через log/slog или стандартный log, попадают в тот же лог.
+	slog.SetDefault(slog.New(jsonlog.NewSlogHandler(logger)))
+
+	err := applyDeprecatedLimiterFlags(&cfg, flag.CommandLine, logger)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
 
 	db, err := openDB(cfg)
 	if err != nil {
@@ -73,10 +307,69 @@ func main() {
 
 	logger.PrintInfo("database connection pool established", nil)
 
+	mail, err := openMailer(cfg)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+
+	var metrics *appMetrics
+	modelOptions := data.Options{QueryTimeout: cfg.db.queryTimeout}
+
+	if cfg.metrics.enabled {
+		metrics = newAppMetrics()
+		metrics.registerDBStats(db)
+		modelOptions.Observe = metrics.observeQuery
+	}
+
+	accessLog, err := openAccessLog(cfg)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+
+	tracer, err := openTracer(cfg, logger)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+
+	if tracer != nil {
+		modelOptions.Trace = traceQuery(tracer)
+	}
+
 	app := &application{
-		config: cfg,
-		logger: logger,
-		models: data.NewModels(db),
+		config:    cfg,
+		logger:    logger,
+		models:    data.NewModels(db, modelOptions),
+		mailer:    mail,
+		metrics:   metrics,
+		tracer:    tracer,
+		accessLog: accessLog,
+	}
+
+	if cfg.limiter.enabled {
+		app.limiter, err = openLimiterStore(cfg)
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+	}
+
+	if cfg.credentials.enabled {
+		app.credentials, err = credcache.New(cfg.credentials.size, cfg.credentials.ttl)
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+
+		metrics.registerCredentialsCache(app.credentials)
+	}
+
+	if cfg.lockout.enabled {
+		app.lockouts = newLoginLockouts(cfg)
+	}
+
+	if len(cfg.jwt.keys) > 0 {
+		err = app.loadJWTKeys()
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
 	}
 
 	err = app.serve()
@@ -101,3 +394,79 @@ func openDB(cfg config) (*sql.DB, error) {
 
 	return db, nil
 }
+
+/*
+Без SMTP сервера письма пишутся в файл или stdout, чтобы при локальной
+разработке ссылки и токены из писем были доступны без почтового сервера.
+*/
+func openMailer(cfg config) (mailer.Mailer, error) {
+	if cfg.smtp.host != "" {
+		return mailer.NewSMTP(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender, cfg.smtp.timeout), nil
+	}
+
+	if cfg.mail.file == "" {
+		return mailer.NewFile(os.Stdout, cfg.smtp.sender), nil
+	}
+
+	f, err := os.OpenFile(cfg.mail.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	return mailer.NewFile(f, cfg.smtp.sender), nil
+}
+
+func openAccessLog(cfg config) (*accessLog, error) {
+	if cfg.accessLog.file == "" {
+		return newAccessLog(cfg.accessLog.format, cfg.accessLog.sample, nil)
+	}
+
+	f, err := os.OpenFile(cfg.accessLog.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o640)
+	if err != nil {
+		return nil, err
+	}
+
+	return newAccessLog(cfg.accessLog.format, cfg.accessLog.sample, f)
+}
+
+/*
+Флаги -limiter-rps и -limiter-burst остались от ограничителя с корзиной
+токенов. Чтобы старые конфигурации продолжали запускаться, -limiter-rps
+переводится в лимит анонимных запросов за минуту, если -limiter-anonymous
+не задан явно, а -limiter-burst игнорируется: у фиксированного окна нет
+отдельного размера всплеска. Об устаревших флагах пишется предупреждение.
+*/
+func applyDeprecatedLimiterFlags(cfg *config, flags *flag.FlagSet, logger *jsonlog.Logger) error {
+	set := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	if set["limiter-rps"] {
+		if cfg.limiter.rps <= 0 {
+			return fmt.Errorf("invalid -limiter-rps %v: must be positive", cfg.limiter.rps)
+		}
+
+		if set["limiter-anonymous"] {
+			logger.Warn("deprecated flag -limiter-rps is ignored because -limiter-anonymous is set")
+		} else {
+			cfg.limiter.anonymous = ratelimit.Limit{Requests: int(math.Ceil(cfg.limiter.rps * 60)), Period: time.Minute}
+			logger.Warn("flag -limiter-rps is deprecated, use -limiter-anonymous",
+				jsonlog.String("limiter_anonymous", cfg.limiter.anonymous.String()))
+		}
+	}
+
+	if set["limiter-burst"] {
+		logger.Warn("flag -limiter-burst is deprecated and ignored")
+	}
+
+	return nil
+}
+
+func openLimiterStore(cfg config) (ratelimit.Store, error) {
+	if cfg.limiter.redisURL == "" {
+		return ratelimit.NewMemoryStore(), nil
+	}
+
+	return ratelimit.NewRedisStore(cfg.limiter.redisURL)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"filmoteka/internal/jsonlog"
+	"filmoteka/internal/ratelimit"
+	"flag"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApplyDeprecatedLimiterFlags(t *testing.T) {
+	parse := func(t *testing.T, out io.Writer, args ...string) (config, error) {
+		t.Helper()
+
+		var cfg config
+		cfg.limiter.anonymous = ratelimit.Limit{Requests: 120, Period: time.Minute}
+
+		fs := flag.NewFlagSet("api", flag.ContinueOnError)
+		fs.Float64Var(&cfg.limiter.rps, "limiter-rps", 2, "")
+		fs.IntVar(&cfg.limiter.burst, "limiter-burst", 4, "")
+		fs.Func("limiter-anonymous", "", func(s string) (err error) {
+			cfg.limiter.anonymous, err = ratelimit.ParseLimit(s)
+			return err
+		})
+
+		if err := fs.Parse(args); err != nil {
+			t.Fatal(err)
+		}
+
+		err := applyDeprecatedLimiterFlags(&cfg, fs, jsonlog.New(out, jsonlog.LevelInfo))
+
+		return cfg, err
+	}
+
+	t.Run("RPS", func(t *testing.T) {
+		var out bytes.Buffer
+
+		cfg, err := parse(t, &out, "-limiter-rps", "5", "-limiter-burst", "10")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if cfg.limiter.anonymous != (ratelimit.Limit{Requests: 300, Period: time.Minute}) {
+			t.Errorf("expected 300/m, but got %v", cfg.limiter.anonymous)
+		}
+
+		if strings.Count(out.String(), "deprecated") != 2 {
+			t.Errorf("expected a warning for each deprecated flag, but got %s", out.String())
+		}
+	})
+
+	t.Run("AnonymousWins", func(t *testing.T) {
+		cfg, err := parse(t, io.Discard, "-limiter-rps", "5", "-limiter-anonymous", "60/m")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if cfg.limiter.anonymous != (ratelimit.Limit{Requests: 60, Period: time.Minute}) {
+			t.Errorf("expected -limiter-anonymous to take precedence, but got %v", cfg.limiter.anonymous)
+		}
+	})
+
+	t.Run("Unset", func(t *testing.T) {
+		var out bytes.Buffer
+
+		cfg, _ := parse(t, &out)
+
+		if cfg.limiter.anonymous != (ratelimit.Limit{Requests: 120, Period: time.Minute}) || out.Len() != 0 {
+			t.Errorf("expected defaults without warnings, but got %v and %s", cfg.limiter.anonymous, out.String())
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := parse(t, io.Discard, "-limiter-rps", "0")
+		if err == nil {
+			t.Error("expected an error for a non-positive rate")
+		}
+	})
+}
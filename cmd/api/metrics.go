@@ -0,0 +1,169 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"filmoteka/internal/credcache"
+	"filmoteka/internal/metrics"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+/*
+Метрики приложения для /metrics. Методы nil *appMetrics ничего не делают,
+поэтому обработчики и тесты не проверяют, включены ли метрики.
+*/
+type appMetrics struct {
+	registry     *metrics.Registry
+	requests     *metrics.CounterVec
+	duration     *metrics.HistogramVec
+	inFlight     *metrics.GaugeVec
+	rateLimited  *metrics.CounterVec
+	authFailures *metrics.CounterVec
+	queries      *metrics.HistogramVec
+}
+
+func newAppMetrics() *appMetrics {
+	r := metrics.NewRegistry()
+
+	return &appMetrics{
+		registry:     r,
+		requests:     r.NewCounterVec("http_requests_total", "Number of HTTP requests by route pattern and status.", "method", "route", "status"),
+		duration:     r.NewHistogramVec("http_request_duration_seconds", "HTTP request latency by route pattern and status.", metrics.DefaultBuckets, "method", "route", "status"),
+		inFlight:     r.NewGaugeVec("http_requests_in_flight", "Number of HTTP requests being served."),
+		rateLimited:  r.NewCounterVec("rate_limit_rejections_total", "Number of requests rejected by the rate limiter by route group.", "group"),
+		authFailures: r.NewCounterVec("auth_failures_total", "Number of rejected authentication attempts by reason.", "reason"),
+		queries:      r.NewHistogramVec("db_query_duration_seconds", "Database query duration by model method.", metrics.DefaultBuckets, "model", "method", "status"),
+	}
+}
+
+// Метрики пула соединений считываются из sql.DB.Stats() при каждом запросе /metrics.
+func (m *appMetrics) registerDBStats(db *sql.DB) {
+	if m == nil {
+		return
+	}
+
+	stats := []struct {
+		name    string
+		help    string
+		counter bool
+		value   func(sql.DBStats) float64
+	}{
+		{"db_max_open_connections", "Maximum number of open connections to the database.", false, func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }},
+		{"db_open_connections", "Number of established connections, both in use and idle.", false, func(s sql.DBStats) float64 { return float64(s.OpenConnections) }},
+		{"db_in_use_connections", "Number of connections currently in use.", false, func(s sql.DBStats) float64 { return float64(s.InUse) }},
+		{"db_idle_connections", "Number of idle connections.", false, func(s sql.DBStats) float64 { return float64(s.Idle) }},
+		{"db_wait_count_total", "Total number of connections waited for.", true, func(s sql.DBStats) float64 { return float64(s.WaitCount) }},
+		{"db_wait_duration_seconds_total", "Total time blocked waiting for a new connection.", true, func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }},
+		{"db_max_idle_closed_total", "Total number of connections closed due to the idle limit.", true, func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) }},
+		{"db_max_lifetime_closed_total", "Total number of connections closed due to the maximum lifetime.", true, func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) }},
+	}
+
+	for _, stat := range stats {
+		value := stat.value
+		fn := func() float64 { return value(db.Stats()) }
+
+		if stat.counter {
+			m.registry.NewCounterFunc(stat.name, stat.help, fn)
+		} else {
+			m.registry.NewGaugeFunc(stat.name, stat.help, fn)
+		}
+	}
+}
+
+// Счетчики кеша проверок пароля считываются из credcache.Cache.Stats() при каждом запросе /metrics.
+func (m *appMetrics) registerCredentialsCache(c *credcache.Cache) {
+	if m == nil || c == nil {
+		return
+	}
+
+	m.registry.NewCounterFunc("credentials_cache_hits_total", "Number of credential checks answered from the cache.", func() float64 { return float64(c.Stats().Hits) })
+	m.registry.NewCounterFunc("credentials_cache_misses_total", "Number of credential checks not found in the cache.", func() float64 { return float64(c.Stats().Misses) })
+	m.registry.NewGaugeFunc("credentials_cache_entries", "Number of entries in the credentials cache.", func() float64 { return float64(c.Stats().Entries) })
+}
+
+func (m *appMetrics) observeRequest(method, route string, status int, duration time.Duration) {
+	if m == nil {
+		return
+	}
+
+	if route == "" {
+		route = "unmatched"
+	}
+
+	method = metricMethod(method)
+
+	m.requests.Inc(method, route, strconv.Itoa(status))
+	m.duration.Observe(duration.Seconds(), method, route, strconv.Itoa(status))
+}
+
+/*
+Группа в метке - первый сегмент шаблона маршрута, а не пути запроса,
+чтобы запросы к произвольным путям не создавали новые ряды метрики.
+*/
+func (m *appMetrics) rateLimitRejected(route string) {
+	if m == nil {
+		return
+	}
+
+	group := "unmatched"
+	if route != "" {
+		group = routeGroup(route)
+	}
+
+	m.rateLimited.Inc(group)
+}
+
+// Нестандартные методы клиент выбирает произвольно, поэтому они объединяются в other.
+func metricMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodOptions, http.MethodConnect, http.MethodTrace:
+		return method
+	default:
+		return "other"
+	}
+}
+
+func (m *appMetrics) authFailed(reason string) {
+	if m == nil {
+		return
+	}
+
+	m.authFailures.Inc(reason)
+}
+
+// Передается в data.NewModels; ErrNoRows не считается ошибкой запроса.
+func (m *appMetrics) observeQuery(model, method string, duration time.Duration, err error) {
+	status := "ok"
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		status = "error"
+	}
+
+	m.queries.Observe(duration.Seconds(), model, method, status)
+}
+
+/*
+Считает запросы и их длительность по шаблону маршрута, а не по URL, чтобы
+число рядов не росло с каждым новым ID. Шаблон определяет matchRoute еще
+до аутентификации, поэтому отказы middleware тоже относятся к маршруту.
+*/
+func (app *application) instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.metrics == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+
+		app.metrics.inFlight.Add(1)
+		defer app.metrics.inFlight.Add(-1)
+
+		lrw := NewLoggingResponseWriter(w)
+		next.ServeHTTP(lrw, r)
+
+		app.metrics.observeRequest(r.Method, app.contextGetRoute(r).pattern, lrw.statusCode, time.Since(start))
+	})
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"filmoteka/internal/credcache"
+	"filmoteka/internal/data"
+	"filmoteka/internal/jsonlog"
+	"filmoteka/internal/ratelimit"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	app := &application{
+		models:  data.NewMockModels(),
+		logger:  jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+		metrics: newAppMetrics(),
+		limiter: ratelimit.NewMemoryStore(),
+	}
+
+	app.config.limiter.enabled = true
+	app.config.limiter.anonymous = ratelimit.Limit{Requests: 100, Period: time.Minute}
+	app.config.limiter.user = ratelimit.Limit{Requests: 100, Period: time.Minute}
+	app.config.limiter.groups = map[string]ratelimit.Limit{"search": {Requests: 1, Period: time.Minute}}
+
+	request := func(target, name, password string) {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if name != "" {
+			req.SetBasicAuth(name, password)
+		}
+
+		app.routes().ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	request("/movies/1", "user", "password123")
+	request("/movies/2", "user", "password123")
+	request("/movies/1", "user", "wrongpassword")
+	request("/search?title=mock", "user", "password123")
+	request("/search?title=mock", "user", "password123")
+	request("/nonexistent", "", "")
+
+	app.metrics.observeQuery("MovieDB", "Get", 20*time.Millisecond, nil)
+	app.metrics.observeQuery("MovieDB", "Get", 20*time.Millisecond, errors.New("connection reset"))
+
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.SetBasicAuth("admin", "password123")
+	app.routes().ServeHTTP(res, req)
+
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected status code %d, but got %d", http.StatusOK, res.Code)
+	}
+
+	body := res.Body.String()
+
+	for _, want := range []string{
+		`http_requests_total{method="GET",route="/movies/:id",status="200"} 1`,
+		`http_requests_total{method="GET",route="/movies/:id",status="404"} 1`,
+		`http_requests_total{method="GET",route="/movies/:id",status="401"} 1`,
+		`http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`http_request_duration_seconds_count{method="GET",route="/search",status="429"} 1`,
+		`http_requests_in_flight 1`,
+		`rate_limit_rejections_total{group="search"} 1`,
+		`auth_failures_total{reason="invalid_credentials"} 1`,
+		`db_query_duration_seconds_count{model="MovieDB",method="Get",status="ok"} 1`,
+		`db_query_duration_seconds_count{model="MovieDB",method="Get",status="error"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected metrics to contain %q", want)
+		}
+	}
+
+	t.Run("RequiresPermission", func(t *testing.T) {
+		for _, name := range []string{"", "user"} {
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if name != "" {
+				req.SetBasicAuth(name, "password123")
+			}
+
+			res := httptest.NewRecorder()
+			app.routes().ServeHTTP(res, req)
+
+			if res.Code == http.StatusOK || strings.Contains(res.Body.String(), "http_requests_total") {
+				t.Errorf("expected /metrics to be refused for %q, but got status code %d", name, res.Code)
+			}
+		}
+	})
+
+	t.Run("AdminListener", func(t *testing.T) {
+		app.config.metrics.addr = "127.0.0.1:9090"
+		defer func() { app.config.metrics.addr = "" }()
+
+		res := httptest.NewRecorder()
+		app.routes().ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+		if res.Code != http.StatusNotFound {
+			t.Errorf("expected /metrics not to be served by the API, but got status code %d", res.Code)
+		}
+
+		res = httptest.NewRecorder()
+		app.adminServer().Handler.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+		if res.Code != http.StatusOK || !strings.Contains(res.Body.String(), "http_requests_total") {
+			t.Errorf("expected admin listener to serve metrics, but got status code %d", res.Code)
+		}
+	})
+}
+
+func TestMetricLabels(t *testing.T) {
+	m := newAppMetrics()
+
+	m.observeRequest("BREW", "", http.StatusMethodNotAllowed, time.Millisecond)
+	m.observeRequest(http.MethodPatch, "/movies/:id", http.StatusOK, time.Millisecond)
+	m.rateLimitRejected("")
+	m.rateLimitRejected("/movies/:id/crew")
+
+	var buf strings.Builder
+
+	_, err := m.registry.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := buf.String()
+
+	for _, want := range []string{
+		`http_requests_total{method="other",route="unmatched",status="405"} 1`,
+		`http_requests_total{method="PATCH",route="/movies/:id",status="200"} 1`,
+		`rate_limit_rejections_total{group="unmatched"} 1`,
+		`rate_limit_rejections_total{group="movies"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected metrics to contain %q, got:\n%s", want, body)
+		}
+	}
+}
+
+func TestMetricsCredentialsCache(t *testing.T) {
+	cache, err := credcache.New(100, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := newAppMetrics()
+	m.registerCredentialsCache(cache)
+
+	cache.Set("user", "password123", &data.User{ID: 1, Name: "user"}, cache.Generation())
+	cache.Get("user", "password123")
+	cache.Get("user", "wrongpassword")
+
+	var buf strings.Builder
+
+	_, err = m.registry.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := buf.String()
+
+	for _, want := range []string{
+		`credentials_cache_hits_total 1`,
+		`credentials_cache_misses_total 1`,
+		`credentials_cache_entries 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected metrics to contain %q, got:\n%s", want, body)
+		}
+	}
+}
@@ -1,27 +1,33 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"filmoteka/internal/data"
+	"filmoteka/internal/jsonlog"
+	"filmoteka/internal/ratelimit"
+	"filmoteka/internal/validator"
 
-	"github.com/tomasen/realip"
-	"golang.org/x/time/rate"
+	"github.com/julienschmidt/httprouter"
 )
 
 type loggingResponseWriter struct {
 	http.ResponseWriter
 	statusCode int
+	bytes      int
 }
 
 func NewLoggingResponseWriter(w http.ResponseWriter) *loggingResponseWriter {
-	return &loggingResponseWriter{w, http.StatusOK}
+	return &loggingResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
 }
 
 func (lrw *loggingResponseWriter) WriteHeader(code int) {
@@ -29,19 +35,128 @@ func (lrw *loggingResponseWriter) WriteHeader(code int) {
 	lrw.ResponseWriter.WriteHeader(code)
 }
 
+func (lrw *loggingResponseWriter) Write(b []byte) (int, error) {
+	n, err := lrw.ResponseWriter.Write(b)
+	lrw.bytes += n
+
+	return n, err
+}
+
+// Позволяет http.ResponseController добраться до исходного ResponseWriter.
+func (lrw *loggingResponseWriter) Unwrap() http.ResponseWriter {
+	return lrw.ResponseWriter
+}
+
+/*
+Записывает в журнал каждый запрос: статус, размер ответа, длительность,
+шаблон маршрута и пользователя, которого authenticate определяет позже
+в цепочке и сохраняет в accessInfo.
+*/
 func (app *application) logRequest(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		info := &accessInfo{}
+		r = app.contextSetAccessInfo(r, info)
+
 		lrw := NewLoggingResponseWriter(w)
 		next.ServeHTTP(lrw, r)
 
-		if lrw.statusCode < http.StatusBadRequest {
-			app.logger.PrintInfo("Request processed", map[string]string{
-				"status_code": fmt.Sprintf("%d", lrw.statusCode),
-				"url":         r.URL.String(),
-				"method":      r.Method,
-				"remote_addr": r.RemoteAddr,
-			})
+		if !app.accessLog.sampled(lrw.statusCode) {
+			return
+		}
+
+		app.writeAccessLog(r, accessLogEntry{
+			time:       start,
+			remoteAddr: r.RemoteAddr,
+			method:     r.Method,
+			url:        r.URL.String(),
+			proto:      r.Proto,
+			route:      app.contextGetRoute(r).pattern,
+			status:     lrw.statusCode,
+			bytes:      lrw.bytes,
+			duration:   time.Since(start),
+			user:       info.user,
+			userAgent:  r.UserAgent(),
+			referer:    r.Referer(),
+			requestID:  app.contextGetRequestID(r),
+		})
+	})
+}
+
+const maxRequestIDLength = 128
+
+/*
+Присваивает запросу идентификатор: берет его из заголовка X-Request-ID,
+если его передал балансировщик или клиент, или создает новый. Идентификатор
+возвращается в заголовке ответа и добавляется к каждой записи лога,
+сделанной при обработке запроса, чтобы ошибку можно было связать с запросом.
+*/
+func (app *application) requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+
+		if !validRequestID(id) {
+			var err error
+
+			id, err = generateRequestID()
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
 		}
+
+		w.Header().Set("X-Request-ID", id)
+
+		r = app.contextSetRequestID(r, id)
+		r = app.contextSetLogger(r, app.contextGetLogger(r).With(map[string]string{
+			"request_id": id,
+		}))
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Чужой идентификатор принимается, только если он не длиннее 128 байт и состоит из видимых ASCII символов.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func generateRequestID() (string, error) {
+	b := make([]byte, 16)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+/*
+Находит шаблон маршрута запроса, например "/movies/:id", и кладет его
+в контекст. patterns содержит те же маршруты, что и основной роутер, но
+обработчики в нем только записывают шаблон.
+*/
+func (app *application) matchRoute(patterns *httprouter.Router, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = app.contextSetRoute(r, &routeInfo{})
+
+		if handle, _, _ := patterns.Lookup(r.Method, r.URL.Path); handle != nil {
+			handle(w, r, nil)
+		}
+
+		next.ServeHTTP(w, r)
 	})
 }
 
@@ -58,61 +173,187 @@ func (app *application) recoverPanic(next http.Handler) http.Handler {
 	})
 }
 
+/*
+Ограничивает число запросов в окне. Аутентифицированные пользователи
+считаются по ID, анонимные - по IP адресу. Лимит пользователя берется
+из настроек для него самого, для его роли или общий. У группы маршрутов,
+то есть первого сегмента пути, например tokens, может быть свой лимит,
+который проверяется в дополнение к лимиту пользователя. Если хранилище
+счетчиков недоступно, запрос пропускается, чтобы не отключать API целиком.
+*/
 func (app *application) rateLimit(next http.Handler) http.Handler {
-	type client struct {
-		limiter  *rate.Limiter
-		lastSeen time.Time
+	type bucket struct {
+		key   string
+		limit ratelimit.Limit
 	}
 
-	var (
-		mu      sync.Mutex
-		clients = make(map[string]*client)
-	)
-
-	go func() {
-		for {
-			time.Sleep(time.Minute)
-
-			mu.Lock()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.config.limiter.enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
 
-			for ip, client := range clients {
-				if time.Since(client.lastSeen) > 3*time.Minute {
-					delete(clients, ip)
-				}
-			}
+		key, limit := app.rateLimitKey(r)
+		buckets := []bucket{{key, limit}}
 
-			mu.Unlock()
+		group := routeGroup(r.URL.Path)
+		if groupLimit, found := app.config.limiter.groups[group]; found {
+			buckets = append(buckets, bucket{key + ":" + group, groupLimit})
 		}
-	}()
 
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if app.config.limiter.enabled {
-			ip := realip.FromRequest(r)
+		var result ratelimit.Result
 
-			mu.Lock()
+		for i, b := range buckets {
+			bucketResult, err := app.limiter.Take(r.Context(), b.key, b.limit)
+			if err != nil {
+				app.contextGetLogger(r).Warn("rate limit store unavailable, request allowed",
+					jsonlog.Err(err),
+				)
 
-			if _, found := clients[ip]; !found {
-				clients[ip] = &client{limiter: rate.NewLimiter(rate.Limit(app.config.limiter.rps), app.config.limiter.burst)}
+				next.ServeHTTP(w, r)
+				return
 			}
 
-			clients[ip].lastSeen = time.Now()
+			if i == 0 || !bucketResult.Allowed || bucketResult.Remaining < result.Remaining {
+				result = bucketResult
+			}
 
-			if !clients[ip].limiter.Allow() {
-				mu.Unlock()
-				app.rateLimitExceededResponse(w, r)
-				return
+			if !bucketResult.Allowed {
+				break
 			}
+		}
 
-			mu.Unlock()
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		w.Header().Set("RateLimit-Reset", retryAfterSeconds(result.Reset))
+
+		if !result.Allowed {
+			w.Header().Set("Retry-After", retryAfterSeconds(result.Reset))
+			app.metrics.rateLimitRejected(app.contextGetRoute(r).pattern)
+			app.rateLimitExceededResponse(w, r)
+			return
 		}
 
 		next.ServeHTTP(w, r)
 	})
 }
 
+/*
+Ограничивает попытки аутентификации с неверными учетными данными. Пока
+rateLimit после authenticate не знает пользователя, каждый ответ 401 на
+запрос с заголовком Authorization или X-API-Key учитывается в лимите IP
+адреса для анонимных запросов. Адрес, исчерпавший лимит, получает 429
+до проверки пароля, токена или API ключа, то есть без обращения к базе
+и bcrypt. Успешная аутентификация лимит адреса не расходует.
+*/
+func (app *application) rateLimitFailedAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.config.limiter.enabled || (r.Header.Get("Authorization") == "" && r.Header.Get("X-API-Key") == "") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key, limit := "ip:"+app.clientIP(r), app.config.limiter.anonymous
+
+		result, err := app.limiter.Peek(r.Context(), key, limit)
+		if err != nil {
+			app.contextGetLogger(r).Warn("rate limit store unavailable, request allowed",
+				jsonlog.Err(err),
+			)
+
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !result.Allowed {
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+			w.Header().Set("RateLimit-Remaining", "0")
+			w.Header().Set("RateLimit-Reset", retryAfterSeconds(result.Reset))
+			w.Header().Set("Retry-After", retryAfterSeconds(result.Reset))
+			app.metrics.rateLimitRejected(app.contextGetRoute(r).pattern)
+			app.rateLimitExceededResponse(w, r)
+			return
+		}
+
+		lrw := NewLoggingResponseWriter(w)
+		next.ServeHTTP(lrw, r)
+
+		if lrw.statusCode == http.StatusUnauthorized {
+			_, err = app.limiter.Take(context.WithoutCancel(r.Context()), key, limit)
+			if err != nil {
+				app.contextGetLogger(r).Warn("rate limit store unavailable, failed authentication not counted",
+					jsonlog.Err(err),
+				)
+			}
+		}
+	})
+}
+
+func (app *application) rateLimitKey(r *http.Request) (string, ratelimit.Limit) {
+	user := app.contextGetUser(r)
+
+	if user.IsAnonymous() {
+		return "ip:" + app.clientIP(r), app.config.limiter.anonymous
+	}
+
+	id := strconv.FormatInt(user.ID, 10)
+
+	if limit, found := app.config.limiter.users[id]; found {
+		return "user:" + id, limit
+	}
+
+	if limit, found := app.config.limiter.roles[user.Role]; found {
+		return "user:" + id, limit
+	}
+
+	return "user:" + id, app.config.limiter.user
+}
+
+// Возвращает первый сегмент пути: "/movies/1/crew" относится к группе movies.
+func routeGroup(path string) string {
+	group, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	return group
+}
+
+/*
+Запрос с заголовком X-API-Key аутентифицируется по API ключу. Иначе
+поддерживаются две схемы: Basic с проверкой пароля через bcrypt (успешные
+проверки кешируются на -credentials-cache-ttl) и Bearer
+с токеном из POST /tokens/authentication, который проверяется одним запросом
+к базе без дорогого хеширования, или с JWT из POST /tokens/jwt, который
+проверяется по подписи вовсе без обращения к базе. Заблокированные
+пользователи получают 403; JWT блокировка не отзывает, но выданный
+токен живет недолго, а refresh токены удаляются при блокировке.
+*/
 func (app *application) authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Vary", "Authorization")
+		w.Header().Add("Vary", "X-API-Key")
+
+		if apiKey := r.Header.Get("X-API-Key"); apiKey != "" {
+			user, key, err := app.models.Users.GetForAPIKey(r.Context(), apiKey)
+			if err != nil {
+				switch {
+				case errors.Is(err, data.ErrRecordNotFound):
+					app.invalidAPIKeyResponse(w, r)
+				default:
+					app.serverErrorResponse(w, r, err)
+				}
+
+				return
+			}
+
+			if user.Disabled {
+				app.accountDisabledResponse(w, r)
+				return
+			}
+
+			r = app.contextSetUser(r, user)
+			r = app.contextSetAPIKey(r, key)
+
+			next.ServeHTTP(w, r)
+			return
+		}
 
 		authorizationHeader := r.Header.Get("Authorization")
 
@@ -123,55 +364,95 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 		}
 
 		headerParts := strings.Split(authorizationHeader, " ")
-		if len(headerParts) != 2 || headerParts[0] != "Basic" {
+		if len(headerParts) != 2 {
 			app.invalidAuthenticationCredentialsResponse(w, r)
 			return
 		}
 
-		encodedCredentials := headerParts[1]
+		var user *data.User
+		var ok bool
 
-		decodedCredentials, err := base64.StdEncoding.DecodeString(encodedCredentials)
-		if err != nil {
+		switch headerParts[0] {
+		case "Basic":
+			user, ok = app.authenticateBasic(w, r, headerParts[1])
+		case "Bearer":
+			user, ok = app.authenticateBearer(w, r, headerParts[1])
+		default:
 			app.invalidAuthenticationCredentialsResponse(w, r)
 			return
 		}
 
-		credentialsParts := strings.Split(string(decodedCredentials), ":")
-		if len(credentialsParts) != 2 {
-			app.invalidAuthenticationCredentialsResponse(w, r)
+		if !ok {
 			return
 		}
 
-		username := credentialsParts[0]
-		password := credentialsParts[1]
-
-		user, err := app.models.Users.Get(username)
-		if err != nil {
-			switch {
-			case errors.Is(err, data.ErrRecordNotFound):
-				app.invalidCredentialsResponse(w, r)
-			default:
-				app.serverErrorResponse(w, r, err)
-			}
-
+		if user.Disabled {
+			app.accountDisabledResponse(w, r)
 			return
 		}
 
-		match, err := user.Password.Matches(password)
-		if err != nil {
+		r = app.contextSetUser(r, user)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (app *application) authenticateBasic(w http.ResponseWriter, r *http.Request, encodedCredentials string) (*data.User, bool) {
+	decodedCredentials, err := base64.StdEncoding.DecodeString(encodedCredentials)
+	if err != nil {
+		app.invalidAuthenticationCredentialsResponse(w, r)
+		return nil, false
+	}
+
+	credentialsParts := strings.Split(string(decodedCredentials), ":")
+	if len(credentialsParts) != 2 {
+		app.invalidAuthenticationCredentialsResponse(w, r)
+		return nil, false
+	}
+
+	return app.checkCredentials(w, r, credentialsParts[0], credentialsParts[1])
+}
+
+func (app *application) authenticateBearer(w http.ResponseWriter, r *http.Request, token string) (*data.User, bool) {
+	if keys := app.jwtKeys.Load(); keys != nil && isJWT(token) {
+		return app.authenticateJWT(w, r, keys, token)
+	}
+
+	v := validator.New()
+
+	if data.ValidateTokenPlaintext(v, token); !v.Valid() {
+		app.invalidAuthenticationTokenResponse(w, r)
+		return nil, false
+	}
+
+	user, err := app.models.Users.GetForToken(r.Context(), data.ScopeAuthentication, token)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.invalidAuthenticationTokenResponse(w, r)
+		default:
 			app.serverErrorResponse(w, r, err)
-			return
 		}
 
-		if !match {
-			app.invalidCredentialsResponse(w, r)
-			return
-		}
+		return nil, false
+	}
 
-		r = app.contextSetUser(r, user)
+	return user, true
+}
 
-		next.ServeHTTP(w, r)
-	})
+// JWT состоит из трех частей через точку, а непрозрачные токены точек не содержат.
+func isJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// Возвращает токен из заголовка Authorization, если запрос аутентифицирован по схеме Bearer.
+func bearerToken(r *http.Request) (string, bool) {
+	headerParts := strings.Split(r.Header.Get("Authorization"), " ")
+	if len(headerParts) != 2 || headerParts[0] != "Bearer" {
+		return "", false
+	}
+
+	return headerParts[1], true
 }
 
 func (app *application) requireAuthenticatedUser(next http.HandlerFunc) http.HandlerFunc {
@@ -183,19 +464,33 @@ func (app *application) requireAuthenticatedUser(next http.HandlerFunc) http.Han
 			return
 		}
 
+		if !user.Activated {
+			app.inactiveAccountResponse(w, r)
+			return
+		}
+
 		next.ServeHTTP(w, r)
 	})
 }
 
-func (app *application) requireRoleAdmin(next http.HandlerFunc) http.HandlerFunc {
+/*
+Проверяет право code у пользователя. Права определяются ролью пользователя,
+а при запросе по API ключу дополнительно ограничиваются областями ключа.
+*/
+func (app *application) requirePermission(code string, next http.HandlerFunc) http.HandlerFunc {
 	fn := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		user := app.contextGetUser(r)
 
-		if user.Role != "admin" {
+		if !user.Permissions.Include(code) {
 			app.notPermittedResponse(w, r)
 			return
 		}
 
+		if key := app.contextGetAPIKey(r); key != nil && !data.ScopesGrant(key.Scopes, code) {
+			app.missingScopeResponse(w, r, code)
+			return
+		}
+
 		next.ServeHTTP(w, r)
 	})
 
@@ -1,50 +1,64 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"filmoteka/internal/data"
 	"filmoteka/internal/validator"
 	"net/http"
+	"slices"
 	"time"
 )
 
 type MovieInput struct {
-	Title       *string  `json:"title"`
-	Description *string  `json:"description"`
-	ReleaseDate *string  `json:"release_date"` // RFC3339
-	Rating      *float32 `json:"rating"`
-	Actors      *[]int64 `json:"actors"`
+	Title       *string            `json:"title"`
+	Description *string            `json:"description"`
+	ReleaseDate *string            `json:"release_date"` // RFC3339
+	Rating      *float32           `json:"rating"`
+	Actors      *[]data.CastMember `json:"actors"`
+	Crew        *[]data.Credit     `json:"crew"`
+	Genres      *[]string          `json:"genres"`
 }
 
 type MovieEnvelope struct {
 	Movie data.Movie `json:"movie"`
 }
 
+type CrewEnvelope struct {
+	Crew map[string][]data.CrewMember `json:"crew"`
+}
+
 type MoviesEnvelope struct {
-	Movie []data.Movie `json:"movie"`
+	Movie    []data.Movie  `json:"movie"`
+	Metadata data.Metadata `json:"metadata"`
 }
 
 // @Summary Add a new movie
-// @Description Adds a new movie to the database. The request body should include the movie's title, description, release date, rating, and a list of actor IDs.
+// @Description Adds a new movie to the database. The request body should include the movie's title, description, release date, rating, a list of actors (each with an actor ID, an optional character name and an optional billing order; a bare actor ID is also accepted), an optional list of crew credits (person ID and role: director, writer, producer, composer, cinematographer or editor) and a list of genre names. People and genres must already exist.
 // @Tags Movies
 // @Accept json
 // @Produce json
 // @Param input body MovieInput true "Movie data"
 // @Success 201 {object} MovieEnvelope "Movie successfully created"
+// @Header 201 {string} ETag "Version of the created movie"
 // @Failure 400 {object} errorResponse "Client error"
 // @Failure 401 {object} errorResponse "Unauthorized"
 // @Failure 403 {object} errorResponse "Forbidden"
 // @Failure 422 {object} errorResponse "Validation error"
 // @Failure 500 {object} errorResponse "Internal server error"
 // @Security BasicAuth
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /movies [post]
 func (app *application) addMovieHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Title       string    `json:"title"`
-		Description string    `json:"description"`
-		ReleaseDate time.Time `json:"release_date"` // RFC3339
-		Rating      float32   `json:"rating"`
-		Actors      []int64   `json:"actors"`
+		Title       string            `json:"title"`
+		Description string            `json:"description"`
+		ReleaseDate time.Time         `json:"release_date"` // RFC3339
+		Rating      float32           `json:"rating"`
+		Actors      []data.CastMember `json:"actors"`
+		Crew        []data.Credit     `json:"crew"`
+		Genres      []string          `json:"genres"`
 	}
 
 	err := app.readJSON(w, r, &input)
@@ -53,12 +67,16 @@ func (app *application) addMovieHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	data.NormalizeCast(input.Actors)
+
 	movie := &data.Movie{
 		Title:       input.Title,
 		Description: input.Description,
 		ReleaseDate: input.ReleaseDate,
 		Rating:      input.Rating,
 		Actors:      input.Actors,
+		Crew:        input.Crew,
+		Genres:      lowerAll(input.Genres),
 	}
 
 	v := validator.New()
@@ -67,7 +85,9 @@ func (app *application) addMovieHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = app.models.Movies.Insert(movie)
+	err = app.models.Transaction(r.Context(), func(tx data.Models) error {
+		return tx.Movies.Insert(r.Context(), movie)
+	})
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrDuplicateName):
@@ -76,6 +96,12 @@ func (app *application) addMovieHandler(w http.ResponseWriter, r *http.Request)
 		case errors.Is(err, data.ErrActorsNotFound):
 			v.AddError("actors", "one or more actor IDs do not exist")
 			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrCrewNotFound):
+			v.AddError("crew", "one or more crew member IDs do not exist")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrGenresNotFound):
+			v.AddError("genres", "one or more genres do not exist")
+			app.failedValidationResponse(w, r, v.Errors)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
@@ -83,27 +109,41 @@ func (app *application) addMovieHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusCreated, envelope{"movie": movie}, nil)
+	if movie.Crew == nil {
+		movie.Crew = []data.Credit{}
+	}
+
+	if movie.Genres == nil {
+		movie.Genres = []string{}
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"movie": movie}, app.etagHeader(movie.Version))
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
 
 // @Summary Update a movie
-// @Description Updates the information of a specific movie in the database. This can be a partial or full update. If a field is not provided in the request body, the current value of that field will be retained.
+// @Description Updates the information of a specific movie in the database. This can be a partial or full update. If a field is not provided in the request body, the current value of that field will be retained. To avoid overwriting concurrent changes, send the ETag received from a previous response in the If-Match header.
 // @Tags Movies
 // @Accept json
 // @Produce json
 // @Param id path int true "Movie ID"
+// @Param If-Match header string false "ETag of the movie version being updated"
 // @Param input body MovieInput true "Movie data"
 // @Success 200 {object} MovieEnvelope "Movie successfully updated"
+// @Header 200 {string} ETag "Version of the updated movie"
 // @Failure 400 {object} errorResponse "Client error"
 // @Failure 401 {object} errorResponse "Unauthorized"
 // @Failure 403 {object} errorResponse "Forbidden"
 // @Failure 404 {object} errorResponse "Movie not found"
+// @Failure 409 {object} errorResponse "Edit conflict"
+// @Failure 412 {object} errorResponse "If-Match does not match the current version"
 // @Failure 422 {object} errorResponse "Validation error"
 // @Failure 500 {object} errorResponse "Internal server error"
 // @Security BasicAuth
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /movies/{id} [patch]
 func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := app.readIDParam(r)
@@ -113,11 +153,13 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 	var input struct {
-		Title       *string    `json:"title"`
-		Description *string    `json:"description"`
-		ReleaseDate *time.Time `json:"release_date"` // RFC3339
-		Rating      *float32   `json:"rating"`
-		Actors      []int64    `json:"actors"`
+		Title       *string           `json:"title"`
+		Description *string           `json:"description"`
+		ReleaseDate *time.Time        `json:"release_date"` // RFC3339
+		Rating      *float32          `json:"rating"`
+		Actors      []data.CastMember `json:"actors"`
+		Crew        []data.Credit     `json:"crew"`
+		Genres      []string          `json:"genres"`
 	}
 
 	err = app.readJSON(w, r, &input)
@@ -126,7 +168,7 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	movie, err := app.models.Movies.Get(id)
+	movie, err := app.models.Movies.Get(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -138,6 +180,11 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	if !app.ifMatch(r, movie.Version) {
+		app.preconditionFailedResponse(w, r)
+		return
+	}
+
 	if input.Title != nil {
 		movie.Title = *input.Title
 	}
@@ -155,24 +202,43 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 	if input.Actors != nil {
+		data.NormalizeCast(input.Actors)
 		movie.Actors = input.Actors
 	}
 
+	if input.Crew != nil {
+		movie.Crew = input.Crew
+	}
+
+	if input.Genres != nil {
+		movie.Genres = lowerAll(input.Genres)
+	}
+
 	v := validator.New()
 	if data.ValidateMovie(v, movie); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	err = app.models.Movies.Update(*movie)
+	err = app.models.Transaction(r.Context(), func(tx data.Models) error {
+		return tx.Movies.Update(r.Context(), movie)
+	})
 	if err != nil {
 		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
 		case errors.Is(err, data.ErrDuplicateName):
 			v.AddError("title", "movie with this title already exists")
 			app.failedValidationResponse(w, r, v.Errors)
 		case errors.Is(err, data.ErrActorsNotFound):
 			v.AddError("actors", "one or more actor IDs do not exist")
 			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrCrewNotFound):
+			v.AddError("crew", "one or more crew member IDs do not exist")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrGenresNotFound):
+			v.AddError("genres", "one or more genres do not exist")
+			app.failedValidationResponse(w, r, v.Errors)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
@@ -180,22 +246,26 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, app.etagHeader(movie.Version))
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
 
 // @Summary Get a movie
-// @Description Retrieves detailed information about a specific movie, including its title, description, release date, rating, and a list of actor IDs.
+// @Description Retrieves detailed information about a specific movie, including its title, description, release date, rating, and its cast in billing order with the character each actor plays. With include=actors every cast entry also carries the full actor object.
 // @Tags Movies
 // @Produce json
 // @Param id path int true "Movie ID"
+// @Param include query string false "Related resources to embed: actors"
 // @Success 200 {object} MovieEnvelope "Movie data"
+// @Header 200 {string} ETag "Version of the movie"
 // @Failure 401 {object} errorResponse "Unauthorized"
 // @Failure 404 {object} errorResponse "Movie not found"
 // @Failure 500 {object} errorResponse "Internal server error"
 // @Security BasicAuth
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /movies/{id} [get]
 func (app *application) getMovieHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := app.readIDParam(r)
@@ -204,7 +274,62 @@ func (app *application) getMovieHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	movie, err := app.models.Movies.Get(id)
+	v := validator.New()
+
+	include := app.readInclude(r.URL.Query(), v, "actors")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	movie, err := app.models.Movies.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if slices.Contains(include, "actors") {
+		movies, err := app.includeActors(r.Context(), []*data.Movie{movie})
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		movie = movies[0]
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, app.etagHeader(movie.Version))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// @Summary Get movie crew
+// @Description Retrieves everyone who worked on a specific movie, including the cast, grouped by role: actor, director, writer, producer, composer, cinematographer and editor. Roles without people are omitted.
+// @Tags Movies
+// @Produce json
+// @Param id path int true "Movie ID"
+// @Success 200 {object} CrewEnvelope "Movie crew grouped by role"
+// @Failure 401 {object} errorResponse "Unauthorized"
+// @Failure 404 {object} errorResponse "Movie not found"
+// @Failure 500 {object} errorResponse "Internal server error"
+// @Security BasicAuth
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /movies/{id}/crew [get]
+func (app *application) getMovieCrewHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	crew, err := app.models.Movies.GetCrew(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -215,22 +340,29 @@ func (app *application) getMovieHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	err = app.writeJSON(w, http.StatusOK, envelope{"crew": crew}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
 
 // @Summary Get all movies
-// @Description Retrieves a list of all movies in the database. Each entry includes the movie's title, description, release date, rating, and a list of actor IDs. The result can be sorted by title, rating, or release date, in ascending or descending order. The default sort order is by rating in descending order.
+// @Description Retrieves a paginated list of movies in the database. Each entry includes the movie's title, description, release date, rating, and a list of actor IDs. The result can be sorted by title, rating, or release date, in ascending or descending order. The default sort order is by rating in descending order. The list can be filtered by one or more genres. Pages can be requested by number or, for deep pages, by the opaque cursor returned in metadata.next_cursor. With include=actors every cast entry also carries the full actor object; all actors of the page are loaded in one batch.
 // @Tags Movies
 // @Produce json
 // @Param sort query string false "Sort order: title, rating, release_date, -title, -rating, -release_date"
+// @Param page query int false "Page number (default 1)"
+// @Param page_size query int false "Page size, maximum 100 (default 20)"
+// @Param cursor query string false "Cursor from metadata.next_cursor of the previous page"
+// @Param genre query string false "Comma-separated genre names; only movies having all of them are returned"
+// @Param include query string false "Related resources to embed: actors"
 // @Success 200 {object} MoviesEnvelope "List of movies"
 // @Failure 401 {object} errorResponse "Unauthorized"
 // @Failure 422 {object} errorResponse "Validation error"
 // @Failure 500 {object} errorResponse "Internal server error"
 // @Security BasicAuth
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /movies [get]
 func (app *application) getMoviesHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
@@ -241,6 +373,12 @@ func (app *application) getMoviesHandler(w http.ResponseWriter, r *http.Request)
 
 	qs := r.URL.Query()
 
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Cursor = app.readString(qs, "cursor", "")
+	input.Filters.Genres = lowerAll(app.readCSV(qs, "genre", nil))
+	include := app.readInclude(qs, v, "actors")
+
 	input.Filters.Sort = app.readString(qs, "sort", "-rating")
 	input.Filters.SortSafelist = []string{"title", "rating", "release_date", "-title", "-rating", "-release_date"}
 
@@ -249,13 +387,21 @@ func (app *application) getMoviesHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	movies, err := app.models.Movies.GetAll(input.Filters)
+	movies, metadata, err := app.models.Movies.GetAll(r.Context(), input.Filters)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"movies": movies}, nil)
+	if slices.Contains(include, "actors") {
+		movies, err = app.includeActors(r.Context(), movies)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movies": movies, "metadata": metadata}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -272,6 +418,8 @@ func (app *application) getMoviesHandler(w http.ResponseWriter, r *http.Request)
 // @Failure 403 {object} errorResponse "Forbidden"
 // @Failure 500 {object} errorResponse "Internal server error"
 // @Security BasicAuth
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /movies/{id} [delete]
 func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := app.readIDParam(r)
@@ -280,7 +428,7 @@ func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	err = app.models.Movies.Delete(id)
+	err = app.models.Movies.Delete(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -298,30 +446,98 @@ func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Reques
 }
 
 // @Summary Search for movies
-// @Description Searches for movies by part of the title or actor name. The query parameters should include the title and/or actor.
+// @Description Searches for movies by a full-text query over the title, description and cast names, and/or by the title, actor name or character name. Full-text search understands English and Russian word forms and supports quoted phrases, OR and -exclusions. Title, actor and character are matched by trigram similarity, so typos are tolerated; set fuzzy=false to match an exact part of them instead. When both actor and character are given, they must match the same cast entry. Results are ordered by similarity and then by relevance, which are returned in each movie's similarity and relevance fields. With include=actors every cast entry also carries the full actor object.
 // @Tags Search
 // @Produce json
+// @Param q query string false "Full-text query"
 // @Param title query string false "Movie title"
 // @Param actor query string false "Actor name"
+// @Param character query string false "Character name played by an actor"
+// @Param fuzzy query bool false "Match title, actor and character by similarity (default true)"
+// @Param threshold query number false "Minimum similarity for fuzzy matches, (0, 1]"
+// @Param include query string false "Related resources to embed: actors"
 // @Success 200 {object} MoviesEnvelope "List of movies"
 // @Failure 401 {object} errorResponse "Unauthorized"
+// @Failure 422 {object} errorResponse "Validation error"
 // @Failure 500 {object} errorResponse "Internal server error"
 // @Security BasicAuth
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /search [get]
 func (app *application) searchMovieHandler(w http.ResponseWriter, r *http.Request) {
 	qs := r.URL.Query()
 
-	title := app.readString(qs, "title", "")
-	actor := app.readString(qs, "actor", "")
+	v := validator.New()
+
+	query := data.SearchQuery{
+		Text:      app.readString(qs, "q", ""),
+		Title:     app.readString(qs, "title", ""),
+		Actor:     app.readString(qs, "actor", ""),
+		Character: app.readString(qs, "character", ""),
+		Fuzzy:     app.readBool(qs, "fuzzy", true, v),
+		Threshold: app.readFloat(qs, "threshold", app.config.search.threshold, v),
+	}
+
+	include := app.readInclude(qs, v, "actors")
 
-	movies, err := app.models.Movies.Search(title, actor)
+	if data.ValidateSearchQuery(v, query); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	movies, err := app.models.Movies.Search(r.Context(), query)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
+	if slices.Contains(include, "actors") {
+		movies, err = app.includeActors(r.Context(), movies)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
 	err = app.writeJSON(w, http.StatusOK, envelope{"movies": movies}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+/*
+Подставляет в состав фильмов полные данные актеров. Все актеры всех фильмов
+загружаются одним запросом; фильмы копируются, чтобы не менять записи модели.
+*/
+func (app *application) includeActors(ctx context.Context, movies []*data.Movie) ([]*data.Movie, error) {
+	var ids []int64
+
+	for _, movie := range movies {
+		for _, member := range movie.Actors {
+			if !slices.Contains(ids, member.ActorID) {
+				ids = append(ids, member.ActorID)
+			}
+		}
+	}
+
+	actors, err := app.models.Actors.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	included := make([]*data.Movie, len(movies))
+
+	for i, movie := range movies {
+		expanded := *movie
+		expanded.Actors = make([]data.CastMember, len(movie.Actors))
+
+		for j, member := range movie.Actors {
+			member.Actor = actors[member.ActorID]
+			expanded.Actors[j] = member
+		}
+
+		included[i] = &expanded
+	}
+
+	return included, nil
+}
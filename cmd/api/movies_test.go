@@ -76,6 +76,48 @@ func TestAddMovieHandler(t *testing.T) {
 		}
 	})
 
+	t.Run("CastWithCharacters", func(t *testing.T) {
+		app := &application{
+			models: data.NewMockModels(),
+			logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+		}
+
+		reqBody := `{
+			"title": "New Movie",
+			"description": "New Movie Description",
+			"release_date": "2021-01-01T00:00:00Z",
+			"rating": 8,
+			"actors": [
+				{"actor_id": 2, "character": "Sidekick", "billing_order": 2},
+				{"actor_id": 1, "character": "Hero", "billing_order": 1}
+			]
+		}`
+
+		req := httptest.NewRequest(http.MethodPost, "/movies", strings.NewReader(reqBody))
+		req.Header.Set("Content-Type", "application/json")
+
+		res := httptest.NewRecorder()
+
+		app.addMovieHandler(res, req)
+
+		if res.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, but got %d", http.StatusCreated, res.Code)
+		}
+
+		var respBody struct {
+			Movie data.Movie `json:"movie"`
+		}
+		err := json.NewDecoder(res.Body).Decode(&respBody)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		cast := respBody.Movie.Actors
+		if len(cast) != 2 || cast[0].ActorID != 1 || cast[0].Character != "Hero" || cast[1].BillingOrder != 2 {
+			t.Errorf("expected cast in billing order, but got %+v", cast)
+		}
+	})
+
 	t.Run("InvalidInput", func(t *testing.T) {
 		app := &application{
 			models: data.NewMockModels(),
@@ -485,6 +527,49 @@ func TestUpdateMovieHandler(t *testing.T) {
 			t.Errorf("expected status code %d, but got %d", http.StatusNotFound, res.Code)
 		}
 	})
+
+	t.Run("IfMatch", func(t *testing.T) {
+		tests := []struct {
+			ifMatch  string
+			expected int
+		}{
+			{`"1"`, http.StatusOK},
+			{`"7", "1"`, http.StatusOK},
+			{"*", http.StatusOK},
+			{`"7"`, http.StatusPreconditionFailed},
+		}
+
+		for _, test := range tests {
+			app := &application{
+				models: data.NewMockModels(),
+				logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+			}
+
+			req := httptest.NewRequest(http.MethodPatch, "/movies/", strings.NewReader(`{"description": "Updated description", "rating": 9.5}`))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("If-Match", test.ifMatch)
+
+			params := httprouter.Params{
+				httprouter.Param{
+					Key:   "id",
+					Value: "1",
+				},
+			}
+			req = req.WithContext(context.WithValue(req.Context(), httprouter.ParamsKey, params))
+
+			res := httptest.NewRecorder()
+
+			app.updateMovieHandler(res, req)
+
+			if res.Code != test.expected {
+				t.Errorf("If-Match %s: expected status code %d, but got %d", test.ifMatch, test.expected, res.Code)
+			}
+
+			if res.Code == http.StatusOK && res.Header().Get("ETag") != `"2"` {
+				t.Errorf("If-Match %s: expected ETag %q, but got %q", test.ifMatch, `"2"`, res.Header().Get("ETag"))
+			}
+		}
+	})
 }
 
 func TestGetMovieHandler(t *testing.T) {
@@ -514,6 +599,61 @@ func TestGetMovieHandler(t *testing.T) {
 		if res.Code != http.StatusOK {
 			t.Errorf("expected status code %d, but got %d", http.StatusOK, res.Code)
 		}
+
+		if res.Header().Get("ETag") != `"1"` {
+			t.Errorf("expected ETag %q, but got %q", `"1"`, res.Header().Get("ETag"))
+		}
+	})
+
+	t.Run("IncludeActors", func(t *testing.T) {
+		models := data.NewMockModels()
+		app := &application{
+			models: models,
+			logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+		}
+
+		req := withIDParam(httptest.NewRequest(http.MethodGet, "/movies/1?include=actors", nil), 1)
+		res := httptest.NewRecorder()
+
+		app.getMovieHandler(res, req)
+
+		if res.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, but got %d", http.StatusOK, res.Code)
+		}
+
+		var respBody struct {
+			Movie data.Movie `json:"movie"`
+		}
+		err := json.NewDecoder(res.Body).Decode(&respBody)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		cast := respBody.Movie.Actors
+		if len(cast) != 2 || cast[0].Actor == nil || cast[0].Actor.FullName != "Mock Actor 1" || cast[1].Actor == nil {
+			t.Errorf("expected cast with embedded actors, but got %+v", cast)
+		}
+
+		stored, _ := models.Movies.Get(context.Background(), 1)
+		if stored.Actors[0].Actor != nil {
+			t.Errorf("expected stored movie to be left unchanged")
+		}
+	})
+
+	t.Run("InvalidInclude", func(t *testing.T) {
+		app := &application{
+			models: data.NewMockModels(),
+			logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+		}
+
+		req := withIDParam(httptest.NewRequest(http.MethodGet, "/movies/1?include=movies", nil), 1)
+		res := httptest.NewRecorder()
+
+		app.getMovieHandler(res, req)
+
+		if res.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected status code %d, but got %d", http.StatusUnprocessableEntity, res.Code)
+		}
 	})
 
 	t.Run("MovieNotFound", func(t *testing.T) {
@@ -545,6 +685,56 @@ func TestGetMovieHandler(t *testing.T) {
 	})
 }
 
+func TestGetMovieCrewHandler(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		app := &application{
+			models: data.NewMockModels(),
+			logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/movies/", nil)
+		req = withIDParam(req, 1)
+
+		res := httptest.NewRecorder()
+
+		app.getMovieCrewHandler(res, req)
+
+		if res.Code != http.StatusOK {
+			t.Errorf("expected status code %d, but got %d", http.StatusOK, res.Code)
+		}
+
+		var respBody struct {
+			Crew map[string][]data.CrewMember `json:"crew"`
+		}
+		err := json.NewDecoder(res.Body).Decode(&respBody)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if len(respBody.Crew["actor"]) != 2 || len(respBody.Crew["director"]) != 1 {
+			t.Errorf("unexpected crew: %+v", respBody.Crew)
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		app := &application{
+			models: data.NewMockModels(),
+			logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/movies/", nil)
+		req = withIDParam(req, 42)
+
+		res := httptest.NewRecorder()
+
+		app.getMovieCrewHandler(res, req)
+
+		if res.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, but got %d", http.StatusNotFound, res.Code)
+		}
+	})
+}
+
 func TestGetMoviesHandler(t *testing.T) {
 	t.Run("ValidInput", func(t *testing.T) {
 		app := &application{
@@ -562,9 +752,280 @@ func TestGetMoviesHandler(t *testing.T) {
 			t.Errorf("expected status code %d, but got %d", http.StatusOK, res.Code)
 		}
 	})
+
+	t.Run("Paginated", func(t *testing.T) {
+		app := &application{
+			models: data.NewMockModels(),
+			logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/movies?page=1&page_size=1", nil)
+
+		res := httptest.NewRecorder()
+
+		app.getMoviesHandler(res, req)
+
+		if res.Code != http.StatusOK {
+			t.Errorf("expected status code %d, but got %d", http.StatusOK, res.Code)
+		}
+
+		var respBody struct {
+			Movies   []data.Movie  `json:"movies"`
+			Metadata data.Metadata `json:"metadata"`
+		}
+		err := json.NewDecoder(res.Body).Decode(&respBody)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if len(respBody.Movies) != 1 {
+			t.Errorf("expected movies count %d, but got %d", 1, len(respBody.Movies))
+		}
+
+		if respBody.Metadata.CurrentPage != 1 || respBody.Metadata.TotalRecords != 1 {
+			t.Errorf("unexpected metadata: %+v", respBody.Metadata)
+		}
+	})
+
+	t.Run("GenreFilter", func(t *testing.T) {
+		tests := []struct {
+			query    string
+			expected int
+		}{
+			{"genre=drama", 1},
+			{"genre=Drama,comedy", 0},
+			{"genre=comedy", 0},
+		}
+
+		for _, tt := range tests {
+			app := &application{
+				models: data.NewMockModels(),
+				logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/movies?"+tt.query, nil)
+
+			res := httptest.NewRecorder()
+
+			app.getMoviesHandler(res, req)
+
+			if res.Code != http.StatusOK {
+				t.Errorf("%s: expected status code %d, but got %d", tt.query, http.StatusOK, res.Code)
+			}
+
+			var respBody struct {
+				Movies []data.Movie `json:"movies"`
+			}
+			err := json.NewDecoder(res.Body).Decode(&respBody)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			if len(respBody.Movies) != tt.expected {
+				t.Errorf("%s: expected movies count %d, but got %d", tt.query, tt.expected, len(respBody.Movies))
+			}
+		}
+	})
+
+	t.Run("DuplicateGenre", func(t *testing.T) {
+		app := &application{
+			models: data.NewMockModels(),
+			logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/movies?genre=drama,drama", nil)
+
+		res := httptest.NewRecorder()
+
+		app.getMoviesHandler(res, req)
+
+		if res.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected status code %d, but got %d", http.StatusUnprocessableEntity, res.Code)
+		}
+	})
+
+	t.Run("IncludeActors", func(t *testing.T) {
+		app := &application{
+			models: data.NewMockModels(),
+			logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/movies?include=actors", nil)
+		res := httptest.NewRecorder()
+
+		app.getMoviesHandler(res, req)
+
+		if res.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, but got %d", http.StatusOK, res.Code)
+		}
+
+		var respBody struct {
+			Movies []data.Movie `json:"movies"`
+		}
+		err := json.NewDecoder(res.Body).Decode(&respBody)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		for _, movie := range respBody.Movies {
+			for _, member := range movie.Actors {
+				if member.Actor == nil || member.Actor.ID != member.ActorID {
+					t.Errorf("expected embedded actor %d, but got %+v", member.ActorID, member.Actor)
+				}
+			}
+		}
+	})
+
+	t.Run("InvalidPageSize", func(t *testing.T) {
+		app := &application{
+			models: data.NewMockModels(),
+			logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/movies?page_size=1000", nil)
+
+		res := httptest.NewRecorder()
+
+		app.getMoviesHandler(res, req)
+
+		if res.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected status code %d, but got %d", http.StatusUnprocessableEntity, res.Code)
+		}
+	})
 }
 
 func TestSearchMoviesHandler(t *testing.T) {
+	t.Run("FullText", func(t *testing.T) {
+		app := &application{
+			models: data.NewMockModels(),
+			logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/search?q=mock+movie", nil)
+
+		res := httptest.NewRecorder()
+
+		app.searchMovieHandler(res, req)
+
+		if res.Code != http.StatusOK {
+			t.Errorf("expected status code %d, but got %d", http.StatusOK, res.Code)
+		}
+
+		var respBody struct {
+			Movies []data.Movie `json:"movies"`
+		}
+		err := json.NewDecoder(res.Body).Decode(&respBody)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if len(respBody.Movies) != 1 || respBody.Movies[0].Relevance <= 0 {
+			t.Errorf("expected one movie with positive relevance, but got %+v", respBody.Movies)
+		}
+	})
+
+	t.Run("Character", func(t *testing.T) {
+		app := &application{
+			models: data.NewMockModels(),
+			logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+		}
+		app.config.search.threshold = 0.3
+
+		req := httptest.NewRequest(http.MethodGet, "/search?character=Mock+Vilain", nil)
+
+		res := httptest.NewRecorder()
+
+		app.searchMovieHandler(res, req)
+
+		var respBody struct {
+			Movies []data.Movie `json:"movies"`
+		}
+		err := json.NewDecoder(res.Body).Decode(&respBody)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if len(respBody.Movies) != 1 {
+			t.Errorf("expected one movie, but got %+v", respBody.Movies)
+		}
+	})
+
+	t.Run("FuzzyActor", func(t *testing.T) {
+		app := &application{
+			models: data.NewMockModels(),
+			logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+		}
+		app.config.search.threshold = 0.3
+
+		req := httptest.NewRequest(http.MethodGet, "/search?actor=Mok+Actr+1", nil)
+
+		res := httptest.NewRecorder()
+
+		app.searchMovieHandler(res, req)
+
+		var respBody struct {
+			Movies []data.Movie `json:"movies"`
+		}
+		err := json.NewDecoder(res.Body).Decode(&respBody)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if len(respBody.Movies) != 1 || respBody.Movies[0].Similarity <= 0 {
+			t.Errorf("expected one movie with positive similarity, but got %+v", respBody.Movies)
+		}
+
+		req = httptest.NewRequest(http.MethodGet, "/search?actor=Mok+Actr+1&fuzzy=false", nil)
+
+		res = httptest.NewRecorder()
+
+		app.searchMovieHandler(res, req)
+
+		respBody.Movies = nil
+		err = json.NewDecoder(res.Body).Decode(&respBody)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if len(respBody.Movies) != 0 {
+			t.Errorf("expected no movies for exact search, but got %d", len(respBody.Movies))
+		}
+	})
+
+	t.Run("InvalidThreshold", func(t *testing.T) {
+		app := &application{
+			models: data.NewMockModels(),
+			logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/search?title=mock&threshold=2", nil)
+
+		res := httptest.NewRecorder()
+
+		app.searchMovieHandler(res, req)
+
+		if res.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected status code %d, but got %d", http.StatusUnprocessableEntity, res.Code)
+		}
+	})
+
+	t.Run("QueryTooLong", func(t *testing.T) {
+		app := &application{
+			models: data.NewMockModels(),
+			logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/search?q="+strings.Repeat("a", 201), nil)
+
+		res := httptest.NewRecorder()
+
+		app.searchMovieHandler(res, req)
+
+		if res.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected status code %d, but got %d", http.StatusUnprocessableEntity, res.Code)
+		}
+	})
+
 	t.Run("ValidTitle", func(t *testing.T) {
 		app := &application{
 			models: data.NewMockModels(),
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"errors"
+	"filmoteka/internal/data"
+	"filmoteka/internal/validator"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type PersonInput struct {
+	FullName  *string    `json:"full_name"`
+	Gender    *string    `json:"gender"`
+	BirthDate *time.Time `json:"birth_date"` // RFC3339
+}
+
+type PersonEnvelope struct {
+	Person data.Person `json:"person"`
+}
+
+type PeopleEnvelope struct {
+	People   []data.Person `json:"people"`
+	Metadata data.Metadata `json:"metadata"`
+}
+
+// @Summary Add new person
+// @Description Adds a new person to the database. The request body should include the person's full name, gender, and birth date. Once the person is added, they can be credited in movies as an actor or as a crew member.
+// @Tags People
+// @Accept json
+// @Produce json
+// @Param input body PersonInput true "Person data"
+// @Success 201 {object} PersonEnvelope "Person successfully created"
+// @Header 201 {string} ETag "Version of the created person"
+// @Failure 400 {object} errorResponse "Client error"
+// @Failure 401 {object} errorResponse "Unauthorized"
+// @Failure 403 {object} errorResponse "Forbidden"
+// @Failure 422 {object} errorResponse "Validation error"
+// @Failure 500 {object} errorResponse "Internal server error"
+// @Router /people [post]
+// @Security BasicAuth
+// @Security BearerAuth
+// @Security ApiKeyAuth
+func (app *application) addPersonHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		FullName  string    `json:"full_name"`
+		Gender    string    `json:"gender"`
+		BirthDate time.Time `json:"birth_date"` // RFC3339
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	person := &data.Person{
+		FullName:  input.FullName,
+		Gender:    strings.ToLower(input.Gender),
+		BirthDate: input.BirthDate,
+	}
+
+	v := validator.New()
+	if data.ValidatePerson(v, person); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.People.Insert(r.Context(), person)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateName):
+			v.AddError("full_name", "person with this full name already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"person": person}, app.etagHeader(person.Version))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// @Summary Update person
+// @Description Updates the information of a specific person in the database. This can be a partial or full update. If a field is not provided in the request body, the current value of that field will be retained. To avoid overwriting concurrent changes, send the ETag received from a previous response in the If-Match header.
+// @Tags People
+// @Accept json
+// @Produce json
+// @Param id path int true "Person ID"
+// @Param If-Match header string false "ETag of the person version being updated"
+// @Param input body PersonInput true "Person data"
+// @Success 200 {object} PersonEnvelope "Person successfully updated"
+// @Header 200 {string} ETag "Version of the updated person"
+// @Failure 400 {object} errorResponse "Client error"
+// @Failure 401 {object} errorResponse "Unauthorized"
+// @Failure 403 {object} errorResponse "Forbidden"
+// @Failure 404 {object} errorResponse "Person not found"
+// @Failure 409 {object} errorResponse "Edit conflict"
+// @Failure 412 {object} errorResponse "If-Match does not match the current version"
+// @Failure 422 {object} errorResponse "Validation error"
+// @Failure 500 {object} errorResponse "Internal server error"
+// @Router /people/{id} [patch]
+// @Security BasicAuth
+// @Security BearerAuth
+// @Security ApiKeyAuth
+func (app *application) updatePersonHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		FullName  *string    `json:"full_name"`
+		Gender    *string    `json:"gender"`
+		BirthDate *time.Time `json:"birth_date"` // RFC3339
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	person, err := app.models.People.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	if !app.ifMatch(r, person.Version) {
+		app.preconditionFailedResponse(w, r)
+		return
+	}
+
+	if input.FullName != nil {
+		person.FullName = *input.FullName
+	}
+
+	if input.Gender != nil {
+		person.Gender = strings.ToLower(*input.Gender)
+	}
+
+	if input.BirthDate != nil {
+		person.BirthDate = *input.BirthDate
+	}
+
+	v := validator.New()
+	if data.ValidatePerson(v, person); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.People.Update(r.Context(), person)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateName):
+			v.AddError("full_name", "person with this full name already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"person": person}, app.etagHeader(person.Version))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// @Summary Get person by ID
+// @Description Retrieves information about a specific person from the database, including their full name, gender, birth date and filmography. The filmography is grouped by role (actor, director, writer, producer, composer, cinematographer, editor); within a role movies are ordered by release date.
+// @Tags People
+// @Produce json
+// @Param id path int true "Person ID"
+// @Success 200 {object} PersonEnvelope "Person data"
+// @Header 200 {string} ETag "Version of the person"
+// @Failure 401 {object} errorResponse "Unauthorized"
+// @Failure 404 {object} errorResponse "Person not found"
+// @Failure 500 {object} errorResponse "Internal server error"
+// @Router /people/{id} [get]
+// @Security BasicAuth
+// @Security BearerAuth
+// @Security ApiKeyAuth
+func (app *application) getPersonHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	person, err := app.models.People.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"person": person}, app.etagHeader(person.Version))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// @Summary Get people
+// @Description Retrieves a paginated list of people in the database: actors and crew members alike. The list can be narrowed to people credited in a given role. The default sort order is by full name in ascending order.
+// @Tags People
+// @Produce json
+// @Param role query string false "Credit role: actor, director, writer, producer, composer, cinematographer, editor"
+// @Param sort query string false "Sort order: full_name, birth_date, -full_name, -birth_date"
+// @Param page query int false "Page number (default 1)"
+// @Param page_size query int false "Page size, maximum 100 (default 20)"
+// @Param cursor query string false "Cursor from metadata.next_cursor of the previous page"
+// @Success 200 {object} PeopleEnvelope "People data"
+// @Failure 401 {object} errorResponse "Unauthorized"
+// @Failure 422 {object} errorResponse "Validation error"
+// @Failure 500 {object} errorResponse "Internal server error"
+// @Router /people [get]
+// @Security BasicAuth
+// @Security BearerAuth
+// @Security ApiKeyAuth
+func (app *application) getPeopleHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Cursor = app.readString(qs, "cursor", "")
+	input.Filters.Role = strings.ToLower(app.readString(qs, "role", ""))
+
+	input.Filters.Sort = app.readString(qs, "sort", "full_name")
+	input.Filters.SortSafelist = []string{"full_name", "birth_date", "-full_name", "-birth_date"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	people, metadata, err := app.models.People.GetAll(r.Context(), input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"people": people, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// @Summary Delete person
+// @Description Deletes a specific person from the database together with all their credits. The movies themselves are kept.
+// @Tags People
+// @Produce json
+// @Param id path int true "Person ID"
+// @Success 200 {object} MessageEnvelope "Person successfully deleted"
+// @Failure 401 {object} errorResponse "Unauthorized"
+// @Failure 403 {object} errorResponse "Forbidden"
+// @Failure 404 {object} errorResponse "Person not found"
+// @Failure 500 {object} errorResponse "Internal server error"
+// @Router /people/{id} [delete]
+// @Security BasicAuth
+// @Security BearerAuth
+// @Security ApiKeyAuth
+func (app *application) deletePersonHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.People.Delete(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "person successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
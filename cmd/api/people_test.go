@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"filmoteka/internal/data"
+	"filmoteka/internal/jsonlog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestAddPersonHandler(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		expectedCode int
+	}{
+		{"Valid", `{"full_name": "Jane Doe", "gender": "Female", "birth_date": "1990-01-01T00:00:00Z"}`, http.StatusCreated},
+		{"Duplicate", `{"full_name": "Mock Director 1", "gender": "female", "birth_date": "1990-01-01T00:00:00Z"}`, http.StatusUnprocessableEntity},
+		{"Invalid", `{"full_name": "Jane Doe", "gender": "unknown"}`, http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &application{
+				models: data.NewMockModels(),
+				logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/people", bytes.NewReader([]byte(tt.body)))
+			req.Header.Set("Content-Type", "application/json")
+
+			res := httptest.NewRecorder()
+
+			app.addPersonHandler(res, req)
+
+			if res.Code != tt.expectedCode {
+				t.Errorf("expected status code %d, but got %d", tt.expectedCode, res.Code)
+			}
+		})
+	}
+}
+
+func TestGetPersonHandler(t *testing.T) {
+	t.Run("Filmography", func(t *testing.T) {
+		app := &application{
+			models: data.NewMockModels(),
+			logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/people/", nil)
+		req = withIDParam(req, 1)
+
+		res := httptest.NewRecorder()
+
+		app.getPersonHandler(res, req)
+
+		if res.Code != http.StatusOK {
+			t.Errorf("expected status code %d, but got %d", http.StatusOK, res.Code)
+		}
+
+		var respBody struct {
+			Person data.Person `json:"person"`
+		}
+		err := json.NewDecoder(res.Body).Decode(&respBody)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if len(respBody.Person.Filmography["actor"]) != 1 {
+			t.Errorf("expected 1 acting credit, but got %+v", respBody.Person.Filmography)
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		app := &application{
+			models: data.NewMockModels(),
+			logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/people/", nil)
+		req = withIDParam(req, 42)
+
+		res := httptest.NewRecorder()
+
+		app.getPersonHandler(res, req)
+
+		if res.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, but got %d", http.StatusNotFound, res.Code)
+		}
+	})
+}
+
+func TestGetPeopleHandler(t *testing.T) {
+	tests := []struct {
+		query        string
+		expectedCode int
+		expected     int
+	}{
+		{"", http.StatusOK, 3},
+		{"role=director", http.StatusOK, 1},
+		{"role=stuntman", http.StatusUnprocessableEntity, 0},
+	}
+
+	for _, tt := range tests {
+		app := &application{
+			models: data.NewMockModels(),
+			logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/people?"+tt.query, nil)
+
+		res := httptest.NewRecorder()
+
+		app.getPeopleHandler(res, req)
+
+		if res.Code != tt.expectedCode {
+			t.Errorf("%q: expected status code %d, but got %d", tt.query, tt.expectedCode, res.Code)
+		}
+
+		var respBody struct {
+			People []data.Person `json:"people"`
+		}
+		err := json.NewDecoder(res.Body).Decode(&respBody)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if len(respBody.People) != tt.expected {
+			t.Errorf("%q: expected people count %d, but got %d", tt.query, tt.expected, len(respBody.People))
+		}
+	}
+}
+
+func TestUpdatePersonHandler(t *testing.T) {
+	tests := []struct {
+		name         string
+		id           int64
+		body         string
+		ifMatch      string
+		expectedCode int
+	}{
+		{"Valid", 3, `{"full_name": "Mock Director 2"}`, `"1"`, http.StatusOK},
+		{"StaleVersion", 3, `{"full_name": "Mock Director 2"}`, `"0"`, http.StatusPreconditionFailed},
+		{"Duplicate", 3, `{"full_name": "Mock Actor 1"}`, "", http.StatusUnprocessableEntity},
+		{"NotFound", 42, `{"full_name": "Mock Director 2"}`, "", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &application{
+				models: data.NewMockModels(),
+				logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+			}
+
+			req := httptest.NewRequest(http.MethodPatch, "/people/", bytes.NewReader([]byte(tt.body)))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			req = withIDParam(req, tt.id)
+
+			res := httptest.NewRecorder()
+
+			app.updatePersonHandler(res, req)
+
+			if res.Code != tt.expectedCode {
+				t.Errorf("expected status code %d, but got %d", tt.expectedCode, res.Code)
+			}
+		})
+	}
+}
+
+func TestDeletePersonHandler(t *testing.T) {
+	app := &application{
+		models: data.NewMockModels(),
+		logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+	}
+
+	for _, expectedCode := range []int{http.StatusOK, http.StatusNotFound} {
+		req := httptest.NewRequest(http.MethodDelete, "/people/", nil)
+		req = withIDParam(req, 3)
+
+		res := httptest.NewRecorder()
+
+		app.deletePersonHandler(res, req)
+
+		if res.Code != expectedCode {
+			t.Errorf("expected status code %d, but got %d", expectedCode, res.Code)
+		}
+	}
+}
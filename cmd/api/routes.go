@@ -10,31 +10,89 @@ import (
 
 func (app *application) routes() http.Handler {
 	router := httprouter.New()
+	patterns := httprouter.New()
 
-	router.HandlerFunc(http.MethodGet, "/swagger/*filepath", httpSwagger.Handler(
+	handle := func(method, pattern string, handler http.HandlerFunc) {
+		router.Handler(method, pattern, app.traceStage("handler", handler))
+		patterns.Handle(method, pattern, func(_ http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+			app.contextGetRoute(r).pattern = pattern
+		})
+	}
+
+	handle(http.MethodGet, "/swagger/*filepath", httpSwagger.Handler(
 		httpSwagger.URL(docs.SwaggerInfo.Host+"/swagger/doc.json"), //The url pointing to API definition
 	))
 
 	router.NotFound = http.HandlerFunc(app.notFoundResponse)
 	router.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedResponse)
 
-	router.HandlerFunc(http.MethodGet, "/healthcheck", app.healthcheckHandler)
+	handle(http.MethodGet, "/healthcheck", app.healthcheckHandler)
+
+	// Без отдельного слушателя метрики доступны на API только администраторам.
+	if app.metrics != nil && app.config.metrics.addr == "" {
+		handle(http.MethodGet, "/metrics", app.requirePermission("users:manage", app.metrics.registry.Handler().ServeHTTP))
+	}
+
+	handle(http.MethodPost, "/users", app.createUserHandler)
+	handle(http.MethodGet, "/users", app.requirePermission("users:manage", app.getUsersHandler))
+	handle(http.MethodGet, "/users/:id", app.requirePermission("users:manage", app.getUserHandler))
+	handle(http.MethodPatch, "/users/:id", app.requirePermission("users:manage", app.updateUserHandler))
+	handle(http.MethodDelete, "/users/:id", app.requirePermission("users:manage", app.deleteUserHandler))
+	handle(http.MethodPut, "/users/me/password", app.requireAuthenticatedUser(app.changePasswordHandler))
+	handle(http.MethodPut, "/users/password", app.resetPasswordHandler)
+	handle(http.MethodPut, "/users/activated", app.activateUserHandler)
+	handle(http.MethodGet, "/users/:id/role-changes", app.requirePermission("users:manage", app.getUserRoleChangesHandler))
+
+	handle(http.MethodGet, "/lockouts", app.requirePermission("users:manage", app.getLockoutsHandler))
+	handle(http.MethodDelete, "/lockouts", app.requirePermission("users:manage", app.deleteLockoutHandler))
+
+	handle(http.MethodPost, "/tokens/authentication", app.createAuthenticationTokenHandler)
+	handle(http.MethodDelete, "/tokens/authentication", app.requireAuthenticatedUser(app.deleteAuthenticationTokenHandler))
+	handle(http.MethodDelete, "/tokens", app.requireAuthenticatedUser(app.deleteAllAuthenticationTokensHandler))
+	handle(http.MethodPost, "/tokens/jwt", app.createJWTHandler)
+	handle(http.MethodPost, "/tokens/refresh", app.refreshJWTHandler)
+	handle(http.MethodPost, "/tokens/password-reset", app.createPasswordResetTokenHandler)
+	handle(http.MethodGet, "/.well-known/jwks.json", app.jwksHandler)
+
+	handle(http.MethodPost, "/api-keys", app.requirePermission("apikeys:manage", app.createAPIKeyHandler))
+	handle(http.MethodGet, "/api-keys", app.requirePermission("apikeys:manage", app.getAPIKeysHandler))
+	handle(http.MethodDelete, "/api-keys/:id", app.requirePermission("apikeys:manage", app.deleteAPIKeyHandler))
+
+	handle(http.MethodPost, "/actors", app.requirePermission("actors:create", app.addActorHandler))
+	handle(http.MethodGet, "/actors/:id", app.requirePermission("actors:read", app.getActorHandler))
+	handle(http.MethodPatch, "/actors/:id", app.requirePermission("actors:update", app.updateActorHandler))
+	handle(http.MethodDelete, "/actors/:id", app.requirePermission("actors:delete", app.deleteActorHandler))
+	handle(http.MethodGet, "/actors", app.requirePermission("actors:read", app.getActorsHandler))
+
+	handle(http.MethodPost, "/people", app.requirePermission("people:create", app.addPersonHandler))
+	handle(http.MethodGet, "/people/:id", app.requirePermission("people:read", app.getPersonHandler))
+	handle(http.MethodPatch, "/people/:id", app.requirePermission("people:update", app.updatePersonHandler))
+	handle(http.MethodDelete, "/people/:id", app.requirePermission("people:delete", app.deletePersonHandler))
+	handle(http.MethodGet, "/people", app.requirePermission("people:read", app.getPeopleHandler))
 
-	router.HandlerFunc(http.MethodPost, "/users", app.createUserHandler)
+	handle(http.MethodPost, "/genres", app.requirePermission("genres:create", app.addGenreHandler))
+	handle(http.MethodGet, "/genres/:id", app.requirePermission("genres:read", app.getGenreHandler))
+	handle(http.MethodPatch, "/genres/:id", app.requirePermission("genres:update", app.updateGenreHandler))
+	handle(http.MethodDelete, "/genres/:id", app.requirePermission("genres:delete", app.deleteGenreHandler))
+	handle(http.MethodGet, "/genres", app.requirePermission("genres:read", app.getGenresHandler))
 
-	router.HandlerFunc(http.MethodPost, "/actors", app.requireRoleAdmin(app.addActorHandler))
-	router.HandlerFunc(http.MethodGet, "/actors/:id", app.requireAuthenticatedUser(app.getActorHandler))
-	router.HandlerFunc(http.MethodPatch, "/actors/:id", app.requireRoleAdmin(app.updateActorHandler))
-	router.HandlerFunc(http.MethodDelete, "/actors/:id", app.requireRoleAdmin(app.deleteActorHandler))
-	router.HandlerFunc(http.MethodGet, "/actors", app.requireAuthenticatedUser(app.getActorsHandler))
+	handle(http.MethodPost, "/movies", app.requirePermission("movies:create", app.addMovieHandler))
+	handle(http.MethodGet, "/movies/:id", app.requirePermission("movies:read", app.getMovieHandler))
+	handle(http.MethodPatch, "/movies/:id", app.requirePermission("movies:update", app.updateMovieHandler))
+	handle(http.MethodDelete, "/movies/:id", app.requirePermission("movies:delete", app.deleteMovieHandler))
+	handle(http.MethodGet, "/movies/:id/crew", app.requirePermission("movies:read", app.getMovieCrewHandler))
+	handle(http.MethodGet, "/movies", app.requirePermission("movies:read", app.getMoviesHandler))
 
-	router.HandlerFunc(http.MethodPost, "/movies", app.requireRoleAdmin(app.addMovieHandler))
-	router.HandlerFunc(http.MethodGet, "/movies/:id", app.requireAuthenticatedUser(app.getMovieHandler))
-	router.HandlerFunc(http.MethodPatch, "/movies/:id", app.requireRoleAdmin(app.updateMovieHandler))
-	router.HandlerFunc(http.MethodDelete, "/movies/:id", app.requireRoleAdmin(app.deleteMovieHandler))
-	router.HandlerFunc(http.MethodGet, "/movies", app.requireAuthenticatedUser(app.getMoviesHandler))
+	handle(http.MethodGet, "/search", app.requirePermission("movies:read", app.searchMovieHandler))
 
-	router.HandlerFunc(http.MethodGet, "/search", app.requireAuthenticatedUser(app.searchMovieHandler))
+	// Лимиты зависят от пользователя, поэтому rateLimit идет после authenticate;
+	// неудачные попытки аутентификации до него ограничивает по IP rateLimitFailedAuth.
+	// logRequest стоит снаружи recoverPanic, чтобы запросы с паникой тоже попадали в журнал.
+	stage := app.traceStage
 
-	return app.recoverPanic(app.logRequest(app.rateLimit(app.authenticate(router))))
+	return app.requestID(app.matchRoute(patterns, app.instrument(app.trace(
+		stage("logRequest", app.logRequest(
+			stage("recoverPanic", app.recoverPanic(
+				stage("authenticate", app.rateLimitFailedAuth(app.authenticate(
+					stage("rateLimit", app.rateLimit(router)))))))))))))
 }
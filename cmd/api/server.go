@@ -20,6 +20,25 @@ func (app *application) serve() error {
 		WriteTimeout: 30 * time.Second,
 	}
 
+	var adminSrv *http.Server
+
+	if app.metrics != nil && app.config.metrics.addr != "" {
+		adminSrv = app.adminServer()
+
+		go func() {
+			app.logger.PrintInfo("starting admin server", map[string]string{
+				"addr": adminSrv.Addr,
+			})
+
+			err := adminSrv.ListenAndServe()
+			if !errors.Is(err, http.ErrServerClosed) {
+				app.logger.PrintError(err, map[string]string{
+					"action": "serve admin listener",
+				})
+			}
+		}()
+	}
+
 	shutdownError := make(chan error)
 
 	go func() {
@@ -36,6 +55,10 @@ func (app *application) serve() error {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
+		if adminSrv != nil {
+			adminSrv.Shutdown(ctx)
+		}
+
 		err := srv.Shutdown(ctx)
 		if err != nil {
 			shutdownError <- err
@@ -46,9 +69,21 @@ func (app *application) serve() error {
 		})
 
 		app.wg.Wait()
+
+		err = app.tracer.Shutdown(ctx)
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{
+				"action": "flush traces",
+			})
+		}
+
 		shutdownError <- nil
 	}()
 
+	if len(app.config.jwt.keys) > 0 {
+		go app.reloadJWTKeysOnSignal()
+	}
+
 	app.logger.PrintInfo("starting server", map[string]string{
 		"addr": srv.Addr,
 		"env":  app.config.env,
@@ -70,3 +105,39 @@ func (app *application) serve() error {
 
 	return nil
 }
+
+/*
+Отдельный слушатель для /metrics, чтобы метрики можно было открыть только
+для сети мониторинга, не публикуя их вместе с API.
+*/
+func (app *application) adminServer() *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", app.metrics.registry.Handler())
+
+	return &http.Server{
+		Addr:         app.config.metrics.addr,
+		Handler:      mux,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 30 * time.Second,
+	}
+}
+
+/*
+По SIGHUP ключи JWT перечитываются из файлов, что позволяет провести ротацию
+без перезапуска: новый ключ ставится первым, а старый остается в списке,
+пока не истекут подписанные им токены. При ошибке продолжают работать прежние ключи.
+*/
+func (app *application) reloadJWTKeysOnSignal() {
+	reload := make(chan os.Signal, 1)
+
+	signal.Notify(reload, syscall.SIGHUP)
+
+	for range reload {
+		err := app.loadJWTKeys()
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{
+				"action": "reload JWT keys",
+			})
+		}
+	}
+}
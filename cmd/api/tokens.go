@@ -0,0 +1,233 @@
+package main
+
+import (
+	"errors"
+	"filmoteka/internal/data"
+	"filmoteka/internal/jsonlog"
+	"filmoteka/internal/validator"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type CreateTokenInput struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+type PasswordResetInput struct {
+	Email string `json:"email"`
+}
+
+type TokenEnvelope struct {
+	AuthenticationToken data.Token `json:"authentication_token"`
+}
+
+// @Summary Create authentication token
+// @Description Exchanges a username and password for an authentication token. Send the token in the Authorization header as "Bearer <token>" instead of Basic credentials: the password is checked only once, here, so authenticated requests do not pay for bcrypt. Only a hash of the token is stored on the server; the token expires after the configured lifetime or when it is revoked.
+// @Tags Tokens
+// @Accept json
+// @Produce json
+// @Param input body CreateTokenInput true "User credentials"
+// @Success 201 {object} TokenEnvelope "Authentication token"
+// @Failure 400 {object} errorResponse "Bad request"
+// @Failure 401 {object} errorResponse "Invalid credentials"
+// @Failure 403 {object} errorResponse "User account is disabled or not activated"
+// @Failure 422 {object} errorResponse "Validation failed"
+// @Failure 423 {object} errorResponse "Sign-in locked after too many failed attempts"
+// @Failure 429 {object} errorResponse "Too many failed attempts, retry after the Retry-After delay"
+// @Failure 500 {object} errorResponse "Internal server error"
+// @Router /tokens/authentication [post]
+func (app *application) createAuthenticationTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name     string `json:"name"`
+		Password string `json:"password"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(input.Name != "", "name", "must be provided")
+	data.ValidatePasswordPlaintext(v, input.Password)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user, ok := app.checkCredentials(w, r, input.Name, input.Password)
+	if !ok {
+		return
+	}
+
+	if user.Disabled {
+		app.accountDisabledResponse(w, r)
+		return
+	}
+
+	if !user.Activated {
+		app.inactiveAccountResponse(w, r)
+		return
+	}
+
+	token, err := app.models.Tokens.New(r.Context(), user.ID, app.config.tokens.ttl, data.ScopeAuthentication)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"authentication_token": token}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// @Summary Revoke current authentication token
+// @Description Revokes the authentication token the request was made with, e.g. on logout. Other tokens of the user stay valid. The request must be authenticated with a bearer token from POST /tokens/authentication: JWT access tokens cannot be revoked individually, so revoke their refresh tokens with DELETE /tokens and let the access token expire.
+// @Tags Tokens
+// @Produce json
+// @Success 200 {object} MessageEnvelope "Token revoked"
+// @Failure 400 {object} errorResponse "Request was not authenticated with an authentication token, e.g. it used a JWT"
+// @Failure 401 {object} errorResponse "Unauthorized"
+// @Failure 500 {object} errorResponse "Internal server error"
+// @Router /tokens/authentication [delete]
+// @Security BearerAuth
+func (app *application) deleteAuthenticationTokenHandler(w http.ResponseWriter, r *http.Request) {
+	token, ok := bearerToken(r)
+	if !ok {
+		app.badRequestResponse(w, r, errors.New("request must be authenticated with a bearer token"))
+		return
+	}
+
+	if app.jwtKeys.Load() != nil && isJWT(token) {
+		app.badRequestResponse(w, r, errors.New("JWT access tokens cannot be revoked individually: use DELETE /tokens to revoke the refresh tokens and let the access token expire"))
+		return
+	}
+
+	err := app.models.Tokens.Delete(r.Context(), data.ScopeAuthentication, token)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.invalidAuthenticationTokenResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "authentication token successfully revoked"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// @Summary Revoke all authentication tokens
+// @Description Revokes every authentication and refresh token of the current user, signing them out on all devices. Already issued JWT access tokens stay valid until they expire, as they are not checked against the database. Basic credentials keep working.
+// @Tags Tokens
+// @Produce json
+// @Success 200 {object} MessageEnvelope "Tokens revoked"
+// @Failure 401 {object} errorResponse "Unauthorized"
+// @Failure 500 {object} errorResponse "Internal server error"
+// @Router /tokens [delete]
+// @Security BasicAuth
+// @Security BearerAuth
+func (app *application) deleteAllAuthenticationTokensHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	for _, scope := range []string{data.ScopeAuthentication, data.ScopeRefresh} {
+		err := app.models.Tokens.DeleteAllForUser(r.Context(), scope, user.ID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"message": "all authentication tokens successfully revoked"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// @Summary Request password reset
+// @Description Sends an email with a single-use password reset token to the given address. The token is passed to PUT /users/password together with the new password and expires after the configured lifetime. The response is the same whether or not an account with this email exists, so the endpoint cannot be used to find out registered emails.
+// @Tags Tokens
+// @Accept json
+// @Produce json
+// @Param input body PasswordResetInput true "Account email"
+// @Success 202 {object} MessageEnvelope "Reset email will be sent if the account exists"
+// @Failure 400 {object} errorResponse "Bad request"
+// @Failure 422 {object} errorResponse "Validation failed"
+// @Failure 500 {object} errorResponse "Internal server error"
+// @Router /tokens/password-reset [post]
+func (app *application) createPasswordResetTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Email string `json:"email"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	input.Email = strings.ToLower(strings.TrimSpace(input.Email))
+
+	v := validator.New()
+	if data.ValidateEmail(v, input.Email); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	message := envelope{"message": "an email will be sent to you containing password reset instructions"}
+
+	user, err := app.models.Users.GetByEmail(r.Context(), input.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			err = app.writeJSON(w, http.StatusAccepted, message, nil)
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+			}
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	if !user.Disabled {
+		token, err := app.models.Tokens.New(r.Context(), user.ID, app.config.tokens.passwordResetTTL, data.ScopePasswordReset)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		app.background(func() {
+			templateData := map[string]interface{}{
+				"Name":   user.Name,
+				"Token":  token.Plaintext,
+				"Expiry": token.Expiry.Format(time.RFC3339),
+			}
+
+			err := app.mailer.Send(user.Email, "password_reset.tmpl", templateData)
+			if err != nil {
+				app.contextGetLogger(r).Error(err,
+					jsonlog.Stack(false),
+					jsonlog.String("action", "send password reset email"),
+					jsonlog.Int64("user_id", user.ID),
+				)
+			}
+		})
+	}
+
+	err = app.writeJSON(w, http.StatusAccepted, message, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
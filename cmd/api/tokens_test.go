@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"filmoteka/internal/data"
+	"filmoteka/internal/jsonlog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTokenTestApp() *application {
+	app := &application{
+		models: data.NewMockModels(),
+		logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+	}
+	app.config.tokens.ttl = time.Hour
+
+	return app
+}
+
+func createToken(t *testing.T, app *application, name, password string) string {
+	t.Helper()
+
+	reqBody := `{"name": "` + name + `", "password": "` + password + `"}`
+
+	req := httptest.NewRequest(http.MethodPost, "/tokens/authentication", strings.NewReader(reqBody))
+	res := httptest.NewRecorder()
+
+	app.createAuthenticationTokenHandler(res, req)
+
+	if res.Code != http.StatusCreated {
+		t.Fatalf("expected status code %d, but got %d", http.StatusCreated, res.Code)
+	}
+
+	var respBody TokenEnvelope
+	err := json.NewDecoder(res.Body).Decode(&respBody)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return respBody.AuthenticationToken.Plaintext
+}
+
+func bearerRequest(app *application, method, target, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	res := httptest.NewRecorder()
+	app.routes().ServeHTTP(res, req)
+
+	return res
+}
+
+func TestCreateAuthenticationTokenHandler(t *testing.T) {
+	t.Run("ValidCredentials", func(t *testing.T) {
+		app := newTokenTestApp()
+
+		token := createToken(t, app, "user", "password123")
+
+		if len(token) != 26 {
+			t.Errorf("expected token of 26 characters, but got %q", token)
+		}
+
+		res := bearerRequest(app, http.MethodGet, "/movies/1", token)
+		if res.Code != http.StatusOK {
+			t.Errorf("expected status code %d, but got %d", http.StatusOK, res.Code)
+		}
+	})
+
+	t.Run("InvalidCredentials", func(t *testing.T) {
+		app := newTokenTestApp()
+
+		req := httptest.NewRequest(http.MethodPost, "/tokens/authentication", strings.NewReader(`{"name": "user", "password": "wrongpassword"}`))
+		res := httptest.NewRecorder()
+
+		app.createAuthenticationTokenHandler(res, req)
+
+		if res.Code != http.StatusUnauthorized {
+			t.Errorf("expected status code %d, but got %d", http.StatusUnauthorized, res.Code)
+		}
+	})
+
+	t.Run("NotActivated", func(t *testing.T) {
+		app := newTokenTestApp()
+
+		user := &data.User{Name: "inactive", Email: "inactive@example.com", Role: "user"}
+		if err := user.Password.Set("password123"); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := app.models.Users.Insert(context.Background(), user); err != nil {
+			t.Fatal(err)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/tokens/authentication", strings.NewReader(`{"name": "inactive", "password": "password123"}`))
+		res := httptest.NewRecorder()
+
+		app.createAuthenticationTokenHandler(res, req)
+
+		if res.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d, but got %d", http.StatusForbidden, res.Code)
+		}
+	})
+
+	t.Run("UnknownToken", func(t *testing.T) {
+		app := newTokenTestApp()
+
+		res := bearerRequest(app, http.MethodGet, "/movies/1", strings.Repeat("A", 26))
+		if res.Code != http.StatusUnauthorized {
+			t.Errorf("expected status code %d, but got %d", http.StatusUnauthorized, res.Code)
+		}
+
+		if res.Header().Get("WWW-Authenticate") != "Bearer" {
+			t.Errorf("expected WWW-Authenticate %q, but got %q", "Bearer", res.Header().Get("WWW-Authenticate"))
+		}
+	})
+
+	t.Run("ExpiredToken", func(t *testing.T) {
+		app := newTokenTestApp()
+		app.config.tokens.ttl = -time.Minute
+
+		token := createToken(t, app, "user", "password123")
+
+		res := bearerRequest(app, http.MethodGet, "/movies/1", token)
+		if res.Code != http.StatusUnauthorized {
+			t.Errorf("expected status code %d, but got %d", http.StatusUnauthorized, res.Code)
+		}
+	})
+}
+
+func TestDeleteAuthenticationTokenHandler(t *testing.T) {
+	app := newTokenTestApp()
+
+	token := createToken(t, app, "user", "password123")
+	other := createToken(t, app, "user", "password123")
+
+	res := bearerRequest(app, http.MethodDelete, "/tokens/authentication", token)
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected status code %d, but got %d", http.StatusOK, res.Code)
+	}
+
+	res = bearerRequest(app, http.MethodGet, "/movies/1", token)
+	if res.Code != http.StatusUnauthorized {
+		t.Errorf("expected revoked token to be rejected, but got %d", res.Code)
+	}
+
+	res = bearerRequest(app, http.MethodGet, "/movies/1", other)
+	if res.Code != http.StatusOK {
+		t.Errorf("expected other token to stay valid, but got %d", res.Code)
+	}
+}
+
+func TestDeleteAllAuthenticationTokensHandler(t *testing.T) {
+	app := newTokenTestApp()
+
+	first := createToken(t, app, "user", "password123")
+	second := createToken(t, app, "user", "password123")
+	admin := createToken(t, app, "admin", "password123")
+
+	res := bearerRequest(app, http.MethodDelete, "/tokens", first)
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected status code %d, but got %d", http.StatusOK, res.Code)
+	}
+
+	for _, token := range []string{first, second} {
+		res = bearerRequest(app, http.MethodGet, "/movies/1", token)
+		if res.Code != http.StatusUnauthorized {
+			t.Errorf("expected revoked token to be rejected, but got %d", res.Code)
+		}
+	}
+
+	res = bearerRequest(app, http.MethodGet, "/movies/1", admin)
+	if res.Code != http.StatusOK {
+		t.Errorf("expected other user's token to stay valid, but got %d", res.Code)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"filmoteka/internal/data"
+	"filmoteka/internal/jsonlog"
+	"filmoteka/internal/tracing"
+)
+
+func openTracer(cfg config, logger *jsonlog.Logger) (*tracing.Tracer, error) {
+	var exporter tracing.Exporter
+
+	switch cfg.tracing.exporter {
+	case "":
+		return nil, nil
+	case "otlp":
+		exporter = tracing.NewOTLPExporter(cfg.tracing.endpoint, cfg.tracing.serviceName, cfg.tracing.headers)
+	case "stdout":
+		exporter = tracing.NewWriterExporter(os.Stdout)
+	case "file":
+		fileExporter, err := tracing.NewFileExporter(cfg.tracing.file)
+		if err != nil {
+			return nil, err
+		}
+
+		exporter = fileExporter
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", cfg.tracing.exporter)
+	}
+
+	tracer := tracing.New(tracing.Config{
+		ServiceName: cfg.tracing.serviceName,
+		SampleRatio: cfg.tracing.sampleRatio,
+		ErrorHandler: func(err error) {
+			logger.Error(err, jsonlog.Stack(false), jsonlog.String("action", "export traces"))
+		},
+	}, exporter)
+
+	logger.PrintInfo("tracing enabled", map[string]string{
+		"exporter": cfg.tracing.exporter,
+	})
+
+	return tracer, nil
+}
+
+/*
+Начинает трассу запроса или продолжает трассу вызывающего сервиса из
+заголовка traceparent. Span называется по шаблону маршрута, который
+matchRoute определяет заранее, а идентификаторы трассы добавляются
+в каждую запись лога, сделанную при обработке запроса.
+*/
+func (app *application) trace(next http.Handler) http.Handler {
+	if app.tracer == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		if parent, ok := tracing.ParseTraceparent(r.Header.Get("traceparent")); ok {
+			ctx = tracing.ContextWithRemoteSpanContext(ctx, parent)
+		}
+
+		route := app.contextGetRoute(r).pattern
+
+		name := r.Method
+		if route != "" {
+			name += " " + route
+		}
+
+		ctx, span := app.tracer.Start(ctx, name, tracing.SpanKindServer,
+			tracing.String("http.request.method", r.Method),
+			tracing.String("http.route", route),
+			tracing.String("url.path", r.URL.Path),
+			tracing.String("client.address", app.clientIP(r)),
+		)
+		defer span.End()
+
+		sc := span.SpanContext()
+
+		r = r.WithContext(ctx)
+		r = app.contextSetLogger(r, app.contextGetLogger(r).With(map[string]string{
+			"trace_id": sc.TraceID.String(),
+			"span_id":  sc.SpanID.String(),
+		}))
+
+		lrw := NewLoggingResponseWriter(w)
+		next.ServeHTTP(lrw, r)
+
+		span.SetAttributes(tracing.Int("http.response.status_code", int64(lrw.statusCode)))
+
+		if lrw.statusCode >= http.StatusInternalServerError {
+			span.RecordError(errors.New(http.StatusText(lrw.statusCode)))
+		}
+	})
+}
+
+/*
+Оборачивает этап обработки запроса в отдельный span. Span этапа включает
+и все последующие этапы, поэтому собственное время этапа, например проверки
+пароля в authenticate, - это его длительность за вычетом вложенных span.
+*/
+func (app *application) traceStage(name string, next http.Handler) http.Handler {
+	if app.tracer == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := app.tracer.Start(r.Context(), name, tracing.SpanKindInternal)
+		defer span.End()
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+/*
+Создает span для каждого запроса к базе, названный по модели и методу,
+например MovieDB.GetAll. Текст запроса записывается без литералов.
+Отсутствие строк не считается ошибкой запроса.
+*/
+func traceQuery(tracer *tracing.Tracer) data.QueryTracer {
+	return func(ctx context.Context, model, method, query string) func(err error) {
+		_, span := tracer.Start(ctx, model+"."+method, tracing.SpanKindClient)
+
+		if span.IsRecording() {
+			span.SetAttributes(
+				tracing.String("db.system", "postgresql"),
+				tracing.String("db.operation", method),
+				tracing.String("db.statement", tracing.SanitizeSQL(query)),
+			)
+		}
+
+		return func(err error) {
+			if !errors.Is(err, sql.ErrNoRows) {
+				span.RecordError(err)
+			}
+
+			span.End()
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"filmoteka/internal/data"
+	"filmoteka/internal/jsonlog"
+	"filmoteka/internal/tracing"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+type recordingExporter struct {
+	mu    sync.Mutex
+	spans []tracing.SpanData
+}
+
+func (e *recordingExporter) Export(ctx context.Context, spans []tracing.SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.spans = append(e.spans, spans...)
+
+	return nil
+}
+
+func (e *recordingExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+func (e *recordingExporter) byName() map[string]tracing.SpanData {
+	spans := make(map[string]tracing.SpanData)
+
+	for _, span := range e.spans {
+		spans[span.Name] = span
+	}
+
+	return spans
+}
+
+func attribute(span tracing.SpanData, key string) interface{} {
+	for _, attr := range span.Attributes {
+		if attr.Key == key {
+			return attr.Value
+		}
+	}
+
+	return nil
+}
+
+func TestTracing(t *testing.T) {
+	exporter := &recordingExporter{}
+	var logs bytes.Buffer
+
+	app := &application{
+		models: data.NewMockModels(),
+		logger: jsonlog.New(&logs, jsonlog.LevelInfo),
+		tracer: tracing.New(tracing.Config{SampleRatio: 1}, exporter),
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/movies/1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.SetBasicAuth("user", "password123")
+
+	res := httptest.NewRecorder()
+	app.routes().ServeHTTP(res, req)
+
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected status code %d, but got %d", http.StatusOK, res.Code)
+	}
+
+	err := app.tracer.Shutdown(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spans := exporter.byName()
+
+	root, ok := spans["GET /movies/:id"]
+	if !ok {
+		t.Fatalf("expected a server span named after the route, got %v", spans)
+	}
+
+	remote, _ := tracing.ParseTraceparent(req.Header.Get("traceparent"))
+
+	if root.TraceID != remote.TraceID || root.Parent != remote.SpanID {
+		t.Error("expected the server span to continue the incoming trace")
+	}
+
+	if got := attribute(root, "http.response.status_code"); got != int64(http.StatusOK) {
+		t.Errorf("expected status code attribute %d, but got %v", http.StatusOK, got)
+	}
+
+	parent := root.SpanID
+
+	for _, name := range []string{"logRequest", "recoverPanic", "authenticate", "rateLimit", "handler"} {
+		span, ok := spans[name]
+		if !ok {
+			t.Fatalf("expected a %q span", name)
+		}
+
+		if span.TraceID != root.TraceID || span.Parent != parent {
+			t.Errorf("expected %q span to be a child of the previous stage", name)
+		}
+
+		parent = span.SpanID
+	}
+
+	if bcrypt, ok := spans["bcrypt"]; !ok || bcrypt.Parent != spans["authenticate"].SpanID {
+		t.Error("expected a bcrypt span inside authenticate")
+	}
+
+	var entry struct {
+		Properties map[string]string `json:"properties"`
+	}
+
+	err = json.Unmarshal(bytes.TrimSpace(logs.Bytes()), &entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if entry.Properties["trace_id"] != root.TraceID.String() || entry.Properties["span_id"] != root.SpanID.String() {
+		t.Errorf("expected request log to carry the trace IDs, got %v", entry.Properties)
+	}
+}
+
+func TestTraceQuery(t *testing.T) {
+	exporter := &recordingExporter{}
+	tracer := tracing.New(tracing.Config{SampleRatio: 1}, exporter)
+
+	ctx, parent := tracer.Start(context.Background(), "handler", tracing.SpanKindInternal)
+
+	traceQuery(tracer)(ctx, "UserDB", "Get", "SELECT * FROM users WHERE name = 'admin' AND user_id = $1")(sql.ErrNoRows)
+	parent.End()
+
+	tracer.Shutdown(context.Background())
+
+	span, ok := exporter.byName()["UserDB.Get"]
+	if !ok {
+		t.Fatal("expected a span named after the model method")
+	}
+
+	if span.Parent != parent.SpanContext().SpanID || span.Kind != tracing.SpanKindClient {
+		t.Errorf("unexpected span %+v", span)
+	}
+
+	if got := attribute(span, "db.statement"); got != "SELECT * FROM users WHERE name = ? AND user_id = $1" {
+		t.Errorf("expected sanitized statement, but got %v", got)
+	}
+
+	if span.Error != "" {
+		t.Errorf("expected no rows not to be recorded as an error, but got %q", span.Error)
+	}
+}
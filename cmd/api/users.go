@@ -3,22 +3,54 @@ package main
 import (
 	"errors"
 	"filmoteka/internal/data"
+	"filmoteka/internal/jsonlog"
 	"filmoteka/internal/validator"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type UserEnvelope struct {
 	User data.User `json:"user"`
 }
 
+type UsersEnvelope struct {
+	Users    []data.User   `json:"users"`
+	Metadata data.Metadata `json:"metadata"`
+}
+
+type UpdateUserInput struct {
+	Role     *string `json:"role"`
+	Disabled *bool   `json:"disabled"`
+}
+
+type RoleChangesEnvelope struct {
+	RoleChanges []data.RoleChange `json:"role_changes"`
+}
+
 type CreateUserInput struct {
 	Name     string `json:"name" binding:"required"`
+	Email    string `json:"email" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type ActivateUserInput struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ChangePasswordInput struct {
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+type ResetPasswordInput struct {
+	Token    string `json:"token" binding:"required"`
 	Password string `json:"password" binding:"required"`
 }
 
 // @Summary Create a new user
-// @Description Create a new user
+// @Description Create a new user. The account is created inactive: an activation token is sent to the given email and has to be passed to PUT /users/activated before the account can access protected resources. The email is also used to reset a forgotten password and must be unique.
 // @Tags Users
 // @Accept json
 // @Produce json
@@ -31,6 +63,7 @@ type CreateUserInput struct {
 func (app *application) createUserHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
 		Name     string `json:"name"`
+		Email    string `json:"email"`
 		Password string `json:"password"`
 	}
 
@@ -41,8 +74,9 @@ func (app *application) createUserHandler(w http.ResponseWriter, r *http.Request
 	}
 
 	user := &data.User{
-		Name: input.Name,
-		Role: strings.ToLower("user"),
+		Name:  input.Name,
+		Email: strings.ToLower(strings.TrimSpace(input.Email)),
+		Role:  strings.ToLower("user"),
 	}
 
 	err = user.Password.Set(input.Password)
@@ -57,12 +91,26 @@ func (app *application) createUserHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	err = app.models.Users.Insert(user)
+	var token *data.Token
+
+	err = app.models.Transaction(r.Context(), func(tx data.Models) error {
+		err := tx.Users.Insert(r.Context(), user)
+		if err != nil {
+			return err
+		}
+
+		token, err = tx.Tokens.New(r.Context(), user.ID, app.config.tokens.activationTTL, data.ScopeActivation)
+
+		return err
+	})
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrDuplicateName):
 			v.AddError("name", "a user with this name already exists")
 			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrDuplicateEmail):
+			v.AddError("email", "a user with this email already exists")
+			app.failedValidationResponse(w, r, v.Errors)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
@@ -70,8 +118,542 @@ func (app *application) createUserHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	app.background(func() {
+		templateData := map[string]interface{}{
+			"ID":     user.ID,
+			"Name":   user.Name,
+			"Token":  token.Plaintext,
+			"Expiry": token.Expiry.Format(time.RFC3339),
+		}
+
+		err := app.mailer.Send(user.Email, "user_welcome.tmpl", templateData)
+		if err != nil {
+			app.contextGetLogger(r).Error(err,
+				jsonlog.Stack(false),
+				jsonlog.String("action", "send activation email"),
+				jsonlog.Int64("user_id", user.ID),
+			)
+		}
+	})
+
 	err = app.writeJSON(w, http.StatusCreated, envelope{"user": user}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+// @Summary Activate user
+// @Description Activates a self-registered account using the token from the welcome email. The token works only once.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param input body ActivateUserInput true "Activation token"
+// @Success 200 {object} UserEnvelope "User successfully activated"
+// @Failure 400 {object} errorResponse "Bad request"
+// @Failure 422 {object} errorResponse "Validation failed or invalid token"
+// @Failure 500 {object} errorResponse "Internal server error"
+// @Router /users/activated [put]
+func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Token string `json:"token"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	if data.ValidateTokenPlaintext(v, input.Token); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user, err := app.models.Users.GetForToken(r.Context(), data.ScopeActivation, input.Token)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("token", "invalid or expired activation token")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	user.Activated = true
+
+	err = app.models.Transaction(r.Context(), func(tx data.Models) error {
+		err := tx.Users.Activate(r.Context(), user.ID)
+		if err != nil {
+			return err
+		}
+
+		return tx.Tokens.DeleteAllForUser(r.Context(), data.ScopeActivation, user.ID)
+	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.credentials.InvalidateUser(user.ID)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// @Summary Get users
+// @Description Retrieves a paginated list of user accounts. The list can be searched by a part of the name (case-insensitive) and narrowed to a role. The default sort order is by ID in ascending order. Requires the users:manage permission.
+// @Tags Users
+// @Produce json
+// @Param name query string false "Part of the user name"
+// @Param role query string false "Role: user, editor, moderator, admin"
+// @Param sort query string false "Sort order: id, name, -id, -name"
+// @Param page query int false "Page number (default 1)"
+// @Param page_size query int false "Page size, maximum 100 (default 20)"
+// @Success 200 {object} UsersEnvelope "Users data"
+// @Failure 401 {object} errorResponse "Unauthorized"
+// @Failure 403 {object} errorResponse "Forbidden"
+// @Failure 422 {object} errorResponse "Validation error"
+// @Failure 500 {object} errorResponse "Internal server error"
+// @Router /users [get]
+// @Security BasicAuth
+// @Security BearerAuth
+func (app *application) getUsersHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name string
+		Role string
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Name = app.readString(qs, "name", "")
+	input.Role = strings.ToLower(app.readString(qs, "role", ""))
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	input.Filters.Sort = app.readString(qs, "sort", "id")
+	input.Filters.SortSafelist = []string{"id", "name", "-id", "-name"}
+
+	if input.Role != "" {
+		v.Check(validator.In(input.Role, data.Roles...), "role", "invalid role value")
+	}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	users, metadata, err := app.models.Users.GetAll(r.Context(), input.Name, input.Role, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"users": users, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// @Summary Get user by ID
+// @Description Retrieves a specific user account with its role, effective permissions and whether it is disabled. Requires the users:manage permission.
+// @Tags Users
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} UserEnvelope "User data"
+// @Failure 401 {object} errorResponse "Unauthorized"
+// @Failure 403 {object} errorResponse "Forbidden"
+// @Failure 404 {object} errorResponse "User not found"
+// @Failure 500 {object} errorResponse "Internal server error"
+// @Router /users/{id} [get]
+// @Security BasicAuth
+// @Security BearerAuth
+func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	user, err := app.models.Users.GetByID(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// @Summary Update user
+// @Description Changes the role of a user account or disables and re-enables it. Every role change is recorded together with the administrator who made it. Disabling an account revokes its authentication and refresh tokens, and requests made with its credentials or API keys are rejected until it is enabled again. Administrators cannot change their own role or disable themselves. Requires the users:manage permission.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param input body UpdateUserInput true "User data"
+// @Success 200 {object} UserEnvelope "User successfully updated"
+// @Failure 400 {object} errorResponse "Client error"
+// @Failure 401 {object} errorResponse "Unauthorized"
+// @Failure 403 {object} errorResponse "Forbidden"
+// @Failure 404 {object} errorResponse "User not found"
+// @Failure 422 {object} errorResponse "Validation error"
+// @Failure 500 {object} errorResponse "Internal server error"
+// @Router /users/{id} [patch]
+// @Security BasicAuth
+// @Security BearerAuth
+func (app *application) updateUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Role     *string `json:"role"`
+		Disabled *bool   `json:"disabled"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user, err := app.models.Users.GetByID(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	admin := app.contextGetUser(r)
+	oldRole, wasDisabled := user.Role, user.Disabled
+
+	if input.Role != nil {
+		user.Role = strings.ToLower(strings.TrimSpace(*input.Role))
+	}
+
+	if input.Disabled != nil {
+		user.Disabled = *input.Disabled
+	}
+
+	v := validator.New()
+
+	if user.ID == admin.ID {
+		v.Check(user.Role == oldRole, "role", "must not change your own role")
+		v.Check(!user.Disabled, "disabled", "must not disable your own account")
+	}
+
+	if data.ValidateUser(v, user); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Transaction(r.Context(), func(tx data.Models) error {
+		err := tx.Users.UpdateRoleStatus(r.Context(), user)
+		if err != nil {
+			return err
+		}
+
+		if user.Role != oldRole {
+			err = tx.RoleChanges.Insert(r.Context(), &data.RoleChange{
+				UserID:    user.ID,
+				OldRole:   oldRole,
+				NewRole:   user.Role,
+				ChangedBy: &admin.ID,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		if user.Disabled && !wasDisabled {
+			for _, scope := range []string{data.ScopeAuthentication, data.ScopeRefresh} {
+				err = tx.Tokens.DeleteAllForUser(r.Context(), scope, user.ID)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	app.credentials.InvalidateUser(user.ID)
+
+	if user.Role != oldRole {
+		app.contextGetLogger(r).PrintInfo("user role changed", map[string]string{
+			"user_id":    strconv.FormatInt(user.ID, 10),
+			"old_role":   oldRole,
+			"new_role":   user.Role,
+			"changed_by": strconv.FormatInt(admin.ID, 10),
+		})
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// @Summary Delete user
+// @Description Deletes a user account together with its tokens and API keys. Administrators cannot delete their own account. Requires the users:manage permission.
+// @Tags Users
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} MessageEnvelope "User successfully deleted"
+// @Failure 401 {object} errorResponse "Unauthorized"
+// @Failure 403 {object} errorResponse "Forbidden"
+// @Failure 404 {object} errorResponse "User not found"
+// @Failure 422 {object} errorResponse "Validation error"
+// @Failure 500 {object} errorResponse "Internal server error"
+// @Router /users/{id} [delete]
+// @Security BasicAuth
+// @Security BearerAuth
+func (app *application) deleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+
+	if v.Check(id != app.contextGetUser(r).ID, "id", "must not be your own account"); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Users.Delete(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	app.credentials.InvalidateUser(id)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "user successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// @Summary Get user role changes
+// @Description Retrieves the audit log of role changes of a specific user: the old and new role, when the change was made and the ID of the administrator who made it. Requires the users:manage permission.
+// @Tags Users
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} RoleChangesEnvelope "Role changes"
+// @Failure 401 {object} errorResponse "Unauthorized"
+// @Failure 403 {object} errorResponse "Forbidden"
+// @Failure 404 {object} errorResponse "User not found"
+// @Failure 500 {object} errorResponse "Internal server error"
+// @Router /users/{id}/role-changes [get]
+// @Security BasicAuth
+// @Security BearerAuth
+func (app *application) getUserRoleChangesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	_, err = app.models.Users.GetByID(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	changes, err := app.models.RoleChanges.GetAllForUser(r.Context(), id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"role_changes": changes}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// @Summary Change own password
+// @Description Changes the password of the authenticated user. The current password must be provided; wrong attempts count towards the sign-in lockout. All authentication and refresh tokens of the user are revoked, so other sessions have to sign in again.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param input body ChangePasswordInput true "Old and new passwords"
+// @Success 200 {object} MessageEnvelope "Password successfully changed"
+// @Failure 400 {object} errorResponse "Bad request"
+// @Failure 401 {object} errorResponse "Unauthorized or invalid old password"
+// @Failure 422 {object} errorResponse "Validation failed"
+// @Failure 423 {object} errorResponse "Sign-in locked after too many failed attempts"
+// @Failure 429 {object} errorResponse "Too many failed attempts, retry after the Retry-After delay"
+// @Failure 500 {object} errorResponse "Internal server error"
+// @Router /users/me/password [put]
+// @Security BasicAuth
+// @Security BearerAuth
+func (app *application) changePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		OldPassword string `json:"old_password"`
+		NewPassword string `json:"new_password"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(input.OldPassword != "", "old_password", "must be provided")
+	data.ValidatePasswordPlaintext(v, input.NewPassword)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// Старый пароль проверяется как при входе: с украденным токеном
+	// его нельзя перебирать без задержек и блокировки.
+	user, ok := app.checkCredentials(w, r, app.contextGetUser(r).Name, input.OldPassword)
+	if !ok {
+		return
+	}
+
+	app.setPassword(w, r, user, input.NewPassword, "password successfully changed")
+}
+
+// @Summary Reset password
+// @Description Sets a new password using a token from the password reset email (see POST /tokens/password-reset). The token works only once. All authentication and refresh tokens of the user are revoked.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param input body ResetPasswordInput true "Reset token and new password"
+// @Success 200 {object} MessageEnvelope "Password successfully reset"
+// @Failure 400 {object} errorResponse "Bad request"
+// @Failure 422 {object} errorResponse "Validation failed or invalid token"
+// @Failure 500 {object} errorResponse "Internal server error"
+// @Router /users/password [put]
+func (app *application) resetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	data.ValidateTokenPlaintext(v, input.Token)
+	data.ValidatePasswordPlaintext(v, input.Password)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user, err := app.models.Users.GetForToken(r.Context(), data.ScopePasswordReset, input.Token)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("token", "invalid or expired password reset token")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	app.setPassword(w, r, user, input.Password, "password successfully reset")
+}
+
+/*
+Сохраняет новый пароль и в той же транзакции отзывает все токены пользователя:
+сессии, открытые со старым паролем, и неиспользованные токены сброса. Старый
+пароль удаляется и из кеша проверок.
+*/
+func (app *application) setPassword(w http.ResponseWriter, r *http.Request, user *data.User, password, message string) {
+	err := user.Password.Set(password)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.Transaction(r.Context(), func(tx data.Models) error {
+		err := tx.Users.UpdatePassword(r.Context(), user)
+		if err != nil {
+			return err
+		}
+
+		for _, scope := range []string{data.ScopeAuthentication, data.ScopeRefresh, data.ScopePasswordReset} {
+			err = tx.Tokens.DeleteAllForUser(r.Context(), scope, user.ID)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.credentials.InvalidateUser(user.ID)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": message}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -1,14 +1,19 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"filmoteka/internal/data"
 	"filmoteka/internal/jsonlog"
+	"filmoteka/internal/mailer"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestCreateUserHandler(t *testing.T) {
@@ -16,13 +21,16 @@ func TestCreateUserHandler(t *testing.T) {
 		app := &application{
 			models: data.NewMockModels(),
 			logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+			mailer: mailer.NewFile(io.Discard, "no-reply@filmoteka.local"),
 		}
 
 		input := struct {
 			Name     string `json:"name"`
+			Email    string `json:"email"`
 			Password string `json:"password"`
 		}{
 			Name:     "John Doe",
+			Email:    "john@example.com",
 			Password: "password123",
 		}
 
@@ -54,6 +62,16 @@ func TestCreateUserHandler(t *testing.T) {
 		if respBody.User.Name != input.Name {
 			t.Errorf("expected user name %q, but got %q", input.Name, respBody.User.Name)
 		}
+
+		if !respBody.User.Permissions.Include("movies:read") || respBody.User.Permissions.Include("movies:delete") {
+			t.Errorf("unexpected permissions %v", respBody.User.Permissions)
+		}
+
+		if respBody.User.Activated {
+			t.Error("expected new user to be inactive")
+		}
+
+		app.wg.Wait()
 	})
 
 	t.Run("InvalidInput", func(t *testing.T) {
@@ -96,9 +114,11 @@ func TestCreateUserHandler(t *testing.T) {
 
 		input := struct {
 			Name     string `json:"name"`
+			Email    string `json:"email"`
 			Password string `json:"password"`
 		}{
 			Name:     "user",
+			Email:    "john@example.com",
 			Password: "password123",
 		}
 
@@ -119,3 +139,379 @@ func TestCreateUserHandler(t *testing.T) {
 		}
 	})
 }
+
+func adminRequest(app *application, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.SetBasicAuth("admin", "password123")
+
+	res := httptest.NewRecorder()
+	app.routes().ServeHTTP(res, req)
+
+	return res
+}
+
+func TestGetUsersHandler(t *testing.T) {
+	app := &application{
+		models: data.NewMockModels(),
+		logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+	}
+
+	t.Run("Search", func(t *testing.T) {
+		res := adminRequest(app, http.MethodGet, "/users?name=ADM&page_size=1", "")
+		if res.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, but got %d", http.StatusOK, res.Code)
+		}
+
+		var respBody UsersEnvelope
+		err := json.NewDecoder(res.Body).Decode(&respBody)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(respBody.Users) != 1 || respBody.Users[0].Name != "admin" {
+			t.Errorf("expected only admin, but got %v", respBody.Users)
+		}
+
+		if respBody.Metadata.TotalRecords != 1 {
+			t.Errorf("expected 1 total record, but got %d", respBody.Metadata.TotalRecords)
+		}
+	})
+
+	t.Run("InvalidRole", func(t *testing.T) {
+		res := adminRequest(app, http.MethodGet, "/users?role=director", "")
+		if res.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected status code %d, but got %d", http.StatusUnprocessableEntity, res.Code)
+		}
+	})
+
+	t.Run("NotPermitted", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/users", nil)
+		req.SetBasicAuth("user", "password123")
+
+		res := httptest.NewRecorder()
+		app.routes().ServeHTTP(res, req)
+
+		if res.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d, but got %d", http.StatusForbidden, res.Code)
+		}
+	})
+}
+
+func TestUpdateUserHandler(t *testing.T) {
+	app := &application{
+		models: data.NewMockModels(),
+		logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+	}
+
+	t.Run("ChangeRole", func(t *testing.T) {
+		res := adminRequest(app, http.MethodPatch, "/users/1", `{"role": "editor"}`)
+		if res.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, but got %d", http.StatusOK, res.Code)
+		}
+
+		var respBody UserEnvelope
+		err := json.NewDecoder(res.Body).Decode(&respBody)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if respBody.User.Role != "editor" || !respBody.User.Permissions.Include("movies:create") {
+			t.Errorf("expected editor permissions, but got %v", respBody.User)
+		}
+
+		res = adminRequest(app, http.MethodGet, "/users/1/role-changes", "")
+
+		var changes RoleChangesEnvelope
+		err = json.NewDecoder(res.Body).Decode(&changes)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(changes.RoleChanges) != 1 {
+			t.Fatalf("expected 1 role change, but got %d", len(changes.RoleChanges))
+		}
+
+		change := changes.RoleChanges[0]
+		if change.OldRole != "user" || change.NewRole != "editor" || change.ChangedBy == nil || *change.ChangedBy != 2 {
+			t.Errorf("unexpected role change %+v", change)
+		}
+	})
+
+	t.Run("Disable", func(t *testing.T) {
+		token := createToken(t, app, "user", "password123")
+
+		res := adminRequest(app, http.MethodPatch, "/users/1", `{"disabled": true}`)
+		if res.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, but got %d", http.StatusOK, res.Code)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/movies/1", nil)
+		req.SetBasicAuth("user", "password123")
+
+		res = httptest.NewRecorder()
+		app.routes().ServeHTTP(res, req)
+
+		if res.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d, but got %d", http.StatusForbidden, res.Code)
+		}
+
+		res = bearerRequest(app, http.MethodGet, "/movies/1", token)
+		if res.Code != http.StatusUnauthorized {
+			t.Errorf("expected revoked token to get status code %d, but got %d", http.StatusUnauthorized, res.Code)
+		}
+
+		res = adminRequest(app, http.MethodPatch, "/users/1", `{"disabled": false}`)
+		if res.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, but got %d", http.StatusOK, res.Code)
+		}
+
+		req = httptest.NewRequest(http.MethodGet, "/movies/1", nil)
+		req.SetBasicAuth("user", "password123")
+
+		res = httptest.NewRecorder()
+		app.routes().ServeHTTP(res, req)
+
+		if res.Code != http.StatusOK {
+			t.Errorf("expected status code %d, but got %d", http.StatusOK, res.Code)
+		}
+	})
+
+	t.Run("OwnAccount", func(t *testing.T) {
+		for _, body := range []string{`{"role": "user"}`, `{"disabled": true}`} {
+			res := adminRequest(app, http.MethodPatch, "/users/2", body)
+			if res.Code != http.StatusUnprocessableEntity {
+				t.Errorf("expected status code %d for %s, but got %d", http.StatusUnprocessableEntity, body, res.Code)
+			}
+		}
+	})
+
+	t.Run("InvalidRole", func(t *testing.T) {
+		res := adminRequest(app, http.MethodPatch, "/users/1", `{"role": "owner"}`)
+		if res.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected status code %d, but got %d", http.StatusUnprocessableEntity, res.Code)
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		res := adminRequest(app, http.MethodPatch, "/users/42", `{"role": "user"}`)
+		if res.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, but got %d", http.StatusNotFound, res.Code)
+		}
+	})
+}
+
+func TestDeleteUserHandler(t *testing.T) {
+	app := &application{
+		models: data.NewMockModels(),
+		logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+	}
+
+	res := adminRequest(app, http.MethodDelete, "/users/2", "")
+	if res.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status code %d, but got %d", http.StatusUnprocessableEntity, res.Code)
+	}
+
+	res = adminRequest(app, http.MethodDelete, "/users/1", "")
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected status code %d, but got %d", http.StatusOK, res.Code)
+	}
+
+	res = adminRequest(app, http.MethodGet, "/users/1", "")
+	if res.Code != http.StatusNotFound {
+		t.Errorf("expected status code %d, but got %d", http.StatusNotFound, res.Code)
+	}
+}
+
+func TestChangePasswordHandler(t *testing.T) {
+	app := &application{
+		models: data.NewMockModels(),
+		logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+	}
+
+	changePassword := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/users/me/password", strings.NewReader(body))
+		req.SetBasicAuth("user", "password123")
+
+		res := httptest.NewRecorder()
+		app.routes().ServeHTTP(res, req)
+
+		return res
+	}
+
+	t.Run("WrongOldPassword", func(t *testing.T) {
+		res := changePassword(`{"old_password": "wrongpassword", "new_password": "newpassword123"}`)
+		if res.Code != http.StatusUnauthorized {
+			t.Errorf("expected status code %d, but got %d", http.StatusUnauthorized, res.Code)
+		}
+	})
+
+	t.Run("ShortNewPassword", func(t *testing.T) {
+		res := changePassword(`{"old_password": "password123", "new_password": "short"}`)
+		if res.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected status code %d, but got %d", http.StatusUnprocessableEntity, res.Code)
+		}
+	})
+
+	t.Run("Valid", func(t *testing.T) {
+		token := createToken(t, app, "user", "password123")
+
+		res := changePassword(`{"old_password": "password123", "new_password": "newpassword123"}`)
+		if res.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, but got %d", http.StatusOK, res.Code)
+		}
+
+		res = bearerRequest(app, http.MethodGet, "/movies/1", token)
+		if res.Code != http.StatusUnauthorized {
+			t.Errorf("expected old token to be revoked, but got status code %d", res.Code)
+		}
+
+		createToken(t, app, "user", "newpassword123")
+	})
+}
+
+func TestResetPasswordHandler(t *testing.T) {
+	var mail bytes.Buffer
+
+	app := &application{
+		models: data.NewMockModels(),
+		logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+		mailer: mailer.NewFile(&mail, "no-reply@filmoteka.local"),
+	}
+
+	app.config.tokens.passwordResetTTL = time.Hour
+
+	requestReset := func(email string) {
+		t.Helper()
+
+		req := httptest.NewRequest(http.MethodPost, "/tokens/password-reset", strings.NewReader(`{"email": "`+email+`"}`))
+		res := httptest.NewRecorder()
+
+		app.routes().ServeHTTP(res, req)
+		app.wg.Wait()
+
+		if res.Code != http.StatusAccepted {
+			t.Fatalf("expected status code %d, but got %d", http.StatusAccepted, res.Code)
+		}
+	}
+
+	resetPassword := func(token, password string) *httptest.ResponseRecorder {
+		body := `{"token": "` + token + `", "password": "` + password + `"}`
+
+		req := httptest.NewRequest(http.MethodPut, "/users/password", strings.NewReader(body))
+		res := httptest.NewRecorder()
+
+		app.routes().ServeHTTP(res, req)
+
+		return res
+	}
+
+	t.Run("UnknownEmail", func(t *testing.T) {
+		requestReset("nobody@example.com")
+
+		if mail.Len() != 0 {
+			t.Errorf("expected no email to be sent, but got:\n%s", mail.String())
+		}
+	})
+
+	t.Run("Valid", func(t *testing.T) {
+		requestReset("USER@example.com")
+
+		match := regexp.MustCompile(`"token": "([A-Z2-7]{26})"`).FindStringSubmatch(mail.String())
+		if match == nil {
+			t.Fatalf("expected email with a reset token, but got:\n%s", mail.String())
+		}
+
+		res := resetPassword(match[1], "newpassword123")
+		if res.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, but got %d", http.StatusOK, res.Code)
+		}
+
+		createToken(t, app, "user", "newpassword123")
+
+		res = resetPassword(match[1], "otherpassword123")
+		if res.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected used token to be rejected with status code %d, but got %d", http.StatusUnprocessableEntity, res.Code)
+		}
+	})
+
+	t.Run("InvalidToken", func(t *testing.T) {
+		res := resetPassword("ABCDEFGHIJKLMNOPQRSTUVWXYZ", "newpassword123")
+		if res.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected status code %d, but got %d", http.StatusUnprocessableEntity, res.Code)
+		}
+	})
+}
+
+func TestActivateUserHandler(t *testing.T) {
+	var mail bytes.Buffer
+
+	app := &application{
+		models: data.NewMockModels(),
+		logger: jsonlog.New(os.Stdout, jsonlog.LevelInfo),
+		mailer: mailer.NewFile(&mail, "no-reply@filmoteka.local"),
+	}
+
+	app.config.tokens.activationTTL = time.Hour
+
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name": "John Doe", "email": "john@example.com", "password": "password123"}`))
+	res := httptest.NewRecorder()
+
+	app.routes().ServeHTTP(res, req)
+	app.wg.Wait()
+
+	if res.Code != http.StatusCreated {
+		t.Fatalf("expected status code %d, but got %d", http.StatusCreated, res.Code)
+	}
+
+	getMovie := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/movies/1", nil)
+		req.SetBasicAuth("John Doe", "password123")
+
+		res := httptest.NewRecorder()
+		app.routes().ServeHTTP(res, req)
+
+		return res.Code
+	}
+
+	activate := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/users/activated", strings.NewReader(`{"token": "`+token+`"}`))
+		res := httptest.NewRecorder()
+
+		app.routes().ServeHTTP(res, req)
+
+		return res
+	}
+
+	if code := getMovie(); code != http.StatusForbidden {
+		t.Errorf("expected inactive user to get status code %d, but got %d", http.StatusForbidden, code)
+	}
+
+	match := regexp.MustCompile(`"token": "([A-Z2-7]{26})"`).FindStringSubmatch(mail.String())
+	if match == nil {
+		t.Fatalf("expected welcome email with an activation token, but got:\n%s", mail.String())
+	}
+
+	res = activate(match[1])
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected status code %d, but got %d", http.StatusOK, res.Code)
+	}
+
+	var respBody UserEnvelope
+	err := json.NewDecoder(res.Body).Decode(&respBody)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !respBody.User.Activated {
+		t.Error("expected user to be activated")
+	}
+
+	if code := getMovie(); code != http.StatusOK {
+		t.Errorf("expected activated user to get status code %d, but got %d", http.StatusOK, code)
+	}
+
+	res = activate(match[1])
+	if res.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected used token to be rejected with status code %d, but got %d", http.StatusUnprocessableEntity, res.Code)
+	}
+}
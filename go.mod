@@ -5,9 +5,7 @@ go 1.21.3
 require (
 	github.com/julienschmidt/httprouter v1.3.0
 	github.com/lib/pq v1.10.9
-	github.com/tomasen/realip v0.0.0-20180522021738-f0c99a92ddce
 	golang.org/x/crypto v0.21.0
-	golang.org/x/time v0.5.0
 )
 
 require (
@@ -0,0 +1,218 @@
+package credcache
+
+import (
+	"container/list"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"filmoteka/internal/data"
+)
+
+/*
+Cache запоминает успешные проверки имени и пароля, чтобы повторные запросы
+с Basic Auth не платили за обращение к базе и bcrypt. Ключ записи -
+HMAC-SHA256 имени и пароля со случайным ключом процесса, поэтому пароли
+в памяти не хранятся даже в виде быстрого хеша, который можно перебрать.
+Записи живут не дольше TTL, а при переполнении вытесняются давно не
+использованные. Методы nil Cache ничего не делают.
+*/
+type Cache struct {
+	mu      sync.Mutex
+	secret  []byte
+	size    int
+	ttl     time.Duration
+	order   *list.List
+	entries map[[sha256.Size]byte]*list.Element
+	byUser  map[int64]map[[sha256.Size]byte]struct{}
+	// Поколение растет при каждом InvalidateUser; invalidated хранит
+	// поколение последнего сброса каждого пользователя.
+	generation  uint64
+	invalidated map[int64]uint64
+	hits        atomic.Uint64
+	misses      atomic.Uint64
+	now         func() time.Time
+}
+
+type entry struct {
+	key    [sha256.Size]byte
+	user   data.User
+	expiry time.Time
+}
+
+type Stats struct {
+	Hits    uint64
+	Misses  uint64
+	Entries int
+}
+
+func New(size int, ttl time.Duration) (*Cache, error) {
+	secret := make([]byte, 32)
+
+	_, err := rand.Read(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cache{
+		secret:  secret,
+		size:    size,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: make(map[[sha256.Size]byte]*list.Element),
+		byUser:  make(map[int64]map[[sha256.Size]byte]struct{}),
+		now:     time.Now,
+
+		invalidated: make(map[int64]uint64),
+	}, nil
+}
+
+// Возвращает копию пользователя, если пара имя-пароль недавно прошла проверку.
+func (c *Cache) Get(name, password string) (*data.User, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	key := c.key(name, password)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, found := c.entries[key]
+	if !found {
+		c.misses.Add(1)
+		return nil, false
+	}
+
+	e := element.Value.(*entry)
+
+	if !c.now().Before(e.expiry) {
+		c.remove(element)
+		c.misses.Add(1)
+		return nil, false
+	}
+
+	c.order.MoveToFront(element)
+	c.hits.Add(1)
+
+	return cloneUser(&e.user), true
+}
+
+/*
+Возвращает текущее поколение кеша. Его нужно получить до чтения пользователя
+из базы и передать в Set: если пользователь был сброшен InvalidateUser после
+этого, прочитанные данные могли устареть, и Set их не запомнит.
+*/
+func (c *Cache) Generation() uint64 {
+	if c == nil {
+		return 0
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.generation
+}
+
+func (c *Cache) Set(name, password string, user *data.User, generation uint64) {
+	if c == nil || c.size <= 0 {
+		return
+	}
+
+	key := c.key(name, password)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.invalidated[user.ID] > generation {
+		return
+	}
+
+	if element, found := c.entries[key]; found {
+		c.remove(element)
+	}
+
+	for c.order.Len() >= c.size {
+		c.remove(c.order.Back())
+	}
+
+	e := &entry{key: key, user: *cloneUser(user), expiry: c.now().Add(c.ttl)}
+	c.entries[key] = c.order.PushFront(e)
+
+	if c.byUser[user.ID] == nil {
+		c.byUser[user.ID] = make(map[[sha256.Size]byte]struct{})
+	}
+
+	c.byUser[user.ID][key] = struct{}{}
+}
+
+/*
+Удаляет все записи пользователя. Вызывается после смены пароля, роли,
+блокировки или удаления, чтобы старые данные не пережили изменение.
+Затрагивает только кеш этого процесса: другие реплики увидят изменение
+после истечения TTL своих записей. Проверки, начатые до сброса, после него
+в кеш уже не попадут (см. Generation).
+*/
+func (c *Cache) InvalidateUser(id int64) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.invalidated[id] = c.generation
+
+	for key := range c.byUser[id] {
+		c.remove(c.entries[key])
+	}
+}
+
+func (c *Cache) Stats() Stats {
+	if c == nil {
+		return Stats{}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return Stats{Hits: c.hits.Load(), Misses: c.misses.Load(), Entries: c.order.Len()}
+}
+
+// Длина имени входит в хеш, чтобы пары ("ab", "c") и ("a", "bc") не совпадали.
+func (c *Cache) key(name, password string) [sha256.Size]byte {
+	mac := hmac.New(sha256.New, c.secret)
+
+	binary.Write(mac, binary.BigEndian, uint64(len(name)))
+	mac.Write([]byte(name))
+	mac.Write([]byte(password))
+
+	var key [sha256.Size]byte
+	mac.Sum(key[:0])
+
+	return key
+}
+
+func (c *Cache) remove(element *list.Element) {
+	e := c.order.Remove(element).(*entry)
+
+	delete(c.entries, e.key)
+
+	delete(c.byUser[e.user.ID], e.key)
+	if len(c.byUser[e.user.ID]) == 0 {
+		delete(c.byUser, e.user.ID)
+	}
+}
+
+func cloneUser(user *data.User) *data.User {
+	clone := *user
+	clone.Permissions = slices.Clone(user.Permissions)
+
+	return &clone
+}
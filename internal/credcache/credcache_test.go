@@ -0,0 +1,141 @@
+package credcache
+
+import (
+	"testing"
+	"time"
+
+	"filmoteka/internal/data"
+)
+
+func newTestCache(t *testing.T, size int, now *time.Time) *Cache {
+	c, err := New(size, time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	c.now = func() time.Time { return *now }
+
+	return c
+}
+
+func TestCache_GetSet(t *testing.T) {
+	now := time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)
+	c := newTestCache(t, 10, &now)
+
+	c.Set("user", "password123", &data.User{ID: 1, Name: "user", Permissions: data.Permissions{"movies:read"}}, c.Generation())
+
+	user, found := c.Get("user", "password123")
+	if !found || user.ID != 1 {
+		t.Fatalf("expected cached user, but got %v, %t", user, found)
+	}
+
+	user.Permissions[0] = "movies:delete"
+
+	if user, _ := c.Get("user", "password123"); user.Permissions[0] != "movies:read" {
+		t.Error("expected returned user to be a copy")
+	}
+
+	for _, tt := range []struct{ name, password string }{
+		{"user", "password12"},
+		{"user", "password1234"},
+		{"userp", "assword123"},
+		{"admin", "password123"},
+	} {
+		if _, found := c.Get(tt.name, tt.password); found {
+			t.Errorf("expected miss for %q/%q", tt.name, tt.password)
+		}
+	}
+
+	now = now.Add(time.Minute)
+
+	if _, found := c.Get("user", "password123"); found {
+		t.Error("expected entry to expire after TTL")
+	}
+
+	stats := c.Stats()
+	if stats.Hits != 2 || stats.Misses != 5 || stats.Entries != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestCache_Eviction(t *testing.T) {
+	now := time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)
+	c := newTestCache(t, 2, &now)
+
+	c.Set("a", "password", &data.User{ID: 1}, c.Generation())
+	c.Set("b", "password", &data.User{ID: 2}, c.Generation())
+	c.Get("a", "password")
+	c.Set("c", "password", &data.User{ID: 3}, c.Generation())
+
+	if _, found := c.Get("b", "password"); found {
+		t.Error("expected least recently used entry to be evicted")
+	}
+
+	for _, name := range []string{"a", "c"} {
+		if _, found := c.Get(name, "password"); !found {
+			t.Errorf("expected %s to stay cached", name)
+		}
+	}
+}
+
+func TestCache_InvalidateUser(t *testing.T) {
+	now := time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)
+	c := newTestCache(t, 10, &now)
+
+	c.Set("user", "password123", &data.User{ID: 1}, c.Generation())
+	c.Set("user", "otherpassword", &data.User{ID: 1}, c.Generation())
+	c.Set("admin", "password123", &data.User{ID: 2}, c.Generation())
+
+	c.InvalidateUser(1)
+
+	if _, found := c.Get("user", "password123"); found {
+		t.Error("expected entries of invalidated user to be removed")
+	}
+
+	if _, found := c.Get("admin", "password123"); !found {
+		t.Error("expected entries of other users to stay cached")
+	}
+
+	if stats := c.Stats(); stats.Entries != 1 {
+		t.Errorf("expected 1 entry, but got %d", stats.Entries)
+	}
+}
+
+func TestCache_InvalidateDuringLookup(t *testing.T) {
+	now := time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)
+	c := newTestCache(t, 10, &now)
+
+	// Запрос со старым паролем начал проверку и прочитал старый хеш,
+	// а пароль тем временем сменили и сбросили кеш пользователя.
+	stale := c.Generation()
+	other := c.Generation()
+	c.InvalidateUser(1)
+
+	c.Set("user", "oldpassword", &data.User{ID: 1}, stale)
+	c.Set("admin", "password123", &data.User{ID: 2}, other)
+
+	if _, found := c.Get("user", "oldpassword"); found {
+		t.Error("expected a lookup started before invalidation not to be cached")
+	}
+
+	if _, found := c.Get("admin", "password123"); !found {
+		t.Error("expected lookups of other users to stay cacheable")
+	}
+
+	c.Set("user", "newpassword", &data.User{ID: 1}, c.Generation())
+
+	if _, found := c.Get("user", "newpassword"); !found {
+		t.Error("expected a lookup started after invalidation to be cached")
+	}
+}
+
+func TestCache_Nil(t *testing.T) {
+	var c *Cache
+
+	c.Set("user", "password123", &data.User{ID: 1}, c.Generation())
+	c.InvalidateUser(1)
+
+	if _, found := c.Get("user", "password123"); found || c.Stats() != (Stats{}) {
+		t.Error("expected nil cache to never hit")
+	}
+}
@@ -6,32 +6,41 @@ import (
 	"encoding/json"
 	"errors"
 	"filmoteka/internal/validator"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
-	"unicode/utf8"
+
+	"github.com/lib/pq"
 )
 
 type Actor struct {
-	ID        int64     `json:"id"`
-	FullName  string    `json:"full_name"`
-	Gender    string    `json:"gender"`
-	BirthDate time.Time `json:"birth_date"` // RFC3339
-	Movies    []int     `json:"movies"`
+	ID           int64     `json:"id"`
+	FullName     string    `json:"full_name"`
+	Gender       string    `json:"gender"`
+	BirthDate    time.Time `json:"birth_date"` // RFC3339
+	Movies       []int     `json:"movies"`
+	MovieDetails []*Movie  `json:"movie_details,omitempty"`
+	Version      int32     `json:"version"`
 }
 
 type ActorModel interface {
-	Insert(actor *Actor) error
-	Delete(actor_id int64) error
-	Get(id int64) (*Actor, error)
-	GetAll() ([]Actor, error)
-	Update(actor *Actor) error
+	Insert(ctx context.Context, actor *Actor) error
+	Delete(ctx context.Context, actor_id int64) error
+	Get(ctx context.Context, id int64) (*Actor, error)
+	GetByIDs(ctx context.Context, ids []int64) (map[int64]*Actor, error)
+	GetAll(ctx context.Context, filters Filters) ([]Actor, Metadata, error)
+	Update(ctx context.Context, actor *Actor) error
 }
 
 type ActorDB struct {
-	DB *sql.DB
+	DB      Querier
+	Timeout time.Duration
 }
 
 type MockActorDB struct {
 	Actors map[int64]*Actor
+	Movies map[int64]*Movie
 }
 
 var (
@@ -39,89 +48,77 @@ var (
 )
 
 func ValidateActor(v *validator.Validator, actor *Actor) {
-	v.Check(actor.FullName != "", "full_name", "must be provided")
-	v.Check(utf8.RuneCountInString(actor.FullName) <= 200, "full_name", "must be no more than 200 symbols")
-
-	v.Check(actor.Gender != "", "gender", "must be provided")
-	v.Check(actor.Gender == "male" || actor.Gender == "female", "gender", "must be either male or female")
-
-	v.Check(!actor.BirthDate.Equal(time.Time{}), "birth_date", "must be provided")
-	v.Check(actor.BirthDate.Before(time.Now()), "birth_date", "must be a valid date")
+	ValidatePerson(v, actor.person())
 }
 
-func (m ActorDB) Insert(actor *Actor) error {
-	query := `
-		INSERT INTO Actors (full_name, gender, birth_date)
-		VALUES ($1, $2, $3)
-		RETURNING actor_id`
-
-	args := []interface{}{actor.FullName, actor.Gender, actor.BirthDate}
+func (m ActorDB) Insert(ctx context.Context, actor *Actor) error {
+	ctx = withQueryCaller(ctx, "ActorDB", "Insert")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
+	person := actor.person()
 
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&actor.ID)
+	err := PersonDB(m).Insert(ctx, person)
 	if err != nil {
-		switch {
-		case err.Error() == `pq: duplicate key value violates unique constraint "actors_full_name_key"`:
-			return ErrDuplicateName
-		default:
-			return err
-		}
+		return err
 	}
 
+	actor.ID, actor.Version = person.ID, person.Version
+
 	return nil
 }
 
 /*
-Удаляет актера и все его связи с фильмами из таблицы Movies_actors,
-но не удаляет сами фильмы из таблицы Movies.
+Удаляет актера вместе со всеми его участиями в фильмах,
+включая неактерские, но не удаляет сами фильмы из таблицы Movies.
+Как и в Get, члены съемочной группы без единой актерской роли
+актерами не считаются: они не удаляются, и возвращается ErrRecordNotFound.
 */
-func (m ActorDB) Delete(actor_id int64) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (m ActorDB) Delete(ctx context.Context, actor_id int64) error {
+	ctx, cancel := startMethod(ctx, m.Timeout, "ActorDB", "Delete")
 	defer cancel()
 
 	query := `
-		DELETE FROM 
-			Actors
+		DELETE FROM
+			People p
 		WHERE
-			actor_id = $1
-	`
+			p.person_id = $1
+		AND (
+			EXISTS (SELECT 1 FROM Credits c WHERE c.person_id = p.person_id AND c.role = 'actor') OR
+			NOT EXISTS (SELECT 1 FROM Credits c WHERE c.person_id = p.person_id))`
 
 	result, err := m.DB.ExecContext(ctx, query, actor_id)
 	if err != nil {
 		return err
 	}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return err
-	}
-
-	if rowsAffected == 0 {
-		return ErrRecordNotFound
-	}
-
-	return nil
+	return checkAffectedRows(result)
 }
 
-func (m ActorDB) Get(id int64) (*Actor, error) {
+/*
+Актер - это человек из таблицы People, в поле Movies которого попадают
+только фильмы, где он снимался, без режиссерских и других работ.
+Как и в GetAll, члены съемочной группы без единой актерской роли
+актерами не считаются, и для них возвращается ErrRecordNotFound.
+*/
+func (m ActorDB) Get(ctx context.Context, id int64) (*Actor, error) {
 	var actor Actor
 
 	query := `
 	SELECT
-		a.actor_id, a.full_name, a.gender, a.birth_date, json_agg(m.movie_id)
+		p.person_id, p.full_name, p.gender, p.birth_date, p.version,
+		COALESCE(json_agg(c.movie_id) FILTER (WHERE c.role = 'actor'), '[]')
 	FROM
-		Actors a
+		People p
 	LEFT JOIN
-		Movies_actors m ON a.actor_id = m.actor_id
+		Credits c ON p.person_id = c.person_id
 	WHERE
-		a.actor_id = $1
+		p.person_id = $1
 	GROUP BY
-		a.actor_id, a.full_name, a.gender, a.birth_date	
+		p.person_id, p.full_name, p.gender, p.birth_date, p.version
+	HAVING
+		count(c.movie_id) FILTER (WHERE c.role = 'actor') > 0 OR count(c.movie_id) = 0
 	`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := startMethod(ctx, m.Timeout, "ActorDB", "Get")
 	defer cancel()
 
 	var movies json.RawMessage
@@ -130,6 +127,7 @@ func (m ActorDB) Get(id int64) (*Actor, error) {
 		&actor.FullName,
 		&actor.Gender,
 		&actor.BirthDate,
+		&actor.Version,
 		&movies)
 	if err != nil {
 		switch {
@@ -145,31 +143,39 @@ func (m ActorDB) Get(id int64) (*Actor, error) {
 		return nil, err
 	}
 
-	if len(actor.Movies) == 1 && actor.Movies[0] == 0 {
-		actor.Movies = []int{}
-	}
-
 	return &actor, nil
 }
 
-func (m ActorDB) GetAll() ([]Actor, error) {
-	var actors []Actor
+/*
+Загружает сразу нескольких актеров одним запросом. Используется для
+?include=actors, чтобы не выполнять отдельный запрос на каждого актера фильма.
+Несуществующие идентификаторы просто отсутствуют в результате.
+*/
+func (m ActorDB) GetByIDs(ctx context.Context, ids []int64) (map[int64]*Actor, error) {
+	actors := make(map[int64]*Actor, len(ids))
+
+	if len(ids) == 0 {
+		return actors, nil
+	}
 
 	query := `
 	SELECT
-		a.actor_id, a.full_name, a.gender, a.birth_date, json_agg(m.movie_id)
+		p.person_id, p.full_name, p.gender, p.birth_date, p.version,
+		COALESCE(json_agg(c.movie_id) FILTER (WHERE c.role = 'actor'), '[]')
 	FROM
-		Actors a
+		People p
 	LEFT JOIN
-		Movies_actors m ON a.actor_id = m.actor_id
+		Credits c ON p.person_id = c.person_id
+	WHERE
+		p.person_id = ANY($1)
 	GROUP BY
-		a.actor_id, a.full_name, a.gender, a.birth_date	
+		p.person_id, p.full_name, p.gender, p.birth_date, p.version
 	`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := startMethod(ctx, m.Timeout, "ActorDB", "GetByIDs")
 	defer cancel()
 
-	rows, err := m.DB.QueryContext(ctx, query)
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(ids))
 	if err != nil {
 		return nil, err
 	}
@@ -184,8 +190,8 @@ func (m ActorDB) GetAll() ([]Actor, error) {
 			&actor.FullName,
 			&actor.Gender,
 			&actor.BirthDate,
+			&actor.Version,
 			&movies)
-
 		if err != nil {
 			return nil, err
 		}
@@ -195,11 +201,7 @@ func (m ActorDB) GetAll() ([]Actor, error) {
 			return nil, err
 		}
 
-		if len(actor.Movies) == 1 && actor.Movies[0] == 0 {
-			actor.Movies = []int{}
-		}
-
-		actors = append(actors, actor)
+		actors[actor.ID] = &actor
 	}
 
 	if err = rows.Err(); err != nil {
@@ -209,91 +211,239 @@ func (m ActorDB) GetAll() ([]Actor, error) {
 	return actors, nil
 }
 
-func (m ActorDB) Update(actor *Actor) error {
-	query := `
-		UPDATE 
-			Actors
-		SET 
-			full_name = $1,
-			gender = $2,
-			birth_date = $3
-		WHERE 
-			actor_id = $4
-	`
+/*
+Список актеров - это люди, у которых есть хотя бы одна актерская роль,
+а также люди без единого участия в фильмах, чтобы только что добавленный
+через /actors человек сразу появлялся в списке. Режиссеры, сценаристы и
+другие члены съемочной группы, не снимавшиеся в кино, в список не попадают.
+*/
+func (m ActorDB) GetAll(ctx context.Context, filters Filters) ([]Actor, Metadata, error) {
+	c, err := filters.cursor()
+	if err != nil {
+		return nil, Metadata{}, err
+	}
 
-	args := []interface{}{
-		actor.FullName,
-		actor.Gender,
-		actor.BirthDate,
-		actor.ID,
+	args := []interface{}{filters.limit() + 1, filters.offset()}
+
+	where := ""
+	if c != nil {
+		where = fmt.Sprintf("WHERE (p.%s, p.person_id) %s ($3, $4)", filters.sortColumn(), filters.cursorOperator())
+		args = append(args, c.Value, c.ID)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	query := fmt.Sprintf(`
+	SELECT
+		%s, p.person_id, p.full_name, p.gender, p.birth_date, p.version,
+		COALESCE(json_agg(c.movie_id) FILTER (WHERE c.role = 'actor'), '[]')
+	FROM
+		People p
+	LEFT JOIN
+		Credits c ON p.person_id = c.person_id
+	%s
+	GROUP BY
+		p.person_id, p.full_name, p.gender, p.birth_date, p.version
+	HAVING
+		count(c.movie_id) FILTER (WHERE c.role = 'actor') > 0 OR count(c.movie_id) = 0
+	ORDER BY
+		p.%s %s, p.person_id %s
+	LIMIT $1 OFFSET $2
+	`, filters.countColumn(), where, filters.sortColumn(), filters.sortDirection(), filters.sortDirection())
+
+	ctx, cancel := startMethod(ctx, m.Timeout, "ActorDB", "GetAll")
 	defer cancel()
 
-	_, err := m.DB.ExecContext(ctx, query, args...)
+	rows, err := m.DB.QueryContext(ctx, query, args...)
 	if err != nil {
-		switch {
-		case errors.Is(err, sql.ErrNoRows):
-			return ErrRecordNotFound
-		case err.Error() == `pq: duplicate key value violates unique constraint "actors_full_name_key"`:
-			return ErrDuplicateName
-		default:
-			return err
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	actors := []Actor{}
+
+	for rows.Next() {
+		var actor Actor
+		var movies json.RawMessage
+
+		err = rows.Scan(&totalRecords,
+			&actor.ID,
+			&actor.FullName,
+			&actor.Gender,
+			&actor.BirthDate,
+			&actor.Version,
+			&movies)
+
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		err = json.Unmarshal(movies, &actor.Movies)
+		if err != nil {
+			return nil, Metadata{}, err
 		}
+
+		actors = append(actors, actor)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	actors, nextCursor := paginateActors(actors, filters)
+
+	return actors, calculateMetadata(totalRecords, filters, nextCursor), nil
+}
+
+func (m ActorDB) Update(ctx context.Context, actor *Actor) error {
+	ctx = withQueryCaller(ctx, "ActorDB", "Update")
+
+	person := actor.person()
+
+	err := PersonDB(m).Update(ctx, person)
+	if err != nil {
+		return err
 	}
 
+	actor.Version = person.Version
+
 	return nil
 }
 
-func (m *MockActorDB) Insert(actor *Actor) error {
+func paginateActors(actors []Actor, filters Filters) ([]Actor, string) {
+	if len(actors) <= filters.limit() {
+		return actors, ""
+	}
+
+	actors = actors[:filters.limit()]
+	last := actors[len(actors)-1]
+
+	value := last.FullName
+	if strings.TrimPrefix(filters.Sort, "-") == "birth_date" {
+		value = last.BirthDate.Format("2006-01-02")
+	}
+
+	return actors, encodeCursor(cursor{Sort: filters.Sort, Value: value, ID: last.ID})
+}
+
+func (m *MockActorDB) Insert(ctx context.Context, actor *Actor) error {
 	if _, found := m.Actors[actor.ID]; found {
 		return ErrDuplicateName
 	}
 
-	m.Actors[actor.ID] = actor
+	actor.Version = 1
+	m.Actors[actor.ID] = actor.clone()
 
 	return nil
 }
 
-func (m *MockActorDB) Get(id int64) (*Actor, error) {
+func (m *MockActorDB) Get(ctx context.Context, id int64) (*Actor, error) {
 	actor, ok := m.Actors[id]
 
-	if !ok {
+	if !ok || !m.isActor(id) {
 		return nil, ErrRecordNotFound
 	}
 
-	return actor, nil
+	return actor.clone(), nil
 }
 
-func (m *MockActorDB) GetAll() ([]Actor, error) {
+func (m *MockActorDB) GetByIDs(ctx context.Context, ids []int64) (map[int64]*Actor, error) {
+	actors := make(map[int64]*Actor, len(ids))
+
+	for _, id := range ids {
+		if actor, ok := m.Actors[id]; ok {
+			actors[id] = actor.clone()
+		}
+	}
+
+	return actors, nil
+}
+
+func (m *MockActorDB) GetAll(ctx context.Context, filters Filters) ([]Actor, Metadata, error) {
 	var actors []Actor
 
 	for _, actor := range m.Actors {
-		actors = append(actors, *actor)
+		if !m.isActor(actor.ID) {
+			continue
+		}
+
+		actors = append(actors, *actor.clone())
 	}
 
-	return actors, nil
+	sort.Slice(actors, func(i, j int) bool {
+		return actors[i].ID < actors[j].ID
+	})
+
+	switch filters.Sort {
+	case "-full_name":
+		sort.SliceStable(actors, func(i, j int) bool {
+			return actors[i].FullName > actors[j].FullName
+		})
+	case "birth_date":
+		sort.SliceStable(actors, func(i, j int) bool {
+			return actors[i].BirthDate.Before(actors[j].BirthDate)
+		})
+	case "-birth_date":
+		sort.SliceStable(actors, func(i, j int) bool {
+			return actors[i].BirthDate.After(actors[j].BirthDate)
+		})
+	default:
+		sort.SliceStable(actors, func(i, j int) bool {
+			return actors[i].FullName < actors[j].FullName
+		})
+	}
+
+	c, err := filters.cursor()
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	start := filters.offset()
+	if c != nil {
+		for i, actor := range actors {
+			if actor.ID == c.ID {
+				start = i + 1
+				break
+			}
+		}
+	}
+
+	totalRecords := len(actors)
+	start = min(start, len(actors))
+	end := min(start+filters.limit()+1, len(actors))
+
+	actors, nextCursor := paginateActors(actors[start:end], filters)
+	if len(actors) == 0 {
+		totalRecords = 0
+	}
+
+	return actors, calculateMetadata(totalRecords, filters, nextCursor), nil
 }
 
-func (m *MockActorDB) Update(actor *Actor) error {
-	if _, found := m.Actors[actor.ID]; !found {
+func (m *MockActorDB) Update(ctx context.Context, actor *Actor) error {
+	existing, found := m.Actors[actor.ID]
+	if !found {
 		return ErrRecordNotFound
 	}
 
+	if existing.Version != actor.Version {
+		return ErrEditConflict
+	}
+
 	for _, a := range m.Actors {
 		if a.FullName == actor.FullName && a.ID != actor.ID {
 			return ErrDuplicateName
 		}
 	}
 
-	m.Actors[actor.ID] = actor
+	actor.Version++
+	m.Actors[actor.ID] = actor.clone()
 
 	return nil
 }
 
-func (m *MockActorDB) Delete(actor_id int64) error {
-	if _, found := m.Actors[actor_id]; !found {
+func (m *MockActorDB) Delete(ctx context.Context, actor_id int64) error {
+	if _, found := m.Actors[actor_id]; !found || !m.isActor(actor_id) {
 		return ErrRecordNotFound
 	}
 
@@ -301,3 +451,19 @@ func (m *MockActorDB) Delete(actor_id int64) error {
 
 	return nil
 }
+
+func (m *MockActorDB) isActor(id int64) bool {
+	credited := false
+
+	for _, movie := range m.Movies {
+		for _, role := range movieRoles(movie, id) {
+			if role == "actor" {
+				return true
+			}
+
+			credited = true
+		}
+	}
+
+	return !credited
+}
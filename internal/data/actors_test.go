@@ -1,6 +1,8 @@
 package data
 
 import (
+	"context"
+	"errors"
 	"testing"
 	"time"
 )
@@ -21,7 +23,7 @@ func TestActorDB_Get(t *testing.T) {
 	t.Run("Valid", func(t *testing.T) {
 		actorID := int64(1)
 
-		actor, err := mockActorModel.Get(actorID)
+		actor, err := mockActorModel.Get(context.Background(), actorID)
 		if err != nil {
 			t.Errorf("unexpected error: %v", err)
 		}
@@ -38,11 +40,20 @@ func TestActorDB_Get(t *testing.T) {
 	t.Run("Invalid", func(t *testing.T) {
 		actorID := int64(2)
 
-		_, err := mockActorModel.Get(actorID)
+		_, err := mockActorModel.Get(context.Background(), actorID)
 		if err == nil {
 			t.Error("expected ErrRecordNotFound, but got nil")
 		}
 	})
+
+	t.Run("CrewOnly", func(t *testing.T) {
+		models := NewMockModels()
+
+		_, err := models.Actors.Get(context.Background(), 3)
+		if !errors.Is(err, ErrRecordNotFound) {
+			t.Errorf("expected ErrRecordNotFound for a director without acting roles, but got %v", err)
+		}
+	})
 }
 
 func TestActorDB_GetAll(t *testing.T) {
@@ -59,7 +70,7 @@ func TestActorDB_GetAll(t *testing.T) {
 	}
 
 	t.Run("Valid", func(t *testing.T) {
-		actors, err := mockActorModel.GetAll()
+		actors, _, err := mockActorModel.GetAll(context.Background(), Filters{Page: 1, PageSize: 20, Sort: "full_name"})
 		if err != nil {
 			t.Errorf("unexpected error: %v", err)
 		}
@@ -72,7 +83,7 @@ func TestActorDB_GetAll(t *testing.T) {
 	t.Run("Invalid", func(t *testing.T) {
 		mockActorModel.Actors = nil
 
-		actors, err := mockActorModel.GetAll()
+		actors, _, err := mockActorModel.GetAll(context.Background(), Filters{Page: 1, PageSize: 20, Sort: "full_name"})
 		if err != nil {
 			t.Errorf("unexpected error: %v", err)
 		}
@@ -103,7 +114,7 @@ func TestActorDB_Insert(t *testing.T) {
 			BirthDate: time.Date(2021, 8, 12, 0, 0, 0, 0, time.UTC),
 		}
 
-		err := mockActorModel.Insert(actor)
+		err := mockActorModel.Insert(context.Background(), actor)
 		if err != nil {
 			t.Errorf("unexpected error: %v", err)
 		}
@@ -116,7 +127,7 @@ func TestActorDB_Insert(t *testing.T) {
 			BirthDate: time.Date(2021, 8, 12, 0, 0, 0, 0, time.UTC),
 		}
 
-		err := mockActorModel.Insert(actor)
+		err := mockActorModel.Insert(context.Background(), actor)
 		if err == nil {
 			t.Error("expected ErrDuplicateName, but got nil")
 		}
@@ -149,7 +160,7 @@ func TestActorDB_Update(t *testing.T) {
 			FullName: "Max Verstappen",
 		}
 
-		err := mockActorModel.Update(actor)
+		err := mockActorModel.Update(context.Background(), actor)
 		if err != nil {
 			t.Errorf("unexpected error: %v", err)
 		}
@@ -163,21 +174,35 @@ func TestActorDB_Update(t *testing.T) {
 		actor := &Actor{
 			ID:       2,
 			FullName: "John Doe",
+			Version:  1,
 		}
 
-		err := mockActorModel.Update(actor)
+		err := mockActorModel.Update(context.Background(), actor)
 		if err == nil {
 			t.Error("expected ErrDuplicateName, but got nil")
 		}
 	})
 
+	t.Run("EditConflict", func(t *testing.T) {
+		actor := &Actor{
+			ID:       2,
+			FullName: "Max Emilian Verstappen",
+			Version:  0,
+		}
+
+		err := mockActorModel.Update(context.Background(), actor)
+		if err != ErrEditConflict {
+			t.Errorf("expected ErrEditConflict, but got %v", err)
+		}
+	})
+
 	t.Run("NotFound", func(t *testing.T) {
 		actor := &Actor{
 			ID:       3,
 			FullName: "John Doe",
 		}
 
-		err := mockActorModel.Update(actor)
+		err := mockActorModel.Update(context.Background(), actor)
 		if err == nil {
 			t.Error("expected ErrRecordNotFound, but got nil")
 		}
@@ -200,7 +225,7 @@ func TestActorDB_Delete(t *testing.T) {
 	t.Run("Valid", func(t *testing.T) {
 		actorID := int64(1)
 
-		err := mockActorModel.Delete(actorID)
+		err := mockActorModel.Delete(context.Background(), actorID)
 		if err != nil {
 			t.Errorf("unexpected error: %v", err)
 		}
@@ -209,10 +234,23 @@ func TestActorDB_Delete(t *testing.T) {
 	t.Run("Invalid", func(t *testing.T) {
 		actorID := int64(1)
 
-		err := mockActorModel.Delete(actorID)
+		err := mockActorModel.Delete(context.Background(), actorID)
 		if err == nil {
 			t.Error("expected ErrRecordNotFound, but got nil")
 		}
 	})
 
 }
+
+func TestMockActorDB_GetByIDs(t *testing.T) {
+	models := NewMockModels()
+
+	actors, err := models.Actors.GetByIDs(context.Background(), []int64{1, 2, 42})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(actors) != 2 || actors[1].FullName != "Mock Actor 1" || actors[2].FullName != "Mock Actor 2" {
+		t.Errorf("expected actors 1 and 2, but got %v", actors)
+	}
+}
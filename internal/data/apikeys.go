@@ -0,0 +1,325 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"filmoteka/internal/validator"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const apiKeyPrefix = "fmk_"
+
+var (
+	ErrUserNotFound = errors.New("user does not exist")
+)
+
+type APIKey struct {
+	ID         int64      `json:"id"`
+	UserID     int64      `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Plaintext  string     `json:"key,omitempty"`
+	Hash       []byte     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	Expiry     *time.Time `json:"expiry,omitempty"`       // RFC3339
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"` // RFC3339
+	CreatedAt  time.Time  `json:"created_at"`             // RFC3339
+}
+
+type APIKeyModel interface {
+	Insert(ctx context.Context, key *APIKey) error
+	GetAll(ctx context.Context, userID int64) ([]*APIKey, error)
+	Delete(ctx context.Context, id int64) error
+}
+
+type APIKeyDB struct {
+	DB      Querier
+	Timeout time.Duration
+}
+
+type MockAPIKeyDB struct {
+	Keys  map[int64]*APIKey
+	Users map[string]*User
+}
+
+func ValidateAPIKey(v *validator.Validator, key *APIKey) {
+	v.Check(key.UserID > 0, "user_id", "must be provided")
+
+	v.Check(key.Name != "", "name", "must be provided")
+	v.Check(len(key.Name) <= 100, "name", "must not be more than 100 bytes long")
+
+	v.Check(len(key.Scopes) > 0, "scopes", "must contain at least one scope")
+	v.Check(validator.Unique(key.Scopes), "scopes", "must not contain duplicate values")
+
+	for _, scope := range key.Scopes {
+		if !validator.In(scope, Scopes...) {
+			v.AddError("scopes", "must contain only known scopes: "+strings.Join(Scopes, ", "))
+			break
+		}
+	}
+
+	if key.Expiry != nil {
+		v.Check(key.Expiry.After(time.Now()), "expiry", "must be in the future")
+	}
+}
+
+/*
+Ключ - 20 случайных байт в base32 с префиксом fmk_, по которому ключ легко
+опознать, например в логах или при поиске утечек. Как и токены, ключ
+хранится только в виде SHA-256 хеша, а для списка ключей - его начало.
+*/
+func generateAPIKey(key *APIKey) error {
+	randomBytes := make([]byte, 20)
+
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return err
+	}
+
+	key.Plaintext = apiKeyPrefix + base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes)
+	key.Prefix = key.Plaintext[:len(apiKeyPrefix)+6]
+
+	hash := sha256.Sum256([]byte(key.Plaintext))
+	key.Hash = hash[:]
+
+	return nil
+}
+
+func hashAPIKey(plaintext string) []byte {
+	hash := sha256.Sum256([]byte(plaintext))
+	return hash[:]
+}
+
+func (m APIKeyDB) Insert(ctx context.Context, key *APIKey) error {
+	err := generateAPIKey(key)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO api_keys (user_id, name, prefix, hash, scopes, expiry)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING api_key_id, created_at`
+
+	args := []interface{}{key.UserID, key.Name, key.Prefix, key.Hash, pq.Array(key.Scopes), key.Expiry}
+
+	ctx, cancel := startMethod(ctx, m.Timeout, "APIKeyDB", "Insert")
+	defer cancel()
+
+	err = m.DB.QueryRowContext(ctx, query, args...).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: insert or update on table "api_keys" violates foreign key constraint "api_keys_user_id_fkey"`:
+			return ErrUserNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Возвращает ключи пользователя userID или, если он равен 0, все ключи.
+func (m APIKeyDB) GetAll(ctx context.Context, userID int64) ([]*APIKey, error) {
+	query := `
+		SELECT api_key_id, user_id, name, prefix, scopes, expiry, last_used_at, created_at
+		FROM api_keys
+		WHERE user_id = $1 OR $1 = 0
+		ORDER BY api_key_id`
+
+	ctx, cancel := startMethod(ctx, m.Timeout, "APIKeyDB", "GetAll")
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	keys := []*APIKey{}
+
+	for rows.Next() {
+		var key APIKey
+
+		err = rows.Scan(&key.ID,
+			&key.UserID,
+			&key.Name,
+			&key.Prefix,
+			pq.Array(&key.Scopes),
+			&key.Expiry,
+			&key.LastUsedAt,
+			&key.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, &key)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func (m APIKeyDB) Delete(ctx context.Context, id int64) error {
+	query := `
+		DELETE FROM api_keys
+		WHERE api_key_id = $1`
+
+	ctx, cancel := startMethod(ctx, m.Timeout, "APIKeyDB", "Delete")
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	return checkAffectedRows(result)
+}
+
+/*
+Находит владельца действующего ключа и одновременно отмечает время
+последнего использования ключа, чтобы обойтись одним запросом.
+*/
+func (m UserDB) GetForAPIKey(ctx context.Context, plaintext string) (*User, *APIKey, error) {
+	query := `
+		WITH used AS (
+			UPDATE api_keys
+			SET last_used_at = now()
+			WHERE hash = $1 AND (expiry IS NULL OR expiry > now())
+			RETURNING api_key_id, user_id, name, prefix, scopes, expiry, last_used_at, created_at
+		)
+		SELECT
+			k.api_key_id, k.user_id, k.name, k.prefix, k.scopes, k.expiry, k.last_used_at, k.created_at,
+			u.username, u.email, u.password_hash, u.role, u.activated, u.disabled, ` + userPermissionsColumn + `
+		FROM used k
+		INNER JOIN users u ON u.user_id = k.user_id`
+
+	ctx, cancel := startMethod(ctx, m.Timeout, "UserDB", "GetForAPIKey")
+	defer cancel()
+
+	var user User
+	var key APIKey
+
+	err := m.DB.QueryRowContext(ctx, query, hashAPIKey(plaintext)).Scan(
+		&key.ID,
+		&key.UserID,
+		&key.Name,
+		&key.Prefix,
+		pq.Array(&key.Scopes),
+		&key.Expiry,
+		&key.LastUsedAt,
+		&key.CreatedAt,
+		&user.Name,
+		&user.Email,
+		&user.Password.hash,
+		&user.Role,
+		&user.Activated,
+		&user.Disabled,
+		pq.Array((*[]string)(&user.Permissions)),
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, nil, ErrRecordNotFound
+		default:
+			return nil, nil, err
+		}
+	}
+
+	user.ID = key.UserID
+
+	return &user, &key, nil
+}
+
+func (m *MockAPIKeyDB) Insert(ctx context.Context, key *APIKey) error {
+	if !m.userExists(key.UserID) {
+		return ErrUserNotFound
+	}
+
+	err := generateAPIKey(key)
+	if err != nil {
+		return err
+	}
+
+	key.ID = int64(len(m.Keys) + 1)
+	for m.Keys[key.ID] != nil {
+		key.ID++
+	}
+
+	key.CreatedAt = time.Now()
+
+	stored := key.clone()
+	stored.Plaintext = ""
+	m.Keys[key.ID] = stored
+
+	return nil
+}
+
+func (m *MockAPIKeyDB) GetAll(ctx context.Context, userID int64) ([]*APIKey, error) {
+	keys := []*APIKey{}
+
+	for _, key := range m.Keys {
+		if userID == 0 || key.UserID == userID {
+			keys = append(keys, key.clone())
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].ID < keys[j].ID
+	})
+
+	return keys, nil
+}
+
+func (m *MockAPIKeyDB) Delete(ctx context.Context, id int64) error {
+	if _, found := m.Keys[id]; !found {
+		return ErrRecordNotFound
+	}
+
+	delete(m.Keys, id)
+
+	return nil
+}
+
+func (m *MockUserDB) GetForAPIKey(ctx context.Context, plaintext string) (*User, *APIKey, error) {
+	hash := string(hashAPIKey(plaintext))
+
+	for _, key := range m.APIKeys {
+		if string(key.Hash) != hash || (key.Expiry != nil && !key.Expiry.After(time.Now())) {
+			continue
+		}
+
+		for _, user := range m.Users {
+			if user.ID == key.UserID {
+				now := time.Now()
+				key.LastUsedAt = &now
+
+				return withPermissions(user.clone()), key.clone(), nil
+			}
+		}
+	}
+
+	return nil, nil, ErrRecordNotFound
+}
+
+func (m *MockAPIKeyDB) userExists(id int64) bool {
+	for _, user := range m.Users {
+		if user.ID == id {
+			return true
+		}
+	}
+
+	return false
+}
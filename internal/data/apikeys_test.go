@@ -0,0 +1,97 @@
+package data
+
+import (
+	"context"
+	"filmoteka/internal/validator"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateAPIKey(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name  string
+		key   APIKey
+		field string
+	}{
+		{"Valid", APIKey{UserID: 1, Name: "ingestion", Scopes: []string{"movies:read", "movies:write"}}, ""},
+		{"NoScopes", APIKey{UserID: 1, Name: "ingestion"}, "scopes"},
+		{"UnknownScope", APIKey{UserID: 1, Name: "ingestion", Scopes: []string{"movies:publish"}}, "scopes"},
+		{"DuplicateScope", APIKey{UserID: 1, Name: "ingestion", Scopes: []string{"movies:read", "movies:read"}}, "scopes"},
+		{"ExpiredKey", APIKey{UserID: 1, Name: "ingestion", Scopes: []string{"movies:read"}, Expiry: &past}, "expiry"},
+		{"NoName", APIKey{UserID: 1, Scopes: []string{"movies:read"}}, "name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidateAPIKey(v, &tt.key)
+
+			if tt.field == "" && !v.Valid() {
+				t.Errorf("expected no errors, but got %v", v.Errors)
+			}
+
+			if _, found := v.Errors[tt.field]; tt.field != "" && !found {
+				t.Errorf("expected error for %q, but got %v", tt.field, v.Errors)
+			}
+		})
+	}
+}
+
+func TestMockAPIKeyDB(t *testing.T) {
+	models := NewMockModels()
+
+	key := &APIKey{UserID: 2, Name: "ingestion", Scopes: []string{"movies:write"}}
+
+	err := models.APIKeys.Insert(context.Background(), key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.HasPrefix(key.Plaintext, "fmk_") || !strings.HasPrefix(key.Plaintext, key.Prefix) {
+		t.Errorf("unexpected key %q with prefix %q", key.Plaintext, key.Prefix)
+	}
+
+	t.Run("GetForAPIKey", func(t *testing.T) {
+		user, found, err := models.Users.GetForAPIKey(context.Background(), key.Plaintext)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if user.Name != "admin" || found.ID != key.ID || found.LastUsedAt == nil {
+			t.Errorf("unexpected user %q and key %+v", user.Name, found)
+		}
+	})
+
+	t.Run("GetAllHidesPlaintext", func(t *testing.T) {
+		keys, err := models.APIKeys.GetAll(context.Background(), 2)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(keys) != 1 || keys[0].Plaintext != "" {
+			t.Errorf("expected one key without plaintext, but got %+v", keys)
+		}
+	})
+
+	t.Run("UnknownUser", func(t *testing.T) {
+		err := models.APIKeys.Insert(context.Background(), &APIKey{UserID: 42, Name: "orphan", Scopes: []string{"movies:read"}})
+		if err != ErrUserNotFound {
+			t.Errorf("expected error %v, but got %v", ErrUserNotFound, err)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		err := models.APIKeys.Delete(context.Background(), key.ID)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		_, _, err = models.Users.GetForAPIKey(context.Background(), key.Plaintext)
+		if err != ErrRecordNotFound {
+			t.Errorf("expected error %v, but got %v", ErrRecordNotFound, err)
+		}
+	})
+}
@@ -1,17 +1,73 @@
 package data
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"filmoteka/internal/validator"
+	"math"
 	"strings"
 )
 
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
 type Filters struct {
+	Page         int
+	PageSize     int
+	Cursor       string
+	Genres       []string
+	Role         string
 	Sort         string
 	SortSafelist []string
 }
 
+type Metadata struct {
+	CurrentPage  int    `json:"current_page,omitempty"`
+	PageSize     int    `json:"page_size,omitempty"`
+	FirstPage    int    `json:"first_page,omitempty"`
+	LastPage     int    `json:"last_page,omitempty"`
+	TotalRecords int    `json:"total_records,omitempty"`
+	NextCursor   string `json:"next_cursor,omitempty"`
+}
+
+/*
+Курсор хранит значение колонки сортировки и ID последней записи страницы.
+Для клиента он непрозрачен: это base64-строка, которую нужно передать
+в параметре cursor для получения следующей страницы.
+*/
+type cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    int64  `json:"id"`
+}
+
 func ValidateFilters(v *validator.Validator, f Filters) {
+	v.Check(f.Page > 0, "page", "must be greater than zero")
+	v.Check(f.Page <= 10_000_000, "page", "must be a maximum of 10 million")
+	v.Check(f.PageSize > 0, "page_size", "must be greater than zero")
+	v.Check(f.PageSize <= 100, "page_size", "must be a maximum of 100")
+
 	v.Check(validator.In(f.Sort, f.SortSafelist...), "sort", "invalid sort value")
+
+	v.Check(validator.Unique(f.Genres), "genre", "must not contain duplicate values")
+
+	if f.Role != "" {
+		v.Check(validator.In(f.Role, CreditRoles...), "role", "invalid role value")
+	}
+
+	if f.Cursor != "" {
+		v.Check(f.Page == 1, "page", "must not be used together with cursor")
+
+		c, err := decodeCursor(f.Cursor)
+		if err != nil {
+			v.AddError("cursor", "invalid cursor value")
+			return
+		}
+
+		v.Check(c.Sort == f.Sort, "cursor", "does not match sort value")
+	}
 }
 
 func (f Filters) sortColumn() string {
@@ -31,3 +87,105 @@ func (f Filters) sortDirection() string {
 
 	return "ASC"
 }
+
+/*
+Оператор сравнения для keyset-пагинации: при сортировке по убыванию
+следующая страница начинается с меньших значений.
+*/
+func (f Filters) cursorOperator() string {
+	if f.sortDirection() == "DESC" {
+		return "<"
+	}
+
+	return ">"
+}
+
+/*
+Столбец с общим количеством записей для списочных запросов. В режиме
+курсора окно count(*) OVER() не вычисляется, а вместо него выбирается 0,
+который calculateMetadata все равно не использует.
+*/
+func (f Filters) countColumn() string {
+	if f.Cursor != "" {
+		return "0"
+	}
+
+	return "count(*) OVER()"
+}
+
+func (f Filters) limit() int {
+	return f.PageSize
+}
+
+func (f Filters) offset() int {
+	if f.Cursor != "" {
+		return 0
+	}
+
+	return (f.Page - 1) * f.PageSize
+}
+
+func (f Filters) cursor() (*cursor, error) {
+	if f.Cursor == "" {
+		return nil, nil
+	}
+
+	c, err := decodeCursor(f.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Sort != f.Sort {
+		return nil, ErrInvalidCursor
+	}
+
+	return c, nil
+}
+
+func encodeCursor(c cursor) string {
+	js, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(js)
+}
+
+func decodeCursor(s string) (*cursor, error) {
+	js, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c cursor
+
+	err = json.Unmarshal(js, &c)
+	if err != nil || c.ID < 1 {
+		return nil, ErrInvalidCursor
+	}
+
+	return &c, nil
+}
+
+/*
+В режиме курсора общее количество записей не считается: именно на глубоких
+страницах COUNT обходится дороже всего, поэтому возвращаются только
+размер страницы и курсор следующей страницы.
+*/
+func calculateMetadata(totalRecords int, f Filters, nextCursor string) Metadata {
+	if f.Cursor != "" {
+		return Metadata{
+			PageSize:   f.PageSize,
+			NextCursor: nextCursor,
+		}
+	}
+
+	if totalRecords == 0 {
+		return Metadata{}
+	}
+
+	return Metadata{
+		CurrentPage:  f.Page,
+		PageSize:     f.PageSize,
+		FirstPage:    1,
+		LastPage:     int(math.Ceil(float64(totalRecords) / float64(f.PageSize))),
+		TotalRecords: totalRecords,
+		NextCursor:   nextCursor,
+	}
+}
@@ -10,6 +10,8 @@ func TestValidateFilters(t *testing.T) {
 
 	t.Run("ValidSortValue", func(t *testing.T) {
 		f := Filters{
+			Page:         1,
+			PageSize:     20,
 			Sort:         "title",
 			SortSafelist: []string{"title", "year", "rating", "-title", "-year", "-rating"},
 		}
@@ -23,6 +25,8 @@ func TestValidateFilters(t *testing.T) {
 
 	t.Run("InvalidSortValue", func(t *testing.T) {
 		f := Filters{
+			Page:         1,
+			PageSize:     20,
 			Sort:         "invalid",
 			SortSafelist: []string{"title", "year", "rating", "-title", "-year", "-rating"},
 		}
@@ -35,6 +39,64 @@ func TestValidateFilters(t *testing.T) {
 	})
 }
 
+func TestValidateFiltersPagination(t *testing.T) {
+	safelist := []string{"title", "-title"}
+
+	tests := []struct {
+		name    string
+		filters Filters
+		key     string
+	}{
+		{"ZeroPage", Filters{Page: 0, PageSize: 20, Sort: "title", SortSafelist: safelist}, "page"},
+		{"PageTooLarge", Filters{Page: 10_000_001, PageSize: 20, Sort: "title", SortSafelist: safelist}, "page"},
+		{"ZeroPageSize", Filters{Page: 1, PageSize: 0, Sort: "title", SortSafelist: safelist}, "page_size"},
+		{"PageSizeTooLarge", Filters{Page: 1, PageSize: 101, Sort: "title", SortSafelist: safelist}, "page_size"},
+		{"MalformedCursor", Filters{Page: 1, PageSize: 20, Cursor: "???", Sort: "title", SortSafelist: safelist}, "cursor"},
+		{"CursorSortMismatch", Filters{Page: 1, PageSize: 20, Cursor: encodeCursor(cursor{Sort: "-title", Value: "a", ID: 1}), Sort: "title", SortSafelist: safelist}, "cursor"},
+		{"CursorWithPage", Filters{Page: 2, PageSize: 20, Cursor: encodeCursor(cursor{Sort: "title", Value: "a", ID: 1}), Sort: "title", SortSafelist: safelist}, "page"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			v := validator.New()
+
+			ValidateFilters(v, test.filters)
+
+			if _, found := v.Errors[test.key]; !found {
+				t.Errorf("expected error for %q, but got %v", test.key, v.Errors)
+			}
+		})
+	}
+}
+
+func TestCalculateMetadata(t *testing.T) {
+	t.Run("Pages", func(t *testing.T) {
+		metadata := calculateMetadata(45, Filters{Page: 2, PageSize: 20}, "next")
+
+		expected := Metadata{CurrentPage: 2, PageSize: 20, FirstPage: 1, LastPage: 3, TotalRecords: 45, NextCursor: "next"}
+		if metadata != expected {
+			t.Errorf("expected %+v, but got %+v", expected, metadata)
+		}
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		metadata := calculateMetadata(0, Filters{Page: 1, PageSize: 20}, "")
+
+		if metadata != (Metadata{}) {
+			t.Errorf("expected empty metadata, but got %+v", metadata)
+		}
+	})
+
+	t.Run("Cursor", func(t *testing.T) {
+		metadata := calculateMetadata(45, Filters{Page: 1, PageSize: 20, Cursor: "cursor"}, "next")
+
+		expected := Metadata{PageSize: 20, NextCursor: "next"}
+		if metadata != expected {
+			t.Errorf("expected %+v, but got %+v", expected, metadata)
+		}
+	})
+}
+
 func TestSortColumn(t *testing.T) {
 	t.Run("ValidSortColValue", func(t *testing.T) {
 		f := Filters{
@@ -0,0 +1,253 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"filmoteka/internal/validator"
+	"regexp"
+	"slices"
+	"sort"
+	"time"
+)
+
+var (
+	GenreNameRX = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+)
+
+type Genre struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+type GenreModel interface {
+	Insert(ctx context.Context, genre *Genre) error
+	Delete(ctx context.Context, id int64) error
+	Get(ctx context.Context, id int64) (*Genre, error)
+	GetAll(ctx context.Context) ([]*Genre, error)
+	Update(ctx context.Context, genre *Genre) error
+}
+
+type GenreDB struct {
+	DB      Querier
+	Timeout time.Duration
+}
+
+type MockGenreDB struct {
+	Genres map[int64]*Genre
+	Movies map[int64]*Movie
+}
+
+var (
+	ErrGenresNotFound = errors.New("one or more genres do not exist")
+)
+
+func ValidateGenre(v *validator.Validator, genre *Genre) {
+	v.Check(genre.Name != "", "name", "must be provided")
+	v.Check(len(genre.Name) <= 50, "name", "must be no more than 50 bytes long")
+	v.Check(validator.Matches(genre.Name, GenreNameRX), "name", "must contain only lowercase latin letters, digits and hyphens")
+}
+
+func (m GenreDB) Insert(ctx context.Context, genre *Genre) error {
+	query := `
+		INSERT INTO Genres (name)
+		VALUES ($1)
+		RETURNING genre_id`
+
+	ctx, cancel := startMethod(ctx, m.Timeout, "GenreDB", "Insert")
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, genre.Name).Scan(&genre.ID)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "genres_name_key"`:
+			return ErrDuplicateName
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+Удаляет жанр и его связи с фильмами из таблицы Movies_genres,
+но не удаляет сами фильмы.
+*/
+func (m GenreDB) Delete(ctx context.Context, id int64) error {
+	ctx, cancel := startMethod(ctx, m.Timeout, "GenreDB", "Delete")
+	defer cancel()
+
+	query := `
+		DELETE FROM
+			Genres
+		WHERE
+			genre_id = $1`
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	return checkAffectedRows(result)
+}
+
+func (m GenreDB) Get(ctx context.Context, id int64) (*Genre, error) {
+	query := `
+		SELECT genre_id, name
+		FROM Genres
+		WHERE genre_id = $1`
+
+	ctx, cancel := startMethod(ctx, m.Timeout, "GenreDB", "Get")
+	defer cancel()
+
+	var genre Genre
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&genre.ID, &genre.Name)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &genre, nil
+}
+
+func (m GenreDB) GetAll(ctx context.Context) ([]*Genre, error) {
+	query := `
+		SELECT genre_id, name
+		FROM Genres
+		ORDER BY name`
+
+	ctx, cancel := startMethod(ctx, m.Timeout, "GenreDB", "GetAll")
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	genres := []*Genre{}
+
+	for rows.Next() {
+		var genre Genre
+
+		err = rows.Scan(&genre.ID, &genre.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		genres = append(genres, &genre)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return genres, nil
+}
+
+func (m GenreDB) Update(ctx context.Context, genre *Genre) error {
+	query := `
+		UPDATE Genres
+		SET name = $1
+		WHERE genre_id = $2`
+
+	ctx, cancel := startMethod(ctx, m.Timeout, "GenreDB", "Update")
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, genre.Name, genre.ID)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "genres_name_key"`:
+			return ErrDuplicateName
+		default:
+			return err
+		}
+	}
+
+	return checkAffectedRows(result)
+}
+
+func (m *MockGenreDB) Insert(ctx context.Context, genre *Genre) error {
+	for _, existing := range m.Genres {
+		if existing.Name == genre.Name {
+			return ErrDuplicateName
+		}
+	}
+
+	genre.ID = int64(len(m.Genres) + 1)
+	m.Genres[genre.ID] = genre.clone().clone()
+
+	return nil
+}
+
+func (m *MockGenreDB) Delete(ctx context.Context, id int64) error {
+	genre, found := m.Genres[id]
+	if !found {
+		return ErrRecordNotFound
+	}
+
+	for _, movie := range m.Movies {
+		movie.Genres = slices.DeleteFunc(movie.Genres, func(name string) bool {
+			return name == genre.Name
+		})
+	}
+
+	delete(m.Genres, id)
+
+	return nil
+}
+
+func (m *MockGenreDB) Get(ctx context.Context, id int64) (*Genre, error) {
+	genre, found := m.Genres[id]
+	if !found {
+		return nil, ErrRecordNotFound
+	}
+
+	return genre.clone(), nil
+}
+
+func (m *MockGenreDB) GetAll(ctx context.Context) ([]*Genre, error) {
+	genres := []*Genre{}
+
+	for _, genre := range m.Genres {
+		genres = append(genres, genre.clone())
+	}
+
+	sort.Slice(genres, func(i, j int) bool {
+		return genres[i].Name < genres[j].Name
+	})
+
+	return genres, nil
+}
+
+func (m *MockGenreDB) Update(ctx context.Context, genre *Genre) error {
+	if _, found := m.Genres[genre.ID]; !found {
+		return ErrRecordNotFound
+	}
+
+	for _, existing := range m.Genres {
+		if existing.Name == genre.Name && existing.ID != genre.ID {
+			return ErrDuplicateName
+		}
+	}
+
+	oldName := m.Genres[genre.ID].Name
+
+	for _, movie := range m.Movies {
+		if i := slices.Index(movie.Genres, oldName); i >= 0 {
+			movie.Genres = slices.Clone(movie.Genres)
+			movie.Genres[i] = genre.Name
+		}
+	}
+
+	m.Genres[genre.ID] = genre
+
+	return nil
+}
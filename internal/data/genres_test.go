@@ -0,0 +1,145 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGenreDB_Insert(t *testing.T) {
+	mockGenreModel := MockGenreDB{
+		Genres: map[int64]*Genre{
+			1: {ID: 1, Name: "drama"},
+		},
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		genre := &Genre{Name: "comedy"}
+
+		err := mockGenreModel.Insert(context.Background(), genre)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if genre.ID != 2 {
+			t.Errorf("expected ID to be 2, but got %d", genre.ID)
+		}
+	})
+
+	t.Run("Duplicate", func(t *testing.T) {
+		err := mockGenreModel.Insert(context.Background(), &Genre{Name: "drama"})
+		if !errors.Is(err, ErrDuplicateName) {
+			t.Errorf("expected ErrDuplicateName, but got %v", err)
+		}
+	})
+}
+
+func TestGenreDB_Update(t *testing.T) {
+	movies := map[int64]*Movie{
+		1: {ID: 1, Title: "Movie 1", Genres: []string{"comedy", "drama"}},
+	}
+
+	mockGenreModel := MockGenreDB{
+		Genres: map[int64]*Genre{
+			1: {ID: 1, Name: "drama"},
+			2: {ID: 2, Name: "comedy"},
+		},
+		Movies: movies,
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		err := mockGenreModel.Update(context.Background(), &Genre{ID: 1, Name: "melodrama"})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if movies[1].Genres[1] != "melodrama" {
+			t.Errorf("expected movie genre to be renamed, but got %v", movies[1].Genres)
+		}
+	})
+
+	t.Run("Duplicate", func(t *testing.T) {
+		err := mockGenreModel.Update(context.Background(), &Genre{ID: 1, Name: "comedy"})
+		if !errors.Is(err, ErrDuplicateName) {
+			t.Errorf("expected ErrDuplicateName, but got %v", err)
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		err := mockGenreModel.Update(context.Background(), &Genre{ID: 3, Name: "thriller"})
+		if !errors.Is(err, ErrRecordNotFound) {
+			t.Errorf("expected ErrRecordNotFound, but got %v", err)
+		}
+	})
+}
+
+func TestGenreDB_Delete(t *testing.T) {
+	movies := map[int64]*Movie{
+		1: {ID: 1, Title: "Movie 1", Genres: []string{"comedy", "drama"}},
+	}
+
+	mockGenreModel := MockGenreDB{
+		Genres: map[int64]*Genre{
+			1: {ID: 1, Name: "drama"},
+		},
+		Movies: movies,
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		err := mockGenreModel.Delete(context.Background(), 1)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if len(movies[1].Genres) != 1 || movies[1].Genres[0] != "comedy" {
+			t.Errorf("expected genre to be removed from movie, but got %v", movies[1].Genres)
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		err := mockGenreModel.Delete(context.Background(), 1)
+		if !errors.Is(err, ErrRecordNotFound) {
+			t.Errorf("expected ErrRecordNotFound, but got %v", err)
+		}
+	})
+}
+
+func TestMovieDB_Genres(t *testing.T) {
+	models := NewMockModels()
+
+	t.Run("UnknownGenre", func(t *testing.T) {
+		movie := &Movie{
+			Title:       "Movie 2",
+			Description: "Description 2",
+			ReleaseDate: time.Date(2000, 8, 12, 0, 0, 0, 0, time.UTC),
+			Rating:      8.5,
+			Genres:      []string{"horror"},
+		}
+
+		err := models.Movies.Insert(context.Background(), movie)
+		if !errors.Is(err, ErrGenresNotFound) {
+			t.Errorf("expected ErrGenresNotFound, but got %v", err)
+		}
+	})
+
+	t.Run("Filter", func(t *testing.T) {
+		movies, _, err := models.Movies.GetAll(context.Background(), Filters{Page: 1, PageSize: 20, Sort: "-rating", Genres: []string{"drama"}})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if len(movies) != 1 {
+			t.Errorf("expected 1 movie, but got %d", len(movies))
+		}
+
+		movies, _, err = models.Movies.GetAll(context.Background(), Filters{Page: 1, PageSize: 20, Sort: "-rating", Genres: []string{"drama", "comedy"}})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if len(movies) != 0 {
+			t.Errorf("expected 0 movies, but got %d", len(movies))
+		}
+	})
+}
@@ -1,9 +1,13 @@
 package data
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"slices"
 	"time"
+
+	"github.com/lib/pq"
 )
 
 var (
@@ -11,28 +15,144 @@ var (
 	ErrEditConflict   = errors.New("edit conflict")
 )
 
+// Время выполнения одного метода модели, если в Options не задано другое.
+const DefaultQueryTimeout = 3 * time.Second
+
+type Options struct {
+	// Предельное время одного метода модели; более ранний дедлайн контекста сохраняется.
+	QueryTimeout time.Duration
+	// Если не nil, получают каждый запрос, в том числе выполненный в транзакции.
+	Observe QueryObserver
+	Trace   QueryTracer
+}
+
+/*
+Querier - общий интерфейс *sql.DB и *sql.Tx, благодаря которому модели
+работают одинаково как вне транзакции, так и внутри неё.
+*/
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
 type Models struct {
-	Movies MovieModel
-	Actors ActorModel
-	Users  UserModel
+	Movies      MovieModel
+	Actors      ActorModel
+	People      PersonModel
+	Genres      GenreModel
+	Users       UserModel
+	Tokens      TokenModel
+	APIKeys     APIKeyModel
+	RoleChanges RoleChangeModel
+
+	transaction func(ctx context.Context, fn func(tx Models) error) error
+}
+
+// Создает модели поверх пула соединений.
+func NewModels(db *sql.DB, opts Options) Models {
+	models := newModels(opts.querier(db), opts.QueryTimeout)
+
+	models.transaction = func(ctx context.Context, fn func(tx Models) error) error {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+
+		// Откат после успешного Commit ничего не делает, но гарантирует
+		// завершение транзакции при ошибке или панике внутри fn.
+		defer tx.Rollback()
+
+		err = fn(newModels(opts.querier(tx), opts.QueryTimeout))
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	}
+
+	movies := models.Movies.(MovieDB)
+	movies.transaction = func(ctx context.Context, fn func(tx MovieDB) error) error {
+		return models.transaction(ctx, func(tx Models) error {
+			return fn(tx.Movies.(MovieDB))
+		})
+	}
+	models.Movies = movies
+
+	return models
 }
 
-func NewModels(db *sql.DB) Models {
+func newModels(q Querier, timeout time.Duration) Models {
 	return Models{
-		Movies: MovieDB{DB: db},
-		Actors: ActorDB{DB: db},
-		Users:  UserDB{DB: db},
+		Movies:      MovieDB{DB: q, Timeout: timeout},
+		Actors:      ActorDB{DB: q, Timeout: timeout},
+		People:      PersonDB{DB: q, Timeout: timeout},
+		Genres:      GenreDB{DB: q, Timeout: timeout},
+		Users:       UserDB{DB: q, Timeout: timeout},
+		Tokens:      TokenDB{DB: q, Timeout: timeout},
+		APIKeys:     APIKeyDB{DB: q, Timeout: timeout},
+		RoleChanges: RoleChangeDB{DB: q, Timeout: timeout},
+	}
+}
+
+func (opts Options) querier(q Querier) Querier {
+	if opts.Observe == nil && opts.Trace == nil {
+		return q
 	}
+
+	return observedQuerier{q: q, observe: opts.Observe, trace: opts.Trace}
+}
+
+/*
+Ограничивает время метода модели. Контекст запроса отменяется, когда клиент
+отключается, и вместе с ним прерывается запрос к базе.
+*/
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		timeout = DefaultQueryTimeout
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
+
+/*
+Сообщает, прерван ли запрос по истечении времени: database/sql возвращает
+ошибку контекста, а PostgreSQL, получив отмену уже начатого запроса, -
+ошибку query_canceled.
+*/
+func IsQueryTimeout(err error) bool {
+	var pqErr *pq.Error
+
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &pqErr) && pqErr.Code == "57014")
+}
+
+/*
+Выполняет fn в одной транзакции: все изменения, сделанные через переданные
+в fn модели, либо фиксируются вместе, либо откатываются, если fn вернула ошибку.
+Вложенный вызов Transaction выполняется в уже открытой транзакции. Отмена
+ctx откатывает транзакцию.
+*/
+func (m Models) Transaction(ctx context.Context, fn func(tx Models) error) error {
+	if m.transaction == nil {
+		return fn(m)
+	}
+
+	return m.transaction(ctx, fn)
 }
 
 func NewMockModels() Models {
 	movies := make(map[int64]*Movie)
 	actors := make(map[int64]*Actor)
+	genres := make(map[int64]*Genre)
 	users := make(map[string]*User)
+	tokens := make(map[string]*Token)
+	apiKeys := make(map[int64]*APIKey)
+	roleChanges := make(map[int64]*RoleChange)
 
 	hash, _ := GeneratePasswordHash("password123")
-	users["user"] = &User{ID: 1, Name: "user", Password: password{hash: hash}, Role: "user"}
-	users["admin"] = &User{ID: 2, Name: "admin", Password: password{hash: hash}, Role: "admin"}
+	users["user"] = &User{ID: 1, Name: "user", Email: "user@example.com", Password: password{hash: hash}, Role: "user", Activated: true}
+	users["admin"] = &User{ID: 2, Name: "admin", Email: "admin@example.com", Password: password{hash: hash}, Role: "admin", Activated: true}
 
 	actors[1] = &Actor{
 		ID:        1,
@@ -40,6 +160,7 @@ func NewMockModels() Models {
 		Gender:    "male",
 		BirthDate: time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC),
 		Movies:    []int{1},
+		Version:   1,
 	}
 	actors[2] = &Actor{
 		ID:        2,
@@ -47,19 +168,165 @@ func NewMockModels() Models {
 		Gender:    "female",
 		BirthDate: time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC),
 		Movies:    []int{1},
+		Version:   1,
+	}
+
+	actors[3] = &Actor{
+		ID:        3,
+		FullName:  "Mock Director 1",
+		Gender:    "female",
+		BirthDate: time.Date(1970, time.January, 1, 0, 0, 0, 0, time.UTC),
+		Movies:    []int{},
+		Version:   1,
 	}
 
+	genres[1] = &Genre{ID: 1, Name: "drama"}
+	genres[2] = &Genre{ID: 2, Name: "comedy"}
+
 	movies[1] = &Movie{
 		ID:          1,
 		Title:       "Mock Movie 1",
 		ReleaseDate: time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC),
 		Rating:      7.0,
-		Actors:      []int64{1, 2},
+		Actors: []CastMember{
+			{ActorID: 1, Character: "Mock Hero", BillingOrder: 1},
+			{ActorID: 2, Character: "Mock Villain", BillingOrder: 2},
+		},
+		Crew:    []Credit{{PersonID: 3, Role: "director"}},
+		Genres:  []string{"drama"},
+		Version: 1,
 	}
 
-	return Models{
-		Movies: &MockMovieDB{Movies: movies, Actors: actors},
-		Actors: &MockActorDB{Actors: actors},
-		Users:  &MockUserDB{Users: users},
+	models := Models{
+		Movies:      &MockMovieDB{Movies: movies, Actors: actors, Genres: genres},
+		Actors:      &MockActorDB{Actors: actors, Movies: movies},
+		People:      &MockPersonDB{People: actors, Movies: movies},
+		Genres:      &MockGenreDB{Genres: genres, Movies: movies},
+		Users:       &MockUserDB{Users: users, Tokens: tokens, APIKeys: apiKeys},
+		Tokens:      &MockTokenDB{Tokens: tokens},
+		APIKeys:     &MockAPIKeyDB{Keys: apiKeys, Users: users},
+		RoleChanges: &MockRoleChangeDB{Changes: roleChanges},
 	}
+
+	tx := models
+
+	models.transaction = func(ctx context.Context, fn func(tx Models) error) error {
+		moviesSnapshot := snapshot(movies)
+		actorsSnapshot := snapshot(actors)
+		genresSnapshot := snapshot(genres)
+		usersSnapshot := snapshot(users)
+		tokensSnapshot := snapshot(tokens)
+		apiKeysSnapshot := snapshot(apiKeys)
+		roleChangesSnapshot := snapshot(roleChanges)
+
+		err := fn(tx)
+		if err != nil {
+			restore(movies, moviesSnapshot)
+			restore(actors, actorsSnapshot)
+			restore(genres, genresSnapshot)
+			restore(users, usersSnapshot)
+			restore(tokens, tokensSnapshot)
+			restore(apiKeys, apiKeysSnapshot)
+			restore(roleChanges, roleChangesSnapshot)
+
+			return err
+		}
+
+		return nil
+	}
+
+	return models
+}
+
+// Глубокая копия записи мока, чтобы изменения в ней не затрагивали сохраненную.
+type cloner[V any] interface {
+	*V
+	clone() *V
+}
+
+func snapshot[K comparable, V any, P cloner[V]](m map[K]*V) map[K]*V {
+	copies := make(map[K]*V, len(m))
+
+	for key, value := range m {
+		copies[key] = P(value).clone()
+	}
+
+	return copies
+}
+
+// Восстанавливает карту на месте, так как MockMovieDB и MockActorDB разделяют одну карту актеров.
+func restore[K comparable, V any, P cloner[V]](m map[K]*V, copies map[K]*V) {
+	clear(m)
+
+	for key, value := range copies {
+		m[key] = P(value).clone()
+	}
+}
+
+/*
+Моки хранят собственные копии записей и возвращают копии наружу, как база:
+иначе обработчик, изменивший полученную запись до неудачной транзакции,
+изменил бы и хранимую, и откат в моках ничего бы не проверял.
+*/
+func (movie *Movie) clone() *Movie {
+	copied := *movie
+	copied.Actors = slices.Clone(movie.Actors)
+	copied.Crew = slices.Clone(movie.Crew)
+	copied.Genres = slices.Clone(movie.Genres)
+
+	return &copied
+}
+
+func (a *Actor) clone() *Actor {
+	copied := *a
+	copied.Movies = slices.Clone(a.Movies)
+	copied.MovieDetails = slices.Clone(a.MovieDetails)
+
+	return &copied
+}
+
+func (g *Genre) clone() *Genre {
+	copied := *g
+	return &copied
+}
+
+func (u *User) clone() *User {
+	copied := *u
+	copied.Password.hash = slices.Clone(u.Password.hash)
+	copied.Permissions = slices.Clone(u.Permissions)
+
+	return &copied
+}
+
+func (t *Token) clone() *Token {
+	copied := *t
+	copied.Hash = slices.Clone(t.Hash)
+
+	return &copied
+}
+
+func (key *APIKey) clone() *APIKey {
+	copied := *key
+	copied.Hash = slices.Clone(key.Hash)
+	copied.Scopes = slices.Clone(key.Scopes)
+	copied.Expiry = clonePointer(key.Expiry)
+	copied.LastUsedAt = clonePointer(key.LastUsedAt)
+
+	return &copied
+}
+
+func (c *RoleChange) clone() *RoleChange {
+	copied := *c
+	copied.ChangedBy = clonePointer(c.ChangedBy)
+
+	return &copied
+}
+
+func clonePointer[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+
+	copied := *p
+	return &copied
 }
@@ -0,0 +1,207 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+func TestMockModels_Transaction(t *testing.T) {
+	t.Run("Commit", func(t *testing.T) {
+		models := NewMockModels()
+
+		movie := &Movie{
+			Title:       "Movie 2",
+			Description: "Description 2",
+			ReleaseDate: time.Date(2000, 8, 12, 0, 0, 0, 0, time.UTC),
+			Rating:      8.5,
+			Actors:      []CastMember{{ActorID: 1, BillingOrder: 1}},
+		}
+
+		err := models.Transaction(context.Background(), func(tx Models) error {
+			return tx.Movies.Insert(context.Background(), movie)
+		})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if _, err := models.Movies.Get(context.Background(), movie.ID); err != nil {
+			t.Errorf("expected movie to be committed, but got %v", err)
+		}
+	})
+
+	t.Run("Rollback", func(t *testing.T) {
+		models := NewMockModels()
+
+		movie, err := models.Movies.Get(context.Background(), 1)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		updated := *movie
+		updated.Title = "Updated Title"
+
+		errFailed := errors.New("failed")
+
+		err = models.Transaction(context.Background(), func(tx Models) error {
+			if err := tx.Movies.Update(context.Background(), &updated); err != nil {
+				return err
+			}
+
+			if err := tx.Actors.Delete(context.Background(), 2); err != nil {
+				return err
+			}
+
+			return errFailed
+		})
+		if !errors.Is(err, errFailed) {
+			t.Errorf("expected %v, but got %v", errFailed, err)
+		}
+
+		movie, err = models.Movies.Get(context.Background(), 1)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if movie.Title != "Mock Movie 1" {
+			t.Errorf("expected title to be rolled back, but got %q", movie.Title)
+		}
+
+		if _, err := models.Actors.Get(context.Background(), 2); err != nil {
+			t.Errorf("expected actor deletion to be rolled back, but got %v", err)
+		}
+	})
+
+	t.Run("RollbackInPlaceChanges", func(t *testing.T) {
+		models := NewMockModels()
+
+		movie, err := models.Movies.Get(context.Background(), 1)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		movie.Title = "Updated Title"
+		movie.Actors[0].Character = "Updated Character"
+
+		user, err := models.Users.Get(context.Background(), "user")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		user.Role = "admin"
+
+		errFailed := errors.New("failed")
+
+		err = models.Transaction(context.Background(), func(tx Models) error {
+			if err := tx.Movies.Update(context.Background(), movie); err != nil {
+				return err
+			}
+
+			if err := tx.Users.UpdateRoleStatus(context.Background(), user); err != nil {
+				return err
+			}
+
+			return errFailed
+		})
+		if !errors.Is(err, errFailed) {
+			t.Errorf("expected %v, but got %v", errFailed, err)
+		}
+
+		movie, err = models.Movies.Get(context.Background(), 1)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if movie.Title != "Mock Movie 1" || movie.Actors[0].Character != "Mock Hero" || movie.Version != 1 {
+			t.Errorf("expected the movie to be rolled back, but got %+v", movie)
+		}
+
+		user, err = models.Users.Get(context.Background(), "user")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if user.Role != "user" {
+			t.Errorf("expected the role change to be rolled back, but got %q", user.Role)
+		}
+	})
+
+	t.Run("WithoutTransactor", func(t *testing.T) {
+		called := false
+
+		err := Models{}.Transaction(context.Background(), func(tx Models) error {
+			called = true
+			return nil
+		})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if !called {
+			t.Error("expected fn to be called")
+		}
+	})
+}
+
+func TestWithTimeout(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		ctx, cancel := withTimeout(context.Background(), 0)
+		defer cancel()
+
+		deadline, ok := ctx.Deadline()
+		if !ok || time.Until(deadline) > DefaultQueryTimeout {
+			t.Errorf("expected deadline within %v, got %v", DefaultQueryTimeout, time.Until(deadline))
+		}
+	})
+
+	t.Run("EarlierParentDeadline", func(t *testing.T) {
+		parent, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+
+		ctx, cancel := withTimeout(parent, time.Hour)
+		defer cancel()
+
+		parentDeadline, _ := parent.Deadline()
+		if deadline, _ := ctx.Deadline(); !deadline.Equal(parentDeadline) {
+			t.Errorf("expected parent deadline %v, got %v", parentDeadline, deadline)
+		}
+	})
+
+	t.Run("ParentCanceled", func(t *testing.T) {
+		parent, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		ctx, cancel := withTimeout(parent, time.Hour)
+		defer cancel()
+
+		if !errors.Is(ctx.Err(), context.Canceled) {
+			t.Errorf("expected %v, got %v", context.Canceled, ctx.Err())
+		}
+	})
+}
+
+func TestIsQueryTimeout(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"DeadlineExceeded", fmt.Errorf("query: %w", context.DeadlineExceeded), true},
+		{"QueryCanceled", &pq.Error{Code: "57014"}, true},
+		{"OtherPostgresError", &pq.Error{Code: "23505"}, false},
+		{"Canceled", context.Canceled, false},
+		{"Nil", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsQueryTimeout(tt.err); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -7,37 +7,70 @@ import (
 	"errors"
 	"filmoteka/internal/validator"
 	"fmt"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 	"unicode/utf8"
+
+	"github.com/lib/pq"
 )
 
 type Movie struct {
-	ID          int64     `json:"id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description,omitempty"`
-	ReleaseDate time.Time `json:"release_date"` // RFC3339
-	Rating      float32   `json:"rating"`
-	Actors      []int64   `json:"actors"`
+	ID          int64        `json:"id"`
+	Title       string       `json:"title"`
+	Description string       `json:"description,omitempty"`
+	ReleaseDate time.Time    `json:"release_date"` // RFC3339
+	Rating      float32      `json:"rating"`
+	Actors      []CastMember `json:"actors"`
+	Crew        []Credit     `json:"crew"`
+	Genres      []string     `json:"genres"`
+	Version     int32        `json:"version"`
+	Relevance   float32      `json:"relevance,omitempty"`
+	Similarity  float32      `json:"similarity,omitempty"`
+}
+
+// Актер в составе фильма: роль, которую он играет, и место в титрах.
+type CastMember struct {
+	ActorID      int64  `json:"actor_id"`
+	Character    string `json:"character"`
+	BillingOrder int    `json:"billing_order"`
+	Actor        *Actor `json:"actor,omitempty"`
+}
+
+type SearchQuery struct {
+	Text      string
+	Title     string
+	Actor     string
+	Character string
+	Fuzzy     bool
+	Threshold float64
 }
 
 type MovieModel interface {
-	Insert(movie *Movie) error
-	Delete(id int64) error
-	GetAll(filters Filters) ([]*Movie, error)
-	Get(id int64) (*Movie, error)
-	Update(movie Movie) error
-	Search(title, actor string) ([]*Movie, error)
+	Insert(ctx context.Context, movie *Movie) error
+	Delete(ctx context.Context, id int64) error
+	GetAll(ctx context.Context, filters Filters) ([]*Movie, Metadata, error)
+	Get(ctx context.Context, id int64) (*Movie, error)
+	GetByIDs(ctx context.Context, ids []int64) (map[int64]*Movie, error)
+	Update(ctx context.Context, movie *Movie) error
+	Search(ctx context.Context, query SearchQuery) ([]*Movie, error)
+	GetCrew(ctx context.Context, id int64) (map[string][]CrewMember, error)
 }
 
 type MovieDB struct {
-	DB *sql.DB
+	DB      Querier
+	Timeout time.Duration
+
+	// Открывает транзакцию поверх пула; nil, если модель уже работает в транзакции.
+	transaction func(ctx context.Context, fn func(tx MovieDB) error) error
 }
 
 type MockMovieDB struct {
 	Movies map[int64]*Movie
 	Actors map[int64]*Actor
+	Genres map[int64]*Genre
 }
 
 var (
@@ -56,24 +89,134 @@ func ValidateMovie(v *validator.Validator, movie *Movie) {
 	v.Check(movie.Rating >= 0 && movie.Rating <= 10, "rating", "must be between 0 and 10")
 
 	v.Check(len(movie.Actors) >= 1, "actors", "must contain at least one actor")
+
+	actorIDs := make([]string, len(movie.Actors))
+	billingOrders := make([]string, len(movie.Actors))
+	for i, member := range movie.Actors {
+		v.Check(utf8.RuneCountInString(member.Character) <= 200, "actors", "must not contain character names longer than 200 symbols")
+		v.Check(member.BillingOrder > 0, "actors", "must contain only positive billing orders")
+
+		actorIDs[i] = strconv.FormatInt(member.ActorID, 10)
+		billingOrders[i] = strconv.Itoa(member.BillingOrder)
+	}
+	v.Check(validator.Unique(actorIDs), "actors", "must not contain duplicate actors")
+	v.Check(validator.Unique(billingOrders), "actors", "must not contain duplicate billing orders")
+
+	credits := make([]string, len(movie.Crew))
+	for i, credit := range movie.Crew {
+		v.Check(credit.PersonID > 0, "crew", "must contain only positive person IDs")
+		v.Check(credit.Role != "actor", "crew", "must not contain actor roles, use the actors field instead")
+		v.Check(validator.In(credit.Role, CreditRoles...), "crew", "must contain only valid roles")
+
+		credits[i] = fmt.Sprintf("%d:%s", credit.PersonID, credit.Role)
+	}
+	v.Check(validator.Unique(credits), "crew", "must not contain duplicate credits")
+
+	v.Check(validator.Unique(movie.Genres), "genres", "must not contain duplicate values")
+	for _, genre := range movie.Genres {
+		v.Check(len(genre) <= 50, "genres", "must not contain names longer than 50 bytes")
+	}
+}
+
+/*
+Актерам без billing_order присваивается их позиция в переданном списке,
+а если это место уже занято явно указанным порядком, то ближайшее
+следующее свободное. После этого состав упорядочивается по месту в титрах.
+*/
+func NormalizeCast(cast []CastMember) {
+	taken := make(map[int]bool, len(cast))
+	for _, member := range cast {
+		taken[member.BillingOrder] = true
+	}
+
+	for i := range cast {
+		if cast[i].BillingOrder != 0 {
+			continue
+		}
+
+		order := i + 1
+		for taken[order] {
+			order++
+		}
+
+		cast[i].BillingOrder = order
+		taken[order] = true
+	}
+
+	sort.SliceStable(cast, func(i, j int) bool {
+		return cast[i].BillingOrder < cast[j].BillingOrder
+	})
+}
+
+// Для обратной совместимости актер может быть передан просто своим ID.
+func (c *CastMember) UnmarshalJSON(js []byte) error {
+	var id int64
+	if err := json.Unmarshal(js, &id); err == nil {
+		*c = CastMember{ActorID: id}
+		return nil
+	}
+
+	type castMember CastMember
+
+	return json.Unmarshal(js, (*castMember)(c))
+}
+
+/*
+Актеры фильма в порядке титров. Собираются коррелированным подзапросом, а не
+соединением с Credits, чтобы фильмы без актеров тоже попадали в выборки.
+*/
+const movieActorsColumn = `COALESCE((
+				SELECT json_agg(json_build_object(
+					'actor_id', ma.person_id,
+					'character', ma.character,
+					'billing_order', ma.billing_order) ORDER BY ma.billing_order, ma.person_id)
+				FROM Credits ma
+				WHERE ma.movie_id = m.movie_id AND ma.role = 'actor'), '[]')`
+
+// Съемочная группа без актеров, которые возвращаются отдельно в поле actors.
+const movieCrewColumn = `COALESCE((
+				SELECT json_agg(json_build_object('person_id', c.person_id, 'role', c.role) ORDER BY c.role, c.person_id)
+				FROM Credits c
+				WHERE c.movie_id = m.movie_id AND c.role <> 'actor'), '[]')`
+
+// Жанры фильма собираются коррелированным подзапросом, чтобы не размножать строки соединения с актерами.
+const movieGenresColumn = `COALESCE((
+				SELECT json_agg(g.name ORDER BY g.name)
+				FROM Movies_genres mg
+				JOIN Genres g ON mg.genre_id = g.genre_id
+				WHERE mg.movie_id = m.movie_id), '[]')`
+
+func ValidateSearchQuery(v *validator.Validator, query SearchQuery) {
+	v.Check(utf8.RuneCountInString(query.Text) <= 200, "q", "must be no more than 200 symbols")
+	v.Check(utf8.RuneCountInString(query.Title) <= 150, "title", "must be no more than 150 symbols")
+	v.Check(utf8.RuneCountInString(query.Actor) <= 200, "actor", "must be no more than 200 symbols")
+	v.Check(utf8.RuneCountInString(query.Character) <= 200, "character", "must be no more than 200 symbols")
+
+	if query.Fuzzy && (query.Title != "" || query.Actor != "" || query.Character != "") {
+		v.Check(query.Threshold > 0 && query.Threshold <= 1, "threshold", "must be greater than 0 and no more than 1")
+	}
 }
 
-func (m MovieDB) Insert(movie *Movie) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (m MovieDB) Insert(ctx context.Context, movie *Movie) error {
+	ctx, cancel := startMethod(ctx, m.Timeout, "MovieDB", "Insert")
 	defer cancel()
 
-	if err := checkActorsExistence(m.DB, movie.Actors); err != nil {
+	if err := checkPeopleExistence(ctx, m.DB, movie.actorIDs(), ErrActorsNotFound); err != nil {
+		return err
+	}
+
+	if err := checkPeopleExistence(ctx, m.DB, movie.crewIDs(), ErrCrewNotFound); err != nil {
 		return err
 	}
 
 	query := `
 		INSERT INTO movies (title, description, release_date, rating)
 		VALUES ($1, $2, $3, $4)
-		RETURNING movie_id`
+		RETURNING movie_id, version`
 
 	args := []interface{}{movie.Title, movie.Description, movie.ReleaseDate, movie.Rating}
 
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.ID)
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.ID, &movie.Version)
 	if err != nil {
 		switch {
 		case err.Error() == `pq: duplicate key value violates unique constraint "movies_title_key"`:
@@ -83,28 +226,16 @@ func (m MovieDB) Insert(movie *Movie) error {
 		}
 	}
 
-	query = `
-		INSERT INTO movies_actors (movie_id, actor_id)
-		VALUES ($1, $2)`
-
-	stmt, err := m.DB.PrepareContext(ctx, query)
+	err = insertMovieCredits(ctx, m.DB, movie)
 	if err != nil {
 		return err
 	}
-	defer stmt.Close()
 
-	for _, actorID := range movie.Actors {
-		_, err := stmt.ExecContext(ctx, movie.ID, actorID)
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return insertMovieGenres(ctx, m.DB, movie)
 }
 
-func (m MovieDB) Delete(id int64) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (m MovieDB) Delete(ctx context.Context, id int64) error {
+	ctx, cancel := startMethod(ctx, m.Timeout, "MovieDB", "Delete")
 	defer cancel()
 
 	query := `
@@ -125,73 +256,138 @@ func (m MovieDB) Delete(id int64) error {
 	return nil
 }
 
-func (m MovieDB) GetAll(filters Filters) ([]*Movie, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (m MovieDB) GetAll(ctx context.Context, filters Filters) ([]*Movie, Metadata, error) {
+	ctx, cancel := startMethod(ctx, m.Timeout, "MovieDB", "GetAll")
 	defer cancel()
 
+	c, err := filters.cursor()
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	args := []interface{}{filters.limit() + 1, filters.offset()}
+
+	var conditions []string
+
+	if c != nil {
+		args = append(args, c.Value, c.ID)
+		conditions = append(conditions, fmt.Sprintf("(%s, m.movie_id) %s ($%d, $%d)", filters.sortColumn(), filters.cursorOperator(), len(args)-1, len(args)))
+	}
+
+	if len(filters.Genres) > 0 {
+		args = append(args, pq.Array(filters.Genres))
+		conditions = append(conditions, fmt.Sprintf(`ARRAY(
+				SELECT g.name::text
+				FROM Movies_genres mg
+				JOIN Genres g ON mg.genre_id = g.genre_id
+				WHERE mg.movie_id = m.movie_id) @> $%d`, len(args)))
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
 	query := fmt.Sprintf(`
 			SELECT
+				%s,
 				m.movie_id,
 				title,
 				description,
 				release_date,
 				rating,
-				json_agg(actor_id)
+				version,
+				%s,
+				%s,
+				%s
 			FROM
 				Movies m
-			JOIN
-				Movies_actors ma ON m.movie_id = ma.movie_id
-			GROUP BY
-					m.movie_id,
-					title,
-					description,
-					release_date,
-					rating
+			%s
 			ORDER BY
-				%s %s`, filters.sortColumn(), filters.sortDirection())
+				%s %s, m.movie_id %s
+			LIMIT $1 OFFSET $2`, filters.countColumn(), movieActorsColumn, movieCrewColumn, movieGenresColumn, where, filters.sortColumn(), filters.sortDirection(), filters.sortDirection())
 
-	rows, err := m.DB.QueryContext(ctx, query)
+	rows, err := m.DB.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return nil, Metadata{}, err
 	}
 
 	defer rows.Close()
 
-	movies, err := parseMoviesRows(rows)
-	if err != nil {
-		return nil, err
+	totalRecords := 0
+	movies := []*Movie{}
+
+	for rows.Next() {
+		var movie Movie
+		var actors, crew, genres json.RawMessage
+
+		err := rows.Scan(
+			&totalRecords,
+			&movie.ID,
+			&movie.Title,
+			&movie.Description,
+			&movie.ReleaseDate,
+			&movie.Rating,
+			&movie.Version,
+			&actors,
+			&crew,
+			&genres,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		err = json.Unmarshal(actors, &movie.Actors)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		err = json.Unmarshal(crew, &movie.Crew)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		err = json.Unmarshal(genres, &movie.Genres)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		movies = append(movies, &movie)
 	}
 
-	return movies, nil
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	movies, nextCursor := paginateMovies(movies, filters)
+
+	metadata := calculateMetadata(totalRecords, filters, nextCursor)
+
+	return movies, metadata, nil
 }
 
-func (m MovieDB) Get(id int64) (*Movie, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (m MovieDB) Get(ctx context.Context, id int64) (*Movie, error) {
+	ctx, cancel := startMethod(ctx, m.Timeout, "MovieDB", "Get")
 	defer cancel()
 
-	query := `
+	query := fmt.Sprintf(`
 		SELECT
 			m.movie_id,
 			title,
 			description,
 			release_date,
 			rating,
-			json_agg(actor_id)
+			version,
+			%s,
+			%s,
+			%s
 		FROM
 			Movies m
-		JOIN
-			Movies_actors ma ON m.movie_id = ma.movie_id
-		GROUP BY
-				m.movie_id,
-				title,
-				description,
-				release_date,
-				rating
-		HAVING
-			m.movie_id = $1`
+		WHERE
+			m.movie_id = $1`, movieActorsColumn, movieCrewColumn, movieGenresColumn)
 
 	var movie Movie
-	var actors json.RawMessage
+	var actors, crew, genres json.RawMessage
 
 	err := m.DB.QueryRowContext(ctx, query, id).Scan(
 		&movie.ID,
@@ -199,7 +395,10 @@ func (m MovieDB) Get(id int64) (*Movie, error) {
 		&movie.Description,
 		&movie.ReleaseDate,
 		&movie.Rating,
+		&movie.Version,
 		&actors,
+		&crew,
+		&genres,
 	)
 
 	if err != nil {
@@ -216,25 +415,130 @@ func (m MovieDB) Get(id int64) (*Movie, error) {
 		return nil, err
 	}
 
+	err = json.Unmarshal(crew, &movie.Crew)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(genres, &movie.Genres)
+	if err != nil {
+		return nil, err
+	}
+
 	return &movie, nil
 }
 
-func (m MovieDB) Update(movie Movie) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+/*
+Загружает сразу несколько фильмов одним запросом. Используется для
+?include=movies, чтобы не выполнять отдельный запрос на каждый фильм актера.
+Несуществующие идентификаторы просто отсутствуют в результате.
+*/
+func (m MovieDB) GetByIDs(ctx context.Context, ids []int64) (map[int64]*Movie, error) {
+	movies := make(map[int64]*Movie, len(ids))
+
+	if len(ids) == 0 {
+		return movies, nil
+	}
+
+	ctx, cancel := startMethod(ctx, m.Timeout, "MovieDB", "GetByIDs")
 	defer cancel()
 
-	if err := checkActorsExistence(m.DB, movie.Actors); err != nil {
+	query := fmt.Sprintf(`
+		SELECT
+			m.movie_id,
+			title,
+			description,
+			release_date,
+			rating,
+			version,
+			%s,
+			%s,
+			%s
+		FROM
+			Movies m
+		WHERE
+			m.movie_id = ANY($1)`, movieActorsColumn, movieCrewColumn, movieGenresColumn)
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var movie Movie
+		var actors, crew, genres json.RawMessage
+
+		err = rows.Scan(
+			&movie.ID,
+			&movie.Title,
+			&movie.Description,
+			&movie.ReleaseDate,
+			&movie.Rating,
+			&movie.Version,
+			&actors,
+			&crew,
+			&genres,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		err = json.Unmarshal(actors, &movie.Actors)
+		if err != nil {
+			return nil, err
+		}
+
+		err = json.Unmarshal(crew, &movie.Crew)
+		if err != nil {
+			return nil, err
+		}
+
+		err = json.Unmarshal(genres, &movie.Genres)
+		if err != nil {
+			return nil, err
+		}
+
+		movies[movie.ID] = &movie
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return movies, nil
+}
+
+/*
+Обновление выполняется только если версия фильма в базе совпадает с версией,
+полученной клиентом; иначе фильм уже кто-то изменил и возвращается ErrEditConflict.
+*/
+func (m MovieDB) Update(ctx context.Context, movie *Movie) error {
+	ctx, cancel := startMethod(ctx, m.Timeout, "MovieDB", "Update")
+	defer cancel()
+
+	if err := checkPeopleExistence(ctx, m.DB, movie.actorIDs(), ErrActorsNotFound); err != nil {
+		return err
+	}
+
+	if err := checkPeopleExistence(ctx, m.DB, movie.crewIDs(), ErrCrewNotFound); err != nil {
 		return err
 	}
 
 	query := `
 		UPDATE movies
-		SET title = $1, description = $2, release_date = $3, rating = $4
-		WHERE movie_id = $5`
+		SET title = $1, description = $2, release_date = $3, rating = $4, version = version + 1
+		WHERE movie_id = $5 AND version = $6
+		RETURNING version`
+
+	args := []interface{}{movie.Title, movie.Description, movie.ReleaseDate, movie.Rating, movie.ID, movie.Version}
 
-	result, err := m.DB.ExecContext(ctx, query, movie.Title, movie.Description, movie.ReleaseDate, movie.Rating, movie.ID)
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.Version)
 	if err != nil {
 		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
 		case err.Error() == `pq: duplicate key value violates unique constraint "movies_title_key"`:
 			return ErrDuplicateName
 		default:
@@ -242,15 +546,11 @@ func (m MovieDB) Update(movie Movie) error {
 		}
 	}
 
-	if err = checkAffectedRows(result); err != nil {
-		return err
-	}
-
 	query = `
-		DELETE FROM movies_actors
+		DELETE FROM credits
 		WHERE movie_id = $1`
 
-	result, err = m.DB.ExecContext(ctx, query, movie.ID)
+	result, err := m.DB.ExecContext(ctx, query, movie.ID)
 	if err != nil {
 		return err
 	}
@@ -259,116 +559,197 @@ func (m MovieDB) Update(movie Movie) error {
 		return err
 	}
 
+	err = insertMovieCredits(ctx, m.DB, movie)
+	if err != nil {
+		return err
+	}
+
 	query = `
-		INSERT INTO movies_actors (movie_id, actor_id)
-		VALUES ($1, $2)`
+		DELETE FROM movies_genres
+		WHERE movie_id = $1`
 
-	stmt, err := m.DB.PrepareContext(ctx, query)
+	_, err = m.DB.ExecContext(ctx, query, movie.ID)
 	if err != nil {
 		return err
 	}
-	defer stmt.Close()
 
-	for _, actorID := range movie.Actors {
-		_, err := stmt.ExecContext(ctx, movie.ID, actorID)
-		if err != nil {
-			return err
-		}
+	return insertMovieGenres(ctx, m.DB, movie)
+}
+
+/*
+Полнотекстовый поиск идет по колонке search_vector, которую поддерживают триггеры
+из sql/tables.sql: название имеет наибольший вес, затем описание и имена актеров.
+Запрос разбирается сразу с английской, русской и простой конфигурациями,
+поэтому находятся словоформы на обоих языках.
+
+Название и имя актера в режиме Fuzzy сравниваются по триграммам (pg_trgm)
+и проходят фильтр, если word_similarity не меньше Threshold; иначе ищется
+точное вхождение подстроки без учета регистра. Фильтр записан оператором <%,
+который использует триграммные GIN индексы, а его порог Threshold задается
+через pg_trgm.word_similarity_threshold до конца транзакции. Вне транзакции
+установка порога и поиск могли бы попасть на разные соединения пула, поэтому
+такой поиск сам открывает транзакцию. Функция word_similarity остается
+только для оценки сходства и сортировки.
+*/
+func (m MovieDB) Search(ctx context.Context, query SearchQuery) ([]*Movie, error) {
+	if !query.fuzzyFilter() || m.transaction == nil {
+		return m.search(ctx, query)
 	}
 
-	return nil
+	var movies []*Movie
+
+	err := m.transaction(ctx, func(tx MovieDB) error {
+		var err error
+		movies, err = tx.search(ctx, query)
+		return err
+	})
+
+	return movies, err
 }
 
-func (m MovieDB) Search(title, actor string) ([]*Movie, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+// Сообщает, нужен ли запросу порог pg_trgm.word_similarity_threshold.
+func (q SearchQuery) fuzzyFilter() bool {
+	return q.Fuzzy && (q.Text != "" || q.Title != "" || q.Actor != "" || q.Character != "")
+}
+
+func (m MovieDB) search(ctx context.Context, query SearchQuery) ([]*Movie, error) {
+	ctx, cancel := startMethod(ctx, m.Timeout, "MovieDB", "Search")
 	defer cancel()
 
-	query := `
-		SELECT
-			m.movie_id
-		FROM
-			Movies m
-		JOIN
-			Movies_actors ma ON m.movie_id = ma.movie_id
-		JOIN
-			Actors a ON ma.actor_id = a.actor_id
-		WHERE
-			title ILIKE '%' || $1 || '%'
-		AND
-			a.full_name ILIKE '%' || $2 || '%'
-		GROUP BY
-				m.movie_id`
+	var args []interface{}
 
-	rows, err := m.DB.QueryContext(ctx, query, title, actor)
-	if err != nil {
-		return nil, err
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
 	}
 
-	var moviesIDs []string
+	text := arg(query.Text)
 
-	for rows.Next() {
-		var movieID string
-		err = rows.Scan(&movieID)
+	// В режиме Fuzzy запрос находит и названия, похожие по триграммам, например
+	// The Matrix по слову matrices, которое стеммер приводит к другой основе.
+	textCondition := "m.search_vector @@ query.q"
+	if query.Fuzzy {
+		textCondition = fmt.Sprintf("(m.search_vector @@ query.q OR %s <%% m.title)", text)
+	}
+
+	var titleCondition, actorCondition, characterCondition string
+	titleSimilarity := "0"
+
+	var similarities, castSimilarities []string
 
+	if query.fuzzyFilter() {
+		// Аналог SET LOCAL, в котором значение можно передать параметром.
+		_, err := m.DB.ExecContext(ctx, `SELECT set_config('pg_trgm.word_similarity_threshold', $1, true)`,
+			strconv.FormatFloat(query.Threshold, 'f', -1, 64))
 		if err != nil {
 			return nil, err
 		}
+	}
 
-		moviesIDs = append(moviesIDs, movieID)
+	if query.Fuzzy && query.Title != "" {
+		title := arg(query.Title)
+		titleSimilarity = fmt.Sprintf("word_similarity(%s, m.title)", title)
+		titleCondition = fmt.Sprintf("%s <%% m.title", title)
+		similarities = append(similarities, titleSimilarity)
+	} else {
+		titleCondition = fmt.Sprintf("m.title ILIKE '%%' || %s || '%%'", arg(query.Title))
 	}
 
-	if err = rows.Err(); err != nil {
-		return nil, err
+	if query.Fuzzy && query.Actor != "" {
+		actor := arg(query.Actor)
+		actorSimilarity := fmt.Sprintf("word_similarity(%s, a.full_name)", actor)
+		actorCondition = fmt.Sprintf("%s <%% a.full_name", actor)
+		castSimilarities = append(castSimilarities, actorSimilarity)
+	} else {
+		actorCondition = fmt.Sprintf("a.full_name ILIKE '%%' || %s || '%%'", arg(query.Actor))
+	}
+
+	if query.Fuzzy && query.Character != "" {
+		character := arg(query.Character)
+		characterSimilarity := fmt.Sprintf("word_similarity(%s, ma.character)", character)
+		characterCondition = fmt.Sprintf("%s <%% ma.character", character)
+		castSimilarities = append(castSimilarities, characterSimilarity)
+	} else {
+		characterCondition = fmt.Sprintf("ma.character ILIKE '%%' || %s || '%%'", arg(query.Character))
+	}
+
+	castSimilarity := "0"
+	if len(castSimilarities) > 0 {
+		castSimilarity = fmt.Sprintf("(%s) / %d", strings.Join(castSimilarities, " + "), len(castSimilarities))
+		similarities = append(similarities, "cm.similarity")
 	}
 
-	rows.Close()
+	// Без фильтра по составу подходят и фильмы без актеров.
+	castCondition := "TRUE"
+	if query.Actor != "" || query.Character != "" {
+		castCondition = "cm.movie_id IS NOT NULL"
+	}
 
-	if len(moviesIDs) == 0 {
-		return []*Movie{}, nil
+	similarity := "0"
+	if len(similarities) > 0 {
+		similarity = fmt.Sprintf("(%s) / %d", strings.Join(similarities, " + "), len(similarities))
 	}
 
-	query = fmt.Sprintf(`
+	stmt := fmt.Sprintf(`
+		WITH query AS (
+			SELECT
+				websearch_to_tsquery('english', %[1]s) ||
+				websearch_to_tsquery('russian', %[1]s) ||
+				websearch_to_tsquery('simple', %[1]s) AS q
+		),
+		cast_match AS (
+			SELECT
+				ma.movie_id,
+				max(%[2]s) AS similarity
+			FROM
+				Credits ma
+			JOIN
+				People a ON ma.person_id = a.person_id
+			WHERE
+				ma.role = 'actor' AND %[3]s AND %[8]s
+			GROUP BY
+				ma.movie_id
+		)
 		SELECT
 			m.movie_id,
-			title,
-			description,
-			release_date,
-			rating,
-			json_agg(actor_id)
+			m.title,
+			m.description,
+			m.release_date,
+			m.rating,
+			m.version,
+			%[11]s,
+			%[7]s,
+			%[6]s,
+			CASE WHEN %[1]s = '' THEN 0 ELSE ts_rank(m.search_vector, query.q) END AS relevance,
+			%[4]s AS similarity
 		FROM
 			Movies m
-		JOIN
-			Movies_actors ma ON m.movie_id = ma.movie_id
-		GROUP BY
-				m.movie_id,
-				title,
-				description,
-				release_date,
-				rating
-		HAVING
-			m.movie_id IN (%s)`, strings.Join(moviesIDs, ","))
+		LEFT JOIN
+			cast_match cm ON m.movie_id = cm.movie_id
+		CROSS JOIN
+			query
+		WHERE
+			(%[1]s = '' OR %[9]s)
+		AND
+			%[5]s
+		AND
+			%[10]s
+		ORDER BY
+			similarity DESC, relevance DESC, m.rating DESC, m.movie_id`,
+		text, castSimilarity, actorCondition, similarity, titleCondition, movieGenresColumn, movieCrewColumn, characterCondition, textCondition, castCondition, movieActorsColumn)
 
-	rows, err = m.DB.QueryContext(ctx, query)
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
 	if err != nil {
 		return nil, err
 	}
 
 	defer rows.Close()
 
-	movies, err := parseMoviesRows(rows)
-	if err != nil {
-		return nil, err
-	}
-
-	return movies, nil
-}
-
-func parseMoviesRows(rows *sql.Rows) ([]*Movie, error) {
-	var movies []*Movie
+	movies := []*Movie{}
 
 	for rows.Next() {
 		var movie Movie
-		var actors json.RawMessage
+		var actors, crew, genres json.RawMessage
 
 		err := rows.Scan(
 			&movie.ID,
@@ -376,9 +757,13 @@ func parseMoviesRows(rows *sql.Rows) ([]*Movie, error) {
 			&movie.Description,
 			&movie.ReleaseDate,
 			&movie.Rating,
+			&movie.Version,
 			&actors,
+			&crew,
+			&genres,
+			&movie.Relevance,
+			&movie.Similarity,
 		)
-
 		if err != nil {
 			return nil, err
 		}
@@ -388,42 +773,205 @@ func parseMoviesRows(rows *sql.Rows) ([]*Movie, error) {
 			return nil, err
 		}
 
+		err = json.Unmarshal(crew, &movie.Crew)
+		if err != nil {
+			return nil, err
+		}
+
+		err = json.Unmarshal(genres, &movie.Genres)
+		if err != nil {
+			return nil, err
+		}
+
 		movies = append(movies, &movie)
 	}
 
-	if err := rows.Err(); err != nil {
+	if err = rows.Err(); err != nil {
 		return nil, err
 	}
 
 	return movies, nil
 }
 
-func checkActorsExistence(db *sql.DB, actors []int64) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+/*
+Возвращает всех участников фильма, включая актеров, сгруппированных по ролям.
+LEFT JOIN нужен, чтобы отличить фильм без участников от несуществующего фильма.
+*/
+func (m MovieDB) GetCrew(ctx context.Context, id int64) (map[string][]CrewMember, error) {
+	ctx, cancel := startMethod(ctx, m.Timeout, "MovieDB", "GetCrew")
 	defer cancel()
 
-	for _, actorID := range actors {
-		query := `
-			SELECT actor_id
-			FROM actors
-			WHERE actor_id = $1
-			`
+	query := `
+		SELECT
+			c.role, p.person_id, p.full_name
+		FROM
+			Movies m
+		LEFT JOIN
+			Credits c ON m.movie_id = c.movie_id
+		LEFT JOIN
+			People p ON c.person_id = p.person_id
+		WHERE
+			m.movie_id = $1
+		ORDER BY
+			c.role, p.full_name`
+
+	rows, err := m.DB.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	found := false
+	crew := make(map[string][]CrewMember)
+
+	for rows.Next() {
+		found = true
+
+		var role, fullName sql.NullString
+		var personID sql.NullInt64
 
-		var result int
-		err := db.QueryRowContext(ctx, query, actorID).Scan(&result)
+		err = rows.Scan(&role, &personID, &fullName)
 		if err != nil {
-			switch {
-			case errors.Is(err, sql.ErrNoRows):
-				return ErrActorsNotFound
-			default:
-				return err
-			}
+			return nil, err
 		}
+
+		if !role.Valid {
+			continue
+		}
+
+		crew[role.String] = append(crew[role.String], CrewMember{ID: personID.Int64, FullName: fullName.String})
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if !found {
+		return nil, ErrRecordNotFound
+	}
+
+	return crew, nil
+}
+
+/*
+Запрос выбирает на одну запись больше размера страницы: если она есть,
+лишняя запись отбрасывается, а по последней оставшейся строится курсор.
+*/
+func paginateMovies(movies []*Movie, filters Filters) ([]*Movie, string) {
+	if len(movies) <= filters.limit() {
+		return movies, ""
+	}
+
+	movies = movies[:filters.limit()]
+	last := movies[len(movies)-1]
+
+	var value string
+
+	switch strings.TrimPrefix(filters.Sort, "-") {
+	case "title":
+		value = last.Title
+	case "release_date":
+		value = last.ReleaseDate.Format("2006-01-02")
+	default:
+		value = strconv.FormatFloat(float64(last.Rating), 'f', -1, 32)
+	}
+
+	return movies, encodeCursor(cursor{Sort: filters.Sort, Value: value, ID: last.ID})
+}
+
+/*
+Связывает фильм с жанрами по их названиям. Если какого-то жанра нет,
+вставится меньше строк, чем передано названий, и вернется ErrGenresNotFound.
+*/
+func insertMovieGenres(ctx context.Context, db Querier, movie *Movie) error {
+	if len(movie.Genres) == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO movies_genres (movie_id, genre_id)
+		SELECT $1, genre_id
+		FROM genres
+		WHERE name = ANY($2)`
+
+	result, err := db.ExecContext(ctx, query, movie.ID, pq.Array(movie.Genres))
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected != int64(len(movie.Genres)) {
+		return ErrGenresNotFound
 	}
 
 	return nil
 }
 
+/*
+Записывает актеров и съемочную группу фильма в таблицу Credits одним
+запросом. Актеры хранятся там же с ролью actor.
+*/
+func insertMovieCredits(ctx context.Context, db Querier, movie *Movie) error {
+	count := len(movie.Actors) + len(movie.Crew)
+	if count == 0 {
+		return nil
+	}
+
+	people := make([]int64, 0, count)
+	roles := make([]string, 0, count)
+	characters := make([]string, 0, count)
+	billingOrders := make([]int64, 0, count)
+
+	for _, member := range movie.Actors {
+		people = append(people, member.ActorID)
+		roles = append(roles, "actor")
+		characters = append(characters, member.Character)
+		billingOrders = append(billingOrders, int64(member.BillingOrder))
+	}
+
+	for _, credit := range movie.Crew {
+		people = append(people, credit.PersonID)
+		roles = append(roles, credit.Role)
+		characters = append(characters, "")
+		billingOrders = append(billingOrders, 0)
+	}
+
+	query := `
+		INSERT INTO credits (movie_id, person_id, role, character, billing_order)
+		SELECT $1, c.person_id, c.role::credit_role, c.character, c.billing_order
+		FROM unnest($2::int[], $3::text[], $4::text[], $5::int[])
+			AS c(person_id, role, character, billing_order)`
+
+	_, err := db.ExecContext(ctx, query, movie.ID, pq.Array(people), pq.Array(roles), pq.Array(characters), pq.Array(billingOrders))
+
+	return err
+}
+
+func (movie *Movie) actorIDs() []int64 {
+	ids := make([]int64, len(movie.Actors))
+
+	for i, member := range movie.Actors {
+		ids[i] = member.ActorID
+	}
+
+	return ids
+}
+
+func (movie *Movie) crewIDs() []int64 {
+	ids := make([]int64, len(movie.Crew))
+
+	for i, credit := range movie.Crew {
+		ids[i] = credit.PersonID
+	}
+
+	return ids
+}
+
 func checkAffectedRows(result sql.Result) error {
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
@@ -437,11 +985,17 @@ func checkAffectedRows(result sql.Result) error {
 	return nil
 }
 
-func (m *MockMovieDB) Insert(movie *Movie) error {
-	for _, actorID := range movie.Actors {
-		if _, found := m.Actors[actorID]; !found {
-			return ErrActorsNotFound
-		}
+func (m *MockMovieDB) Insert(ctx context.Context, movie *Movie) error {
+	if !m.peopleExist(movie.actorIDs()) {
+		return ErrActorsNotFound
+	}
+
+	if !m.peopleExist(movie.crewIDs()) {
+		return ErrCrewNotFound
+	}
+
+	if !m.genresExist(movie.Genres) {
+		return ErrGenresNotFound
 	}
 
 	if m.Movies == nil {
@@ -455,12 +1009,13 @@ func (m *MockMovieDB) Insert(movie *Movie) error {
 	}
 
 	movie.ID = int64(len(m.Movies) + 1)
-	m.Movies[int64(movie.ID)] = movie
+	movie.Version = 1
+	m.Movies[int64(movie.ID)] = movie.clone()
 
 	return nil
 }
 
-func (m *MockMovieDB) Delete(id int64) error {
+func (m *MockMovieDB) Delete(ctx context.Context, id int64) error {
 	_, found := m.Movies[id]
 	if !found {
 		return ErrRecordNotFound
@@ -471,13 +1026,19 @@ func (m *MockMovieDB) Delete(id int64) error {
 	return nil
 }
 
-func (m *MockMovieDB) GetAll(filters Filters) ([]*Movie, error) {
+func (m *MockMovieDB) GetAll(ctx context.Context, filters Filters) ([]*Movie, Metadata, error) {
 	var movies []*Movie
 
 	for _, movie := range m.Movies {
-		movies = append(movies, movie)
+		if hasAllGenres(movie.Genres, filters.Genres) {
+			movies = append(movies, movie.clone())
+		}
 	}
 
+	sort.Slice(movies, func(i, j int) bool {
+		return movies[i].ID < movies[j].ID
+	})
+
 	switch filters.Sort {
 	case "title":
 		sort.SliceStable(movies, func(i, j int) bool {
@@ -505,51 +1066,320 @@ func (m *MockMovieDB) GetAll(filters Filters) ([]*Movie, error) {
 		})
 	}
 
-	return movies, nil
+	c, err := filters.cursor()
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	start := filters.offset()
+	if c != nil {
+		for i, movie := range movies {
+			if movie.ID == c.ID {
+				start = i + 1
+				break
+			}
+		}
+	}
+
+	totalRecords := len(movies)
+	start = min(start, len(movies))
+	end := min(start+filters.limit()+1, len(movies))
+
+	movies, nextCursor := paginateMovies(movies[start:end], filters)
+	if len(movies) == 0 {
+		totalRecords = 0
+	}
+
+	return movies, calculateMetadata(totalRecords, filters, nextCursor), nil
 }
 
-func (m *MockMovieDB) Get(id int64) (*Movie, error) {
+func (m *MockMovieDB) Get(ctx context.Context, id int64) (*Movie, error) {
 	movie, ok := m.Movies[id]
 
 	if !ok {
 		return nil, ErrRecordNotFound
 	}
 
-	return movie, nil
+	return movie.clone(), nil
 }
 
-func (m *MockMovieDB) Update(movie Movie) error {
-	if _, found := m.Movies[movie.ID]; !found {
+func (m *MockMovieDB) GetByIDs(ctx context.Context, ids []int64) (map[int64]*Movie, error) {
+	movies := make(map[int64]*Movie, len(ids))
+
+	for _, id := range ids {
+		if movie, ok := m.Movies[id]; ok {
+			movies[id] = movie.clone()
+		}
+	}
+
+	return movies, nil
+}
+
+func (m *MockMovieDB) Update(ctx context.Context, movie *Movie) error {
+	existing, found := m.Movies[movie.ID]
+	if !found {
 		return ErrRecordNotFound
 	}
 
-	for _, actorID := range movie.Actors {
-		if _, found := m.Actors[actorID]; !found {
-			return ErrActorsNotFound
-		}
+	if !m.peopleExist(movie.actorIDs()) {
+		return ErrActorsNotFound
 	}
 
-	m.Movies[movie.ID] = &movie
+	if !m.peopleExist(movie.crewIDs()) {
+		return ErrCrewNotFound
+	}
+
+	if !m.genresExist(movie.Genres) {
+		return ErrGenresNotFound
+	}
+
+	if existing.Version != movie.Version {
+		return ErrEditConflict
+	}
+
+	updated := movie.clone()
+	updated.Version++
+	m.Movies[movie.ID] = updated
+
+	movie.Version = updated.Version
 
 	return nil
 }
 
-func (m *MockMovieDB) Search(title, actor string) ([]*Movie, error) {
-	var movies []*Movie
+func (m *MockMovieDB) Search(ctx context.Context, query SearchQuery) ([]*Movie, error) {
+	movies := []*Movie{}
 
 	for _, movie := range m.Movies {
-		if strings.Contains(movie.Title, title) {
-			movies = append(movies, movie)
+		var similarities []float64
+
+		titleSimilarity := 0.0
+		if query.Fuzzy && query.Title != "" {
+			titleSimilarity = wordSimilarity(query.Title, movie.Title)
+			if titleSimilarity < query.Threshold {
+				continue
+			}
+
+			similarities = append(similarities, titleSimilarity)
+		} else if !containsFold(movie.Title, query.Title) {
 			continue
 		}
 
-		for _, actorID := range movie.Actors {
-			if strings.Contains(m.Actors[actorID].FullName, actor) {
-				movies = append(movies, movie)
-				break
+		var cast []string
+		castFound := query.Actor == "" && query.Character == ""
+		castSimilarity := 0.0
+
+		for _, member := range movie.Actors {
+			actor, found := m.Actors[member.ActorID]
+			if !found {
+				continue
+			}
+
+			cast = append(cast, actor.FullName)
+
+			actorMatch, actorSimilarity := m.matchCast(query, query.Actor, actor.FullName)
+			characterMatch, characterSimilarity := m.matchCast(query, query.Character, member.Character)
+
+			if actorMatch && characterMatch {
+				castFound = true
+				castSimilarity = max(castSimilarity, actorSimilarity+characterSimilarity)
+			}
+		}
+
+		if !castFound {
+			continue
+		}
+
+		for _, credit := range movie.Crew {
+			if person, found := m.Actors[credit.PersonID]; found {
+				cast = append(cast, person.FullName)
+			}
+		}
+
+		fuzzyCast := 0
+		for _, value := range []string{query.Actor, query.Character} {
+			if query.Fuzzy && value != "" {
+				fuzzyCast++
 			}
 		}
+
+		if fuzzyCast > 0 {
+			similarities = append(similarities, castSimilarity/float64(fuzzyCast))
+		}
+
+		result := *movie.clone()
+		result.Relevance = 0
+		result.Similarity = 0
+
+		for _, similarity := range similarities {
+			result.Similarity += float32(similarity) / float32(len(similarities))
+		}
+
+		for _, word := range strings.Fields(query.Text) {
+			switch {
+			case containsFold(movie.Title, word):
+				result.Relevance += 1
+			case containsFold(movie.Description, word):
+				result.Relevance += 0.4
+			case containsFold(strings.Join(cast, " "), word):
+				result.Relevance += 0.2
+			}
+		}
+
+		textMatch := result.Relevance > 0 || query.Fuzzy && wordSimilarity(query.Text, movie.Title) >= query.Threshold
+		if query.Text != "" && !textMatch {
+			continue
+		}
+
+		movies = append(movies, &result)
 	}
 
+	sort.Slice(movies, func(i, j int) bool {
+		if movies[i].Similarity != movies[j].Similarity {
+			return movies[i].Similarity > movies[j].Similarity
+		}
+
+		if movies[i].Relevance != movies[j].Relevance {
+			return movies[i].Relevance > movies[j].Relevance
+		}
+
+		return movies[i].ID < movies[j].ID
+	})
+
 	return movies, nil
 }
+
+/*
+Сравнивает значение из состава фильма с параметром поиска так же, как MovieDB.Search:
+по сходству в режиме Fuzzy или по вхождению подстроки иначе. Пустой параметр
+совпадает с любым значением и не влияет на сходство.
+*/
+func (m *MockMovieDB) matchCast(query SearchQuery, param, value string) (bool, float64) {
+	if query.Fuzzy && param != "" {
+		similarity := wordSimilarity(param, value)
+		return similarity >= query.Threshold, similarity
+	}
+
+	return containsFold(value, param), 0
+}
+
+func (m *MockMovieDB) GetCrew(ctx context.Context, id int64) (map[string][]CrewMember, error) {
+	movie, found := m.Movies[id]
+	if !found {
+		return nil, ErrRecordNotFound
+	}
+
+	crew := make(map[string][]CrewMember)
+
+	for _, personID := range append(movie.actorIDs(), movie.crewIDs()...) {
+		person, found := m.Actors[personID]
+		if !found {
+			continue
+		}
+
+		for _, role := range movieRoles(movie, personID) {
+			if !slices.ContainsFunc(crew[role], func(c CrewMember) bool { return c.ID == personID }) {
+				crew[role] = append(crew[role], CrewMember{ID: person.ID, FullName: person.FullName})
+			}
+		}
+	}
+
+	for _, members := range crew {
+		sort.Slice(members, func(i, j int) bool {
+			return members[i].FullName < members[j].FullName
+		})
+	}
+
+	return crew, nil
+}
+
+func (m *MockMovieDB) peopleExist(ids []int64) bool {
+	for _, id := range ids {
+		if _, found := m.Actors[id]; !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (m *MockMovieDB) genresExist(names []string) bool {
+	for _, name := range names {
+		found := false
+
+		for _, genre := range m.Genres {
+			if genre.Name == name {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+func hasAllGenres(genres, required []string) bool {
+	for _, name := range required {
+		if !slices.Contains(genres, name) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+/*
+Приближение word_similarity из pg_trgm для моков: наибольшее триграммное сходство
+строки запроса с любой последовательностью подряд идущих слов текста той же длины.
+*/
+func wordSimilarity(query, text string) float64 {
+	queryWords := strings.Fields(strings.ToLower(query))
+	textWords := strings.Fields(strings.ToLower(text))
+
+	if len(queryWords) == 0 || len(textWords) == 0 {
+		return 0
+	}
+
+	queryTrigrams := trigrams(queryWords)
+	best := 0.0
+
+	for i := 0; i+len(queryWords) <= len(textWords) || i == 0; i++ {
+		window := textWords[i:min(i+len(queryWords), len(textWords))]
+		best = max(best, jaccard(queryTrigrams, trigrams(window)))
+	}
+
+	return best
+}
+
+func trigrams(words []string) map[string]bool {
+	set := make(map[string]bool)
+
+	for _, word := range words {
+		padded := []rune("  " + word + " ")
+
+		for i := 0; i+3 <= len(padded); i++ {
+			set[string(padded[i:i+3])] = true
+		}
+	}
+
+	return set
+}
+
+func jaccard(a, b map[string]bool) float64 {
+	shared := 0
+
+	for trigram := range a {
+		if b[trigram] {
+			shared++
+		}
+	}
+
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
@@ -1,7 +1,14 @@
 package data
 
 import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"filmoteka/internal/validator"
+	"os"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 )
@@ -34,7 +41,7 @@ func TestMockMovieDB_Insert(t *testing.T) {
 		Description: "Description 1",
 		Rating:      8.0,
 		ReleaseDate: time.Date(2021, 8, 12, 0, 0, 0, 0, time.UTC),
-		Actors:      []int64{1, 2},
+		Actors:      []CastMember{{ActorID: 1, BillingOrder: 1}, {ActorID: 2, BillingOrder: 2}},
 	}
 
 	mockModel.Movies[movie.ID] = movie
@@ -45,10 +52,10 @@ func TestMockMovieDB_Insert(t *testing.T) {
 			Description: "Description 2",
 			Rating:      8.5,
 			ReleaseDate: time.Date(2000, 8, 12, 0, 0, 0, 0, time.UTC),
-			Actors:      []int64{1},
+			Actors:      []CastMember{{ActorID: 1, BillingOrder: 1}},
 		}
 
-		err := mockModel.Insert(movie)
+		err := mockModel.Insert(context.Background(), movie)
 		if err != nil {
 			t.Errorf("unexpected error: %v", err)
 		}
@@ -64,10 +71,10 @@ func TestMockMovieDB_Insert(t *testing.T) {
 			Description: "Description 1",
 			Rating:      8.0,
 			ReleaseDate: time.Date(2021, 8, 12, 0, 0, 0, 0, time.UTC),
-			Actors:      []int64{1, 2},
+			Actors:      []CastMember{{ActorID: 1, BillingOrder: 1}, {ActorID: 2, BillingOrder: 2}},
 		}
 
-		err := mockModel.Insert(movie)
+		err := mockModel.Insert(context.Background(), movie)
 		if err == nil {
 			t.Error("expected error, but got nil")
 		}
@@ -79,10 +86,10 @@ func TestMockMovieDB_Insert(t *testing.T) {
 			Description: "Description 3",
 			Rating:      7.8,
 			ReleaseDate: time.Date(2015, 8, 12, 0, 0, 0, 0, time.UTC),
-			Actors:      []int64{3},
+			Actors:      []CastMember{{ActorID: 3, BillingOrder: 1}},
 		}
 
-		err := mockModel.Insert(movie)
+		err := mockModel.Insert(context.Background(), movie)
 		if err == nil {
 			t.Error("expected error, but got nil")
 		}
@@ -117,13 +124,13 @@ func TestMockMovieDB_Get(t *testing.T) {
 		Description: "Description 1",
 		Rating:      8.0,
 		ReleaseDate: time.Date(2021, 8, 12, 0, 0, 0, 0, time.UTC),
-		Actors:      []int64{1, 2},
+		Actors:      []CastMember{{ActorID: 1, BillingOrder: 1}, {ActorID: 2, BillingOrder: 2}},
 	}
 
 	mockModel.Movies[movie.ID] = movie
 
 	t.Run("Valid", func(t *testing.T) {
-		m, err := mockModel.Get(movie.ID)
+		m, err := mockModel.Get(context.Background(), movie.ID)
 		if err != nil {
 			t.Errorf("unexpected error: %v", err)
 		}
@@ -134,7 +141,7 @@ func TestMockMovieDB_Get(t *testing.T) {
 	})
 
 	t.Run("InvalidID", func(t *testing.T) {
-		m, err := mockModel.Get(2)
+		m, err := mockModel.Get(context.Background(), 2)
 		if err != ErrRecordNotFound {
 			t.Error("expected ErrRecordNotFound, but got nil")
 		}
@@ -173,7 +180,7 @@ func TestMockMovieDB_GetAll(t *testing.T) {
 		Description: "Description 1",
 		ReleaseDate: time.Date(2021, 8, 12, 0, 0, 0, 0, time.UTC),
 		Rating:      8.0,
-		Actors:      []int64{1, 2},
+		Actors:      []CastMember{{ActorID: 1, BillingOrder: 1}, {ActorID: 2, BillingOrder: 2}},
 	}
 
 	mockModel.Movies[movie.ID] = movie
@@ -184,13 +191,13 @@ func TestMockMovieDB_GetAll(t *testing.T) {
 		Description: "Description 2",
 		ReleaseDate: time.Date(2000, 8, 12, 0, 0, 0, 0, time.UTC),
 		Rating:      8.5,
-		Actors:      []int64{1, 2},
+		Actors:      []CastMember{{ActorID: 1, BillingOrder: 1}, {ActorID: 2, BillingOrder: 2}},
 	}
 
 	mockModel.Movies[movie.ID] = movie
 
 	t.Run("ValidDefault", func(t *testing.T) {
-		movies, err := mockModel.GetAll(Filters{})
+		movies, _, err := mockModel.GetAll(context.Background(), Filters{Page: 1, PageSize: 20})
 		if err != nil {
 			t.Errorf("unexpected error: %v", err)
 		}
@@ -201,7 +208,7 @@ func TestMockMovieDB_GetAll(t *testing.T) {
 	})
 
 	t.Run("ValidRatingDesc", func(t *testing.T) {
-		movies, err := mockModel.GetAll(Filters{Sort: "rating"})
+		movies, _, err := mockModel.GetAll(context.Background(), Filters{Page: 1, PageSize: 20, Sort: "rating"})
 		if err != nil {
 			t.Errorf("unexpected error: %v", err)
 		}
@@ -212,7 +219,7 @@ func TestMockMovieDB_GetAll(t *testing.T) {
 	})
 
 	t.Run("ValidTitle", func(t *testing.T) {
-		movies, err := mockModel.GetAll(Filters{Sort: "title"})
+		movies, _, err := mockModel.GetAll(context.Background(), Filters{Page: 1, PageSize: 20, Sort: "title"})
 		if err != nil {
 			t.Errorf("unexpected error: %v", err)
 		}
@@ -223,7 +230,7 @@ func TestMockMovieDB_GetAll(t *testing.T) {
 	})
 
 	t.Run("ValidTitleDesc", func(t *testing.T) {
-		movies, err := mockModel.GetAll(Filters{Sort: "-title"})
+		movies, _, err := mockModel.GetAll(context.Background(), Filters{Page: 1, PageSize: 20, Sort: "-title"})
 		if err != nil {
 			t.Errorf("unexpected error: %v", err)
 		}
@@ -234,7 +241,7 @@ func TestMockMovieDB_GetAll(t *testing.T) {
 	})
 
 	t.Run("ValidReleaseDate", func(t *testing.T) {
-		movies, err := mockModel.GetAll(Filters{Sort: "release_date"})
+		movies, _, err := mockModel.GetAll(context.Background(), Filters{Page: 1, PageSize: 20, Sort: "release_date"})
 		if err != nil {
 			t.Errorf("unexpected error: %v", err)
 		}
@@ -245,7 +252,7 @@ func TestMockMovieDB_GetAll(t *testing.T) {
 	})
 
 	t.Run("ValidReleaseDateDesc", func(t *testing.T) {
-		movies, err := mockModel.GetAll(Filters{Sort: "-release_date"})
+		movies, _, err := mockModel.GetAll(context.Background(), Filters{Page: 1, PageSize: 20, Sort: "-release_date"})
 		if err != nil {
 			t.Errorf("unexpected error: %v", err)
 		}
@@ -254,6 +261,56 @@ func TestMockMovieDB_GetAll(t *testing.T) {
 			t.Error("expected movies to be sorted by rating in descending order")
 		}
 	})
+
+	t.Run("ValidPage", func(t *testing.T) {
+		movies, metadata, err := mockModel.GetAll(context.Background(), Filters{Page: 2, PageSize: 1, Sort: "title"})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if len(movies) != 1 || movies[0].Title != "Movie 2" {
+			t.Errorf("expected second page to contain Movie 2, but got %v", movies)
+		}
+
+		if metadata.CurrentPage != 2 || metadata.LastPage != 2 || metadata.TotalRecords != 2 {
+			t.Errorf("unexpected metadata: %+v", metadata)
+		}
+
+		if metadata.NextCursor != "" {
+			t.Errorf("expected no next cursor on the last page, but got %q", metadata.NextCursor)
+		}
+	})
+
+	t.Run("ValidCursor", func(t *testing.T) {
+		movies, metadata, err := mockModel.GetAll(context.Background(), Filters{Page: 1, PageSize: 1, Sort: "title"})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if len(movies) != 1 || metadata.NextCursor == "" {
+			t.Fatalf("expected one movie and a next cursor, but got %d movies and %q", len(movies), metadata.NextCursor)
+		}
+
+		movies, metadata, err = mockModel.GetAll(context.Background(), Filters{Page: 1, PageSize: 1, Sort: "title", Cursor: metadata.NextCursor})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if len(movies) != 1 || movies[0].Title != "Movie 2" {
+			t.Errorf("expected cursor page to contain Movie 2, but got %v", movies)
+		}
+
+		if metadata.TotalRecords != 0 || metadata.NextCursor != "" {
+			t.Errorf("unexpected metadata: %+v", metadata)
+		}
+	})
+
+	t.Run("InvalidCursor", func(t *testing.T) {
+		_, _, err := mockModel.GetAll(context.Background(), Filters{Page: 1, PageSize: 1, Sort: "title", Cursor: "invalid"})
+		if err == nil {
+			t.Error("expected error, but got nil")
+		}
+	})
 }
 
 func TestMockMovieDB_Update(t *testing.T) {
@@ -284,7 +341,7 @@ func TestMockMovieDB_Update(t *testing.T) {
 		Description: "Description 1",
 		ReleaseDate: time.Date(2021, 8, 12, 0, 0, 0, 0, time.UTC),
 		Rating:      8.0,
-		Actors:      []int64{1, 2},
+		Actors:      []CastMember{{ActorID: 1, BillingOrder: 1}, {ActorID: 2, BillingOrder: 2}},
 	}
 
 	mockModel.Movies[movie.ID] = movie
@@ -295,7 +352,7 @@ func TestMockMovieDB_Update(t *testing.T) {
 		Description: "Description 2",
 		ReleaseDate: time.Date(2000, 8, 12, 0, 0, 0, 0, time.UTC),
 		Rating:      8.5,
-		Actors:      []int64{1, 2},
+		Actors:      []CastMember{{ActorID: 1, BillingOrder: 1}, {ActorID: 2, BillingOrder: 2}},
 	}
 
 	mockModel.Movies[movie.ID] = movie
@@ -306,7 +363,7 @@ func TestMockMovieDB_Update(t *testing.T) {
 			Rating: 9.0,
 		}
 
-		err := mockModel.Update(*movie)
+		err := mockModel.Update(context.Background(), movie)
 		if err != nil {
 			t.Errorf("unexpected error: %v", err)
 		}
@@ -322,7 +379,7 @@ func TestMockMovieDB_Update(t *testing.T) {
 			Rating: 7.0,
 		}
 
-		err := mockModel.Update(*movie)
+		err := mockModel.Update(context.Background(), movie)
 		if err == nil {
 			t.Error("expected error, but got nil")
 		}
@@ -335,10 +392,10 @@ func TestMockMovieDB_Update(t *testing.T) {
 	t.Run("InvalidActor", func(t *testing.T) {
 		movie := &Movie{
 			ID:     1,
-			Actors: []int64{1, 10},
+			Actors: []CastMember{{ActorID: 1, BillingOrder: 1}, {ActorID: 10, BillingOrder: 2}},
 		}
 
-		err := mockModel.Update(*movie)
+		err := mockModel.Update(context.Background(), movie)
 		if err == nil {
 			t.Error("expected error, but got nil")
 		}
@@ -347,6 +404,37 @@ func TestMockMovieDB_Update(t *testing.T) {
 			t.Errorf("expected ErrInvalidActor, but got %v", err)
 		}
 	})
+
+	t.Run("EditConflict", func(t *testing.T) {
+		movie := &Movie{
+			ID:      2,
+			Rating:  7.0,
+			Version: 5,
+		}
+
+		err := mockModel.Update(context.Background(), movie)
+		if err != ErrEditConflict {
+			t.Errorf("expected ErrEditConflict, but got %v", err)
+		}
+
+		if mockModel.Movies[movie.ID].Rating != 8.5 {
+			t.Error("expected movie not to be updated")
+		}
+	})
+
+	t.Run("VersionIncremented", func(t *testing.T) {
+		movie := *mockModel.Movies[2]
+		movie.Rating = 7.5
+
+		err := mockModel.Update(context.Background(), &movie)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if movie.Version != 1 || mockModel.Movies[2].Version != 1 {
+			t.Errorf("expected version to be 1, but got %d", movie.Version)
+		}
+	})
 }
 
 func TestValidateMovie(t *testing.T) {
@@ -358,7 +446,7 @@ func TestValidateMovie(t *testing.T) {
 			Description: "Description 1",
 			Rating:      8.0,
 			ReleaseDate: time.Date(2021, 8, 12, 0, 0, 0, 0, time.UTC),
-			Actors:      []int64{1, 2},
+			Actors:      []CastMember{{ActorID: 1, BillingOrder: 1}, {ActorID: 2, BillingOrder: 2}},
 		}
 
 		ValidateMovie(v, movie)
@@ -375,7 +463,7 @@ func TestValidateMovie(t *testing.T) {
 			Description: "Description 1",
 			Rating:      8.0,
 			ReleaseDate: time.Date(2021, 8, 12, 0, 0, 0, 0, time.UTC),
-			Actors:      []int64{1, 2},
+			Actors:      []CastMember{{ActorID: 1, BillingOrder: 1}, {ActorID: 2, BillingOrder: 2}},
 		}
 
 		ValidateMovie(v, movie)
@@ -396,7 +484,7 @@ func TestValidateMovie(t *testing.T) {
 			Description: "Description 1",
 			Rating:      8.0,
 			ReleaseDate: time.Date(2021, 8, 12, 0, 0, 0, 0, time.UTC),
-			Actors:      []int64{1, 2},
+			Actors:      []CastMember{{ActorID: 1, BillingOrder: 1}, {ActorID: 2, BillingOrder: 2}},
 		}
 
 		ValidateMovie(v, movie)
@@ -417,7 +505,7 @@ func TestValidateMovie(t *testing.T) {
 			Description: "",
 			Rating:      8.0,
 			ReleaseDate: time.Date(2021, 8, 12, 0, 0, 0, 0, time.UTC),
-			Actors:      []int64{1, 2},
+			Actors:      []CastMember{{ActorID: 1, BillingOrder: 1}, {ActorID: 2, BillingOrder: 2}},
 		}
 
 		ValidateMovie(v, movie)
@@ -438,7 +526,7 @@ func TestValidateMovie(t *testing.T) {
 			Description: "Lorem ipsum dolor sit amet, consectetur adipiscing elit. Suspendisse vel elementum lectus. Etiam aliquam, dui ac scelerisque feugiat, lacus orci bibendum turpis, vitae vehicula erat leo scelerisque orci. Morbi mollis scelerisque erat eget gravida. Nullam nec imperdiet nisi, non consectetur sem. Nunc volutpat elit in ultricies feugiat. Suspendisse potenti. Donec facilisis diam tristique erat bibendum, ut maximus lorem malesuada. Nulla in ultrices est. Proin rutrum odio tortor, vel consequat magna venenatis in. Aenean volutpat ipsum nisi, ac venenatis massa hendrerit sit amet. Nam ac dapibus nisi. Etiam quis est iaculis, mollis eros at, luctus nunc. Phasellus ultrices elit vel fringilla lobortis. Nullam felis risus, semper vitae bibendum sit amet, scelerisque et orci. Fusce non viverra metus. Phasellus dignissim mattis convallis. Nulla vel tortor lectus. Curabitur arcu lorem, lacinia sed purus malesuada, tincidunt porta nibh. Cras ut risus at erat malesuada mattis. Ut lacinia dolor non nibh rhoncus bibendum. In a ex eget lectus commodo eleifend. Fusce arcu lorem, suscipit quis ornare in, elementum sit amet ex. Aliquam eget tortor ullamcorper, mattis neque eu, pharetra orci. Praesent posuere felis at dolor facilisis, eget semper neque mattis. Duis egestas faucibus euismod. Sed felis nunc, tincidunt vitae orci ac, finibus rhoncus sapien. In convallis cursus rutrum. Orci varius natoque penatibus et magnis dis parturient montes, nascetur ridiculus mus. In sit amet sagittis libero. Vivamus posuere quam rhoncus iaculis accumsan. Nullam magna mi, ornare in justo quis, dapibus sagittis urna. Aenean ac condimentum arcu, a sagittis magna. Nullam aliquam dolor vitae libero blandit, vel luctus lorem faucibus. Sed dapibus lorem sit amet gravida pellentesque. Donec id bibendum urna, vitae ornare augue. Phasellus elit purus, hendrerit sit amet interdum non, venenatis ut libero. Cras id sollicitudin risus. Sed pulvinar lacus nec dapibus maximus.",
 			Rating:      8.0,
 			ReleaseDate: time.Date(2021, 8, 12, 0, 0, 0, 0, time.UTC),
-			Actors:      []int64{1, 2},
+			Actors:      []CastMember{{ActorID: 1, BillingOrder: 1}, {ActorID: 2, BillingOrder: 2}},
 		}
 
 		ValidateMovie(v, movie)
@@ -459,7 +547,7 @@ func TestValidateMovie(t *testing.T) {
 			Description: "Description 1",
 			Rating:      11.0,
 			ReleaseDate: time.Date(2021, 8, 12, 0, 0, 0, 0, time.UTC),
-			Actors:      []int64{1, 2},
+			Actors:      []CastMember{{ActorID: 1, BillingOrder: 1}, {ActorID: 2, BillingOrder: 2}},
 		}
 
 		ValidateMovie(v, movie)
@@ -479,7 +567,7 @@ func TestValidateMovie(t *testing.T) {
 			Title:       "Movie 1",
 			Description: "Description 1",
 			Rating:      8.0,
-			Actors:      []int64{1, 2},
+			Actors:      []CastMember{{ActorID: 1, BillingOrder: 1}, {ActorID: 2, BillingOrder: 2}},
 		}
 
 		ValidateMovie(v, movie)
@@ -492,6 +580,35 @@ func TestValidateMovie(t *testing.T) {
 		}
 	})
 
+	t.Run("DuplicateCast", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			actors   []CastMember
+			expected string
+		}{
+			{"Actor", []CastMember{{ActorID: 1, BillingOrder: 1}, {ActorID: 1, BillingOrder: 2}}, "must not contain duplicate actors"},
+			{"BillingOrder", []CastMember{{ActorID: 1, BillingOrder: 1}, {ActorID: 2, BillingOrder: 1}}, "must not contain duplicate billing orders"},
+			{"MissingBillingOrder", []CastMember{{ActorID: 1}}, "must contain only positive billing orders"},
+		}
+
+		for _, tt := range tests {
+			v := validator.New()
+
+			movie := &Movie{
+				Title:       "Movie 1",
+				Description: "Description 1",
+				Rating:      8.0,
+				ReleaseDate: time.Date(2021, 8, 12, 0, 0, 0, 0, time.UTC),
+				Actors:      tt.actors,
+			}
+
+			ValidateMovie(v, movie)
+			if v.Errors["actors"] != tt.expected {
+				t.Errorf("%s: expected error %q, but got %q", tt.name, tt.expected, v.Errors["actors"])
+			}
+		}
+	})
+
 	t.Run("ActorsMustBeProvided", func(t *testing.T) {
 		v := validator.New()
 
@@ -513,3 +630,393 @@ func TestValidateMovie(t *testing.T) {
 	})
 
 }
+
+func TestMockMovieDB_Search(t *testing.T) {
+	mockModel := MockMovieDB{
+		Actors: map[int64]*Actor{
+			1: {ID: 1, FullName: "Keanu Reeves"},
+			2: {ID: 2, FullName: "Carrie-Anne Moss"},
+		},
+		Movies: map[int64]*Movie{
+			1: {ID: 1, Title: "The Matrix", Description: "A hacker learns the truth", Actors: []CastMember{{ActorID: 1, Character: "Neo", BillingOrder: 1}, {ActorID: 2, Character: "Trinity", BillingOrder: 2}}},
+			2: {ID: 2, Title: "John Wick", Description: "A retired hitman and the matrix of crime", Actors: []CastMember{{ActorID: 1, Character: "John Wick", BillingOrder: 1}}},
+		},
+	}
+
+	t.Run("RankedByRelevance", func(t *testing.T) {
+		movies, err := mockModel.Search(context.Background(), SearchQuery{Text: "matrix"})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if len(movies) != 2 {
+			t.Fatalf("expected 2 movies, but got %d", len(movies))
+		}
+
+		if movies[0].ID != 1 || movies[0].Relevance <= movies[1].Relevance {
+			t.Errorf("expected title match to rank first, but got %+v", movies)
+		}
+	})
+
+	t.Run("DifferentStem", func(t *testing.T) {
+		movies, err := mockModel.Search(context.Background(), SearchQuery{Text: "matrices", Fuzzy: true, Threshold: 0.3})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if len(movies) == 0 || movies[0].ID != 1 {
+			t.Errorf("expected The Matrix for a different word form, but got %+v", movies)
+		}
+
+		movies, err = mockModel.Search(context.Background(), SearchQuery{Text: "matrices"})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if len(movies) != 0 {
+			t.Errorf("expected no trigram title matches without fuzzy, but got %+v", movies)
+		}
+	})
+
+	t.Run("WithoutCast", func(t *testing.T) {
+		mockModel := MockMovieDB{Movies: map[int64]*Movie{
+			1: {ID: 1, Title: "The Matrix", Actors: []CastMember{}},
+		}}
+
+		movies, err := mockModel.Search(context.Background(), SearchQuery{Text: "matrix"})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if len(movies) != 1 {
+			t.Errorf("expected a movie without cast to be found, but got %+v", movies)
+		}
+
+		movies, err = mockModel.Search(context.Background(), SearchQuery{Text: "matrix", Actor: "reeves"})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if len(movies) != 0 {
+			t.Errorf("expected the actor filter to exclude a movie without cast, but got %+v", movies)
+		}
+	})
+
+	t.Run("ActorFilter", func(t *testing.T) {
+		movies, err := mockModel.Search(context.Background(), SearchQuery{Actor: "moss"})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if len(movies) != 1 || movies[0].ID != 1 {
+			t.Errorf("expected only The Matrix, but got %+v", movies)
+		}
+	})
+
+	t.Run("FuzzyActor", func(t *testing.T) {
+		movies, err := mockModel.Search(context.Background(), SearchQuery{Actor: "Keanu Reevs", Fuzzy: true, Threshold: 0.3})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if len(movies) != 2 || movies[0].Similarity <= 0 {
+			t.Errorf("expected 2 movies with positive similarity, but got %+v", movies)
+		}
+	})
+
+	t.Run("FuzzyTitleSortedBySimilarity", func(t *testing.T) {
+		movies, err := mockModel.Search(context.Background(), SearchQuery{Title: "Matrx", Fuzzy: true, Threshold: 0.1})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if len(movies) == 0 || movies[0].ID != 1 {
+			t.Errorf("expected The Matrix first, but got %+v", movies)
+		}
+
+		for i := 1; i < len(movies); i++ {
+			if movies[i].Similarity > movies[i-1].Similarity {
+				t.Errorf("expected movies to be sorted by similarity, but got %+v", movies)
+			}
+		}
+	})
+
+	t.Run("ExactWithoutFuzzy", func(t *testing.T) {
+		movies, err := mockModel.Search(context.Background(), SearchQuery{Actor: "Keanu Reevs", Fuzzy: false, Threshold: 0.3})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if len(movies) != 0 {
+			t.Errorf("expected no movies, but got %d", len(movies))
+		}
+	})
+
+	t.Run("Character", func(t *testing.T) {
+		movies, err := mockModel.Search(context.Background(), SearchQuery{Character: "Trinty", Fuzzy: true, Threshold: 0.3})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if len(movies) != 1 || movies[0].ID != 1 || movies[0].Similarity <= 0 {
+			t.Errorf("expected only The Matrix, but got %+v", movies)
+		}
+	})
+
+	t.Run("ActorAndCharacterOnSameCastEntry", func(t *testing.T) {
+		movies, err := mockModel.Search(context.Background(), SearchQuery{Actor: "Keanu", Character: "Trinity"})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if len(movies) != 0 {
+			t.Errorf("expected no movies, but got %+v", movies)
+		}
+	})
+
+	t.Run("NoMatch", func(t *testing.T) {
+		movies, err := mockModel.Search(context.Background(), SearchQuery{Text: "titanic"})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if len(movies) != 0 {
+			t.Errorf("expected no movies, but got %d", len(movies))
+		}
+	})
+}
+
+func TestWordSimilarity(t *testing.T) {
+	tests := []struct {
+		query    string
+		text     string
+		expected float64
+	}{
+		{"Scarlett Johansson", "Scarlett Johansson", 1},
+		{"", "Scarlett Johansson", 0},
+		{"titanic", "Scarlett Johansson", 0},
+	}
+
+	for _, test := range tests {
+		similarity := wordSimilarity(test.query, test.text)
+		if similarity != test.expected {
+			t.Errorf("wordSimilarity(%q, %q) = %f, expected %f", test.query, test.text, similarity, test.expected)
+		}
+	}
+
+	similarity := wordSimilarity("Scarlet Johanson", "Scarlett Johansson")
+	if similarity < 0.5 || similarity >= 1 {
+		t.Errorf("expected typo to be similar, but got %f", similarity)
+	}
+}
+
+func TestNormalizeCast(t *testing.T) {
+	cast := []CastMember{
+		{ActorID: 1, Character: "Sidekick", BillingOrder: 3},
+		{ActorID: 2, Character: "Lead"},
+		{ActorID: 3, Character: "Cameo", BillingOrder: 10},
+	}
+
+	NormalizeCast(cast)
+
+	expected := []int64{2, 1, 3}
+	for i, member := range cast {
+		if member.ActorID != expected[i] {
+			t.Errorf("expected actor %d at position %d, but got %d", expected[i], i, member.ActorID)
+		}
+	}
+
+	if cast[0].BillingOrder != 2 {
+		t.Errorf("expected missing billing order to default to position, but got %d", cast[0].BillingOrder)
+	}
+
+	t.Run("TakenPosition", func(t *testing.T) {
+		cast := []CastMember{
+			{ActorID: 5, BillingOrder: 2},
+			{ActorID: 7},
+		}
+
+		NormalizeCast(cast)
+
+		if cast[0].ActorID != 5 || cast[1].ActorID != 7 || cast[1].BillingOrder != 3 {
+			t.Errorf("expected implicit billing order to skip the taken position, but got %+v", cast)
+		}
+
+		v := validator.New()
+		ValidateMovie(v, &Movie{Title: "Mock", ReleaseDate: time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC), Rating: 5, Actors: cast})
+		if _, ok := v.Errors["actors"]; ok {
+			t.Errorf("expected normalized cast to pass validation, but got %q", v.Errors["actors"])
+		}
+	})
+}
+
+func TestCastMember_UnmarshalJSON(t *testing.T) {
+	var cast []CastMember
+
+	err := json.Unmarshal([]byte(`[1, {"actor_id": 2, "character": "Trinity", "billing_order": 2}]`), &cast)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cast[0].ActorID != 1 || cast[1].ActorID != 2 || cast[1].Character != "Trinity" || cast[1].BillingOrder != 2 {
+		t.Errorf("unexpected cast: %+v", cast)
+	}
+}
+
+func TestMockMovieDB_GetByIDs(t *testing.T) {
+	models := NewMockModels()
+
+	movies, err := models.Movies.GetByIDs(context.Background(), []int64{1, 42})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(movies) != 1 || movies[1].Title != "Mock Movie 1" {
+		t.Errorf("expected only movie 1, but got %v", movies)
+	}
+}
+
+// Записывает запросы; Exec выполняется успешно, а Query возвращает ошибку.
+type recordingQuerier struct {
+	failingQuerier
+	queries []string
+	args    [][]interface{}
+}
+
+func (q *recordingQuerier) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	q.queries = append(q.queries, query)
+	q.args = append(q.args, args)
+
+	return nil, nil
+}
+
+func (q *recordingQuerier) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	q.queries = append(q.queries, query)
+	q.args = append(q.args, args)
+
+	return nil, errFakeQuery
+}
+
+func TestMovieDB_SearchUsesTrigramOperator(t *testing.T) {
+	q := &recordingQuerier{}
+	model := MovieDB{DB: q}
+
+	_, err := model.Search(context.Background(), SearchQuery{Title: "Matrx", Actor: "Reevs", Fuzzy: true, Threshold: 0.4})
+	if !errors.Is(err, errFakeQuery) {
+		t.Fatalf("expected the fake query error, but got %v", err)
+	}
+
+	if len(q.queries) != 2 {
+		t.Fatalf("expected threshold setup and search queries, but got %q", q.queries)
+	}
+
+	if !strings.Contains(q.queries[0], "pg_trgm.word_similarity_threshold") || len(q.args[0]) != 1 || q.args[0][0] != "0.4" {
+		t.Errorf("expected the threshold 0.4 to be set for the transaction, but got %q %v", q.queries[0], q.args[0])
+	}
+
+	for _, condition := range []string{"<% m.title", "<% a.full_name"} {
+		if !strings.Contains(q.queries[1], condition) {
+			t.Errorf("expected the search to filter with the indexable %q, but got:\n%s", condition, q.queries[1])
+		}
+	}
+
+	if strings.Contains(q.queries[1], ">=") {
+		t.Errorf("expected no word_similarity comparison in the filter, but got:\n%s", q.queries[1])
+	}
+
+	q = &recordingQuerier{}
+	model.DB = q
+	model.Search(context.Background(), SearchQuery{Text: "matrix"})
+
+	if len(q.queries) != 1 {
+		t.Errorf("expected no threshold setup without fuzzy parameters, but got %q", q.queries)
+	}
+}
+
+func TestMovieDB_GetAllSkipsCountWithCursor(t *testing.T) {
+	filters := Filters{Page: 1, PageSize: 20, Sort: "title", SortSafelist: []string{"title"}}
+
+	q := &recordingQuerier{}
+	model := MovieDB{DB: q}
+	model.GetAll(context.Background(), filters)
+
+	if len(q.queries) != 1 || !strings.Contains(q.queries[0], "count(*) OVER()") {
+		t.Errorf("expected the total count on offset pages, but got %q", q.queries)
+	}
+
+	filters.Cursor = encodeCursor(cursor{Sort: "title", Value: "Matrix", ID: 1})
+
+	q = &recordingQuerier{}
+	model.DB = q
+	model.GetAll(context.Background(), filters)
+
+	if len(q.queries) != 1 || strings.Contains(q.queries[0], "count(*) OVER()") {
+		t.Errorf("expected no total count in cursor mode, but got %q", q.queries)
+	}
+}
+
+/*
+Проверяет запросы MovieDB.Search и MovieDB.Get на базе, созданной из
+sql/tables.sql. Запускается, только если задана переменная окружения
+FILMOTEKA_TEST_DB_DSN; данные теста откатываются вместе с транзакцией.
+*/
+func TestMovieDB_SearchDatabase(t *testing.T) {
+	dsn := os.Getenv("FILMOTEKA_TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("FILMOTEKA_TEST_DB_DSN is not set")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	model := MovieDB{DB: tx}
+	ids := make(map[string]int64)
+
+	// Фильмы без актеров, как The Matrix в sql/testdata.sql.
+	for _, movie := range []*Movie{
+		{Title: "Search check: The Matrix", Description: "A computer hacker learns about the true nature of his reality.", ReleaseDate: time.Date(1999, 3, 31, 0, 0, 0, 0, time.UTC), Rating: 8.7},
+		{Title: "Search check: Матрица", Description: "Хакер узнает правду о мире, в котором живет.", ReleaseDate: time.Date(1999, 3, 31, 0, 0, 0, 0, time.UTC), Rating: 8.7},
+	} {
+		err := model.Insert(context.Background(), movie)
+		if err != nil {
+			t.Fatalf("could not insert %q: %v", movie.Title, err)
+		}
+
+		ids[movie.Title] = movie.ID
+	}
+
+	movie, err := model.Get(context.Background(), ids["Search check: The Matrix"])
+	if err != nil || len(movie.Actors) != 0 {
+		t.Fatalf("expected a movie without cast, but got %+v, %v", movie, err)
+	}
+
+	tests := []struct {
+		query SearchQuery
+		want  string
+	}{
+		{SearchQuery{Text: "matrices", Fuzzy: true, Threshold: 0.3}, "Search check: The Matrix"},
+		{SearchQuery{Text: "hacker reality"}, "Search check: The Matrix"},
+		{SearchQuery{Text: "матрица"}, "Search check: Матрица"},
+		{SearchQuery{Text: "матрицы", Fuzzy: true, Threshold: 0.3}, "Search check: Матрица"},
+	}
+
+	for _, tt := range tests {
+		movies, err := model.Search(context.Background(), tt.query)
+		if err != nil {
+			t.Fatalf("search for %q failed: %v", tt.query.Text, err)
+		}
+
+		if !slices.ContainsFunc(movies, func(m *Movie) bool { return m.Title == tt.want }) {
+			t.Errorf("expected search for %q to find %q, but got %+v", tt.query.Text, tt.want, movies)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Получает длительность каждого запроса к базе и модель с методом, который его выполнил.
+type QueryObserver func(model, method string, duration time.Duration, err error)
+
+/*
+Вызывается перед каждым запросом к базе, например чтобы начать span
+трассировки, и возвращает функцию, которая получит ошибку запроса.
+*/
+type QueryTracer func(ctx context.Context, model, method, query string) (end func(err error))
+
+/*
+observedQuerier замеряет и трассирует каждый запрос. Модель и метод,
+например MovieDB.Get, берутся из контекста, который метод модели
+подписывает через startMethod. Для QueryContext замеряется выполнение
+запроса до получения первых строк, но не чтение результата.
+*/
+type observedQuerier struct {
+	q       Querier
+	observe QueryObserver
+	trace   QueryTracer
+}
+
+func (o observedQuerier) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	done := o.start(ctx, query)
+	result, err := o.q.ExecContext(ctx, query, args...)
+	done(err)
+
+	return result, err
+}
+
+func (o observedQuerier) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	done := o.start(ctx, query)
+	stmt, err := o.q.PrepareContext(ctx, query)
+	done(err)
+
+	return stmt, err
+}
+
+func (o observedQuerier) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	done := o.start(ctx, query)
+	rows, err := o.q.QueryContext(ctx, query, args...)
+	done(err)
+
+	return rows, err
+}
+
+func (o observedQuerier) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	done := o.start(ctx, query)
+	row := o.q.QueryRowContext(ctx, query, args...)
+	done(row.Err())
+
+	return row
+}
+
+func (o observedQuerier) start(ctx context.Context, query string) func(err error) {
+	model, method := queryCaller(ctx)
+	start := time.Now()
+
+	var end func(err error)
+	if o.trace != nil {
+		end = o.trace(ctx, model, method, query)
+	}
+
+	return func(err error) {
+		if o.observe != nil {
+			o.observe(model, method, time.Since(start), err)
+		}
+
+		if end != nil {
+			end(err)
+		}
+	}
+}
+
+type queryCallerKey struct{}
+
+type queryCallerName struct {
+	model, method string
+}
+
+/*
+Подписывает контекст метода модели, чтобы observedQuerier отнес запросы
+к нему, и ограничивает время метода. Вызывается в начале каждого метода,
+который обращается к базе.
+*/
+func startMethod(ctx context.Context, timeout time.Duration, model, method string) (context.Context, context.CancelFunc) {
+	return withTimeout(withQueryCaller(ctx, model, method), timeout)
+}
+
+/*
+Подпись, поставленная раньше, сохраняется: запросы метода, который
+делегирует другой модели, например ActorDB.Insert через PersonDB.Insert,
+считаются запросами ActorDB.Insert.
+*/
+func withQueryCaller(ctx context.Context, model, method string) context.Context {
+	if _, found := ctx.Value(queryCallerKey{}).(queryCallerName); found {
+		return ctx
+	}
+
+	return context.WithValue(ctx, queryCallerKey{}, queryCallerName{model, method})
+}
+
+func queryCaller(ctx context.Context) (string, string) {
+	name, found := ctx.Value(queryCallerKey{}).(queryCallerName)
+	if !found {
+		return "unknown", "unknown"
+	}
+
+	return name.model, name.method
+}
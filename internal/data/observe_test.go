@@ -0,0 +1,129 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+var errFakeQuery = errors.New("fake query error")
+
+// Querier, который сразу возвращает ошибку, не обращаясь к базе.
+type failingQuerier struct{}
+
+func (failingQuerier) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return nil, errFakeQuery
+}
+
+func (failingQuerier) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return nil, errFakeQuery
+}
+
+func (failingQuerier) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return nil, errFakeQuery
+}
+
+func (failingQuerier) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return &sql.Row{}
+}
+
+func TestObservedQuerier(t *testing.T) {
+	type observation struct {
+		model, method string
+		err           error
+	}
+
+	var observed []observation
+
+	q := observedQuerier{q: failingQuerier{}, observe: func(model, method string, duration time.Duration, err error) {
+		observed = append(observed, observation{model, method, err})
+	}}
+
+	MovieDB{DB: q}.Delete(context.Background(), 1)
+	UserDB{DB: q}.GetAll(context.Background(), "", "", Filters{Page: 1, PageSize: 20, Sort: "id", SortSafelist: []string{"id"}})
+	ActorDB{DB: q}.Delete(context.Background(), 1)
+	PersonDB{DB: q}.Delete(context.Background(), 1)
+	q.ExecContext(context.Background(), "SELECT 1")
+
+	want := []observation{
+		{"MovieDB", "Delete", errFakeQuery},
+		{"UserDB", "GetAll", errFakeQuery},
+		{"ActorDB", "Delete", errFakeQuery},
+		{"PersonDB", "Delete", errFakeQuery},
+		{"unknown", "unknown", errFakeQuery},
+	}
+
+	if len(observed) != len(want) {
+		t.Fatalf("expected %d observations, but got %v", len(want), observed)
+	}
+
+	for i := range want {
+		if observed[i] != want[i] {
+			t.Errorf("expected %v, but got %v", want[i], observed[i])
+		}
+	}
+}
+
+func TestObservedQuerier_Trace(t *testing.T) {
+	type key struct{}
+
+	var traced []string
+	var ended []error
+
+	q := observedQuerier{q: failingQuerier{}, trace: func(ctx context.Context, model, method, query string) func(err error) {
+		if ctx.Value(key{}) != "request" {
+			t.Error("expected the caller's context to be passed to the tracer")
+		}
+
+		traced = append(traced, model+"."+method)
+
+		if !strings.Contains(query, "movie_id = $1") {
+			t.Errorf("unexpected query %q", query)
+		}
+
+		return func(err error) {
+			ended = append(ended, err)
+		}
+	}}
+
+	MovieDB{DB: q}.Delete(context.WithValue(context.Background(), key{}, "request"), 1)
+
+	if len(traced) != 1 || traced[0] != "MovieDB.Delete" {
+		t.Fatalf("expected MovieDB.Delete to be traced, but got %v", traced)
+	}
+
+	if len(ended) != 1 || ended[0] != errFakeQuery {
+		t.Errorf("expected the span to end with %v, but got %v", errFakeQuery, ended)
+	}
+}
+
+func TestObservedQuerier_MovieCredits(t *testing.T) {
+	var observed []string
+
+	rec := &recordingQuerier{}
+	q := observedQuerier{q: rec, observe: func(model, method string, duration time.Duration, err error) {
+		observed = append(observed, model+"."+method)
+	}}
+
+	movie := &Movie{
+		ID:     1,
+		Actors: []CastMember{{ActorID: 1, Character: "Neo", BillingOrder: 1}, {ActorID: 2, Character: "Trinity", BillingOrder: 2}},
+		Crew:   []Credit{{PersonID: 3, Role: "director"}},
+	}
+
+	err := insertMovieCredits(withQueryCaller(context.Background(), "MovieDB", "Insert"), q, movie)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(observed) != 1 || observed[0] != "MovieDB.Insert" {
+		t.Fatalf("expected the credits insert to be observed as MovieDB.Insert, but got %v", observed)
+	}
+
+	if len(rec.queries) != 1 || !strings.Contains(rec.queries[0], "INSERT INTO credits") {
+		t.Errorf("expected one insert for all credits, but got %q", rec.queries)
+	}
+}
@@ -0,0 +1,556 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"filmoteka/internal/validator"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	CreditRoles = []string{"actor", "director", "writer", "producer", "composer", "cinematographer", "editor"}
+)
+
+type Person struct {
+	ID          int64                         `json:"id"`
+	FullName    string                        `json:"full_name"`
+	Gender      string                        `json:"gender"`
+	BirthDate   time.Time                     `json:"birth_date"` // RFC3339
+	Version     int32                         `json:"version"`
+	Filmography map[string][]FilmographyEntry `json:"filmography,omitempty"`
+}
+
+type FilmographyEntry struct {
+	MovieID      int64     `json:"movie_id"`
+	Title        string    `json:"title"`
+	ReleaseDate  time.Time `json:"release_date"` // RFC3339
+	Character    string    `json:"character,omitempty"`
+	BillingOrder int       `json:"billing_order,omitempty"`
+}
+
+// Участие человека в фильме в роли, отличной от актерской.
+type Credit struct {
+	PersonID int64  `json:"person_id"`
+	Role     string `json:"role"`
+}
+
+type CrewMember struct {
+	ID       int64  `json:"id"`
+	FullName string `json:"full_name"`
+}
+
+type PersonModel interface {
+	Insert(ctx context.Context, person *Person) error
+	Delete(ctx context.Context, id int64) error
+	Get(ctx context.Context, id int64) (*Person, error)
+	GetAll(ctx context.Context, filters Filters) ([]*Person, Metadata, error)
+	Update(ctx context.Context, person *Person) error
+}
+
+type PersonDB struct {
+	DB      Querier
+	Timeout time.Duration
+}
+
+// В моках люди хранятся в той же карте, что и актеры.
+type MockPersonDB struct {
+	People map[int64]*Actor
+	Movies map[int64]*Movie
+}
+
+var (
+	ErrCrewNotFound = errors.New("one or more crew member IDs do not exist")
+)
+
+func ValidatePerson(v *validator.Validator, person *Person) {
+	v.Check(person.FullName != "", "full_name", "must be provided")
+	v.Check(utf8.RuneCountInString(person.FullName) <= 200, "full_name", "must be no more than 200 symbols")
+
+	v.Check(person.Gender != "", "gender", "must be provided")
+	v.Check(person.Gender == "male" || person.Gender == "female", "gender", "must be either male or female")
+
+	v.Check(!person.BirthDate.Equal(time.Time{}), "birth_date", "must be provided")
+	v.Check(person.BirthDate.Before(time.Now()), "birth_date", "must be a valid date")
+}
+
+func (m PersonDB) Insert(ctx context.Context, person *Person) error {
+	query := `
+		INSERT INTO People (full_name, gender, birth_date)
+		VALUES ($1, $2, $3)
+		RETURNING person_id, version`
+
+	args := []interface{}{person.FullName, person.Gender, person.BirthDate}
+
+	ctx, cancel := startMethod(ctx, m.Timeout, "PersonDB", "Insert")
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&person.ID, &person.Version)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "people_full_name_key"`:
+			return ErrDuplicateName
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+Удаляет человека и все его участия в фильмах из таблицы Credits,
+но не удаляет сами фильмы из таблицы Movies.
+*/
+func (m PersonDB) Delete(ctx context.Context, id int64) error {
+	ctx, cancel := startMethod(ctx, m.Timeout, "PersonDB", "Delete")
+	defer cancel()
+
+	query := `
+		DELETE FROM
+			People
+		WHERE
+			person_id = $1`
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	return checkAffectedRows(result)
+}
+
+/*
+Фильмография группируется по ролям; внутри роли фильмы идут по дате выхода.
+Для актерских ролей также возвращаются имя персонажа и место в титрах.
+*/
+func (m PersonDB) Get(ctx context.Context, id int64) (*Person, error) {
+	query := `
+		SELECT person_id, full_name, gender, birth_date, version
+		FROM People
+		WHERE person_id = $1`
+
+	ctx, cancel := startMethod(ctx, m.Timeout, "PersonDB", "Get")
+	defer cancel()
+
+	var person Person
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&person.ID,
+		&person.FullName,
+		&person.Gender,
+		&person.BirthDate,
+		&person.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	query = `
+		SELECT c.role, m.movie_id, m.title, m.release_date, c.character, c.billing_order
+		FROM Credits c
+		JOIN Movies m ON c.movie_id = m.movie_id
+		WHERE c.person_id = $1
+		ORDER BY c.role, m.release_date, m.movie_id`
+
+	rows, err := m.DB.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	person.Filmography = make(map[string][]FilmographyEntry)
+
+	for rows.Next() {
+		var role string
+		var entry FilmographyEntry
+
+		err = rows.Scan(&role, &entry.MovieID, &entry.Title, &entry.ReleaseDate, &entry.Character, &entry.BillingOrder)
+		if err != nil {
+			return nil, err
+		}
+
+		person.Filmography[role] = append(person.Filmography[role], entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &person, nil
+}
+
+func (m PersonDB) GetAll(ctx context.Context, filters Filters) ([]*Person, Metadata, error) {
+	c, err := filters.cursor()
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	args := []interface{}{filters.limit() + 1, filters.offset()}
+
+	var conditions []string
+
+	if c != nil {
+		args = append(args, c.Value, c.ID)
+		conditions = append(conditions, fmt.Sprintf("(p.%s, p.person_id) %s ($%d, $%d)", filters.sortColumn(), filters.cursorOperator(), len(args)-1, len(args)))
+	}
+
+	if filters.Role != "" {
+		args = append(args, filters.Role)
+		conditions = append(conditions, fmt.Sprintf("EXISTS (SELECT 1 FROM Credits c WHERE c.person_id = p.person_id AND c.role = $%d)", len(args)))
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			%s, p.person_id, p.full_name, p.gender, p.birth_date, p.version
+		FROM
+			People p
+		%s
+		ORDER BY
+			p.%s %s, p.person_id %s
+		LIMIT $1 OFFSET $2`, filters.countColumn(), where, filters.sortColumn(), filters.sortDirection(), filters.sortDirection())
+
+	ctx, cancel := startMethod(ctx, m.Timeout, "PersonDB", "GetAll")
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	people := []*Person{}
+
+	for rows.Next() {
+		var person Person
+
+		err = rows.Scan(&totalRecords,
+			&person.ID,
+			&person.FullName,
+			&person.Gender,
+			&person.BirthDate,
+			&person.Version)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		people = append(people, &person)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	people, nextCursor := paginatePeople(people, filters)
+
+	return people, calculateMetadata(totalRecords, filters, nextCursor), nil
+}
+
+func (m PersonDB) Update(ctx context.Context, person *Person) error {
+	query := `
+		UPDATE
+			People
+		SET
+			full_name = $1,
+			gender = $2,
+			birth_date = $3,
+			version = version + 1
+		WHERE
+			person_id = $4 AND version = $5
+		RETURNING
+			version`
+
+	args := []interface{}{
+		person.FullName,
+		person.Gender,
+		person.BirthDate,
+		person.ID,
+		person.Version,
+	}
+
+	ctx, cancel := startMethod(ctx, m.Timeout, "PersonDB", "Update")
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&person.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		case err.Error() == `pq: duplicate key value violates unique constraint "people_full_name_key"`:
+			return ErrDuplicateName
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func paginatePeople(people []*Person, filters Filters) ([]*Person, string) {
+	if len(people) <= filters.limit() {
+		return people, ""
+	}
+
+	people = people[:filters.limit()]
+	last := people[len(people)-1]
+
+	value := last.FullName
+	if strings.TrimPrefix(filters.Sort, "-") == "birth_date" {
+		value = last.BirthDate.Format("2006-01-02")
+	}
+
+	return people, encodeCursor(cursor{Sort: filters.Sort, Value: value, ID: last.ID})
+}
+
+/*
+Проверяет, что все люди существуют. Если кого-то нет, возвращается errNotFound,
+чтобы вызывающий код мог указать, в каком поле ошибка: в актерах или в съемочной группе.
+*/
+func checkPeopleExistence(ctx context.Context, db Querier, ids []int64, errNotFound error) error {
+	query := `
+		SELECT person_id
+		FROM people
+		WHERE person_id = $1`
+
+	for _, id := range ids {
+		var result int64
+
+		err := db.QueryRowContext(ctx, query, id).Scan(&result)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return errNotFound
+			default:
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (a *Actor) person() *Person {
+	return &Person{
+		ID:        a.ID,
+		FullName:  a.FullName,
+		Gender:    a.Gender,
+		BirthDate: a.BirthDate,
+		Version:   a.Version,
+	}
+}
+
+func (m *MockPersonDB) Insert(ctx context.Context, person *Person) error {
+	for _, existing := range m.People {
+		if existing.FullName == person.FullName {
+			return ErrDuplicateName
+		}
+	}
+
+	person.ID = int64(len(m.People) + 1)
+	person.Version = 1
+
+	m.People[person.ID] = &Actor{
+		ID:        person.ID,
+		FullName:  person.FullName,
+		Gender:    person.Gender,
+		BirthDate: person.BirthDate,
+		Movies:    []int{},
+		Version:   person.Version,
+	}
+
+	return nil
+}
+
+func (m *MockPersonDB) Delete(ctx context.Context, id int64) error {
+	if _, found := m.People[id]; !found {
+		return ErrRecordNotFound
+	}
+
+	delete(m.People, id)
+
+	return nil
+}
+
+func (m *MockPersonDB) Get(ctx context.Context, id int64) (*Person, error) {
+	actor, found := m.People[id]
+	if !found {
+		return nil, ErrRecordNotFound
+	}
+
+	person := actor.person()
+	person.Filmography = make(map[string][]FilmographyEntry)
+
+	for _, movie := range sortedMovies(m.Movies) {
+		for _, role := range movieRoles(movie, id) {
+			entry := FilmographyEntry{MovieID: movie.ID, Title: movie.Title, ReleaseDate: movie.ReleaseDate}
+
+			if role == "actor" {
+				for _, member := range movie.Actors {
+					if member.ActorID == id {
+						entry.Character, entry.BillingOrder = member.Character, member.BillingOrder
+					}
+				}
+			}
+
+			person.Filmography[role] = append(person.Filmography[role], entry)
+		}
+	}
+
+	return person, nil
+}
+
+func (m *MockPersonDB) GetAll(ctx context.Context, filters Filters) ([]*Person, Metadata, error) {
+	var people []*Person
+
+	for _, actor := range m.People {
+		if filters.Role != "" && !hasRole(m.Movies, actor.ID, filters.Role) {
+			continue
+		}
+
+		people = append(people, actor.person())
+	}
+
+	sort.Slice(people, func(i, j int) bool {
+		return people[i].ID < people[j].ID
+	})
+
+	switch filters.Sort {
+	case "-full_name":
+		sort.SliceStable(people, func(i, j int) bool {
+			return people[i].FullName > people[j].FullName
+		})
+	case "birth_date":
+		sort.SliceStable(people, func(i, j int) bool {
+			return people[i].BirthDate.Before(people[j].BirthDate)
+		})
+	case "-birth_date":
+		sort.SliceStable(people, func(i, j int) bool {
+			return people[i].BirthDate.After(people[j].BirthDate)
+		})
+	default:
+		sort.SliceStable(people, func(i, j int) bool {
+			return people[i].FullName < people[j].FullName
+		})
+	}
+
+	c, err := filters.cursor()
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	start := filters.offset()
+	if c != nil {
+		for i, person := range people {
+			if person.ID == c.ID {
+				start = i + 1
+				break
+			}
+		}
+	}
+
+	totalRecords := len(people)
+	start = min(start, len(people))
+	end := min(start+filters.limit()+1, len(people))
+
+	people, nextCursor := paginatePeople(people[start:end], filters)
+	if len(people) == 0 {
+		totalRecords = 0
+	}
+
+	return people, calculateMetadata(totalRecords, filters, nextCursor), nil
+}
+
+func (m *MockPersonDB) Update(ctx context.Context, person *Person) error {
+	existing, found := m.People[person.ID]
+	if !found {
+		return ErrRecordNotFound
+	}
+
+	if existing.Version != person.Version {
+		return ErrEditConflict
+	}
+
+	for _, a := range m.People {
+		if a.FullName == person.FullName && a.ID != person.ID {
+			return ErrDuplicateName
+		}
+	}
+
+	person.Version++
+
+	updated := *existing
+	updated.FullName = person.FullName
+	updated.Gender = person.Gender
+	updated.BirthDate = person.BirthDate
+	updated.Version = person.Version
+	m.People[person.ID] = &updated
+
+	return nil
+}
+
+func sortedMovies(movies map[int64]*Movie) []*Movie {
+	sorted := make([]*Movie, 0, len(movies))
+
+	for _, movie := range movies {
+		sorted = append(sorted, movie)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		if !sorted[i].ReleaseDate.Equal(sorted[j].ReleaseDate) {
+			return sorted[i].ReleaseDate.Before(sorted[j].ReleaseDate)
+		}
+
+		return sorted[i].ID < sorted[j].ID
+	})
+
+	return sorted
+}
+
+// Роли человека в фильме: актерская из списка актеров и остальные из съемочной группы.
+func movieRoles(movie *Movie, personID int64) []string {
+	var roles []string
+
+	for _, member := range movie.Actors {
+		if member.ActorID == personID {
+			roles = append(roles, "actor")
+			break
+		}
+	}
+
+	for _, credit := range movie.Crew {
+		if credit.PersonID == personID {
+			roles = append(roles, credit.Role)
+		}
+	}
+
+	return roles
+}
+
+func hasRole(movies map[int64]*Movie, personID int64, role string) bool {
+	for _, movie := range movies {
+		for _, r := range movieRoles(movie, personID) {
+			if r == role {
+				return true
+			}
+		}
+	}
+
+	return false
+}
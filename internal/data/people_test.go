@@ -0,0 +1,169 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPersonDB_Get(t *testing.T) {
+	models := NewMockModels()
+
+	t.Run("Filmography", func(t *testing.T) {
+		person, err := models.People.Get(context.Background(), 3)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(person.Filmography["director"]) != 1 || person.Filmography["director"][0].MovieID != 1 {
+			t.Errorf("expected to direct movie 1, but got %+v", person.Filmography)
+		}
+
+		if _, found := person.Filmography["actor"]; found {
+			t.Errorf("expected no acting credits, but got %+v", person.Filmography["actor"])
+		}
+	})
+
+	t.Run("Character", func(t *testing.T) {
+		person, err := models.People.Get(context.Background(), 2)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		entries := person.Filmography["actor"]
+		if len(entries) != 1 || entries[0].Character != "Mock Villain" || entries[0].BillingOrder != 2 {
+			t.Errorf("expected Mock Villain billed second, but got %+v", entries)
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		_, err := models.People.Get(context.Background(), 42)
+		if !errors.Is(err, ErrRecordNotFound) {
+			t.Errorf("expected ErrRecordNotFound, but got %v", err)
+		}
+	})
+}
+
+func TestPersonDB_GetAll(t *testing.T) {
+	models := NewMockModels()
+
+	tests := []struct {
+		role     string
+		expected int
+	}{
+		{"", 3},
+		{"actor", 2},
+		{"director", 1},
+		{"composer", 0},
+	}
+
+	for _, tt := range tests {
+		people, _, err := models.People.GetAll(context.Background(), Filters{Page: 1, PageSize: 20, Sort: "full_name", Role: tt.role})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if len(people) != tt.expected {
+			t.Errorf("role %q: expected %d people, but got %d", tt.role, tt.expected, len(people))
+		}
+	}
+}
+
+func TestPersonDB_Insert(t *testing.T) {
+	models := NewMockModels()
+
+	t.Run("Valid", func(t *testing.T) {
+		person := &Person{
+			FullName:  "Jane Doe",
+			Gender:    "female",
+			BirthDate: time.Date(1990, 8, 12, 0, 0, 0, 0, time.UTC),
+		}
+
+		err := models.People.Insert(context.Background(), person)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if _, err := models.Actors.Get(context.Background(), person.ID); err != nil {
+			t.Errorf("expected person to be visible as actor, but got %v", err)
+		}
+	})
+
+	t.Run("Duplicate", func(t *testing.T) {
+		err := models.People.Insert(context.Background(), &Person{FullName: "Mock Director 1"})
+		if !errors.Is(err, ErrDuplicateName) {
+			t.Errorf("expected ErrDuplicateName, but got %v", err)
+		}
+	})
+}
+
+func TestPersonDB_Update(t *testing.T) {
+	models := NewMockModels()
+
+	t.Run("Valid", func(t *testing.T) {
+		person, err := models.People.Get(context.Background(), 3)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		person.FullName = "Mock Director 2"
+
+		err = models.People.Update(context.Background(), person)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if person.Version != 2 {
+			t.Errorf("expected version 2, but got %d", person.Version)
+		}
+	})
+
+	t.Run("EditConflict", func(t *testing.T) {
+		err := models.People.Update(context.Background(), &Person{ID: 3, FullName: "Mock Director 3", Version: 1})
+		if !errors.Is(err, ErrEditConflict) {
+			t.Errorf("expected ErrEditConflict, but got %v", err)
+		}
+	})
+}
+
+func TestMovieDB_GetCrew(t *testing.T) {
+	models := NewMockModels()
+
+	t.Run("Valid", func(t *testing.T) {
+		crew, err := models.Movies.GetCrew(context.Background(), 1)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(crew["actor"]) != 2 {
+			t.Errorf("expected 2 actors, but got %+v", crew["actor"])
+		}
+
+		if len(crew["director"]) != 1 || crew["director"][0].FullName != "Mock Director 1" {
+			t.Errorf("expected Mock Director 1, but got %+v", crew["director"])
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		_, err := models.Movies.GetCrew(context.Background(), 42)
+		if !errors.Is(err, ErrRecordNotFound) {
+			t.Errorf("expected ErrRecordNotFound, but got %v", err)
+		}
+	})
+
+	t.Run("UnknownCrewMember", func(t *testing.T) {
+		movie := &Movie{
+			Title:       "Movie 2",
+			Description: "Description 2",
+			ReleaseDate: time.Date(2000, 8, 12, 0, 0, 0, 0, time.UTC),
+			Actors:      []CastMember{{ActorID: 1, BillingOrder: 1}},
+			Crew:        []Credit{{PersonID: 42, Role: "writer"}},
+		}
+
+		err := models.Movies.Insert(context.Background(), movie)
+		if !errors.Is(err, ErrCrewNotFound) {
+			t.Errorf("expected ErrCrewNotFound, but got %v", err)
+		}
+	})
+}
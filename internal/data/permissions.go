@@ -0,0 +1,78 @@
+package data
+
+import (
+	"slices"
+	"strings"
+)
+
+// Права вида "ресурс:действие", например "movies:delete".
+type Permissions []string
+
+var Roles = []string{"user", "editor", "moderator", "admin"}
+
+var resources = []string{"movies", "actors", "people", "genres"}
+
+/*
+Права ролей по умолчанию. Таблица roles_permissions в sql/tables.sql
+заполняется так же, а моки используют эту карту вместо таблицы.
+Редактор добавляет и изменяет записи, но не удаляет их; модератор,
+наоборот, исправляет и удаляет, но не добавляет новое.
+*/
+var RolePermissions = map[string]Permissions{
+	"user":      resourcePermissions("read"),
+	"editor":    resourcePermissions("read", "create", "update"),
+	"moderator": resourcePermissions("read", "update", "delete"),
+	"admin":     append(resourcePermissions("read", "create", "update", "delete"), "apikeys:manage", "users:manage"),
+}
+
+/*
+Права, которые можно выдать API ключу: права на ресурсы и сокращения
+вида "movies:write", означающие create, update и delete для ресурса.
+Управление ключами и пользователями ключу не выдается никогда.
+*/
+var Scopes = scopes()
+
+func (p Permissions) Include(code string) bool {
+	return slices.Contains(p, code)
+}
+
+// Проверяет, дает ли хотя бы одна из областей API ключа право code.
+func ScopesGrant(scopes []string, code string) bool {
+	resource, action, _ := strings.Cut(code, ":")
+
+	for _, scope := range scopes {
+		if scope == code {
+			return true
+		}
+
+		if scope == resource+":write" && action != "read" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func resourcePermissions(actions ...string) Permissions {
+	var permissions Permissions
+
+	for _, resource := range resources {
+		for _, action := range actions {
+			permissions = append(permissions, resource+":"+action)
+		}
+	}
+
+	return permissions
+}
+
+func scopes() []string {
+	var scopes []string
+
+	for _, resource := range resources {
+		for _, action := range []string{"read", "write", "create", "update", "delete"} {
+			scopes = append(scopes, resource+":"+action)
+		}
+	}
+
+	return scopes
+}
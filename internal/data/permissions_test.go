@@ -0,0 +1,85 @@
+package data
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRolePermissions(t *testing.T) {
+	for _, role := range Roles {
+		if len(RolePermissions[role]) == 0 {
+			t.Errorf("expected permissions for role %q", role)
+		}
+	}
+
+	for _, permission := range RolePermissions["user"] {
+		if !strings.HasSuffix(permission, ":read") {
+			t.Errorf("expected only read permissions for user, but got %q", permission)
+		}
+	}
+
+	tests := []struct {
+		role       string
+		permission string
+		want       bool
+	}{
+		{"editor", "movies:create", true},
+		{"editor", "movies:update", true},
+		{"editor", "movies:delete", false},
+		{"moderator", "movies:delete", true},
+		{"moderator", "movies:create", false},
+		{"admin", "apikeys:manage", true},
+		{"moderator", "apikeys:manage", false},
+	}
+
+	for _, tt := range tests {
+		if got := RolePermissions[tt.role].Include(tt.permission); got != tt.want {
+			t.Errorf("expected %q to include %q = %v, but got %v", tt.role, tt.permission, tt.want, got)
+		}
+	}
+}
+
+func TestScopesGrant(t *testing.T) {
+	tests := []struct {
+		name   string
+		scopes []string
+		code   string
+		want   bool
+	}{
+		{"ExactScope", []string{"movies:delete"}, "movies:delete", true},
+		{"WriteCoversCreate", []string{"movies:write"}, "movies:create", true},
+		{"WriteCoversDelete", []string{"movies:write"}, "movies:delete", true},
+		{"WriteDoesNotCoverRead", []string{"movies:write"}, "movies:read", false},
+		{"OtherResource", []string{"actors:write"}, "movies:update", false},
+		{"NoManageScope", []string{"movies:write"}, "apikeys:manage", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ScopesGrant(tt.scopes, tt.code); got != tt.want {
+				t.Errorf("expected %v, but got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMockUserDB_Permissions(t *testing.T) {
+	models := NewMockModels()
+
+	user, err := models.Users.Get(context.Background(), "user")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !user.Permissions.Include("movies:read") || user.Permissions.Include("movies:create") {
+		t.Errorf("unexpected permissions %v", user.Permissions)
+	}
+
+	user.Permissions = append(user.Permissions, "movies:delete")
+
+	again, _ := models.Users.Get(context.Background(), "user")
+	if again.Permissions.Include("movies:delete") {
+		t.Errorf("expected permissions to be copied from the role")
+	}
+}
@@ -0,0 +1,116 @@
+package data
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+/*
+RoleChange - запись журнала изменений ролей: кто, когда и какую роль
+назначил пользователю. ChangedBy равен nil, если администратор, сделавший
+изменение, уже удален.
+*/
+type RoleChange struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	OldRole   string    `json:"old_role"`
+	NewRole   string    `json:"new_role"`
+	ChangedBy *int64    `json:"changed_by"`
+	ChangedAt time.Time `json:"changed_at"` // RFC3339
+}
+
+type RoleChangeModel interface {
+	Insert(ctx context.Context, change *RoleChange) error
+	GetAllForUser(ctx context.Context, userID int64) ([]*RoleChange, error)
+}
+
+type RoleChangeDB struct {
+	DB      Querier
+	Timeout time.Duration
+}
+
+type MockRoleChangeDB struct {
+	Changes map[int64]*RoleChange
+}
+
+func (m RoleChangeDB) Insert(ctx context.Context, change *RoleChange) error {
+	query := `
+		INSERT INTO role_changes (user_id, old_role, new_role, changed_by)
+		VALUES ($1, $2, $3, $4)
+		RETURNING role_change_id, changed_at`
+
+	args := []interface{}{change.UserID, change.OldRole, change.NewRole, change.ChangedBy}
+
+	ctx, cancel := startMethod(ctx, m.Timeout, "RoleChangeDB", "Insert")
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&change.ID, &change.ChangedAt)
+}
+
+func (m RoleChangeDB) GetAllForUser(ctx context.Context, userID int64) ([]*RoleChange, error) {
+	query := `
+		SELECT role_change_id, user_id, old_role, new_role, changed_by, changed_at
+		FROM role_changes
+		WHERE user_id = $1
+		ORDER BY role_change_id`
+
+	ctx, cancel := startMethod(ctx, m.Timeout, "RoleChangeDB", "GetAllForUser")
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	changes := []*RoleChange{}
+
+	for rows.Next() {
+		var change RoleChange
+
+		err = rows.Scan(&change.ID,
+			&change.UserID,
+			&change.OldRole,
+			&change.NewRole,
+			&change.ChangedBy,
+			&change.ChangedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		changes = append(changes, &change)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+func (m *MockRoleChangeDB) Insert(ctx context.Context, change *RoleChange) error {
+	change.ID = int64(len(m.Changes) + 1)
+	change.ChangedAt = time.Now()
+
+	m.Changes[change.ID] = change.clone()
+
+	return nil
+}
+
+func (m *MockRoleChangeDB) GetAllForUser(ctx context.Context, userID int64) ([]*RoleChange, error) {
+	changes := []*RoleChange{}
+
+	for _, change := range m.Changes {
+		if change.UserID == userID {
+			changes = append(changes, change.clone())
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].ID < changes[j].ID
+	})
+
+	return changes, nil
+}
@@ -0,0 +1,79 @@
+package data
+
+import (
+	"context"
+	"filmoteka/internal/validator"
+	"testing"
+	"time"
+)
+
+func TestGenerateToken(t *testing.T) {
+	token, err := generateToken(1, time.Hour, ScopeAuthentication)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	v := validator.New()
+	if ValidateTokenPlaintext(v, token.Plaintext); !v.Valid() {
+		t.Errorf("expected valid token, but got errors %v", v.Errors)
+	}
+
+	if len(token.Hash) != 32 {
+		t.Errorf("expected SHA-256 hash, but got %d bytes", len(token.Hash))
+	}
+}
+
+func TestMockTokenDB(t *testing.T) {
+	models := NewMockModels()
+
+	token, err := models.Tokens.New(context.Background(), 1, time.Hour, ScopeAuthentication)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	t.Run("GetForToken", func(t *testing.T) {
+		user, err := models.Users.GetForToken(context.Background(), ScopeAuthentication, token.Plaintext)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if user.Name != "user" {
+			t.Errorf("expected user %q, but got %q", "user", user.Name)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		err := models.Tokens.Delete(context.Background(), ScopeAuthentication, token.Plaintext)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		_, err = models.Users.GetForToken(context.Background(), ScopeAuthentication, token.Plaintext)
+		if err != ErrRecordNotFound {
+			t.Errorf("expected error %v, but got %v", ErrRecordNotFound, err)
+		}
+
+		err = models.Tokens.Delete(context.Background(), ScopeAuthentication, token.Plaintext)
+		if err != ErrRecordNotFound {
+			t.Errorf("expected error %v, but got %v", ErrRecordNotFound, err)
+		}
+	})
+
+	t.Run("DeleteAllForUser", func(t *testing.T) {
+		first, _ := models.Tokens.New(context.Background(), 1, time.Hour, ScopeAuthentication)
+		second, _ := models.Tokens.New(context.Background(), 2, time.Hour, ScopeAuthentication)
+
+		err := models.Tokens.DeleteAllForUser(context.Background(), ScopeAuthentication, 1)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if _, err := models.Users.GetForToken(context.Background(), ScopeAuthentication, first.Plaintext); err != ErrRecordNotFound {
+			t.Errorf("expected error %v, but got %v", ErrRecordNotFound, err)
+		}
+
+		if _, err := models.Users.GetForToken(context.Background(), ScopeAuthentication, second.Plaintext); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
@@ -2,39 +2,79 @@ package data
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
 	"errors"
 	"filmoteka/internal/validator"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/lib/pq"
 	"golang.org/x/crypto/bcrypt"
 )
 
+var (
+	ErrDuplicateEmail = errors.New("duplicate email")
+)
+
 var AnonymousUser = &User{}
 
 type User struct {
-	ID       int64    `json:"id"`
-	Name     string   `json:"name"`
-	Password password `json:"-"`
-	Role     string   `json:"role"`
+	ID          int64       `json:"id"`
+	Name        string      `json:"name"`
+	Email       string      `json:"email"`
+	Password    password    `json:"-"`
+	Role        string      `json:"role"`
+	Activated   bool        `json:"activated"`
+	Disabled    bool        `json:"disabled"`
+	Permissions Permissions `json:"permissions"`
+}
+
+var userSortColumns = map[string]string{
+	"id":   "user_id",
+	"name": "username",
 }
 
+// Права роли пользователя из таблицы roles_permissions.
+const userPermissionsColumn = `
+	ARRAY(
+		SELECT rp.permission
+		FROM roles_permissions rp
+		WHERE rp.role = u.role
+		ORDER BY rp.permission
+	)`
+
 type password struct {
 	plaintext *string
 	hash      []byte
 }
 
 type UserModel interface {
-	Insert(user *User) error
-	Get(username string) (*User, error)
+	Insert(ctx context.Context, user *User) error
+	Get(ctx context.Context, username string) (*User, error)
+	GetForToken(ctx context.Context, scope, tokenPlaintext string) (*User, error)
+	GetForAPIKey(ctx context.Context, plaintext string) (*User, *APIKey, error)
+	GetByID(ctx context.Context, id int64) (*User, error)
+	GetByEmail(ctx context.Context, email string) (*User, error)
+	GetAll(ctx context.Context, name, role string, filters Filters) ([]*User, Metadata, error)
+	UpdatePassword(ctx context.Context, user *User) error
+	Activate(ctx context.Context, id int64) error
+	UpdateRoleStatus(ctx context.Context, user *User) error
+	Delete(ctx context.Context, id int64) error
 }
 
 type UserDB struct {
-	DB *sql.DB
+	DB      Querier
+	Timeout time.Duration
 }
 
 type MockUserDB struct {
-	Users map[string]*User
+	Users   map[string]*User
+	Tokens  map[string]*Token
+	APIKeys map[int64]*APIKey
 }
 
 func GeneratePasswordHash(plaintextPassword string) ([]byte, error) {
@@ -73,11 +113,19 @@ func ValidatePasswordPlaintext(v *validator.Validator, password string) {
 	v.Check(len(password) <= 72, "password", "must not be more than 72 bytes long")
 }
 
+func ValidateEmail(v *validator.Validator, email string) {
+	v.Check(email != "", "email", "must be provided")
+	v.Check(len(email) <= 254, "email", "must not be more than 254 bytes long")
+	v.Check(validator.Matches(email, validator.EmailRX), "email", "must be a valid email address")
+}
+
 func ValidateUser(v *validator.Validator, user *User) {
 	v.Check(user.Name != "", "name", "must be provided")
 	v.Check(len(user.Name) <= 200, "name", "must not be more than 500 bytes long")
 
-	v.Check(user.Role == "user" || user.Role == "admin", "role", "must be either user or admin")
+	ValidateEmail(v, user.Email)
+
+	v.Check(validator.In(user.Role, Roles...), "role", "must be one of: "+strings.Join(Roles, ", "))
 
 	if user.Password.plaintext != nil {
 		ValidatePasswordPlaintext(v, *user.Password.plaintext)
@@ -88,22 +136,24 @@ func ValidateUser(v *validator.Validator, user *User) {
 	}
 }
 
-func (m UserDB) Insert(user *User) error {
+func (m UserDB) Insert(ctx context.Context, user *User) error {
 	query := `
-		INSERT INTO users (username, password_hash, role)
-		VALUES ($1, $2, $3)
-		RETURNING user_id`
+		INSERT INTO users AS u (username, email, password_hash, role, activated)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING u.user_id, ` + userPermissionsColumn
 
-	args := []interface{}{user.Name, user.Password.hash, user.Role}
+	args := []interface{}{user.Name, user.Email, user.Password.hash, user.Role, user.Activated}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := startMethod(ctx, m.Timeout, "UserDB", "Insert")
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&user.ID)
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&user.ID, pq.Array((*[]string)(&user.Permissions)))
 	if err != nil {
 		switch {
 		case err.Error() == `pq: duplicate key value violates unique constraint "users_username_key"`:
 			return ErrDuplicateName
+		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
+			return ErrDuplicateEmail
 		default:
 			return err
 		}
@@ -112,19 +162,101 @@ func (m UserDB) Insert(user *User) error {
 	return nil
 }
 
-func (m UserDB) Get(username string) (*User, error) {
+func (m UserDB) Get(ctx context.Context, username string) (*User, error) {
+	query := `
+		SELECT u.user_id, u.username, u.email, u.password_hash, u.role, u.activated, u.disabled, ` + userPermissionsColumn + `
+		FROM users u
+		WHERE u.username = $1`
+
+	ctx, cancel := startMethod(ctx, m.Timeout, "UserDB", "Get")
+	defer cancel()
+
+	var user User
+
+	err := m.DB.QueryRowContext(ctx, query, username).Scan(&user.ID, &user.Name, &user.Email, &user.Password.hash, &user.Role, &user.Activated, &user.Disabled, pq.Array((*[]string)(&user.Permissions)))
+	if err != nil {
+		switch {
+		case err == sql.ErrNoRows:
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &user, nil
+}
+
+/*
+Находит владельца токена. Истекшие токены не удаляются отдельно, а просто
+перестают находиться, поэтому для них, как и для отозванных, возвращается ErrRecordNotFound.
+*/
+func (m UserDB) GetForToken(ctx context.Context, scope, tokenPlaintext string) (*User, error) {
+	query := `
+		SELECT u.user_id, u.username, u.email, u.password_hash, u.role, u.activated, u.disabled, ` + userPermissionsColumn + `
+		FROM users u
+		INNER JOIN tokens t ON u.user_id = t.user_id
+		WHERE t.hash = $1
+		AND t.scope = $2
+		AND t.expiry > $3`
+
+	hash := sha256.Sum256([]byte(tokenPlaintext))
+
+	args := []interface{}{hash[:], scope, time.Now()}
+
+	ctx, cancel := startMethod(ctx, m.Timeout, "UserDB", "GetForToken")
+	defer cancel()
+
+	var user User
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&user.ID, &user.Name, &user.Email, &user.Password.hash, &user.Role, &user.Activated, &user.Disabled, pq.Array((*[]string)(&user.Permissions)))
+	if err != nil {
+		switch {
+		case err == sql.ErrNoRows:
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &user, nil
+}
+
+func (m UserDB) GetByID(ctx context.Context, id int64) (*User, error) {
+	query := `
+		SELECT u.user_id, u.username, u.email, u.password_hash, u.role, u.activated, u.disabled, ` + userPermissionsColumn + `
+		FROM users u
+		WHERE u.user_id = $1`
+
+	ctx, cancel := startMethod(ctx, m.Timeout, "UserDB", "GetByID")
+	defer cancel()
+
+	var user User
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&user.ID, &user.Name, &user.Email, &user.Password.hash, &user.Role, &user.Activated, &user.Disabled, pq.Array((*[]string)(&user.Permissions)))
+	if err != nil {
+		switch {
+		case err == sql.ErrNoRows:
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &user, nil
+}
+
+func (m UserDB) GetByEmail(ctx context.Context, email string) (*User, error) {
 	query := `
-		SELECT user_id, username, password_hash, role
-		FROM users
-		WHERE username = $1
-	`
+		SELECT u.user_id, u.username, u.email, u.password_hash, u.role, u.activated, u.disabled, ` + userPermissionsColumn + `
+		FROM users u
+		WHERE u.email = $1`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := startMethod(ctx, m.Timeout, "UserDB", "GetByEmail")
 	defer cancel()
 
 	var user User
 
-	err := m.DB.QueryRowContext(ctx, query, username).Scan(&user.ID, &user.Name, &user.Password.hash, &user.Role)
+	err := m.DB.QueryRowContext(ctx, query, email).Scan(&user.ID, &user.Name, &user.Email, &user.Password.hash, &user.Role, &user.Activated, &user.Disabled, pq.Array((*[]string)(&user.Permissions)))
 	if err != nil {
 		switch {
 		case err == sql.ErrNoRows:
@@ -137,26 +269,328 @@ func (m UserDB) Get(username string) (*User, error) {
 	return &user, nil
 }
 
+/*
+Возвращает страницу пользователей, имя которых содержит name без учета
+регистра. Пустые name и role не ограничивают выборку.
+*/
+func (m UserDB) GetAll(ctx context.Context, name, role string, filters Filters) ([]*User, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), u.user_id, u.username, u.email, u.role, u.activated, u.disabled, %s
+		FROM users u
+		WHERE (u.username ILIKE '%%' || $1 || '%%' OR $1 = '')
+		AND (u.role = $2 OR $2 = '')
+		ORDER BY u.%s %s, u.user_id ASC
+		LIMIT $3 OFFSET $4`, userPermissionsColumn, userSortColumns[filters.sortColumn()], filters.sortDirection())
+
+	args := []interface{}{name, role, filters.limit(), filters.offset()}
+
+	ctx, cancel := startMethod(ctx, m.Timeout, "UserDB", "GetAll")
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	users := []*User{}
+
+	for rows.Next() {
+		var user User
+
+		err = rows.Scan(&totalRecords,
+			&user.ID,
+			&user.Name,
+			&user.Email,
+			&user.Role,
+			&user.Activated,
+			&user.Disabled,
+			pq.Array((*[]string)(&user.Permissions)))
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		users = append(users, &user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return users, calculateMetadata(totalRecords, filters, ""), nil
+}
+
+/*
+Сохраняет только хеш пароля. Пользователь прочитан в начале запроса, и
+запись всей строки затерла бы блокировку или роль, выставленные
+администратором, пока вычислялся bcrypt-хеш.
+*/
+func (m UserDB) UpdatePassword(ctx context.Context, user *User) error {
+	query := `
+		UPDATE users
+		SET password_hash = $1
+		WHERE user_id = $2`
+
+	ctx, cancel := startMethod(ctx, m.Timeout, "UserDB", "UpdatePassword")
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, user.Password.hash, user.ID)
+	if err != nil {
+		return err
+	}
+
+	return checkAffectedRows(result)
+}
+
+// Отмечает аккаунт активированным.
+func (m UserDB) Activate(ctx context.Context, id int64) error {
+	query := `
+		UPDATE users
+		SET activated = true
+		WHERE user_id = $1`
+
+	ctx, cancel := startMethod(ctx, m.Timeout, "UserDB", "Activate")
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	return checkAffectedRows(result)
+}
+
+// Сохраняет роль и блокировку; права пересчитываются по новой роли.
+func (m UserDB) UpdateRoleStatus(ctx context.Context, user *User) error {
+	query := `
+		UPDATE users AS u
+		SET role = $1, disabled = $2
+		WHERE u.user_id = $3
+		RETURNING ` + userPermissionsColumn
+
+	ctx, cancel := startMethod(ctx, m.Timeout, "UserDB", "UpdateRoleStatus")
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, user.Role, user.Disabled, user.ID).Scan(pq.Array((*[]string)(&user.Permissions)))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Удаляет пользователя вместе с его токенами и API ключами.
+func (m UserDB) Delete(ctx context.Context, id int64) error {
+	query := `
+		DELETE FROM users
+		WHERE user_id = $1`
+
+	ctx, cancel := startMethod(ctx, m.Timeout, "UserDB", "Delete")
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	return checkAffectedRows(result)
+}
+
 func (u *User) IsAnonymous() bool {
 	return u == AnonymousUser
 }
 
-func (m *MockUserDB) Insert(user *User) error {
+func (m *MockUserDB) Insert(ctx context.Context, user *User) error {
 	if _, found := m.Users[user.Name]; found {
 		return ErrDuplicateName
 	}
 
-	user.ID = int64(len(m.Users) + 1)
-	m.Users[user.Name] = user
+	if m.emailTaken(user.Email, 0) {
+		return ErrDuplicateEmail
+	}
+
+	user.ID = 1
+	for _, existing := range m.Users {
+		user.ID = max(user.ID, existing.ID+1)
+	}
+
+	m.Users[user.Name] = withPermissions(user).clone()
 
 	return nil
 }
 
-func (m *MockUserDB) Get(username string) (*User, error) {
+func (m *MockUserDB) Get(ctx context.Context, username string) (*User, error) {
 	user, found := m.Users[username]
 	if !found {
 		return nil, ErrRecordNotFound
 	}
 
-	return user, nil
+	return withPermissions(user.clone()), nil
+}
+
+func (m *MockUserDB) GetForToken(ctx context.Context, scope, tokenPlaintext string) (*User, error) {
+	hash := sha256.Sum256([]byte(tokenPlaintext))
+
+	token, found := m.Tokens[string(hash[:])]
+	if !found || token.Scope != scope || !token.Expiry.After(time.Now()) {
+		return nil, ErrRecordNotFound
+	}
+
+	for _, user := range m.Users {
+		if user.ID == token.UserID {
+			return withPermissions(user.clone()), nil
+		}
+	}
+
+	return nil, ErrRecordNotFound
+}
+
+func (m *MockUserDB) GetByID(ctx context.Context, id int64) (*User, error) {
+	for _, user := range m.Users {
+		if user.ID == id {
+			return withPermissions(user.clone()), nil
+		}
+	}
+
+	return nil, ErrRecordNotFound
+}
+
+func (m *MockUserDB) GetByEmail(ctx context.Context, email string) (*User, error) {
+	for _, user := range m.Users {
+		if user.Email == email {
+			return withPermissions(user.clone()), nil
+		}
+	}
+
+	return nil, ErrRecordNotFound
+}
+
+func (m *MockUserDB) GetAll(ctx context.Context, name, role string, filters Filters) ([]*User, Metadata, error) {
+	var users []*User
+
+	for _, user := range m.Users {
+		if !strings.Contains(strings.ToLower(user.Name), strings.ToLower(name)) {
+			continue
+		}
+
+		if role != "" && user.Role != role {
+			continue
+		}
+
+		users = append(users, withPermissions(user.clone()))
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].ID < users[j].ID
+	})
+
+	switch filters.Sort {
+	case "-id":
+		slices.Reverse(users)
+	case "name":
+		sort.SliceStable(users, func(i, j int) bool {
+			return users[i].Name < users[j].Name
+		})
+	case "-name":
+		sort.SliceStable(users, func(i, j int) bool {
+			return users[i].Name > users[j].Name
+		})
+	}
+
+	totalRecords := len(users)
+	start := min(filters.offset(), len(users))
+	end := min(start+filters.limit(), len(users))
+
+	users = users[start:end]
+	if len(users) == 0 {
+		totalRecords = 0
+		users = []*User{}
+	}
+
+	return users, calculateMetadata(totalRecords, filters, ""), nil
+}
+
+func (m *MockUserDB) UpdatePassword(ctx context.Context, user *User) error {
+	return m.update(user.ID, func(stored *User) {
+		stored.Password = user.Password
+	})
+}
+
+func (m *MockUserDB) Activate(ctx context.Context, id int64) error {
+	return m.update(id, func(stored *User) {
+		stored.Activated = true
+	})
+}
+
+func (m *MockUserDB) UpdateRoleStatus(ctx context.Context, user *User) error {
+	return m.update(user.ID, func(stored *User) {
+		stored.Role = user.Role
+		stored.Disabled = user.Disabled
+		user.Permissions = withPermissions(stored).Permissions
+	})
+}
+
+// Заменяет сохраненного пользователя копией, измененной функцией change.
+func (m *MockUserDB) update(id int64, change func(stored *User)) error {
+	for name, existing := range m.Users {
+		if existing.ID == id {
+			updated := existing.clone()
+			change(updated)
+			m.Users[name] = updated
+
+			return nil
+		}
+	}
+
+	return ErrRecordNotFound
+}
+
+func (m *MockUserDB) Delete(ctx context.Context, id int64) error {
+	for name, user := range m.Users {
+		if user.ID != id {
+			continue
+		}
+
+		delete(m.Users, name)
+
+		for hash, token := range m.Tokens {
+			if token.UserID == id {
+				delete(m.Tokens, hash)
+			}
+		}
+
+		for keyID, key := range m.APIKeys {
+			if key.UserID == id {
+				delete(m.APIKeys, keyID)
+			}
+		}
+
+		return nil
+	}
+
+	return ErrRecordNotFound
+}
+
+// Проверяет, занят ли email другим пользователем, чем exceptID.
+func (m *MockUserDB) emailTaken(email string, exceptID int64) bool {
+	for _, user := range m.Users {
+		if email != "" && user.Email == email && user.ID != exceptID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Заменяет таблицу roles_permissions в моках: права берутся из RolePermissions.
+func withPermissions(user *User) *User {
+	user.Permissions = slices.Clone(RolePermissions[user.Role])
+	return user
 }
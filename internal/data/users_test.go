@@ -1,6 +1,7 @@
 package data
 
 import (
+	"context"
 	"filmoteka/internal/validator"
 	"testing"
 
@@ -118,8 +119,9 @@ func TestValidateUser(t *testing.T) {
 
 	t.Run("Valid", func(t *testing.T) {
 		user := &User{
-			Name: "John Doe",
-			Role: "user",
+			Name:  "John Doe",
+			Email: "john@example.com",
+			Role:  "user",
 		}
 
 		err := user.Password.Set("password123")
@@ -167,6 +169,26 @@ func TestValidateUser(t *testing.T) {
 		}
 	})
 
+	t.Run("InvalidEmail", func(t *testing.T) {
+		v := validator.New()
+
+		user := &User{
+			Name:  "John Doe",
+			Email: "john.example.com",
+			Role:  "user",
+		}
+
+		err := user.Password.Set("password123")
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		ValidateUser(v, user)
+		if _, found := v.Errors["email"]; !found {
+			t.Errorf("expected email error, but got %v", v.Errors)
+		}
+	})
+
 	t.Run("InvalidRole", func(t *testing.T) {
 		user := &User{
 			Name: "John Doe",
@@ -213,7 +235,7 @@ func TestUserDB_Insert(t *testing.T) {
 			Role: "user",
 		}
 
-		err := mockDB.Insert(user)
+		err := mockDB.Insert(context.Background(), user)
 		if err != nil {
 			t.Errorf("unexpected error: %v", err)
 		}
@@ -235,7 +257,7 @@ func TestUserDB_Insert(t *testing.T) {
 
 		lenBefore := len(mockDB.Users)
 
-		err := mockDB.Insert(user)
+		err := mockDB.Insert(context.Background(), user)
 		if err == nil {
 			t.Error("expected error, but got none")
 		}
@@ -269,7 +291,7 @@ func TestMockUserDB_Get(t *testing.T) {
 		username := "John Doe"
 		expectedUser := &User{Name: "John Doe", Role: "user"}
 
-		user, err := mockDB.Get(username)
+		user, err := mockDB.Get(context.Background(), username)
 		if err != nil {
 			t.Errorf("unexpected error: %v", err)
 		}
@@ -286,7 +308,7 @@ func TestMockUserDB_Get(t *testing.T) {
 	t.Run("NonExistingUser", func(t *testing.T) {
 		username := "Jane Doe"
 
-		user, err := mockDB.Get(username)
+		user, err := mockDB.Get(context.Background(), username)
 		if err != ErrRecordNotFound {
 			t.Errorf("expected ErrRecordNotFound, but got %v", err)
 		}
@@ -296,3 +318,115 @@ func TestMockUserDB_Get(t *testing.T) {
 		}
 	})
 }
+
+func TestMockUserDB_GetAll(t *testing.T) {
+	mockDB := MockUserDB{
+		Users: map[string]*User{
+			"John Doe":   {ID: 1, Name: "John Doe", Role: "user"},
+			"Jane Doe":   {ID: 2, Name: "Jane Doe", Role: "editor"},
+			"Jack Smith": {ID: 3, Name: "Jack Smith", Role: "editor"},
+		},
+	}
+
+	filters := Filters{Page: 1, PageSize: 20, Sort: "-name", SortSafelist: []string{"id", "name", "-id", "-name"}}
+
+	users, metadata, err := mockDB.GetAll(context.Background(), "doe", "", filters)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(users) != 2 || users[0].Name != "John Doe" || metadata.TotalRecords != 2 {
+		t.Errorf("unexpected users %v with metadata %+v", users, metadata)
+	}
+
+	users, _, err = mockDB.GetAll(context.Background(), "", "editor", filters)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, user := range users {
+		if user.Role != "editor" || !user.Permissions.Include("movies:update") {
+			t.Errorf("unexpected user %v", user)
+		}
+	}
+}
+
+func TestMockUserDB_UpdateRoleStatus(t *testing.T) {
+	mockDB := MockUserDB{
+		Users: map[string]*User{
+			"John Doe": {ID: 1, Name: "John Doe", Role: "user", Activated: true, Password: password{hash: []byte("hash")}},
+		},
+	}
+
+	user := &User{ID: 1, Name: "John Doe", Role: "moderator", Disabled: true}
+
+	err := mockDB.UpdateRoleStatus(context.Background(), user)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !user.Permissions.Include("movies:delete") {
+		t.Errorf("expected moderator permissions, but got %v", user.Permissions)
+	}
+
+	stored, _ := mockDB.GetByID(context.Background(), 1)
+	if stored.Role != "moderator" || !stored.Disabled {
+		t.Errorf("unexpected stored user %v", stored)
+	}
+
+	if !stored.Activated || string(stored.Password.hash) != "hash" {
+		t.Errorf("expected activation and password to be left untouched, but got %v", stored)
+	}
+
+	err = mockDB.UpdateRoleStatus(context.Background(), &User{ID: 42, Role: "user"})
+	if err != ErrRecordNotFound {
+		t.Errorf("expected ErrRecordNotFound, but got %v", err)
+	}
+}
+
+func TestMockUserDB_UpdatePassword(t *testing.T) {
+	mockDB := MockUserDB{
+		Users: map[string]*User{
+			"John Doe": {ID: 1, Name: "John Doe", Role: "user"},
+		},
+	}
+
+	// Администратор заблокировал аккаунт после того, как пользователь был прочитан.
+	user, _ := mockDB.GetByID(context.Background(), 1)
+	mockDB.UpdateRoleStatus(context.Background(), &User{ID: 1, Role: "user", Disabled: true})
+
+	user.Password.hash = []byte("new hash")
+
+	err := mockDB.UpdatePassword(context.Background(), user)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	stored, _ := mockDB.GetByID(context.Background(), 1)
+	if string(stored.Password.hash) != "new hash" || !stored.Disabled {
+		t.Errorf("expected only the password to change, but got %v", stored)
+	}
+}
+
+func TestMockUserDB_GetByEmail(t *testing.T) {
+	mockDB := MockUserDB{
+		Users: map[string]*User{
+			"John Doe": {ID: 1, Name: "John Doe", Email: "john@example.com", Role: "user"},
+		},
+	}
+
+	user, err := mockDB.GetByEmail(context.Background(), "john@example.com")
+	if err != nil || user.Name != "John Doe" {
+		t.Errorf("expected John Doe, but got %v, %v", user, err)
+	}
+
+	_, err = mockDB.GetByEmail(context.Background(), "jane@example.com")
+	if err != ErrRecordNotFound {
+		t.Errorf("expected ErrRecordNotFound, but got %v", err)
+	}
+
+	err = mockDB.Insert(context.Background(), &User{Name: "Jane Doe", Email: "john@example.com", Role: "user"})
+	if err != ErrDuplicateEmail {
+		t.Errorf("expected ErrDuplicateEmail, but got %v", err)
+	}
+}
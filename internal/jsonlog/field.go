@@ -0,0 +1,120 @@
+package jsonlog
+
+import (
+	"time"
+)
+
+/*
+Field - свойство записи лога с типизированным значением. Числа и логические
+значения попадают в JSON как есть, длительности - строкой вида 1.5s,
+время - в RFC3339, ошибки - текстом, а Group - вложенным объектом.
+Если ключ повторяется, побеждает поле, добавленное позже.
+*/
+type Field struct {
+	Key   string
+	Value any
+}
+
+// Значение поля Stack, которое не попадает в свойства записи.
+type stackOption bool
+
+func String(key, value string) Field { return Field{key, value} }
+
+func Int(key string, value int) Field { return Field{key, value} }
+
+func Int64(key string, value int64) Field { return Field{key, value} }
+
+func Float64(key string, value float64) Field { return Field{key, value} }
+
+func Bool(key string, value bool) Field { return Field{key, value} }
+
+func Duration(key string, value time.Duration) Field { return Field{key, value} }
+
+func Time(key string, value time.Time) Field { return Field{key, value} }
+
+// Текст ошибки под ключом error.
+func Err(err error) Field { return Field{"error", err} }
+
+// Произвольное значение, которое кодируется через encoding/json.
+func Any(key string, value any) Field { return Field{key, value} }
+
+// Вложенный объект; поля группы с пустым ключом добавляются на верхний уровень.
+func Group(key string, fields ...Field) Field { return Field{key, fields} }
+
+// Включает или отключает стек вызовов для одной записи независимо от ее уровня.
+func Stack(enabled bool) Field { return Field{Value: stackOption(enabled)} }
+
+func propertyFields(properties map[string]string) []Field {
+	if len(properties) == 0 {
+		return nil
+	}
+
+	fields := make([]Field, 0, len(properties))
+
+	for key, value := range properties {
+		fields = append(fields, String(key, value))
+	}
+
+	return fields
+}
+
+func stackEnabled(level Level, fields []Field) bool {
+	enabled := level >= LevelError
+
+	for _, field := range fields {
+		if option, ok := field.Value.(stackOption); ok {
+			enabled = bool(option)
+		}
+	}
+
+	return enabled
+}
+
+func fieldsMap(fields []Field) map[string]any {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	properties := make(map[string]any, len(fields))
+	addFields(properties, fields)
+
+	if len(properties) == 0 {
+		return nil
+	}
+
+	return properties
+}
+
+// Одноименные группы, например из slog.Logger.WithGroup, сливаются в один объект.
+func addFields(properties map[string]any, fields []Field) {
+	for _, field := range fields {
+		switch value := field.Value.(type) {
+		case stackOption:
+		case []Field:
+			if len(value) == 0 {
+				continue
+			}
+
+			if field.Key == "" {
+				addFields(properties, value)
+				continue
+			}
+
+			group, ok := properties[field.Key].(map[string]any)
+			if !ok {
+				group = make(map[string]any, len(value))
+				properties[field.Key] = group
+			}
+
+			addFields(group, value)
+		case time.Duration:
+			properties[field.Key] = value.String()
+		case time.Time:
+			properties[field.Key] = value.UTC().Format(time.RFC3339Nano)
+		case error:
+			properties[field.Key] = value.Error()
+		default:
+			properties[field.Key] = value
+		}
+	}
+}
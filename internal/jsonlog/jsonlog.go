@@ -2,9 +2,13 @@ package jsonlog
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"runtime"
 	"runtime/debug"
+	"strings"
 	"sync"
 	"time"
 )
@@ -12,7 +16,9 @@ import (
 type Level int8
 
 const (
-	LevelInfo Level = iota
+	LevelDebug Level = iota - 1
+	LevelInfo
+	LevelWarn
 	LevelError
 	LevelFatal
 	LevelOff
@@ -20,8 +26,12 @@ const (
 
 func (l Level) String() string {
 	switch l {
+	case LevelDebug:
+		return "DEBUG"
 	case LevelInfo:
 		return "INFO"
+	case LevelWarn:
+		return "WARN"
 	case LevelError:
 		return "ERROR"
 	case LevelFatal:
@@ -31,10 +41,36 @@ func (l Level) String() string {
 	}
 }
 
+// Разбирает название уровня без учета регистра, например из флага -log-level.
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	case "fatal":
+		return LevelFatal, nil
+	case "off":
+		return LevelOff, nil
+	default:
+		return LevelInfo, fmt.Errorf("unknown log level %q, expected debug, info, warn, error, fatal or off", s)
+	}
+}
+
 type Logger struct {
 	out      io.Writer
 	minLevel Level
-	mu       sync.Mutex
+	// Добавлять к записям файл и строку, откуда они сделаны.
+	caller bool
+	mu     sync.Mutex
+
+	// У логгера, созданного With, - исходный логгер, через который идет запись.
+	root   *Logger
+	fields []Field
 }
 
 func New(out io.Writer, minLevel Level) *Logger {
@@ -44,38 +80,145 @@ func New(out io.Writer, minLevel Level) *Logger {
 	}
 }
 
+/*
+Возвращает логгер, добавляющий properties к каждой записи, например
+идентификатор трассы запроса. Свойства, переданные при вызове, имеют
+приоритет. Записи пишутся в тот же out под той же блокировкой.
+*/
+func (l *Logger) With(properties map[string]string) *Logger {
+	return l.WithFields(propertyFields(properties)...)
+}
+
+// То же, что With, но с типизированными полями.
+func (l *Logger) WithFields(fields ...Field) *Logger {
+	child := l.child()
+	child.fields = append(child.fields, fields...)
+
+	return child
+}
+
+// Возвращает логгер, который добавляет или не добавляет к записям место вызова.
+func (l *Logger) WithCaller(enabled bool) *Logger {
+	child := l.child()
+	child.caller = enabled
+
+	return child
+}
+
+func (l *Logger) child() *Logger {
+	root := l
+	if l.root != nil {
+		root = l.root
+	}
+
+	return &Logger{
+		out:      l.out,
+		minLevel: l.minLevel,
+		caller:   l.caller,
+		root:     root,
+		fields:   l.fields[:len(l.fields):len(l.fields)],
+	}
+}
+
+// Сообщает, будет ли записана запись уровня level, чтобы не готовить поля впустую.
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.minLevel
+}
+
+func (l *Logger) Debug(message string, fields ...Field) {
+	l.log(LevelDebug, message, fields)
+}
+
+func (l *Logger) Info(message string, fields ...Field) {
+	l.log(LevelInfo, message, fields)
+}
+
+func (l *Logger) Warn(message string, fields ...Field) {
+	l.log(LevelWarn, message, fields)
+}
+
+/*
+Записывает ошибку со стеком вызовов. Для ожидаемых сбоев, например
+недоступности почтового сервера, стек можно отключить полем Stack(false).
+*/
+func (l *Logger) Error(err error, fields ...Field) {
+	l.log(LevelError, err.Error(), fields)
+}
+
 func (l *Logger) PrintInfo(message string, properties map[string]string) {
-	l.print(LevelInfo, message, properties)
+	l.log(LevelInfo, message, propertyFields(properties))
 }
 
 func (l *Logger) PrintError(err error, properties map[string]string) {
-	l.print(LevelError, err.Error(), properties)
+	l.log(LevelError, err.Error(), propertyFields(properties))
 }
 
 func (l *Logger) PrintFatal(err error, properties map[string]string) {
-	l.print(LevelFatal, err.Error(), properties)
+	l.log(LevelFatal, err.Error(), propertyFields(properties))
 	os.Exit(1)
 }
 
+// Вызывается только из экспортируемых методов, чтобы callerPC пропускал одинаковое число кадров.
+func (l *Logger) log(level Level, message string, fields []Field) {
+	if !l.Enabled(level) {
+		return
+	}
+
+	l.write(level, message, fields, l.callerPC())
+}
+
 func (l *Logger) print(level Level, message string, properties map[string]string) (int, error) {
-	if level < l.minLevel {
+	if !l.Enabled(level) {
 		return 0, nil
 	}
 
+	return l.write(level, message, propertyFields(properties), l.callerPC())
+}
+
+// Адрес кода, вызвавшего метод логгера: пропускаются runtime.Callers, callerPC, log и сам метод.
+func (l *Logger) callerPC() uintptr {
+	if !l.caller {
+		return 0
+	}
+
+	var pcs [1]uintptr
+	runtime.Callers(4, pcs[:])
+
+	return pcs[0]
+}
+
+/*
+Пишет запись уровня level. По умолчанию стек вызовов добавляется к записям
+уровня ERROR и выше; поле Stack меняет это для отдельной записи. pc - адрес
+места вызова или 0, если оно не записывается.
+*/
+func (l *Logger) write(level Level, message string, fields []Field, pc uintptr) (int, error) {
+	if len(l.fields) > 0 {
+		fields = append(l.fields[:len(l.fields):len(l.fields)], fields...)
+	}
+
 	aux := struct {
-		Level      string            `json:"level"`
-		Time       string            `json:"time"`
-		Message    string            `json:"message"`
-		Properties map[string]string `json:"properties,omitempty"`
-		Trace      string            `json:"trace,omitempty"`
+		Level      string         `json:"level"`
+		Time       string         `json:"time"`
+		Message    string         `json:"message"`
+		Caller     string         `json:"caller,omitempty"`
+		Properties map[string]any `json:"properties,omitempty"`
+		Trace      string         `json:"trace,omitempty"`
 	}{
 		Level:      level.String(),
 		Time:       time.Now().UTC().Format(time.RFC3339),
 		Message:    message,
-		Properties: properties,
+		Properties: fieldsMap(fields),
+	}
+
+	if pc != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+		if frame.File != "" {
+			aux.Caller = fmt.Sprintf("%s/%s:%d", filepath.Base(filepath.Dir(frame.File)), filepath.Base(frame.File), frame.Line)
+		}
 	}
 
-	if level >= LevelError {
+	if stackEnabled(level, fields) {
 		aux.Trace = string(debug.Stack())
 	}
 
@@ -86,8 +229,13 @@ func (l *Logger) print(level Level, message string, properties map[string]string
 		line = []byte(LevelError.String() + ": unable to marshal log message:" + err.Error())
 	}
 
-	l.mu.Lock()
-	defer l.mu.Unlock()
+	mu := &l.mu
+	if l.root != nil {
+		mu = &l.root.mu
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
 
 	return l.out.Write(append(line, '\n'))
 }
@@ -2,10 +2,12 @@ package jsonlog
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"runtime/debug"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestLevelString(t *testing.T) {
@@ -13,7 +15,9 @@ func TestLevelString(t *testing.T) {
 		level    Level
 		expected string
 	}{
+		{LevelDebug, "DEBUG"},
 		{LevelInfo, "INFO"},
+		{LevelWarn, "WARN"},
 		{LevelError, "ERROR"},
 		{LevelFatal, "FATAL"},
 		{Level(100), ""},
@@ -162,3 +166,200 @@ func TestLogger_print(t *testing.T) {
 		}
 	}
 }
+
+func TestLogger_With(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, LevelInfo)
+
+	child := logger.With(map[string]string{"trace_id": "abc", "key1": "default"}).With(map[string]string{"span_id": "def"})
+
+	child.PrintInfo("message", map[string]string{"key1": "value1"})
+
+	var entry struct {
+		Properties map[string]string `json:"properties"`
+	}
+
+	err := json.Unmarshal(buf.Bytes(), &entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"trace_id": "abc", "span_id": "def", "key1": "value1"}
+
+	if len(entry.Properties) != len(want) {
+		t.Errorf("expected properties %v, got %v", want, entry.Properties)
+	}
+
+	for key, value := range want {
+		if entry.Properties[key] != value {
+			t.Errorf("expected %s=%q, got %q", key, value, entry.Properties[key])
+		}
+	}
+
+	buf.Reset()
+	logger.PrintInfo("message", nil)
+
+	if strings.Contains(buf.String(), "trace_id") {
+		t.Errorf("expected parent logger to be unaffected, got %q", buf.String())
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Level
+		wantErr  bool
+	}{
+		{"debug", LevelDebug, false},
+		{"INFO", LevelInfo, false},
+		{"warn", LevelWarn, false},
+		{"warning", LevelWarn, false},
+		{" error ", LevelError, false},
+		{"off", LevelOff, false},
+		{"verbose", LevelInfo, true},
+	}
+
+	for _, test := range tests {
+		level, err := ParseLevel(test.input)
+		if (err != nil) != test.wantErr {
+			t.Errorf("ParseLevel(%q) returned error %v, want error %v", test.input, err, test.wantErr)
+		}
+
+		if err == nil && level != test.expected {
+			t.Errorf("ParseLevel(%q) returned %v, expected %v", test.input, level, test.expected)
+		}
+	}
+}
+
+func TestLogger_MinLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, LevelWarn)
+
+	logger.Debug("debug")
+	logger.Info("info")
+
+	if buf.Len() != 0 {
+		t.Errorf("expected records below WARN to be dropped, got %q", buf.String())
+	}
+
+	logger.Warn("warn")
+
+	if !strings.HasPrefix(buf.String(), `{"level":"WARN",`) {
+		t.Errorf("expected a WARN record, got %q", buf.String())
+	}
+
+	buf.Reset()
+	New(&buf, LevelDebug).Debug("debug")
+
+	if !strings.HasPrefix(buf.String(), `{"level":"DEBUG",`) {
+		t.Errorf("expected a DEBUG record, got %q", buf.String())
+	}
+}
+
+func TestLogger_Fields(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, LevelInfo).WithFields(Int("attempt", 1), String("key", "default"))
+
+	logger.Info("message",
+		Int("attempt", 2),
+		Int64("user_id", 42),
+		Float64("ratio", 0.5),
+		Bool("cached", true),
+		Duration("elapsed", 1500*time.Millisecond),
+		Time("at", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)),
+		Err(errors.New("boom")),
+		Group("db", String("table", "movies"), Group("pool", Int("open", 3))),
+		Group("db", Int("rows", 10)),
+		Group("", String("inline", "yes")),
+		Any("tags", []string{"a", "b"}),
+	)
+
+	var entry struct {
+		Properties map[string]any `json:"properties"`
+		Trace      string         `json:"trace"`
+	}
+
+	err := json.Unmarshal(buf.Bytes(), &entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := json.Marshal(entry.Properties)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"at":"2024-01-02T03:04:05Z","attempt":2,"cached":true,"db":{"pool":{"open":3},"rows":10,"table":"movies"},"elapsed":"1.5s","error":"boom","inline":"yes","key":"default","ratio":0.5,"tags":["a","b"],"user_id":42}`
+
+	if string(got) != want {
+		t.Errorf("expected properties %s, got %s", want, got)
+	}
+
+	if entry.Trace != "" {
+		t.Errorf("expected no stack trace for an INFO record, got %q", entry.Trace)
+	}
+}
+
+func TestLogger_Stack(t *testing.T) {
+	tests := []struct {
+		name   string
+		log    func(l *Logger)
+		traced bool
+	}{
+		{"error", func(l *Logger) { l.Error(errors.New("boom")) }, true},
+		{"error without stack", func(l *Logger) { l.Error(errors.New("boom"), Stack(false)) }, false},
+		{"warn", func(l *Logger) { l.Warn("slow") }, false},
+		{"warn with stack", func(l *Logger) { l.Warn("slow", Stack(true)) }, true},
+		{"logger without stack", func(l *Logger) { l.WithFields(Stack(false)).PrintError(errors.New("boom"), nil) }, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			test.log(New(&buf, LevelInfo))
+
+			var entry map[string]any
+
+			err := json.Unmarshal(buf.Bytes(), &entry)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, traced := entry["trace"]
+			if traced != test.traced {
+				t.Errorf("expected trace present = %v, got %q", test.traced, buf.String())
+			}
+
+			if _, found := entry["properties"]; found {
+				t.Errorf("expected Stack not to be written as a property, got %q", buf.String())
+			}
+		})
+	}
+}
+
+func TestLogger_WithCaller(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, LevelInfo)
+
+	logger.Info("message")
+
+	if strings.Contains(buf.String(), `"caller"`) {
+		t.Errorf("expected no caller by default, got %q", buf.String())
+	}
+
+	buf.Reset()
+	logger.WithCaller(true).With(map[string]string{"key": "value"}).PrintInfo("message", nil)
+
+	var entry struct {
+		Caller string `json:"caller"`
+	}
+
+	err := json.Unmarshal(buf.Bytes(), &entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(entry.Caller, "jsonlog/jsonlog_test.go:") {
+		t.Errorf("expected caller in jsonlog_test.go, got %q", entry.Caller)
+	}
+}
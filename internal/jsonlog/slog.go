@@ -0,0 +1,136 @@
+package jsonlog
+
+import (
+	"context"
+	"log/slog"
+)
+
+/*
+Handler пропускает записи log/slog через Logger: они фильтруются по его
+минимальному уровню и пишутся в том же формате, с теми же общими полями.
+Атрибуты групп становятся вложенными объектами в properties.
+*/
+type Handler struct {
+	logger *Logger
+	groups []string
+	fields []Field
+}
+
+func NewSlogHandler(logger *Logger) *Handler {
+	return &Handler{logger: logger}
+}
+
+// slog.LevelDebug-1 и ниже тоже считаются DEBUG, а уровни между стандартными - ближайшим меньшим.
+func slogLevel(level slog.Level) Level {
+	switch {
+	case level < slog.LevelInfo:
+		return LevelDebug
+	case level < slog.LevelWarn:
+		return LevelInfo
+	case level < slog.LevelError:
+		return LevelWarn
+	default:
+		return LevelError
+	}
+}
+
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.logger.Enabled(slogLevel(level))
+}
+
+func (h *Handler) Handle(ctx context.Context, record slog.Record) error {
+	fields := make([]Field, 0, record.NumAttrs())
+
+	record.Attrs(func(attr slog.Attr) bool {
+		fields = appendAttr(fields, attr)
+		return true
+	})
+
+	fields = append(h.fields[:len(h.fields):len(h.fields)], h.group(fields)...)
+
+	var pc uintptr
+	if h.logger.caller {
+		pc = record.PC
+	}
+
+	_, err := h.logger.write(slogLevel(record.Level), record.Message, fields, pc)
+
+	return err
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := make([]Field, 0, len(attrs))
+
+	for _, attr := range attrs {
+		fields = appendAttr(fields, attr)
+	}
+
+	return &Handler{
+		logger: h.logger,
+		groups: h.groups,
+		fields: append(h.fields[:len(h.fields):len(h.fields)], h.group(fields)...),
+	}
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	return &Handler{
+		logger: h.logger,
+		groups: append(h.groups[:len(h.groups):len(h.groups)], name),
+		fields: h.fields,
+	}
+}
+
+// Вкладывает поля в открытые WithGroup группы.
+func (h *Handler) group(fields []Field) []Field {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	for i := len(h.groups) - 1; i >= 0; i-- {
+		fields = []Field{Group(h.groups[i], fields...)}
+	}
+
+	return fields
+}
+
+// Пустые атрибуты пропускаются, как того требует контракт slog.Handler.
+func appendAttr(fields []Field, attr slog.Attr) []Field {
+	attr.Value = attr.Value.Resolve()
+
+	if attr.Equal(slog.Attr{}) {
+		return fields
+	}
+
+	value := attr.Value
+
+	switch value.Kind() {
+	case slog.KindString:
+		return append(fields, String(attr.Key, value.String()))
+	case slog.KindInt64:
+		return append(fields, Int64(attr.Key, value.Int64()))
+	case slog.KindUint64:
+		return append(fields, Any(attr.Key, value.Uint64()))
+	case slog.KindFloat64:
+		return append(fields, Float64(attr.Key, value.Float64()))
+	case slog.KindBool:
+		return append(fields, Bool(attr.Key, value.Bool()))
+	case slog.KindDuration:
+		return append(fields, Duration(attr.Key, value.Duration()))
+	case slog.KindTime:
+		return append(fields, Time(attr.Key, value.Time()))
+	case slog.KindGroup:
+		var group []Field
+
+		for _, groupAttr := range value.Group() {
+			group = appendAttr(group, groupAttr)
+		}
+
+		return append(fields, Group(attr.Key, group...))
+	default:
+		return append(fields, Any(attr.Key, value.Any()))
+	}
+}
@@ -0,0 +1,84 @@
+package jsonlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewSlogHandler(New(&buf, LevelInfo).With(map[string]string{"request_id": "abc"}).WithCaller(true)))
+
+	logger.Debug("dropped")
+
+	if buf.Len() != 0 {
+		t.Fatalf("expected DEBUG record to be dropped by the logger level, got %q", buf.String())
+	}
+
+	logger.With("component", "cache").WithGroup("db").With("table", "movies").
+		Warn("slow query", "elapsed", 2*time.Second, slog.Group("pool", "open", 3), "rows", 10, "err", errors.New("boom"))
+
+	var entry struct {
+		Level      string         `json:"level"`
+		Message    string         `json:"message"`
+		Caller     string         `json:"caller"`
+		Properties map[string]any `json:"properties"`
+	}
+
+	err := json.Unmarshal(buf.Bytes(), &entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if entry.Level != "WARN" || entry.Message != "slow query" {
+		t.Errorf("expected WARN record \"slow query\", got %s %q", entry.Level, entry.Message)
+	}
+
+	if !strings.HasPrefix(entry.Caller, "jsonlog/slog_test.go:") {
+		t.Errorf("expected caller in slog_test.go, got %q", entry.Caller)
+	}
+
+	got, err := json.Marshal(entry.Properties)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"component":"cache","db":{"elapsed":"2s","err":"boom","pool":{"open":3},"rows":10,"table":"movies"},"request_id":"abc"}`
+
+	if string(got) != want {
+		t.Errorf("expected properties %s, got %s", want, got)
+	}
+}
+
+func TestSlogLevel(t *testing.T) {
+	tests := []struct {
+		level    slog.Level
+		expected Level
+	}{
+		{slog.LevelDebug - 4, LevelDebug},
+		{slog.LevelDebug, LevelDebug},
+		{slog.LevelInfo, LevelInfo},
+		{slog.LevelInfo + 2, LevelInfo},
+		{slog.LevelWarn, LevelWarn},
+		{slog.LevelError, LevelError},
+		{slog.LevelError + 4, LevelError},
+	}
+
+	for _, test := range tests {
+		if got := slogLevel(test.level); got != test.expected {
+			t.Errorf("slogLevel(%v) returned %v, expected %v", test.level, got, test.expected)
+		}
+	}
+
+	handler := NewSlogHandler(New(&bytes.Buffer{}, LevelError))
+
+	if handler.Enabled(context.Background(), slog.LevelWarn) || !handler.Enabled(context.Background(), slog.LevelError) {
+		t.Errorf("expected handler to follow the logger's minimum level")
+	}
+}
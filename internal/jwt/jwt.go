@@ -0,0 +1,349 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrExpiredToken = errors.New("token has expired")
+	ErrNoSigningKey = errors.New("no private key to sign tokens with")
+)
+
+const (
+	AlgEdDSA = "EdDSA"
+	AlgRS256 = "RS256"
+)
+
+var encoding = base64.RawURLEncoding
+
+type Claims struct {
+	Issuer      string   `json:"iss"`
+	Audience    string   `json:"aud"`
+	Subject     string   `json:"sub"`
+	Name        string   `json:"name"`
+	Role        string   `json:"role"`
+	Permissions []string `json:"permissions,omitempty"`
+	IssuedAt    int64    `json:"iat"`
+	NotBefore   int64    `json:"nbf,omitempty"`
+	ExpiresAt   int64    `json:"exp"`
+	ID          string   `json:"jti"`
+}
+
+type header struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	Type      string `json:"typ"`
+}
+
+/*
+Key - ключ подписи Ed25519 (EdDSA) или RSA (RS256). Ключ, загруженный только
+из открытой части, годится для проверки подписи, но не для выпуска токенов.
+*/
+type Key struct {
+	ID        string
+	Algorithm string
+	public    crypto.PublicKey
+	private   crypto.Signer
+}
+
+/*
+KeySet - набор ключей для ротации с перекрытием: первый ключ подписывает
+новые токены, а остальные только проверяют уже выданные, пока те не истекут.
+Audience отличает токены этого сервиса от токенов других сервисов,
+подписанных теми же ключами.
+*/
+type KeySet struct {
+	Issuer   string
+	Audience string
+	keys     []*Key
+}
+
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+/*
+Разбирает ключ в формате PEM: PKCS#8 ("PRIVATE KEY"), PKCS#1 ("RSA PRIVATE KEY")
+или открытый ключ PKIX ("PUBLIC KEY"). Идентификатор ключа вычисляется
+из SHA-256 его открытой части, поэтому не зависит от имени файла.
+*/
+func ParseKey(data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed interface{}
+	var err error
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return NewKey(parsed)
+}
+
+// Создает ключ из ed25519 или *rsa ключа, закрытого или открытого.
+func NewKey(k interface{}) (*Key, error) {
+	key := &Key{}
+
+	switch k := k.(type) {
+	case ed25519.PrivateKey:
+		key.Algorithm, key.private, key.public = AlgEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.Algorithm, key.public = AlgEdDSA, k
+	case *rsa.PrivateKey:
+		key.Algorithm, key.private, key.public = AlgRS256, k, k.Public()
+	case *rsa.PublicKey:
+		key.Algorithm, key.public = AlgRS256, k
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", k)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(key.public)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(der)
+	key.ID = encoding.EncodeToString(sum[:16])
+
+	return key, nil
+}
+
+func LoadKeySet(issuer, audience string, paths []string) (*KeySet, error) {
+	var keys []*Key
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := ParseKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		keys = append(keys, key)
+	}
+
+	return NewKeySet(issuer, audience, keys...)
+}
+
+func NewKeySet(issuer, audience string, keys ...*Key) (*KeySet, error) {
+	if len(keys) == 0 || keys[0].private == nil {
+		return nil, ErrNoSigningKey
+	}
+
+	return &KeySet{Issuer: issuer, Audience: audience, keys: keys}, nil
+}
+
+func (ks *KeySet) SigningKey() *Key {
+	return ks.keys[0]
+}
+
+/*
+Подписывает claims текущим ключом. Issuer, Audience, IssuedAt и ID
+заполняются, если не заданы; срок действия задает вызывающий код.
+*/
+func (ks *KeySet) Sign(claims Claims) (string, error) {
+	key := ks.SigningKey()
+
+	if claims.Issuer == "" {
+		claims.Issuer = ks.Issuer
+	}
+
+	if claims.Audience == "" {
+		claims.Audience = ks.Audience
+	}
+
+	if claims.IssuedAt == 0 {
+		claims.IssuedAt = time.Now().Unix()
+	}
+
+	if claims.ID == "" {
+		id := make([]byte, 16)
+
+		_, err := rand.Read(id)
+		if err != nil {
+			return "", err
+		}
+
+		claims.ID = encoding.EncodeToString(id)
+	}
+
+	headerJSON, err := json.Marshal(header{Algorithm: key.Algorithm, KeyID: key.ID, Type: "JWT"})
+	if err != nil {
+		return "", err
+	}
+
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := encoding.EncodeToString(headerJSON) + "." + encoding.EncodeToString(claimsJSON)
+
+	signature, err := key.sign([]byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+
+	return signingInput + "." + encoding.EncodeToString(signature), nil
+}
+
+/*
+Проверяет подпись, издателя, получателя и срок действия токена (nbf и exp).
+Алгоритм берется из найденного по kid ключа, а не из заголовка, поэтому
+подменить его в токене нельзя.
+*/
+func (ks *KeySet) Verify(token string, now time.Time) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	headerJSON, err := encoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	var h header
+
+	err = json.Unmarshal(headerJSON, &h)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	key := ks.key(h.KeyID)
+	if key == nil || key.Algorithm != h.Algorithm {
+		return nil, ErrInvalidToken
+	}
+
+	signature, err := encoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if !key.verify([]byte(parts[0]+"."+parts[1]), signature) {
+		return nil, ErrInvalidToken
+	}
+
+	claimsJSON, err := encoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	var claims Claims
+
+	err = json.Unmarshal(claimsJSON, &claims)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if claims.Issuer != ks.Issuer || claims.Audience != ks.Audience {
+		return nil, ErrInvalidToken
+	}
+
+	if now.Unix() < claims.NotBefore {
+		return nil, ErrInvalidToken
+	}
+
+	if now.Unix() >= claims.ExpiresAt {
+		return nil, ErrExpiredToken
+	}
+
+	return &claims, nil
+}
+
+// Возвращает открытые части всех ключей набора для /.well-known/jwks.json.
+func (ks *KeySet) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+
+	for _, key := range ks.keys {
+		jwk := JWK{KeyID: key.ID, Algorithm: key.Algorithm, Use: "sig"}
+
+		switch public := key.public.(type) {
+		case ed25519.PublicKey:
+			jwk.KeyType, jwk.Curve, jwk.X = "OKP", "Ed25519", encoding.EncodeToString(public)
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = encoding.EncodeToString(public.N.Bytes())
+			jwk.E = encoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		}
+
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	return jwks
+}
+
+func (ks *KeySet) key(id string) *Key {
+	for _, key := range ks.keys {
+		if key.ID == id {
+			return key
+		}
+	}
+
+	return nil
+}
+
+func (k *Key) sign(data []byte) ([]byte, error) {
+	switch k.Algorithm {
+	case AlgEdDSA:
+		return k.private.Sign(rand.Reader, data, crypto.Hash(0))
+	default:
+		digest := sha256.Sum256(data)
+		return k.private.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+}
+
+func (k *Key) verify(data, signature []byte) bool {
+	switch public := k.public.(type) {
+	case ed25519.PublicKey:
+		return ed25519.Verify(public, data, signature)
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(data)
+		return rsa.VerifyPKCS1v15(public, crypto.SHA256, digest[:], signature) == nil
+	default:
+		return false
+	}
+}
@@ -0,0 +1,241 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newEd25519Key(t *testing.T) *Key {
+	t.Helper()
+
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := NewKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func newRSAKey(t *testing.T) *Key {
+	t.Helper()
+
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := NewKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func TestSignVerify(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		key  *Key
+	}{
+		{"EdDSA", newEd25519Key(t)},
+		{"RS256", newRSAKey(t)},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := NewKeySet("filmoteka", "filmoteka", tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			token, err := keys.Sign(Claims{Subject: "1", Role: "admin", ExpiresAt: time.Now().Add(time.Minute).Unix()})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			claims, err := keys.Verify(token, time.Now())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if claims.Subject != "1" || claims.Role != "admin" || claims.Issuer != "filmoteka" || claims.ID == "" {
+				t.Errorf("unexpected claims %+v", claims)
+			}
+
+			if tt.key.Algorithm != tt.name {
+				t.Errorf("expected algorithm %q, but got %q", tt.name, tt.key.Algorithm)
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	keys, err := NewKeySet("filmoteka", "filmoteka", newEd25519Key(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := keys.Sign(Claims{Subject: "1", Role: "user", ExpiresAt: time.Now().Add(time.Minute).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Expired", func(t *testing.T) {
+		_, err := keys.Verify(token, time.Now().Add(2*time.Minute))
+		if err != ErrExpiredToken {
+			t.Errorf("expected error %v, but got %v", ErrExpiredToken, err)
+		}
+	})
+
+	t.Run("TamperedClaims", func(t *testing.T) {
+		parts := strings.Split(token, ".")
+
+		forged, err := keys.Sign(Claims{Subject: "1", Role: "admin", ExpiresAt: time.Now().Add(time.Minute).Unix()})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parts[1] = strings.Split(forged, ".")[1]
+
+		_, err = keys.Verify(strings.Join(parts, "."), time.Now())
+		if err != ErrInvalidToken {
+			t.Errorf("expected error %v, but got %v", ErrInvalidToken, err)
+		}
+	})
+
+	t.Run("UnknownKey", func(t *testing.T) {
+		other, err := NewKeySet("filmoteka", "filmoteka", newEd25519Key(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = other.Verify(token, time.Now())
+		if err != ErrInvalidToken {
+			t.Errorf("expected error %v, but got %v", ErrInvalidToken, err)
+		}
+	})
+
+	t.Run("WrongIssuer", func(t *testing.T) {
+		other := &KeySet{Issuer: "someone-else", Audience: "filmoteka", keys: keys.keys}
+
+		_, err := other.Verify(token, time.Now())
+		if err != ErrInvalidToken {
+			t.Errorf("expected error %v, but got %v", ErrInvalidToken, err)
+		}
+	})
+
+	t.Run("WrongAudience", func(t *testing.T) {
+		other := &KeySet{Issuer: "filmoteka", Audience: "billing", keys: keys.keys}
+
+		foreign, err := other.Sign(Claims{Subject: "1", Role: "admin", ExpiresAt: time.Now().Add(time.Minute).Unix()})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = keys.Verify(foreign, time.Now())
+		if err != ErrInvalidToken {
+			t.Errorf("expected error %v, but got %v", ErrInvalidToken, err)
+		}
+	})
+
+	t.Run("NotYetValid", func(t *testing.T) {
+		early, err := keys.Sign(Claims{
+			Subject:   "1",
+			Role:      "user",
+			NotBefore: time.Now().Add(time.Minute).Unix(),
+			ExpiresAt: time.Now().Add(2 * time.Minute).Unix(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = keys.Verify(early, time.Now())
+		if err != ErrInvalidToken {
+			t.Errorf("expected error %v, but got %v", ErrInvalidToken, err)
+		}
+
+		if _, err := keys.Verify(early, time.Now().Add(90*time.Second)); err != nil {
+			t.Errorf("expected token to be valid after nbf, but got %v", err)
+		}
+	})
+}
+
+func TestRotation(t *testing.T) {
+	oldKey, newKey := newEd25519Key(t), newRSAKey(t)
+
+	before, err := NewKeySet("filmoteka", "filmoteka", oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := before.Sign(Claims{Subject: "1", ExpiresAt: time.Now().Add(time.Minute).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := NewKeySet("filmoteka", "filmoteka", newKey, oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := after.Verify(token, time.Now()); err != nil {
+		t.Errorf("expected token signed with the previous key to verify, but got %v", err)
+	}
+
+	if after.SigningKey() != newKey {
+		t.Errorf("expected the first key to sign new tokens")
+	}
+
+	jwks := after.JWKS()
+	if len(jwks.Keys) != 2 || jwks.Keys[0].KeyType != "RSA" || jwks.Keys[1].KeyType != "OKP" {
+		t.Errorf("expected both keys in JWKS, but got %+v", jwks.Keys)
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	publicDER, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	privateKey, err := ParseKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	publicKey, err := ParseKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if privateKey.ID != publicKey.ID {
+		t.Errorf("expected the same key ID for both halves, but got %q and %q", privateKey.ID, publicKey.ID)
+	}
+
+	if _, err := NewKeySet("filmoteka", "filmoteka", publicKey); err != ErrNoSigningKey {
+		t.Errorf("expected error %v, but got %v", ErrNoSigningKey, err)
+	}
+
+	if _, err := ParseKey([]byte("not a key")); err == nil {
+		t.Errorf("expected error for invalid PEM")
+	}
+}
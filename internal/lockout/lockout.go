@@ -0,0 +1,188 @@
+package lockout
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+/*
+Начиная со второй неудачи подряд следующая попытка откладывается на BaseDelay,
+и задержка удваивается с каждой неудачей, но не превышает MaxDelay. После
+Threshold неудач ключ блокируется на Lockout. Нулевой BaseDelay отключает
+задержки, оставляя только блокировку. Счетчик забывается, если неудач
+не было дольше Lockout.
+*/
+type Config struct {
+	Threshold int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	Lockout   time.Duration
+}
+
+/*
+Tracker считает неудачные попытки входа по ключу, например имени пользователя
+или IP адресу. Состояние хранится в памяти процесса. Методы nil Tracker
+ничего не делают, поэтому отключенная защита не требует отдельных проверок.
+*/
+type Tracker struct {
+	mu        sync.Mutex
+	cfg       Config
+	entries   map[string]*entry
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+type entry struct {
+	failures    int
+	lastFailure time.Time
+	retryAt     time.Time
+	locked      bool
+}
+
+type Lock struct {
+	Key         string    `json:"key"`
+	Failures    int       `json:"failures"`
+	LockedUntil time.Time `json:"locked_until"` // RFC3339
+}
+
+func New(cfg Config) *Tracker {
+	return &Tracker{
+		cfg:     cfg,
+		entries: make(map[string]*entry),
+		now:     time.Now,
+	}
+}
+
+/*
+Возвращает, сколько осталось ждать до следующей попытки, и заблокирован ли
+ключ. Нулевое ожидание означает, что попытку можно делать сейчас.
+*/
+func (t *Tracker) Check(key string) (time.Duration, bool) {
+	if t == nil {
+		return 0, false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e, found := t.entries[key]
+	if !found {
+		return 0, false
+	}
+
+	wait := e.retryAt.Sub(t.now())
+	if wait <= 0 {
+		return 0, false
+	}
+
+	return wait, e.locked
+}
+
+/*
+Учитывает неудачную попытку. Возвращает задержку до следующей попытки и то,
+началась ли из-за этой неудачи блокировка.
+*/
+func (t *Tracker) Fail(key string) (time.Duration, bool) {
+	if t == nil {
+		return 0, false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+
+	t.prune(now)
+
+	e, found := t.entries[key]
+	if !found || t.expired(e, now) {
+		e = &entry{}
+		t.entries[key] = e
+	}
+
+	e.failures++
+	e.lastFailure = now
+
+	if e.failures >= t.cfg.Threshold {
+		lockedBefore := e.locked && e.retryAt.After(now)
+
+		e.locked = true
+		e.retryAt = now.Add(t.cfg.Lockout)
+
+		return t.cfg.Lockout, !lockedBefore
+	}
+
+	if e.failures < 2 || t.cfg.BaseDelay <= 0 {
+		return 0, false
+	}
+
+	delay := t.cfg.BaseDelay << min(e.failures-2, 30)
+	if delay > t.cfg.MaxDelay {
+		delay = t.cfg.MaxDelay
+	}
+
+	e.retryAt = now.Add(delay)
+
+	return delay, false
+}
+
+// Сбрасывает счетчик ключа после успешного входа или по запросу администратора.
+func (t *Tracker) Reset(key string) bool {
+	if t == nil {
+		return false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	_, found := t.entries[key]
+	delete(t.entries, key)
+
+	return found
+}
+
+// Возвращает действующие блокировки, отсортированные по ключу.
+func (t *Tracker) Locks() []Lock {
+	locks := []Lock{}
+
+	if t == nil {
+		return locks
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+
+	for key, e := range t.entries {
+		if e.locked && e.retryAt.After(now) {
+			locks = append(locks, Lock{Key: key, Failures: e.failures, LockedUntil: e.retryAt})
+		}
+	}
+
+	sort.Slice(locks, func(i, j int) bool {
+		return locks[i].Key < locks[j].Key
+	})
+
+	return locks
+}
+
+func (t *Tracker) expired(e *entry, now time.Time) bool {
+	return !e.retryAt.After(now) && now.Sub(e.lastFailure) >= t.cfg.Lockout
+}
+
+// Не чаще раза в минуту удаляет забытые записи, чтобы карта не росла бесконечно.
+func (t *Tracker) prune(now time.Time) {
+	if now.Sub(t.lastPrune) < time.Minute {
+		return
+	}
+
+	t.lastPrune = now
+
+	for key, e := range t.entries {
+		if t.expired(e, now) {
+			delete(t.entries, key)
+		}
+	}
+}
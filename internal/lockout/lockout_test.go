@@ -0,0 +1,120 @@
+package lockout
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestTracker(now *time.Time) *Tracker {
+	t := New(Config{Threshold: 5, BaseDelay: time.Second, MaxDelay: 3 * time.Second, Lockout: time.Minute})
+	t.now = func() time.Time { return *now }
+
+	return t
+}
+
+func TestTracker_BackoffAndLockout(t *testing.T) {
+	now := time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)
+	tracker := newTestTracker(&now)
+
+	tests := []struct {
+		wantDelay  time.Duration
+		wantLocked bool
+	}{
+		{0, false},
+		{time.Second, false},
+		{2 * time.Second, false},
+		{3 * time.Second, false},
+		{time.Minute, true},
+	}
+
+	for i, tt := range tests {
+		delay, locked := tracker.Fail("john")
+		if delay != tt.wantDelay || locked != tt.wantLocked {
+			t.Errorf("failure %d: expected (%v, %t), but got (%v, %t)", i+1, tt.wantDelay, tt.wantLocked, delay, locked)
+		}
+	}
+
+	if wait, locked := tracker.Check("john"); wait != time.Minute || !locked {
+		t.Errorf("expected john to be locked for 1m, but got (%v, %t)", wait, locked)
+	}
+
+	if wait, _ := tracker.Check("jane"); wait != 0 {
+		t.Errorf("expected other keys not to be affected, but got %v", wait)
+	}
+
+	if _, started := tracker.Fail("john"); started {
+		t.Error("expected failure during lockout not to start a new lockout")
+	}
+
+	now = now.Add(2 * time.Minute)
+
+	if wait, locked := tracker.Check("john"); wait != 0 || locked {
+		t.Errorf("expected lockout to expire, but got (%v, %t)", wait, locked)
+	}
+
+	if delay, _ := tracker.Fail("john"); delay != 0 {
+		t.Errorf("expected failures to be forgotten after lockout, but got delay %v", delay)
+	}
+}
+
+func TestTracker_NoBackoff(t *testing.T) {
+	now := time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)
+	tracker := New(Config{Threshold: 3, Lockout: time.Minute})
+	tracker.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if delay, _ := tracker.Fail("192.0.2.1"); delay != 0 {
+			t.Errorf("expected no delay without base delay, but got %v", delay)
+		}
+	}
+
+	if delay, locked := tracker.Fail("192.0.2.1"); delay != time.Minute || !locked {
+		t.Errorf("expected lockout at threshold, but got (%v, %t)", delay, locked)
+	}
+}
+
+func TestTracker_Locks(t *testing.T) {
+	now := time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)
+	tracker := newTestTracker(&now)
+
+	for i := 0; i < 5; i++ {
+		tracker.Fail("john")
+		tracker.Fail("alice")
+	}
+
+	tracker.Fail("jane")
+	tracker.Fail("jane")
+
+	locks := tracker.Locks()
+	if len(locks) != 2 || locks[0].Key != "alice" || locks[1].Key != "john" {
+		t.Fatalf("expected alice and john to be locked, but got %v", locks)
+	}
+
+	if !locks[1].LockedUntil.Equal(now.Add(time.Minute)) || locks[1].Failures != 5 {
+		t.Errorf("unexpected lock %+v", locks[1])
+	}
+
+	if !tracker.Reset("john") {
+		t.Error("expected reset to find john")
+	}
+
+	if wait, _ := tracker.Check("john"); wait != 0 {
+		t.Errorf("expected reset to clear the lockout, but got %v", wait)
+	}
+
+	if tracker.Reset("bob") {
+		t.Error("expected reset of unknown key to report false")
+	}
+}
+
+func TestTracker_Nil(t *testing.T) {
+	var tracker *Tracker
+
+	if delay, locked := tracker.Fail("john"); delay != 0 || locked {
+		t.Error("expected nil tracker not to delay")
+	}
+
+	if wait, _ := tracker.Check("john"); wait != 0 || tracker.Reset("john") || len(tracker.Locks()) != 0 {
+		t.Error("expected nil tracker to never lock")
+	}
+}
@@ -0,0 +1,255 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto/tls"
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	ttemplate "text/template"
+	"time"
+)
+
+//go:embed "templates"
+var templateFS embed.FS
+
+/*
+Mailer отправляет письмо по шаблону из templates: шаблон определяет тему
+(subject), текстовую (plainBody) и HTML (htmlBody) версии письма.
+*/
+type Mailer interface {
+	Send(recipient, templateFile string, data interface{}) error
+}
+
+/*
+SMTP отправляет письма через SMTP сервер. Соединение, включая отправку
+письма целиком, ограничено timeout, чтобы зависший сервер не задерживал
+фоновую задачу и вместе с ней остановку приложения.
+*/
+type SMTP struct {
+	addr    string
+	host    string
+	auth    smtp.Auth
+	sender  string
+	timeout time.Duration
+}
+
+/*
+File записывает письма в w вместо отправки, например в stdout или файл
+при локальной разработке. Письма из разных горутин не перемешиваются.
+*/
+type File struct {
+	mu     sync.Mutex
+	w      io.Writer
+	sender string
+}
+
+func NewSMTP(host string, port int, username, password, sender string, timeout time.Duration) *SMTP {
+	m := &SMTP{
+		addr:    net.JoinHostPort(host, strconv.Itoa(port)),
+		host:    host,
+		sender:  sender,
+		timeout: timeout,
+	}
+
+	if username != "" {
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return m
+}
+
+func NewFile(w io.Writer, sender string) *File {
+	return &File{w: w, sender: sender}
+}
+
+func (m *SMTP) Send(recipient, templateFile string, data interface{}) error {
+	msg, err := compose(m.sender, recipient, templateFile, data)
+	if err != nil {
+		return err
+	}
+
+	if strings.ContainsAny(m.sender+recipient, "\r\n") {
+		return errors.New("smtp: sender or recipient contains CR or LF")
+	}
+
+	conn, err := net.DialTimeout("tcp", m.addr, m.timeout)
+	if err != nil {
+		return err
+	}
+
+	err = conn.SetDeadline(time.Now().Add(m.timeout))
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	c, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	defer c.Close()
+
+	// Повторяет smtp.SendMail, который не позволяет задать таймаут.
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		err = c.StartTLS(&tls.Config{ServerName: m.host})
+		if err != nil {
+			return err
+		}
+	}
+
+	if m.auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("smtp: server doesn't support AUTH")
+		}
+
+		err = c.Auth(m.auth)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = c.Mail(m.sender)
+	if err != nil {
+		return err
+	}
+
+	err = c.Rcpt(recipient)
+	if err != nil {
+		return err
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(msg)
+	if err != nil {
+		return err
+	}
+
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+func (m *File) Send(recipient, templateFile string, data interface{}) error {
+	msg, err := compose(m.sender, recipient, templateFile, data)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err = fmt.Fprintf(m.w, "%s\r\n\r\n", msg)
+
+	return err
+}
+
+// Собирает письмо в формате MIME с текстовой и HTML версиями.
+func compose(sender, recipient, templateFile string, data interface{}) ([]byte, error) {
+	subject, plainBody, htmlBody, err := render(templateFile, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+
+	mw := multipart.NewWriter(&body)
+
+	parts := []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=UTF-8", plainBody},
+		{"text/html; charset=UTF-8", htmlBody},
+	}
+
+	for _, part := range parts {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType}})
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = io.WriteString(pw, part.content)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = mw.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+
+	fmt.Fprintf(&msg, "From: %s\r\n", sender)
+	fmt.Fprintf(&msg, "To: %s\r\n", recipient)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", mw.Boundary())
+
+	msg.Write(body.Bytes())
+
+	return msg.Bytes(), nil
+}
+
+func render(templateFile string, data interface{}) (subject, plainBody, htmlBody string, err error) {
+	tmpl, err := ttemplate.New("email").ParseFS(templateFS, "templates/"+templateFile)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	var buf bytes.Buffer
+
+	err = tmpl.ExecuteTemplate(&buf, "subject", data)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	subject = buf.String()
+
+	buf.Reset()
+
+	err = tmpl.ExecuteTemplate(&buf, "plainBody", data)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	plainBody = buf.String()
+
+	htmlTmpl, err := template.New("email").ParseFS(templateFS, "templates/"+templateFile)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	buf.Reset()
+
+	err = htmlTmpl.ExecuteTemplate(&buf, "htmlBody", data)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	htmlBody = buf.String()
+
+	return subject, plainBody, htmlBody, nil
+}
@@ -0,0 +1,157 @@
+package mailer
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+var resetData = map[string]interface{}{
+	"Name":   "John Doe",
+	"Token":  "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	"Expiry": "2030-01-01T00:00:00Z",
+}
+
+func TestFile_Send(t *testing.T) {
+	var buf bytes.Buffer
+
+	m := NewFile(&buf, "Filmoteka <no-reply@filmoteka.local>")
+
+	err := m.Send("john@example.com", "password_reset.tmpl", resetData)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	msg := buf.String()
+
+	for _, want := range []string{
+		"To: john@example.com",
+		"Subject: Reset your Filmoteka password",
+		"multipart/alternative",
+		"text/html; charset=UTF-8",
+		`{"token": "ABCDEFGHIJKLMNOPQRSTUVWXYZ"`,
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("expected message to contain %q, but got:\n%s", want, msg)
+		}
+	}
+}
+
+func TestFile_UnknownTemplate(t *testing.T) {
+	m := NewFile(&bytes.Buffer{}, "no-reply@filmoteka.local")
+
+	err := m.Send("john@example.com", "missing.tmpl", nil)
+	if err == nil {
+		t.Error("expected error, but got none")
+	}
+}
+
+func TestSMTP_Send(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer ln.Close()
+
+	received := make(chan string, 1)
+
+	go serveSMTP(t, ln, received)
+
+	addr := ln.Addr().(*net.TCPAddr)
+
+	m := NewSMTP("127.0.0.1", addr.Port, "", "", "no-reply@filmoteka.local", 5*time.Second)
+
+	err = m.Send("john@example.com", "password_reset.tmpl", resetData)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	msg := <-received
+	if !strings.Contains(msg, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") {
+		t.Errorf("expected message to contain the token, but got:\n%s", msg)
+	}
+}
+
+func TestSMTP_SendTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer ln.Close()
+
+	// Сервер принимает соединение, но не отвечает.
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+
+		defer conn.Close()
+
+		io.Copy(io.Discard, conn)
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+
+	m := NewSMTP("127.0.0.1", addr.Port, "", "", "no-reply@filmoteka.local", 100*time.Millisecond)
+
+	start := time.Now()
+
+	err = m.Send("john@example.com", "password_reset.tmpl", resetData)
+	if err == nil {
+		t.Fatal("expected error from a server that never answers, but got none")
+	}
+
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected Send to give up after the timeout, but it took %v", elapsed)
+	}
+}
+
+// Минимальный SMTP сервер, которого достаточно для SMTP.Send без TLS и авторизации.
+func serveSMTP(t *testing.T, ln net.Listener, received chan<- string) {
+	conn, err := ln.Accept()
+	if err != nil {
+		return
+	}
+
+	defer conn.Close()
+
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 localhost ESMTP")
+
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+
+		switch cmd := strings.ToUpper(strings.Fields(line)[0]); cmd {
+		case "EHLO", "HELO":
+			tp.PrintfLine("250 localhost")
+		case "MAIL", "RCPT":
+			tp.PrintfLine("250 OK")
+		case "DATA":
+			tp.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+
+			received <- string(data)
+			tp.PrintfLine("250 OK")
+		case "QUIT":
+			tp.PrintfLine("221 Bye")
+			return
+		default:
+			tp.PrintfLine("502 Command not implemented")
+		}
+	}
+}
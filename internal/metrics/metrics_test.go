@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+
+	requests := r.NewCounterVec("http_requests_total", "Number of HTTP requests.", "method", "status")
+	inFlight := r.NewGaugeVec("http_requests_in_flight", "Requests being served.")
+	duration := r.NewHistogramVec("http_request_duration_seconds", "Request latency.", []float64{0.1, 1}, "route")
+	r.NewGaugeFunc("db_open_connections", "Open connections.", func() float64 { return 3 })
+
+	requests.Inc("GET", "200")
+	requests.Inc("GET", "200")
+	requests.Add(1, "POST", `4"0\1`)
+	inFlight.Add(2)
+	inFlight.Add(-1)
+	duration.Observe(0.05, "/movies/:id")
+	duration.Observe(0.5, "/movies/:id")
+
+	res := httptest.NewRecorder()
+	r.Handler().ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if ct := res.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %q", ct)
+	}
+
+	want := `# HELP http_requests_total Number of HTTP requests.
+# TYPE http_requests_total counter
+http_requests_total{method="GET",status="200"} 2
+http_requests_total{method="POST",status="4\"0\\1"} 1
+# HELP http_requests_in_flight Requests being served.
+# TYPE http_requests_in_flight gauge
+http_requests_in_flight 1
+# HELP http_request_duration_seconds Request latency.
+# TYPE http_request_duration_seconds histogram
+http_request_duration_seconds_bucket{route="/movies/:id",le="0.1"} 1
+http_request_duration_seconds_bucket{route="/movies/:id",le="1"} 2
+http_request_duration_seconds_bucket{route="/movies/:id",le="+Inf"} 2
+http_request_duration_seconds_sum{route="/movies/:id"} 0.55
+http_request_duration_seconds_count{route="/movies/:id"} 2
+# HELP db_open_connections Open connections.
+# TYPE db_open_connections gauge
+db_open_connections 3
+`
+
+	if got := res.Body.String(); got != want {
+		t.Errorf("unexpected output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestLabelMismatch(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic on wrong number of label values")
+		}
+	}()
+
+	NewRegistry().NewCounterVec("errors_total", "Errors.", "kind").Inc()
+}
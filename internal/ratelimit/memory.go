@@ -0,0 +1,75 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore хранит счетчики в памяти процесса, у каждой реплики свои.
+type MemoryStore struct {
+	mu        sync.Mutex
+	windows   map[string]*window
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+type window struct {
+	count   int64
+	resetAt time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		windows: make(map[string]*window),
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+
+	s.prune(now)
+
+	w, found := s.windows[key]
+	if !found || !now.Before(w.resetAt) {
+		w = &window{resetAt: now.Add(limit.Period)}
+		s.windows[key] = w
+	}
+
+	w.count++
+
+	return newResult(w.count, limit, w.resetAt.Sub(now)), nil
+}
+
+func (s *MemoryStore) Peek(ctx context.Context, key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+
+	w, found := s.windows[key]
+	if !found || !now.Before(w.resetAt) {
+		return newResult(1, limit, limit.Period), nil
+	}
+
+	return newResult(w.count+1, limit, w.resetAt.Sub(now)), nil
+}
+
+// Не чаще раза в минуту удаляет закончившиеся окна.
+func (s *MemoryStore) prune(now time.Time) {
+	if now.Sub(s.lastPrune) < time.Minute {
+		return
+	}
+
+	s.lastPrune = now
+
+	for key, w := range s.windows {
+		if !now.Before(w.resetAt) {
+			delete(s.windows, key)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	now := time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+
+	limit := Limit{Requests: 2, Period: time.Minute}
+
+	for i, want := range []Result{
+		{Allowed: true, Limit: 2, Remaining: 1, Reset: time.Minute},
+		{Allowed: true, Limit: 2, Remaining: 0, Reset: time.Minute},
+		{Allowed: false, Limit: 2, Remaining: 0, Reset: time.Minute},
+	} {
+		peeked, err := store.Peek(context.Background(), "ip:192.0.2.1", limit)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if peeked != want {
+			t.Errorf("request %d: expected Peek to return %+v, but got %+v", i+1, want, peeked)
+		}
+
+		got, err := store.Take(context.Background(), "ip:192.0.2.1", limit)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got != want {
+			t.Errorf("request %d: expected %+v, but got %+v", i+1, want, got)
+		}
+	}
+
+	if got, _ := store.Take(context.Background(), "ip:192.0.2.2", limit); !got.Allowed {
+		t.Error("expected other keys to have their own window")
+	}
+
+	now = now.Add(time.Minute)
+
+	if got, _ := store.Take(context.Background(), "ip:192.0.2.1", limit); !got.Allowed || got.Remaining != 1 {
+		t.Errorf("expected a new window, but got %+v", got)
+	}
+}
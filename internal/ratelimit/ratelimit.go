@@ -0,0 +1,110 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidLimit = errors.New(`limit must look like "100/m" with period s, m or h`)
+
+// Limit - не больше Requests запросов за окно длиной Period.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// Result описывает состояние окна после учета запроса; поля соответствуют заголовкам RateLimit-*.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	Reset     time.Duration
+}
+
+/*
+Store считает запросы по ключу в фиксированных окнах. Окно начинается
+с первого запроса по ключу и длится Period, после чего счетчик обнуляется.
+Хранилище, общее для нескольких экземпляров сервера, например Redis,
+не дает лимитам умножаться с числом реплик.
+*/
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+	// Возвращает результат, который дал бы Take, не учитывая запрос.
+	Peek(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+var periods = map[string]time.Duration{
+	"s": time.Second,
+	"m": time.Minute,
+	"h": time.Hour,
+}
+
+// Разбирает лимит вида "100/m".
+func ParseLimit(s string) (Limit, error) {
+	requests, period, found := strings.Cut(strings.TrimSpace(s), "/")
+	if !found {
+		return Limit{}, ErrInvalidLimit
+	}
+
+	n, err := strconv.Atoi(requests)
+	if err != nil || n < 1 {
+		return Limit{}, ErrInvalidLimit
+	}
+
+	d, ok := periods[period]
+	if !ok {
+		return Limit{}, ErrInvalidLimit
+	}
+
+	return Limit{Requests: n, Period: d}, nil
+}
+
+// Разбирает список лимитов вида "admin=1000/m,user=100/m".
+func ParseLimits(s string) (map[string]Limit, error) {
+	limits := make(map[string]Limit)
+
+	for _, part := range strings.Split(s, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+
+		name, value, found := strings.Cut(part, "=")
+		name = strings.TrimSpace(name)
+
+		if !found || name == "" {
+			return nil, fmt.Errorf("%q: expected name=limit", part)
+		}
+
+		limit, err := ParseLimit(value)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", part, err)
+		}
+
+		limits[name] = limit
+	}
+
+	return limits, nil
+}
+
+func (l Limit) String() string {
+	for name, d := range periods {
+		if l.Period == d {
+			return fmt.Sprintf("%d/%s", l.Requests, name)
+		}
+	}
+
+	return fmt.Sprintf("%d/%s", l.Requests, l.Period)
+}
+
+func newResult(count int64, limit Limit, reset time.Duration) Result {
+	return Result{
+		Allowed:   count <= int64(limit.Requests),
+		Limit:     limit.Requests,
+		Remaining: max(limit.Requests-int(count), 0),
+		Reset:     reset,
+	}
+}
@@ -0,0 +1,52 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Limit
+		wantErr bool
+	}{
+		{"100/m", Limit{100, time.Minute}, false},
+		{" 5/s ", Limit{5, time.Second}, false},
+		{"1000/h", Limit{1000, time.Hour}, false},
+		{"100", Limit{}, true},
+		{"0/m", Limit{}, true},
+		{"ten/m", Limit{}, true},
+		{"10/d", Limit{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseLimit(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %t, but got %v", tt.wantErr, err)
+			}
+
+			if got != tt.want {
+				t.Errorf("expected %v, but got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	limits, err := ParseLimits("admin=1000/m, user=10/s,")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(limits) != 2 || limits["admin"] != (Limit{1000, time.Minute}) || limits["user"] != (Limit{10, time.Second}) {
+		t.Errorf("unexpected limits %v", limits)
+	}
+
+	for _, input := range []string{"admin", "=10/m", "admin=10"} {
+		if _, err := ParseLimits(input); err == nil {
+			t.Errorf("expected error for %q", input)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package ratelimit
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	redisKeyPrefix = "filmoteka:ratelimit:"
+	redisPoolSize  = 16
+	redisTimeout   = time.Second
+)
+
+/*
+RedisStore хранит счетчики в Redis или совместимом сервере, поэтому лимиты
+общие для всех реплик. Окно - ключ со счетчиком INCR и временем жизни
+PEXPIRE; если процесс упал между INCR и PEXPIRE, время жизни выставит
+следующий запрос, увидев ключ без него. Клиент поддерживает только
+нужные команды протокола RESP и держит небольшой пул соединений.
+*/
+type RedisStore struct {
+	addr     string
+	password string
+	db       int
+	pool     chan *redisConn
+}
+
+type redisConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// Принимает адрес вида redis://[:password@]host:port[/db].
+func NewRedisStore(rawURL string) (*RedisStore, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme != "redis" || u.Host == "" {
+		return nil, fmt.Errorf("invalid redis URL %q", rawURL)
+	}
+
+	s := &RedisStore{addr: u.Host, pool: make(chan *redisConn, redisPoolSize)}
+
+	if !strings.Contains(u.Host, ":") {
+		s.addr = u.Host + ":6379"
+	}
+
+	if password, ok := u.User.Password(); ok {
+		s.password = password
+	}
+
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		s.db, err = strconv.Atoi(db)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redis database %q", db)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, err := s.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = conn.do(ctx, []string{"PING"})
+	if err != nil {
+		conn.close()
+		return nil, err
+	}
+
+	s.put(conn)
+
+	return s, nil
+}
+
+func (s *RedisStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	conn, err := s.get(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+
+	key = redisKeyPrefix + key
+
+	replies, err := conn.do(ctx, []string{"INCR", key}, []string{"PTTL", key})
+	if err != nil {
+		conn.close()
+		return Result{}, err
+	}
+
+	count, countOK := replies[0].(int64)
+	ttl, ttlOK := replies[1].(int64)
+
+	if !countOK || !ttlOK {
+		conn.close()
+		return Result{}, fmt.Errorf("redis: unexpected replies %v", replies)
+	}
+
+	reset := time.Duration(ttl) * time.Millisecond
+
+	if ttl < 0 {
+		reset = limit.Period
+
+		_, err = conn.do(ctx, []string{"PEXPIRE", key, strconv.FormatInt(limit.Period.Milliseconds(), 10)})
+		if err != nil {
+			conn.close()
+			return Result{}, err
+		}
+	}
+
+	s.put(conn)
+
+	return newResult(count, limit, reset), nil
+}
+
+func (s *RedisStore) Peek(ctx context.Context, key string, limit Limit) (Result, error) {
+	conn, err := s.get(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+
+	key = redisKeyPrefix + key
+
+	replies, err := conn.do(ctx, []string{"GET", key}, []string{"PTTL", key})
+	if err != nil {
+		conn.close()
+		return Result{}, err
+	}
+
+	s.put(conn)
+
+	var count int64
+
+	// Несуществующий ключ GET возвращает как nil.
+	if value, ok := replies[0].(string); ok {
+		count, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return Result{}, fmt.Errorf("redis: unexpected counter %q", value)
+		}
+	}
+
+	ttl, ok := replies[1].(int64)
+	if !ok {
+		return Result{}, fmt.Errorf("redis: unexpected replies %v", replies)
+	}
+
+	reset := time.Duration(ttl) * time.Millisecond
+	if ttl < 0 {
+		reset = limit.Period
+	}
+
+	return newResult(count+1, limit, reset), nil
+}
+
+// Закрывает соединения, которые сейчас в пуле.
+func (s *RedisStore) Close() error {
+	for {
+		select {
+		case conn := <-s.pool:
+			conn.close()
+		default:
+			return nil
+		}
+	}
+}
+
+func (s *RedisStore) get(ctx context.Context) (*redisConn, error) {
+	select {
+	case conn := <-s.pool:
+		return conn, nil
+	default:
+	}
+
+	var dialer net.Dialer
+
+	netConn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := &redisConn{conn: netConn, r: bufio.NewReader(netConn)}
+
+	var commands [][]string
+
+	if s.password != "" {
+		commands = append(commands, []string{"AUTH", s.password})
+	}
+
+	if s.db != 0 {
+		commands = append(commands, []string{"SELECT", strconv.Itoa(s.db)})
+	}
+
+	if len(commands) > 0 {
+		_, err = conn.do(ctx, commands...)
+		if err != nil {
+			conn.close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
+
+func (s *RedisStore) put(conn *redisConn) {
+	select {
+	case s.pool <- conn:
+	default:
+		conn.close()
+	}
+}
+
+// Отправляет команды одним пакетом и читает ответы на каждую.
+func (c *redisConn) do(ctx context.Context, commands ...[]string) ([]interface{}, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(redisTimeout)
+	}
+
+	err := c.conn.SetDeadline(deadline)
+	if err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+
+	for _, args := range commands {
+		fmt.Fprintf(&b, "*%d\r\n", len(args))
+
+		for _, arg := range args {
+			fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+		}
+	}
+
+	_, err = io.WriteString(c.conn, b.String())
+	if err != nil {
+		return nil, err
+	}
+
+	replies := make([]interface{}, len(commands))
+
+	for i := range commands {
+		replies[i], err = c.readReply()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return replies, nil
+}
+
+func (c *redisConn) readReply() (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+
+		buf := make([]byte, n+2)
+
+		_, err = io.ReadFull(c.r, buf)
+		if err != nil {
+			return nil, err
+		}
+
+		return string(buf[:n]), nil
+	default:
+		return nil, fmt.Errorf("redis: unsupported reply %q", line)
+	}
+}
+
+func (c *redisConn) close() {
+	c.conn.Close()
+}
@@ -0,0 +1,234 @@
+package ratelimit
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+/*
+Минимальная замена Redis для тестов: понимает только команды, которые
+использует RedisStore, и хранит значения в памяти.
+*/
+type fakeRedis struct {
+	mu       sync.Mutex
+	password string
+	values   map[string]int64
+	expiry   map[string]time.Time
+	commands []string
+}
+
+func startFakeRedis(t *testing.T, password string) (*fakeRedis, string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	t.Cleanup(func() { ln.Close() })
+
+	f := &fakeRedis{password: password, values: make(map[string]int64), expiry: make(map[string]time.Time)}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			go f.serve(conn)
+		}
+	}()
+
+	return f, ln.Addr().String()
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	authenticated := f.password == ""
+
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+
+		f.mu.Lock()
+
+		f.commands = append(f.commands, args[0])
+
+		key := ""
+		if len(args) > 1 {
+			key = args[1]
+		}
+
+		if deadline, found := f.expiry[key]; found && !time.Now().Before(deadline) {
+			delete(f.values, key)
+			delete(f.expiry, key)
+		}
+
+		var reply string
+
+		switch {
+		case args[0] == "AUTH":
+			authenticated = key == f.password
+			reply = "+OK\r\n"
+			if !authenticated {
+				reply = "-WRONGPASS invalid password\r\n"
+			}
+		case !authenticated:
+			reply = "-NOAUTH Authentication required\r\n"
+		case args[0] == "PING":
+			reply = "+PONG\r\n"
+		case args[0] == "SELECT":
+			reply = "+OK\r\n"
+		case args[0] == "INCR":
+			f.values[key]++
+			reply = fmt.Sprintf(":%d\r\n", f.values[key])
+		case args[0] == "GET":
+			reply = "$-1\r\n"
+			if value, exists := f.values[key]; exists {
+				s := strconv.FormatInt(value, 10)
+				reply = fmt.Sprintf("$%d\r\n%s\r\n", len(s), s)
+			}
+		case args[0] == "PTTL":
+			deadline, hasTTL := f.expiry[key]
+			_, exists := f.values[key]
+
+			switch {
+			case !exists:
+				reply = ":-2\r\n"
+			case !hasTTL:
+				reply = ":-1\r\n"
+			default:
+				reply = fmt.Sprintf(":%d\r\n", time.Until(deadline).Milliseconds())
+			}
+		case args[0] == "PEXPIRE":
+			ms, _ := strconv.ParseInt(args[2], 10, 64)
+			f.expiry[key] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+			reply = ":1\r\n"
+		default:
+			reply = "-ERR unknown command\r\n"
+		}
+
+		f.mu.Unlock()
+
+		_, err = io.WriteString(conn, reply)
+		if err != nil {
+			return
+		}
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, n)
+
+	for i := range args {
+		line, err = r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, err
+		}
+
+		buf := make([]byte, size+2)
+
+		_, err = io.ReadFull(r, buf)
+		if err != nil {
+			return nil, err
+		}
+
+		args[i] = string(buf[:size])
+	}
+
+	return args, nil
+}
+
+func TestRedisStore(t *testing.T) {
+	fake, addr := startFakeRedis(t, "secret")
+
+	store, err := NewRedisStore("redis://:secret@" + addr + "/2")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	defer store.Close()
+
+	limit := Limit{Requests: 2, Period: time.Minute}
+
+	for i, wantAllowed := range []bool{true, true, false} {
+		peeked, err := store.Peek(context.Background(), "user:1", limit)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		got, err := store.Take(context.Background(), "user:1", limit)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if peeked.Allowed != got.Allowed || peeked.Remaining != got.Remaining {
+			t.Errorf("request %d: expected Peek to predict %+v, but got %+v", i+1, got, peeked)
+		}
+
+		if got.Allowed != wantAllowed || got.Limit != 2 || got.Remaining != max(1-i, 0) {
+			t.Errorf("request %d: unexpected result %+v", i+1, got)
+		}
+
+		if got.Reset <= 0 || got.Reset > time.Minute {
+			t.Errorf("request %d: expected reset within the window, but got %v", i+1, got.Reset)
+		}
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	if _, found := fake.expiry[redisKeyPrefix+"user:1"]; !found {
+		t.Error("expected window key to expire")
+	}
+
+	if got := strings.Join(fake.commands[:2], " "); got != "AUTH SELECT" {
+		t.Errorf("expected connection to authenticate and select the database, but got %q", got)
+	}
+}
+
+func TestRedisStore_Errors(t *testing.T) {
+	_, addr := startFakeRedis(t, "secret")
+
+	if _, err := NewRedisStore("redis://:wrong@" + addr); err == nil {
+		t.Error("expected error for wrong password")
+	}
+
+	if _, err := NewRedisStore("redis://" + addr); err == nil {
+		t.Error("expected error for missing password")
+	}
+
+	if _, err := NewRedisStore("http://" + addr); err == nil {
+		t.Error("expected error for non-redis URL")
+	}
+
+	if _, err := NewRedisStore("redis://" + addr + "/db"); err == nil {
+		t.Error("expected error for invalid database")
+	}
+}
@@ -0,0 +1,177 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testSpans() []SpanData {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	root := SpanData{
+		SpanContext: SpanContext{TraceID: TraceID{1}, SpanID: SpanID{2}, Sampled: true},
+		Name:        "GET /movies/:id",
+		Kind:        SpanKindServer,
+		Start:       start,
+		End:         start.Add(15 * time.Millisecond),
+		Attributes:  []Attribute{String("http.route", "/movies/:id"), Int("http.response.status_code", 500)},
+		Error:       "boom",
+	}
+
+	child := SpanData{
+		SpanContext: SpanContext{TraceID: TraceID{1}, SpanID: SpanID{3}, Sampled: true},
+		Parent:      SpanID{2},
+		Name:        "MovieDB.Get",
+		Kind:        SpanKindClient,
+		Start:       start,
+		End:         start.Add(5 * time.Millisecond),
+	}
+
+	return []SpanData{root, child}
+}
+
+func TestWriterExporter(t *testing.T) {
+	var buf bytes.Buffer
+
+	err := NewWriterExporter(&buf).Export(context.Background(), testSpans())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(lines))
+	}
+
+	var got map[string]interface{}
+
+	err = json.Unmarshal([]byte(lines[1]), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]interface{}{
+		"trace_id":       "01000000000000000000000000000000",
+		"span_id":        "0300000000000000",
+		"parent_span_id": "0200000000000000",
+		"name":           "MovieDB.Get",
+		"kind":           "client",
+		"duration_ms":    5.0,
+	}
+
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("expected %s %v, got %v", key, value, got[key])
+		}
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var body otlpRequest
+	var headers http.Header
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			http.NotFound(w, r)
+			return
+		}
+
+		headers = r.Header
+		json.NewDecoder(r.Body).Decode(&body)
+	}))
+	defer ts.Close()
+
+	exporter := NewOTLPExporter(ts.URL+"/", "filmoteka", map[string]string{"Authorization": "Bearer secret"})
+
+	err := exporter.Export(context.Background(), testSpans())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if headers.Get("Content-Type") != "application/json" || headers.Get("Authorization") != "Bearer secret" {
+		t.Errorf("unexpected headers %v", headers)
+	}
+
+	if len(body.ResourceSpans) != 1 || len(body.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("unexpected request %+v", body)
+	}
+
+	if service := body.ResourceSpans[0].Resource.Attributes[0]; service.Key != "service.name" || *service.Value.StringValue != "filmoteka" {
+		t.Errorf("unexpected resource attribute %+v", service)
+	}
+
+	spans := body.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+
+	root := spans[0]
+
+	if root.TraceID != "01000000000000000000000000000000" || root.SpanID != "0200000000000000" || root.ParentSpanID != "" {
+		t.Errorf("unexpected root IDs %+v", root)
+	}
+
+	if root.Status.Code != 2 || root.Status.Message != "boom" {
+		t.Errorf("expected error status, got %+v", root.Status)
+	}
+
+	if root.StartTimeUnixNano != "1709294400000000000" || root.EndTimeUnixNano != "1709294400015000000" {
+		t.Errorf("unexpected timestamps %s - %s", root.StartTimeUnixNano, root.EndTimeUnixNano)
+	}
+
+	if status := root.Attributes[1]; status.Value.IntValue == nil || *status.Value.IntValue != "500" {
+		t.Errorf("expected integer status code attribute, got %+v", status)
+	}
+
+	if spans[1].ParentSpanID != "0200000000000000" || spans[1].Kind != SpanKindClient {
+		t.Errorf("unexpected child span %+v", spans[1])
+	}
+
+	t.Run("CollectorError", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer ts.Close()
+
+		err := NewOTLPExporter(ts.URL, "filmoteka", nil).Export(context.Background(), testSpans())
+		if err == nil {
+			t.Error("expected an error for a failed export")
+		}
+	})
+}
+
+func TestFileExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.json")
+
+	exporter, err := NewFileExporter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = exporter.Export(context.Background(), testSpans())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = exporter.Shutdown(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if lines := strings.Count(string(data), "\n"); lines != 2 {
+		t.Errorf("expected 2 lines, got %d", lines)
+	}
+}
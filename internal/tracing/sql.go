@@ -0,0 +1,82 @@
+package tracing
+
+import (
+	"strings"
+	"unicode"
+)
+
+/*
+Заменяет литералы в SQL на ?, чтобы значения, например хеши паролей или
+токенов, не попадали в трассы, и схлопывает пробелы. Параметры $1, $2
+остаются как есть: их значения в запрос не подставляются.
+*/
+func SanitizeSQL(query string) string {
+	var b strings.Builder
+
+	b.Grow(len(query))
+
+	runes := []rune(query)
+	space := false
+
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+
+		switch {
+		case unicode.IsSpace(c):
+			space = b.Len() > 0
+			continue
+		case space:
+			b.WriteByte(' ')
+			space = false
+		}
+
+		switch {
+		case c == '\'':
+			i = skipQuoted(runes, i)
+			b.WriteByte('?')
+		case c == '$' && i+1 < len(runes) && unicode.IsDigit(runes[i+1]):
+			b.WriteRune(c)
+			for i+1 < len(runes) && unicode.IsDigit(runes[i+1]) {
+				i++
+				b.WriteRune(runes[i])
+			}
+		case unicode.IsDigit(c) && (i == 0 || !isIdentifier(runes[i-1])):
+			for i+1 < len(runes) && (unicode.IsDigit(runes[i+1]) || runes[i+1] == '.') {
+				i++
+			}
+			b.WriteByte('?')
+		case isIdentifier(c):
+			b.WriteRune(c)
+			for i+1 < len(runes) && isIdentifier(runes[i+1]) {
+				i++
+				b.WriteRune(runes[i])
+			}
+		default:
+			b.WriteRune(c)
+		}
+	}
+
+	return b.String()
+}
+
+// Возвращает индекс закрывающей кавычки; удвоенная кавычка внутри строки - экранированная.
+func skipQuoted(runes []rune, start int) int {
+	for i := start + 1; i < len(runes); i++ {
+		if runes[i] != '\'' {
+			continue
+		}
+
+		if i+1 < len(runes) && runes[i+1] == '\'' {
+			i++
+			continue
+		}
+
+		return i
+	}
+
+	return len(runes) - 1
+}
+
+func isIdentifier(c rune) bool {
+	return c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
@@ -0,0 +1,45 @@
+package tracing
+
+import "testing"
+
+func TestSanitizeSQL(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{
+			name:  "Placeholders",
+			query: "\n\t\tSELECT movie_id, title\n\t\tFROM movies\n\t\tWHERE movie_id = $1",
+			want:  "SELECT movie_id, title FROM movies WHERE movie_id = $1",
+		},
+		{
+			name:  "StringLiterals",
+			query: "SELECT 1 FROM users WHERE name = 'O''Brien' AND role = 'admin'",
+			want:  "SELECT ? FROM users WHERE name = ? AND role = ?",
+		},
+		{
+			name:  "NumericLiterals",
+			query: "SELECT * FROM movies WHERE rating > 7.5 LIMIT 20 OFFSET $2",
+			want:  "SELECT * FROM movies WHERE rating > ? LIMIT ? OFFSET $2",
+		},
+		{
+			name:  "IdentifiersWithDigits",
+			query: "SELECT sha256(hash), t1.id FROM t1",
+			want:  "SELECT sha256(hash), t1.id FROM t1",
+		},
+		{
+			name:  "UnterminatedString",
+			query: "SELECT 'secret",
+			want:  "SELECT ?",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SanitizeSQL(tt.query); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
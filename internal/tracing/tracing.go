@@ -0,0 +1,419 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type TraceID [16]byte
+
+type SpanID [8]byte
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+
+func (id TraceID) IsValid() bool { return id != TraceID{} }
+
+func (id SpanID) String() string { return hex.EncodeToString(id[:]) }
+
+func (id SpanID) IsValid() bool { return id != SpanID{} }
+
+// Идентификаторы span и флаг выборки, передаваемые между сервисами в заголовке traceparent.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+/*
+Разбирает заголовок traceparent по W3C Trace Context, например
+00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01. Заголовки
+неизвестных будущих версий разбираются по тем же первым четырем полям.
+*/
+func ParseTraceparent(header string) (SpanContext, bool) {
+	var sc SpanContext
+
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return sc, false
+	}
+
+	if parts[0] == "00" && len(parts) != 4 {
+		return sc, false
+	}
+
+	if !decodeHex(sc.TraceID[:], parts[1]) || !decodeHex(sc.SpanID[:], parts[2]) {
+		return sc, false
+	}
+
+	var flags [1]byte
+	if !decodeHex(flags[:], parts[3]) {
+		return sc, false
+	}
+
+	sc.Sampled = flags[0]&0x01 == 1
+
+	return sc, sc.IsValid()
+}
+
+// Значение заголовка traceparent для передачи контекста следующему сервису.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+
+	return fmt.Sprintf("00-%s-%s-%s", sc.TraceID, sc.SpanID, flags)
+}
+
+// Допускаются только шестнадцатеричные цифры в нижнем регистре, как требует спецификация.
+func decodeHex(dst []byte, s string) bool {
+	if len(s) != hex.EncodedLen(len(dst)) || strings.ToLower(s) != s {
+		return false
+	}
+
+	_, err := hex.Decode(dst, []byte(s))
+
+	return err == nil
+}
+
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+func String(key, value string) Attribute { return Attribute{key, value} }
+
+func Int(key string, value int64) Attribute { return Attribute{key, value} }
+
+func Bool(key string, value bool) Attribute { return Attribute{key, value} }
+
+// Завершенный span в том виде, в котором его получает Exporter.
+type SpanData struct {
+	SpanContext
+	Parent     SpanID
+	Name       string
+	Kind       SpanKind
+	Start      time.Time
+	End        time.Time
+	Attributes []Attribute
+	Error      string
+}
+
+/*
+Span - одна операция трассы. Методы nil Span ничего не делают, поэтому
+код, который его создает, работает одинаково с трассировкой и без нее.
+Span, не попавший в выборку, передает идентификаторы, но не экспортируется.
+*/
+type Span struct {
+	tracer *Tracer
+	ended  atomic.Bool
+
+	mu   sync.Mutex
+	data SpanData
+}
+
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+
+	return s.data.SpanContext
+}
+
+// Сообщает, будет ли span экспортирован, чтобы не вычислять атрибуты впустую.
+func (s *Span) IsRecording() bool {
+	return s != nil && s.data.Sampled
+}
+
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if s == nil || !s.data.Sampled {
+		return
+	}
+
+	s.mu.Lock()
+	s.data.Attributes = append(s.data.Attributes, attrs...)
+	s.mu.Unlock()
+}
+
+// Отмечает span как завершившийся ошибкой; nil ошибку игнорирует.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil || !s.data.Sampled {
+		return
+	}
+
+	s.mu.Lock()
+	s.data.Error = err.Error()
+	s.mu.Unlock()
+}
+
+func (s *Span) End() {
+	if s == nil || !s.ended.CompareAndSwap(false, true) || !s.data.Sampled {
+		return
+	}
+
+	s.mu.Lock()
+	s.data.End = time.Now()
+	data := s.data
+	s.mu.Unlock()
+
+	s.tracer.enqueue(data)
+}
+
+type spanContextKey struct{}
+
+type remoteContextKey struct{}
+
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, span)
+}
+
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanContextKey{}).(*Span)
+	return span
+}
+
+// Кладет в контекст span вызывающего сервиса, который станет родителем следующего span.
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteContextKey{}, sc)
+}
+
+// Возвращает контекст текущего span или, если его нет, span вызывающего сервиса.
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.SpanContext()
+	}
+
+	sc, _ := ctx.Value(remoteContextKey{}).(SpanContext)
+
+	return sc
+}
+
+type Config struct {
+	ServiceName string
+	// Доля новых трасс, попадающих в выборку; трассы с родителем следуют его решению.
+	SampleRatio float64
+	// Максимум span в одной отправке и период, с которым отправляются неполные пачки.
+	BatchSize     int
+	FlushInterval time.Duration
+	// Получает ошибки экспорта; ошибки не прерывают работу трассировщика.
+	ErrorHandler func(err error)
+}
+
+/*
+Tracer создает span и отправляет завершенные пачками в фоновой горутине,
+чтобы экспорт не задерживал запросы. Если очередь переполнена, новые span
+отбрасываются. Методы nil Tracer ничего не делают.
+*/
+type Tracer struct {
+	config   Config
+	exporter Exporter
+	queue    chan SpanData
+	done     chan struct{}
+	stopped  chan struct{}
+	dropped  atomic.Uint64
+	closing  sync.Once
+}
+
+func New(cfg Config, exporter Exporter) *Tracer {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 512
+	}
+
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 5 * time.Second
+	}
+
+	if cfg.ErrorHandler == nil {
+		cfg.ErrorHandler = func(error) {}
+	}
+
+	t := &Tracer{
+		config:   cfg,
+		exporter: exporter,
+		queue:    make(chan SpanData, 4*cfg.BatchSize),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+
+	go t.run()
+
+	return t
+}
+
+/*
+Начинает span, дочерний по отношению к span из ctx или к span вызывающего
+сервиса. Без родителя начинается новая трасса. Возвращенный контекст
+содержит новый span и передается вложенным операциям.
+*/
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind, attrs ...Attribute) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+
+	parent := SpanContextFromContext(ctx)
+
+	span := &Span{tracer: t}
+	span.data.Name = name
+	span.data.Kind = kind
+	span.data.Start = time.Now()
+	span.data.SpanID = newSpanID()
+
+	if parent.IsValid() {
+		span.data.TraceID = parent.TraceID
+		span.data.Parent = parent.SpanID
+		span.data.Sampled = parent.Sampled
+	} else {
+		span.data.TraceID = newTraceID()
+		span.data.Sampled = t.sample(span.data.TraceID)
+	}
+
+	if span.data.Sampled {
+		span.data.Attributes = attrs
+	}
+
+	return ContextWithSpan(ctx, span), span
+}
+
+// Решение о выборке зависит только от trace ID, как в TraceIDRatioBased из OpenTelemetry.
+func (t *Tracer) sample(id TraceID) bool {
+	ratio := t.config.SampleRatio
+
+	switch {
+	case ratio >= 1:
+		return true
+	case ratio <= 0:
+		return false
+	}
+
+	return binary.BigEndian.Uint64(id[8:])>>1 < uint64(ratio*(1<<63))
+}
+
+// Число span, отброшенных из-за переполнения очереди.
+func (t *Tracer) Dropped() uint64 {
+	if t == nil {
+		return 0
+	}
+
+	return t.dropped.Load()
+}
+
+func (t *Tracer) enqueue(data SpanData) {
+	select {
+	case <-t.done:
+		t.dropped.Add(1)
+		return
+	default:
+	}
+
+	select {
+	case t.queue <- data:
+	default:
+		t.dropped.Add(1)
+	}
+}
+
+func (t *Tracer) run() {
+	defer close(t.stopped)
+
+	ticker := time.NewTicker(t.config.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]SpanData, 0, t.config.BatchSize)
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+
+		err := t.exporter.Export(context.Background(), batch)
+		if err != nil {
+			t.config.ErrorHandler(err)
+		}
+
+		batch = make([]SpanData, 0, t.config.BatchSize)
+	}
+
+	for {
+		select {
+		case data := <-t.queue:
+			batch = append(batch, data)
+			if len(batch) >= t.config.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-t.done:
+			for {
+				select {
+				case data := <-t.queue:
+					batch = append(batch, data)
+					if len(batch) >= t.config.BatchSize {
+						flush()
+					}
+				default:
+					flush()
+					t.exporter.Shutdown(context.Background())
+					return
+				}
+			}
+		}
+	}
+}
+
+/*
+Отправляет накопленные span и останавливает трассировщик. span, завершенные
+после вызова Shutdown, отбрасываются.
+*/
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+
+	t.closing.Do(func() { close(t.done) })
+
+	select {
+	case <-t.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+
+	return id
+}
@@ -0,0 +1,220 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+	shut  bool
+}
+
+func (e *recordingExporter) Export(ctx context.Context, spans []SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.spans = append(e.spans, spans...)
+
+	return nil
+}
+
+func (e *recordingExporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.shut = true
+
+	return nil
+}
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		valid   bool
+		sampled bool
+	}{
+		{"Sampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true, true},
+		{"NotSampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true, false},
+		{"FutureVersion", "cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true, true},
+		{"ExtraFieldsVersion00", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false, false},
+		{"InvalidVersion", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, false},
+		{"ZeroTraceID", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", false, false},
+		{"ZeroSpanID", "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false, false},
+		{"UpperCase", "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false, false},
+		{"ShortTraceID", "00-4bf92f3577b34da6-00f067aa0ba902b7-01", false, false},
+		{"Empty", "", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, ok := ParseTraceparent(tt.header)
+			if ok != tt.valid {
+				t.Fatalf("expected valid %v, got %v", tt.valid, ok)
+			}
+
+			if ok && sc.Sampled != tt.sampled {
+				t.Errorf("expected sampled %v, got %v", tt.sampled, sc.Sampled)
+			}
+		})
+	}
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		header := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+		sc, _ := ParseTraceparent(header)
+		if got := sc.Traceparent(); got != header {
+			t.Errorf("expected %q, got %q", header, got)
+		}
+	})
+}
+
+func TestTracer(t *testing.T) {
+	t.Run("ParentChild", func(t *testing.T) {
+		exporter := &recordingExporter{}
+		tracer := New(Config{ServiceName: "test", SampleRatio: 1}, exporter)
+
+		ctx, root := tracer.Start(context.Background(), "root", SpanKindServer, String("http.route", "/movies/:id"))
+		_, child := tracer.Start(ctx, "child", SpanKindInternal)
+
+		child.RecordError(errors.New("boom"))
+		child.End()
+		root.End()
+		root.End()
+
+		err := tracer.Shutdown(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(exporter.spans) != 2 {
+			t.Fatalf("expected 2 spans, got %d", len(exporter.spans))
+		}
+
+		gotChild, gotRoot := exporter.spans[0], exporter.spans[1]
+
+		if gotChild.TraceID != gotRoot.TraceID {
+			t.Error("expected child to share the trace ID of its parent")
+		}
+
+		if gotChild.Parent != gotRoot.SpanID {
+			t.Errorf("expected parent %s, got %s", gotRoot.SpanID, gotChild.Parent)
+		}
+
+		if gotChild.Error != "boom" {
+			t.Errorf("expected error %q, got %q", "boom", gotChild.Error)
+		}
+
+		if len(gotRoot.Attributes) != 1 || gotRoot.Attributes[0].Value != "/movies/:id" {
+			t.Errorf("unexpected root attributes %v", gotRoot.Attributes)
+		}
+
+		if !exporter.shut {
+			t.Error("expected exporter to be shut down")
+		}
+	})
+
+	t.Run("RemoteParent", func(t *testing.T) {
+		exporter := &recordingExporter{}
+		tracer := New(Config{SampleRatio: 0}, exporter)
+
+		remote, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+		_, span := tracer.Start(ContextWithRemoteSpanContext(context.Background(), remote), "server", SpanKindServer)
+		span.End()
+		tracer.Shutdown(context.Background())
+
+		if len(exporter.spans) != 1 {
+			t.Fatalf("expected sampled parent to override the ratio, got %d spans", len(exporter.spans))
+		}
+
+		if exporter.spans[0].TraceID != remote.TraceID || exporter.spans[0].Parent != remote.SpanID {
+			t.Error("expected span to continue the remote trace")
+		}
+	})
+
+	t.Run("NotSampled", func(t *testing.T) {
+		exporter := &recordingExporter{}
+		tracer := New(Config{SampleRatio: 0}, exporter)
+
+		ctx, span := tracer.Start(context.Background(), "dropped", SpanKindServer)
+		span.End()
+		tracer.Shutdown(context.Background())
+
+		if len(exporter.spans) != 0 {
+			t.Errorf("expected no exported spans, got %d", len(exporter.spans))
+		}
+
+		if !SpanContextFromContext(ctx).TraceID.IsValid() {
+			t.Error("expected unsampled span to still carry a trace ID")
+		}
+	})
+
+	t.Run("Batching", func(t *testing.T) {
+		exporter := &recordingExporter{}
+		tracer := New(Config{SampleRatio: 1, BatchSize: 2, FlushInterval: time.Hour}, exporter)
+
+		for i := 0; i < 2; i++ {
+			_, span := tracer.Start(context.Background(), "span", SpanKindInternal)
+			span.End()
+		}
+
+		deadline := time.Now().Add(time.Second)
+
+		for {
+			exporter.mu.Lock()
+			n := len(exporter.spans)
+			exporter.mu.Unlock()
+
+			if n == 2 {
+				break
+			}
+
+			if time.Now().After(deadline) {
+				t.Fatalf("expected a full batch to be exported without waiting for the flush interval, got %d spans", n)
+			}
+
+			time.Sleep(time.Millisecond)
+		}
+
+		tracer.Shutdown(context.Background())
+	})
+
+	t.Run("Nil", func(t *testing.T) {
+		var tracer *Tracer
+
+		ctx, span := tracer.Start(context.Background(), "noop", SpanKindInternal)
+		span.SetAttributes(String("key", "value"))
+		span.RecordError(errors.New("ignored"))
+		span.End()
+
+		if ctx != context.Background() || span != nil {
+			t.Error("expected nil tracer to return the context unchanged and a nil span")
+		}
+
+		if err := tracer.Shutdown(context.Background()); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
+
+func TestTracer_Sample(t *testing.T) {
+	tracer := &Tracer{config: Config{SampleRatio: 0.25}}
+
+	sampled := 0
+
+	for i := 0; i < 10000; i++ {
+		if tracer.sample(newTraceID()) {
+			sampled++
+		}
+	}
+
+	if sampled < 2000 || sampled > 3000 {
+		t.Errorf("expected about 2500 of 10000 traces to be sampled, got %d", sampled)
+	}
+}
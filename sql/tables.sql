@@ -1,10 +1,14 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
 CREATE TYPE gender AS ENUM ('male', 'female');
 
-CREATE TABLE Actors (
-    actor_id SERIAL PRIMARY KEY,
+-- People are actors and crew members alike; what they did on a movie is stored in Credits.
+CREATE TABLE People (
+    person_id SERIAL PRIMARY KEY,
     full_name VARCHAR(200) UNIQUE NOT NULL,
     gender gender NOT NULL,
-    birth_date DATE NOT NULL
+    birth_date DATE NOT NULL,
+    version INT NOT NULL DEFAULT 1
 );
 
 CREATE TABLE Movies (
@@ -12,20 +16,189 @@ CREATE TABLE Movies (
     title VARCHAR(150) UNIQUE NOT NULL,
     description VARCHAR(1000) NOT NULL,
     release_date DATE NOT NULL,
-    rating DECIMAL(3,1) NOT NULL CHECK (rating >= 0 AND rating <= 10)
+    rating DECIMAL(3,1) NOT NULL CHECK (rating >= 0 AND rating <= 10),
+    version INT NOT NULL DEFAULT 1
+);
+
+CREATE TYPE credit_role AS ENUM ('actor', 'director', 'writer', 'producer', 'composer', 'cinematographer', 'editor');
+
+-- character and billing_order describe actor credits only; crew credits keep the defaults.
+CREATE TABLE Credits (
+    movie_id INT REFERENCES movies(movie_id) ON DELETE CASCADE,
+    person_id INT REFERENCES people(person_id) ON DELETE CASCADE,
+    role credit_role NOT NULL,
+    character VARCHAR(200) NOT NULL DEFAULT '',
+    billing_order INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (movie_id, person_id, role)
 );
 
-CREATE TABLE Movies_actors (
+CREATE INDEX credits_person_id_idx ON Credits (person_id);
+
+CREATE TABLE Genres (
+    genre_id SERIAL PRIMARY KEY,
+    name VARCHAR(50) UNIQUE NOT NULL
+);
+
+CREATE TABLE Movies_genres (
     movie_id INT REFERENCES movies(movie_id) ON DELETE CASCADE,
-    actor_id INT REFERENCES actors(actor_id) ON DELETE CASCADE,
-    PRIMARY KEY (movie_id, actor_id)
+    genre_id INT REFERENCES genres(genre_id) ON DELETE CASCADE,
+    PRIMARY KEY (movie_id, genre_id)
+);
+
+CREATE INDEX movies_genres_genre_id_idx ON Movies_genres (genre_id);
+
+-- Trigram indexes for typo-tolerant and substring search by title, person and character name.
+CREATE INDEX movies_title_trgm_idx ON Movies USING GIN (title gin_trgm_ops);
+CREATE INDEX people_full_name_trgm_idx ON People USING GIN (full_name gin_trgm_ops);
+CREATE INDEX credits_character_trgm_idx ON Credits USING GIN (character gin_trgm_ops);
+
+-- Full-text search: title has weight A, description B and cast and crew names C.
+-- Title and description are indexed with both English and Russian stemming.
+-- Title words are also indexed unstemmed. Words the stemmers reduce differently
+-- (matrices -> matric, Matrix -> matrix) are matched by the trigram title index instead.
+-- TestMovieDB_SearchDatabase in internal/data checks the search against a database with the schema.
+ALTER TABLE Movies ADD COLUMN search_vector tsvector;
+
+CREATE INDEX movies_search_vector_idx ON Movies USING GIN (search_vector);
+
+CREATE FUNCTION movie_search_vector(p_movie_id INT, p_title TEXT, p_description TEXT) RETURNS tsvector AS $$
+    SELECT
+        setweight(to_tsvector('english', p_title), 'A') ||
+        setweight(to_tsvector('russian', p_title), 'A') ||
+        setweight(to_tsvector('simple', p_title), 'A') ||
+        setweight(to_tsvector('english', p_description), 'B') ||
+        setweight(to_tsvector('russian', p_description), 'B') ||
+        setweight(to_tsvector('simple', coalesce(
+            (SELECT string_agg(DISTINCT p.full_name, ' ')
+             FROM Credits c
+             JOIN People p ON c.person_id = p.person_id
+             WHERE c.movie_id = p_movie_id), '')), 'C')
+$$ LANGUAGE SQL STABLE;
+
+CREATE FUNCTION movies_search_vector_trigger() RETURNS trigger AS $$
+BEGIN
+    NEW.search_vector := movie_search_vector(NEW.movie_id, NEW.title, NEW.description);
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER movies_search_vector_update
+    BEFORE INSERT OR UPDATE OF title, description ON Movies
+    FOR EACH ROW EXECUTE FUNCTION movies_search_vector_trigger();
+
+CREATE FUNCTION credits_search_vector_trigger() RETURNS trigger AS $$
+DECLARE
+    changed_movie_id INT;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        changed_movie_id := OLD.movie_id;
+    ELSE
+        changed_movie_id := NEW.movie_id;
+    END IF;
+
+    UPDATE Movies m
+    SET search_vector = movie_search_vector(m.movie_id, m.title, m.description)
+    WHERE m.movie_id = changed_movie_id;
+
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER credits_search_vector_update
+    AFTER INSERT OR DELETE ON Credits
+    FOR EACH ROW EXECUTE FUNCTION credits_search_vector_trigger();
+
+CREATE FUNCTION people_search_vector_trigger() RETURNS trigger AS $$
+BEGIN
+    UPDATE Movies m
+    SET search_vector = movie_search_vector(m.movie_id, m.title, m.description)
+    WHERE m.movie_id IN (SELECT movie_id FROM Credits WHERE person_id = NEW.person_id);
+
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER people_search_vector_update
+    AFTER UPDATE OF full_name ON People
+    FOR EACH ROW EXECUTE FUNCTION people_search_vector_trigger();
+
+-- Roles grant permissions of the form "resource:action"; the mapping mirrors data.RolePermissions.
+CREATE TABLE Roles (
+    name VARCHAR(50) PRIMARY KEY
 );
 
-CREATE TYPE user_role AS ENUM ('user', 'admin');
+CREATE TABLE Permissions (
+    code VARCHAR(50) PRIMARY KEY
+);
+
+CREATE TABLE Roles_permissions (
+    role VARCHAR(50) NOT NULL REFERENCES roles(name) ON DELETE CASCADE,
+    permission VARCHAR(50) NOT NULL REFERENCES permissions(code) ON DELETE CASCADE,
+    PRIMARY KEY (role, permission)
+);
+
+INSERT INTO Roles (name)
+VALUES ('user'), ('editor'), ('moderator'), ('admin');
+
+INSERT INTO Permissions (code)
+SELECT resource || ':' || action
+FROM unnest(ARRAY['movies', 'actors', 'people', 'genres']) AS resource,
+     unnest(ARRAY['read', 'create', 'update', 'delete']) AS action
+UNION ALL
+VALUES ('apikeys:manage'), ('users:manage');
+
+INSERT INTO Roles_permissions (role, permission)
+SELECT r.name, p.code
+FROM Roles r
+INNER JOIN Permissions p ON
+    CASE r.name
+        WHEN 'user' THEN p.code LIKE '%:read'
+        WHEN 'editor' THEN p.code SIMILAR TO '%:(read|create|update)'
+        WHEN 'moderator' THEN p.code SIMILAR TO '%:(read|update|delete)'
+        WHEN 'admin' THEN TRUE
+    END;
 
 CREATE TABLE Users (
     user_id SERIAL PRIMARY KEY,
     username VARCHAR(100) UNIQUE NOT NULL,
+    email VARCHAR(254) UNIQUE NOT NULL,
     password_hash VARCHAR(100) NOT NULL,
-    role user_role NOT NULL
+    role VARCHAR(50) NOT NULL REFERENCES roles(name),
+    activated BOOLEAN NOT NULL DEFAULT false,
+    disabled BOOLEAN NOT NULL DEFAULT false
+);
+
+-- Audit log of role changes; changed_by is kept as NULL when the admin who made the change is deleted.
+CREATE TABLE Role_changes (
+    role_change_id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    old_role VARCHAR(50) NOT NULL,
+    new_role VARCHAR(50) NOT NULL,
+    changed_by INT REFERENCES users(user_id) ON DELETE SET NULL,
+    changed_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX role_changes_user_id_idx ON Role_changes (user_id);
+
+-- Authentication tokens are stored as SHA-256 hashes; expired rows are ignored on lookup.
+CREATE TABLE Tokens (
+    hash BYTEA PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    expiry TIMESTAMP(0) WITH TIME ZONE NOT NULL,
+    scope TEXT NOT NULL
+);
+
+CREATE INDEX tokens_user_id_idx ON Tokens (user_id);
+
+-- API keys for service-to-service clients; like tokens, only SHA-256 hashes are stored.
+CREATE TABLE Api_keys (
+    api_key_id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(16) NOT NULL,
+    hash BYTEA UNIQUE NOT NULL,
+    scopes TEXT[] NOT NULL,
+    expiry TIMESTAMP(0) WITH TIME ZONE,
+    last_used_at TIMESTAMP(0) WITH TIME ZONE,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT now()
 );
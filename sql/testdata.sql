@@ -1,10 +1,16 @@
-INSERT INTO Actors (full_name, gender, birth_date) VALUES
+INSERT INTO People (full_name, gender, birth_date) VALUES
     ('Brad Pitt', 'male', '1963-12-18'),
     ('Angelina Jolie', 'female', '1975-06-04'),
     ('Robert Downey Jr.', 'male', '1965-04-04'),
     ('Scarlett Johansson', 'female', '1984-11-22'),
     ('Denzel Washington', 'male', '1954-12-28'),
-    ('Kate Winslet', 'female', '1975-10-05');
+    ('Kate Winslet', 'female', '1975-10-05'),
+    ('Christopher Nolan', 'male', '1970-07-30'),
+    ('James Cameron', 'male', '1954-08-16'),
+    ('Quentin Tarantino', 'male', '1963-03-27'),
+    ('Frank Darabont', 'male', '1959-01-28'),
+    ('Francis Ford Coppola', 'male', '1939-04-07'),
+    ('Hans Zimmer', 'male', '1957-09-12');
 
 INSERT INTO Movies (title, description, release_date, rating) VALUES
     ('The Dark Knight', 'When the menace known as the Joker wreaks havoc and chaos on the people of Gotham, Batman must accept one of the greatest psychological and physical tests of his ability to fight injustice.', '2008-07-18', 9.0),
@@ -16,17 +22,59 @@ INSERT INTO Movies (title, description, release_date, rating) VALUES
     ('Inglourious Basterds', 'In Nazi-occupied France during World War II, a plan to assassinate Nazi leaders by a group of Jewish U.S. soldiers coincides with a theatre owner''s vengeful plans for the same.', '2009-08-21', 8.3),
     ('The Godfather', 'The aging patriarch of an organized crime dynasty transfers control of his clandestine empire to his reluctant son.', '1972-03-24', 9.2);
 
-INSERT INTO Movies_actors (movie_id, actor_id) VALUES
-    (1, 4), -- Scarlett Johansson in The Dark Knight
-    (3, 3), -- Robert Downey Jr. in Avengers: Endgame
-    (3, 4), -- Scarlett Johansson in Avengers: Endgame
-    (5, 1), -- Brad Pitt in The Shawshank Redemption
-    (5, 5), -- Denzel Washington in The Shawshank Redemption
-    (6, 1), -- Brad Pitt in Pulp Fiction
-    (6, 2), -- Angelina Jolie in Pulp Fiction
-    (7, 1), -- Brad Pitt in Inglourious Basterds
-    (7, 2); -- Angelina Jolie in Inglourious Basterds
+INSERT INTO Credits (movie_id, person_id, role, character, billing_order) VALUES
+    (1, 4, 'actor', 'Rachel Dawes', 1), -- Scarlett Johansson in The Dark Knight
+    (3, 3, 'actor', 'Tony Stark', 1), -- Robert Downey Jr. in Avengers: Endgame
+    (3, 4, 'actor', 'Natasha Romanoff', 2), -- Scarlett Johansson in Avengers: Endgame
+    (5, 1, 'actor', 'Andy Dufresne', 1), -- Brad Pitt in The Shawshank Redemption
+    (5, 5, 'actor', 'Ellis ''Red'' Redding', 2), -- Denzel Washington in The Shawshank Redemption
+    (6, 1, 'actor', 'Vincent Vega', 1), -- Brad Pitt in Pulp Fiction
+    (6, 2, 'actor', 'Mia Wallace', 2), -- Angelina Jolie in Pulp Fiction
+    (6, 9, 'actor', 'Jimmie', 3), -- Quentin Tarantino in Pulp Fiction
+    (7, 1, 'actor', 'Lt. Aldo Raine', 1), -- Brad Pitt in Inglourious Basterds
+    (7, 2, 'actor', 'Shosanna Dreyfus', 2); -- Angelina Jolie in Inglourious Basterds
 
-INSERT INTO Users (username, password_hash, role) VALUES
-    ('admin', '$2a$12$6EASj861izXc62eMuaQGXOAOG/eWGHHcAYZTEP8GSoNG0qEWbRpDm', 'admin'), -- password: password123
-    ('user', '$2a$12$6EASj861izXc62eMuaQGXOAOG/eWGHHcAYZTEP8GSoNG0qEWbRpDm', 'user'); -- password: password123
\ No newline at end of file
+INSERT INTO Credits (movie_id, person_id, role) VALUES
+    (1, 7, 'director'), -- Christopher Nolan directed The Dark Knight
+    (1, 7, 'writer'), -- Christopher Nolan wrote The Dark Knight
+    (1, 12, 'composer'), -- Hans Zimmer scored The Dark Knight
+    (2, 8, 'director'), -- James Cameron directed Titanic
+    (2, 8, 'writer'), -- James Cameron wrote Titanic
+    (5, 10, 'director'), -- Frank Darabont directed The Shawshank Redemption
+    (5, 10, 'writer'), -- Frank Darabont wrote The Shawshank Redemption
+    (6, 9, 'director'), -- Quentin Tarantino directed Pulp Fiction
+    (6, 9, 'writer'), -- Quentin Tarantino wrote Pulp Fiction
+    (7, 9, 'director'), -- Quentin Tarantino directed Inglourious Basterds
+    (7, 9, 'writer'), -- Quentin Tarantino wrote Inglourious Basterds
+    (8, 11, 'director'), -- Francis Ford Coppola directed The Godfather
+    (8, 11, 'writer'); -- Francis Ford Coppola wrote The Godfather
+
+INSERT INTO Genres (name) VALUES
+    ('action'),
+    ('crime'),
+    ('drama'),
+    ('romance'),
+    ('sci-fi'),
+    ('war');
+
+INSERT INTO Movies_genres (movie_id, genre_id) VALUES
+    (1, 1), -- The Dark Knight: action
+    (1, 2), -- The Dark Knight: crime
+    (1, 3), -- The Dark Knight: drama
+    (2, 3), -- Titanic: drama
+    (2, 4), -- Titanic: romance
+    (3, 1), -- Avengers: Endgame: action
+    (3, 5), -- Avengers: Endgame: sci-fi
+    (4, 1), -- The Matrix: action
+    (4, 5), -- The Matrix: sci-fi
+    (5, 3), -- The Shawshank Redemption: drama
+    (6, 2), -- Pulp Fiction: crime
+    (6, 3), -- Pulp Fiction: drama
+    (7, 3), -- Inglourious Basterds: drama
+    (7, 6), -- Inglourious Basterds: war
+    (8, 2), -- The Godfather: crime
+    (8, 3); -- The Godfather: drama
+
+INSERT INTO Users (username, email, password_hash, role, activated) VALUES
+    ('admin', 'admin@example.com', '$2a$12$6EASj861izXc62eMuaQGXOAOG/eWGHHcAYZTEP8GSoNG0qEWbRpDm', 'admin', true), -- password: password123
+    ('user', 'user@example.com', '$2a$12$6EASj861izXc62eMuaQGXOAOG/eWGHHcAYZTEP8GSoNG0qEWbRpDm', 'user', true); -- password: password123
\ No newline at end of file